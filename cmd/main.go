@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,50 +11,83 @@ import (
 	"time"
 
 	"sum/pkg/config"
+	"sum/pkg/database"
 	"sum/pkg/listener"
 	"sum/pkg/logger"
+	"sum/pkg/models"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-telegram/bot"
+	"gorm.io/gorm"
 )
 
 func main() {
+	migrate := flag.Bool("migrate", false, "Apply pending database migrations on startup")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [rotate-keys]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	cfg := config.LoadConfig(config.DefaultConfigLoaders())
 	log := logger.NewLogrusLogger()
 
-	// TODO: disable for now
-	// if err := models.InitIDGenerators(); err != nil {
-	// 	log.Error(err, "Failed to initialize ID generators")
-	// 	return
-	// }
-
-	// TODO: disable for now
-	// dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-	// 	cfg.DB.User, cfg.DB.Password, cfg.DB.Host, cfg.DB.Port, cfg.DB.Name)
-
-	// db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-	// 	PrepareStmt: true,
-	// 	NamingStrategy: schema.NamingStrategy{
-	// 		SingularTable: false,
-	// 	},
-	// })
-	// if err != nil {
-	// 	log.Error(err, "Failed to connect to database")
-	// 	return
-	// }
+	if err := models.InitIDGenerators(); err != nil {
+		log.Error(err, "Failed to initialize ID generators")
+		return
+	}
 
 	var (
+		db              *gorm.DB
 		discordSession  *discordgo.Session
 		telegramSession *bot.Bot
 		err             error
 	)
 
+	if config.IsDBEnabled(cfg) {
+		db, err = database.Open(cfg.DB, log)
+		if err != nil {
+			log.Error(err, "Failed to connect to database")
+			return
+		}
+
+		if *migrate {
+			n, err := database.Migrate(db)
+			if err != nil {
+				log.Error(err, "Failed to migrate database")
+				return
+			}
+			log.Infof("Applied %d migrations", n)
+
+			// Upgrade stored secrets to the current envelope format. A failure rolls
+			// back every row, so the old values stay readable and are retried on the next start
+			if err := rotateKeys(cfg, db, log); err != nil {
+				log.Error(err, "Failed to upgrade encrypted API keys")
+			}
+		}
+	} else {
+		log.Warn("Database is not configured, only commands without storage are enabled")
+	}
+
+	// Admin subcommands run once and exit instead of starting the bots
+	if flag.Arg(0) == "rotate-keys" {
+		if err := rotateKeys(cfg, db, log); err != nil {
+			log.Error(err, "Failed to rotate encryption keys")
+			os.Exit(1)
+		}
+		return
+	}
+
 	if config.IsDiscordEnabled(cfg) {
 		discordSession, err = discordgo.New("Bot " + cfg.DiscordBotToken)
 		if err != nil {
 			log.Error(err, "Failed to create Discord session")
 			return
 		}
+		// Discord closes the connection when a privileged intent isn't enabled for the bot
+		if cfg.DiscordContent {
+			discordSession.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent
+		}
 	}
 
 	if config.IsTelegramEnabled(cfg) {
@@ -63,7 +98,7 @@ func main() {
 		}
 	}
 
-	listener := listener.New(cfg, log, discordSession, telegramSession, nil)
+	listener := listener.New(cfg, log, discordSession, telegramSession, db)
 
 	if config.IsDiscordEnabled(cfg) {
 		if err := listener.Discord.Start(); err != nil {
@@ -83,6 +118,30 @@ func main() {
 		defer listener.Telegram.End()
 	}
 
+	if listener.Scheduler != nil {
+		if err := listener.Scheduler.Start(); err != nil {
+			log.Error(err, "Failed to start scheduler")
+			return
+		}
+		defer func() {
+			if err := listener.Scheduler.End(); err != nil {
+				log.Error(err, "Failed to stop scheduler")
+			}
+		}()
+	}
+
+	if listener.Queue != nil {
+		if err := listener.Queue.Start(); err != nil {
+			log.Error(err, "Failed to start job queue")
+			return
+		}
+		defer func() {
+			if err := listener.Queue.End(); err != nil {
+				log.Error(err, "Failed to stop job queue")
+			}
+		}()
+	}
+
 	// Start server
 	srv := startServer(log)
 
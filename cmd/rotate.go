@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/repo"
+	"sum/pkg/repo/secret"
+
+	"gorm.io/gorm"
+)
+
+// rotateKeys re-encrypts the API keys of every user and server agent config
+// with the primary key of the configured secret store, upgrading legacy AES-CFB
+// values to AES-GCM. The previous local keys must be listed in
+// ENCRYPTION_RETIRED_KEYS so the existing values can be decrypted.
+func rotateKeys(cfg config.Config, db *gorm.DB, log logger.Logger) error {
+	if db == nil {
+		return errors.New("database is not configured")
+	}
+
+	// Rotating is how legacy values are upgraded, so they are read whatever SECRET_LEGACY_CFB says
+	cfg.SecretStore.LegacyCFB = true
+
+	secrets, err := secret.NewFromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid encryption keys: %w", err)
+	}
+
+	return repo.NewRepository(db, secrets).WithTx(func(txRepo repo.Repository) error {
+		users, err := txRepo.UserConfig().RotateAPIKeys()
+		if err != nil {
+			return err
+		}
+
+		servers, err := txRepo.ServerConfig().RotateAPIKeys()
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Re-encrypted %d user and %d server API keys", users, servers)
+		return nil
+	})
+}
@@ -0,0 +1,15 @@
+// Package migrations embeds the SQL schema migrations so they can be applied by the binary.
+package migrations
+
+import "embed"
+
+// Schemas holds the Postgres migrations of the schemas directory, in the sql-migrate format
+//
+//go:embed schemas/*.sql
+var Schemas embed.FS
+
+// SQLite holds the SQLite migrations of the sqlite directory, in the sql-migrate format.
+// Every Postgres migration must have a counterpart with the same file name here.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
@@ -0,0 +1,18 @@
+-- +migrate Up
+-- Access control rules per server
+CREATE TABLE IF NOT EXISTS permission_rules (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    server_id BIGINT NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+    config_id BIGINT NOT NULL DEFAULT 0,  -- Server admin config the rule applies to, 0 for all commands
+    action VARCHAR(20) NOT NULL,  -- 'use', 'register', 'remove' or '*'
+    effect VARCHAR(10) NOT NULL,  -- 'allow' or 'deny'
+    subject_type VARCHAR(20) NOT NULL,  -- 'everyone', 'admins', 'user' or 'role'
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',  -- Platform-specific user identifier
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_permission_rules_server_id ON permission_rules(server_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS permission_rules;
@@ -0,0 +1,12 @@
+-- +migrate Up
+-- When the endpoint and API key of a config passed the registration probe
+ALTER TABLE user_agent_configs ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE server_admin_configs ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Configs completed before the probe existed are considered verified
+UPDATE user_agent_configs SET verified_at = updated_at WHERE api_key <> '' AND endpoint_url <> '';
+UPDATE server_admin_configs SET verified_at = updated_at WHERE api_key <> '' AND endpoint_url <> '';
+
+-- +migrate Down
+ALTER TABLE server_admin_configs DROP COLUMN IF EXISTS verified_at;
+ALTER TABLE user_agent_configs DROP COLUMN IF EXISTS verified_at;
@@ -0,0 +1,18 @@
+-- +migrate Up
+-- State of multi-step conversations, one per user and chat
+CREATE TABLE IF NOT EXISTS wizard_sessions (
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier
+    user_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    flow VARCHAR(50) NOT NULL,  -- Wizard the conversation belongs to, e.g. 'reg_user'
+    step VARCHAR(50) NOT NULL,  -- Step waiting for an answer
+    subject_id VARCHAR(255) NOT NULL DEFAULT '',  -- Record filled by the wizard, e.g. a config ID
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (platform, chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wizard_sessions_expires_at ON wizard_sessions(expires_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS wizard_sessions;
@@ -0,0 +1,27 @@
+-- +migrate Up
+-- Inputs sent to the agent with every request, as a JSON object
+ALTER TABLE user_agent_configs ADD COLUMN IF NOT EXISTS inputs TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE server_admin_configs ADD COLUMN IF NOT EXISTS inputs TEXT NOT NULL DEFAULT '{}';
+
+-- Append-only record of changes to configurations
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    platform VARCHAR(20) NOT NULL,
+    actor_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier of who made the change
+    action VARCHAR(50) NOT NULL,  -- e.g. 'config.edit'
+    entity_type VARCHAR(50) NOT NULL,  -- 'user_agent_config' or 'server_admin_config'
+    entity_id BIGINT NOT NULL,
+    server_id BIGINT NOT NULL DEFAULT 0,  -- Server the entity belongs to, 0 for personal configs
+    before TEXT NOT NULL DEFAULT '',  -- JSON of the changed fields before the change, secrets redacted
+    after TEXT NOT NULL DEFAULT '',  -- JSON of the changed fields after the change, secrets redacted
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_server_id ON audit_events(server_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events(entity_type, entity_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS audit_events;
+
+ALTER TABLE server_admin_configs DROP COLUMN IF EXISTS inputs;
+ALTER TABLE user_agent_configs DROP COLUMN IF EXISTS inputs;
@@ -0,0 +1,23 @@
+-- +migrate Up
+-- Summaries of the links posted in a server without /sum
+CREATE TABLE IF NOT EXISTS auto_summaries (
+    server_id BIGINT PRIMARY KEY REFERENCES servers(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL DEFAULT 'off',  -- 'off', 'auto' or 'button'
+    allow_domains TEXT NOT NULL DEFAULT '',  -- Comma separated, empty for every domain
+    deny_domains TEXT NOT NULL DEFAULT '',  -- Comma separated
+    min_length INT NOT NULL DEFAULT 0,  -- Characters of text of the article
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Links recently summarized in a chat, so each is summarized once
+CREATE TABLE IF NOT EXISTS summarized_urls (
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier
+    url_hash VARCHAR(64) NOT NULL,  -- SHA-256 of the link
+    summarized_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (platform, chat_id, url_hash)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS summarized_urls;
+DROP TABLE IF EXISTS auto_summaries;
@@ -0,0 +1,23 @@
+-- +migrate Up
+-- Long-running agent tasks, run by the workers of every instance and answered in the chat they came from
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier the answer is posted to
+    user_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    message_id VARCHAR(255) NOT NULL DEFAULT '',  -- Message the answer replies to
+    notice_id VARCHAR(255) NOT NULL DEFAULT '',  -- Message telling the job is queued, removed with the answer
+    command VARCHAR(255) NOT NULL,  -- Built-in agent running the job
+    message TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 0,  -- Higher runs first
+    attempts INT NOT NULL DEFAULT 0,
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL,  -- Pushed past the visibility timeout while a worker runs the job
+    locked_by VARCHAR(255) NOT NULL DEFAULT '',  -- Instance that claimed the job last
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_available ON jobs (priority DESC, available_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_jobs_available;
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,28 @@
+-- +migrate Up
+-- Prompts sent to several agent commands at once with /ai compare, their answers are voted on
+CREATE TABLE IF NOT EXISTS comparisons (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier the answers were posted to
+    message_id VARCHAR(255) NOT NULL DEFAULT '',  -- Platform-specific identifier of the message of the answers, empty until it is posted
+    created_by VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    commands TEXT NOT NULL,  -- Comma separated names of the compared configs, in the order of their answers
+    prompt TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_comparisons_chat ON comparisons (platform, chat_id);
+
+-- The answer each user found best, a new vote replaces the previous one
+CREATE TABLE IF NOT EXISTS comparison_votes (
+    comparison_id BIGINT NOT NULL REFERENCES comparisons(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    command VARCHAR(255) NOT NULL,  -- Name of the config voted for
+    voted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comparison_id, user_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS comparison_votes;
+DROP INDEX IF EXISTS idx_comparisons_chat;
+DROP TABLE IF EXISTS comparisons;
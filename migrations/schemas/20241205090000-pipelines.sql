@@ -0,0 +1,16 @@
+-- +migrate Up
+-- Named pipelines of agent commands, each command answers the answer of the previous one
+CREATE TABLE IF NOT EXISTS pipelines (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    owner_type VARCHAR(20) NOT NULL,  -- 'user' or 'server', like the configs
+    owner_id BIGINT NOT NULL,  -- ID of the user or the server owning the pipeline
+    name VARCHAR(255) NOT NULL,
+    steps TEXT NOT NULL,  -- Commands separated by |, each with the inputs it overrides, e.g. summarize | translate lang=vi
+    created_by VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_type, owner_id, name)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS pipelines;
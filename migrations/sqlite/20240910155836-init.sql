@@ -0,0 +1,64 @@
+-- +migrate Up
+-- SQLite has no enum types, platform columns are constrained with CHECK instead
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    user_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    platform TEXT NOT NULL CHECK (platform IN ('discord', 'telegram')),
+    username VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_active DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, platform)
+);
+
+-- User agent configurations table
+CREATE TABLE IF NOT EXISTS user_agent_configs (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    api_key VARCHAR(255) NOT NULL,
+    endpoint_url VARCHAR(255) NOT NULL,
+    command VARCHAR(50) NOT NULL,
+    description TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE (user_id, command)  -- Ensure unique command per user
+);
+
+-- Servers table
+CREATE TABLE IF NOT EXISTS servers (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    server_id VARCHAR(255) NOT NULL,  -- Platform-specific server identifier
+    platform TEXT NOT NULL CHECK (platform IN ('discord', 'telegram')),
+    server_name VARCHAR(255) NOT NULL,
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (server_id, platform)
+);
+
+-- Server admin configurations table
+CREATE TABLE IF NOT EXISTS server_admin_configs (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    server_id BIGINT NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+    api_key VARCHAR(255) NOT NULL,
+    endpoint_url VARCHAR(255) NOT NULL,
+    command VARCHAR(50) NOT NULL,
+    description TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE (server_id, command)  -- Ensure unique command per server
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_user_id ON users(user_id);
+CREATE INDEX IF NOT EXISTS idx_servers_server_id ON servers(server_id);
+CREATE INDEX IF NOT EXISTS idx_servers_owner_id ON servers(owner_id);
+CREATE INDEX IF NOT EXISTS idx_user_agent_configs_command ON user_agent_configs(command);
+CREATE INDEX IF NOT EXISTS idx_server_admin_configs_command ON server_admin_configs(command);
+
+-- +migrate Down
+DROP TABLE IF EXISTS server_admin_configs;
+DROP TABLE IF EXISTS user_agent_configs;
+DROP TABLE IF EXISTS servers;
+DROP TABLE IF EXISTS users;
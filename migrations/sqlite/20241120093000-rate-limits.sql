@@ -0,0 +1,30 @@
+-- +migrate Up
+-- Quotas for server admin configurations, 0 means unlimited
+ALTER TABLE server_admin_configs ADD COLUMN daily_quota INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE server_admin_configs ADD COLUMN monthly_quota INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE server_admin_configs ADD COLUMN user_daily_quota INTEGER NOT NULL DEFAULT 0;
+
+-- Token buckets for rate limiting
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY,  -- Bucket key, e.g. user:<id>, chat:<id>, command:<chat>:<command>
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Quota counters per server admin configuration
+CREATE TABLE IF NOT EXISTS quota_usages (
+    config_id BIGINT NOT NULL REFERENCES server_admin_configs(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL DEFAULT '',  -- Platform-specific user identifier, empty for group-wide counters
+    period VARCHAR(10) NOT NULL,  -- 'day' or 'month'
+    period_start DATETIME NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (config_id, user_id, period, period_start)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS quota_usages;
+DROP TABLE IF EXISTS rate_limit_buckets;
+
+ALTER TABLE server_admin_configs DROP COLUMN user_daily_quota;
+ALTER TABLE server_admin_configs DROP COLUMN monthly_quota;
+ALTER TABLE server_admin_configs DROP COLUMN daily_quota;
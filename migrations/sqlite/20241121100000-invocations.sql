@@ -0,0 +1,27 @@
+-- +migrate Up
+-- Agent invocations with usage and outcome
+CREATE TABLE IF NOT EXISTS invocations (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    platform TEXT NOT NULL CHECK (platform IN ('discord', 'telegram')),
+    user_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    server_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier
+    config_id BIGINT NOT NULL DEFAULT 0,  -- User or server config ID, 0 for built-in commands
+    config_type VARCHAR(20) NOT NULL,  -- 'user', 'server' or 'builtin'
+    command VARCHAR(50) NOT NULL,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens INTEGER NOT NULL DEFAULT 0,
+    cost NUMERIC(16, 7) NOT NULL DEFAULT 0,
+    currency VARCHAR(10) NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    error TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invocations_user_id ON invocations(user_id, platform, created_at);
+CREATE INDEX IF NOT EXISTS idx_invocations_server_id ON invocations(server_id, platform, created_at);
+CREATE INDEX IF NOT EXISTS idx_invocations_config_id ON invocations(config_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS invocations;
@@ -0,0 +1,6 @@
+-- +migrate Up
+-- One-time token of the private chat link a session waits for, empty once opened
+ALTER TABLE wizard_sessions ADD COLUMN token VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE wizard_sessions DROP COLUMN token;
@@ -0,0 +1,19 @@
+-- +migrate Up
+-- Other names the command can be invoked with, comma separated
+ALTER TABLE user_agent_configs ADD COLUMN aliases TEXT NOT NULL DEFAULT '';
+ALTER TABLE server_admin_configs ADD COLUMN aliases TEXT NOT NULL DEFAULT '';
+
+-- Settings of a chat, such as the command answering messages without a command
+CREATE TABLE IF NOT EXISTS chat_settings (
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier
+    default_command VARCHAR(255) NOT NULL DEFAULT '',  -- Command run for mentions and private messages, empty for the active config
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (platform, chat_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS chat_settings;
+
+ALTER TABLE server_admin_configs DROP COLUMN aliases;
+ALTER TABLE user_agent_configs DROP COLUMN aliases;
@@ -0,0 +1,15 @@
+-- +migrate Up
+-- Telegram group members shown their own commands in the command menu of the group
+CREATE TABLE IF NOT EXISTS member_menus (
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific group identifier
+    user_id VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (platform, chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_member_menus_user ON member_menus (platform, user_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_member_menus_user;
+DROP TABLE IF EXISTS member_menus;
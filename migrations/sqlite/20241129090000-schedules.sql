@@ -0,0 +1,34 @@
+-- +migrate Up
+-- Agent commands run on a cron schedule, their answers are posted to a chat
+CREATE TABLE IF NOT EXISTS schedules (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier the answers are posted to
+    created_by VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    config_type VARCHAR(20) NOT NULL,  -- 'user' or 'server'
+    config_id BIGINT NOT NULL,
+    command VARCHAR(255) NOT NULL,  -- Name of the config when the schedule was added
+    cron VARCHAR(255) NOT NULL,  -- Five field cron expression or a macro such as @daily
+    timezone VARCHAR(64) NOT NULL,  -- IANA time zone the expression is evaluated in
+    prompt TEXT NOT NULL,
+    next_run_at DATETIME NOT NULL,
+    last_run_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_next_run_at ON schedules (next_run_at);
+CREATE INDEX IF NOT EXISTS idx_schedules_chat ON schedules (platform, chat_id);
+
+-- Leases elect the instance running shared background work, such as the scheduler
+CREATE TABLE IF NOT EXISTS leases (
+    name VARCHAR(64) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,  -- Instance holding the lease
+    expires_at DATETIME NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS leases;
+DROP INDEX IF EXISTS idx_schedules_chat;
+DROP INDEX IF EXISTS idx_schedules_next_run_at;
+DROP TABLE IF EXISTS schedules;
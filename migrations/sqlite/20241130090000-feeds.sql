@@ -0,0 +1,38 @@
+-- +migrate Up
+-- RSS and Atom feeds watched by a chat, their new items are summarized and posted to it
+CREATE TABLE IF NOT EXISTS feeds (
+    id BIGINT PRIMARY KEY,  -- Numeric primary key
+    platform VARCHAR(20) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,  -- Platform-specific chat identifier the items are posted to
+    created_by VARCHAR(255) NOT NULL,  -- Platform-specific user identifier
+    url TEXT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    config_type VARCHAR(20) NOT NULL,  -- 'builtin' for the /sum agent, 'user' or 'server'
+    config_id BIGINT NOT NULL DEFAULT 0,
+    command VARCHAR(255) NOT NULL,  -- Name of the summarizer when the feed was watched
+    interval_minutes INT NOT NULL,
+    max_items INT NOT NULL,  -- Items posted per poll, the others are skipped
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    next_poll_at DATETIME NOT NULL,
+    last_polled_at DATETIME,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_feeds_next_poll_at ON feeds (next_poll_at);
+CREATE INDEX IF NOT EXISTS idx_feeds_chat ON feeds (platform, chat_id);
+
+-- Items of a feed already seen, so each is posted once
+CREATE TABLE IF NOT EXISTS feed_items (
+    feed_id BIGINT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    guid VARCHAR(255) NOT NULL,  -- GUID of the item, hashed when longer
+    seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (feed_id, guid)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS feed_items;
+DROP INDEX IF EXISTS idx_feeds_chat;
+DROP INDEX IF EXISTS idx_feeds_next_poll_at;
+DROP TABLE IF EXISTS feeds;
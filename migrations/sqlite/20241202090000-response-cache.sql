@@ -0,0 +1,19 @@
+-- +migrate Up
+-- Answers of agents cached by endpoint, API key, message and inputs
+CREATE TABLE IF NOT EXISTS cached_responses (
+    key VARCHAR(64) PRIMARY KEY,  -- SHA-256 of the endpoint, API key, normalized message and inputs
+    answer TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_cached_responses_created_at ON cached_responses (created_at);
+
+-- Invocations answered from the cache
+ALTER TABLE invocations ADD COLUMN cached BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Down
+ALTER TABLE invocations DROP COLUMN cached;
+
+DROP INDEX IF EXISTS idx_cached_responses_created_at;
+DROP TABLE IF EXISTS cached_responses;
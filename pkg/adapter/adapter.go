@@ -3,24 +3,37 @@
 package adapter
 
 import (
+	"sum/pkg/adapter/article"
 	"sum/pkg/adapter/dify"
+	"sum/pkg/adapter/feed"
 	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/outbound"
 )
 
 // IAdapter defines the interface for adapters.
 type IAdapter interface {
 	Dify() dify.DifyAdapter
+	Feed() feed.FeedAdapter
+	Article() article.ArticleAdapter
 }
 
 // Adapter implements the IAdapter interface.
 type Adapter struct {
-	dify dify.DifyAdapter
+	dify    dify.DifyAdapter
+	feed    feed.FeedAdapter
+	article article.ArticleAdapter
 }
 
 // New creates a new Adapter instance with the provided configuration.
-func New(cfg config.Config) IAdapter {
+// Requests to agent endpoints, feeds and articles go through the outbound request
+// policy, except those to the origin of the agent configured by the operator.
+func New(cfg config.Config, logger logger.Logger) IAdapter {
+	policy := outbound.New(cfg.Outbound, logger)
 	return &Adapter{
-		dify: dify.New(),
+		dify:    dify.New(policy.Trust(cfg.AgentURL).Client(0)),
+		feed:    feed.New(policy.Client(0)),
+		article: article.New(policy.Client(0)),
 	}
 }
 
@@ -28,3 +41,13 @@ func New(cfg config.Config) IAdapter {
 func (a *Adapter) Dify() dify.DifyAdapter {
 	return a.dify
 }
+
+// Feed returns the FeedAdapter instance.
+func (a *Adapter) Feed() feed.FeedAdapter {
+	return a.feed
+}
+
+// Article returns the ArticleAdapter instance.
+func (a *Adapter) Article() article.ArticleAdapter {
+	return a.article
+}
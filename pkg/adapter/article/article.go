@@ -0,0 +1,129 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// FetchTimeout bounds a whole page request, including reading the page.
+const FetchTimeout = 20 * time.Second
+
+// maxPageSize bounds the bytes of a page read, the rest of the page is ignored
+const maxPageSize = 2 << 20
+
+// ErrNotArticle is returned when the document fetched isn't an HTML or text page
+var ErrNotArticle = errors.New("not an HTML page")
+
+// skipped are the elements whose text isn't part of the article
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+}
+
+// Client represents a client fetching articles.
+type Client struct {
+	client *http.Client
+}
+
+// New creates a new instance of ArticleAdapter sending its requests with client,
+// which is expected to enforce the outbound request policy.
+func New(client *http.Client) ArticleAdapter {
+	return &Client{client: client}
+}
+
+// Fetch downloads the page at url and extracts its text
+func (c *Client) Fetch(url string) (*Article, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html, text/plain;q=0.9")
+
+	client := *c.client
+	client.Timeout = FetchTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageSize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "":
+		return Parse(body)
+	case "text/plain":
+		text, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return &Article{Text: collapse(string(text))}, nil
+	default:
+		return nil, ErrNotArticle
+	}
+}
+
+// Parse extracts the title and the visible text of an HTML page
+func Parse(r io.Reader) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotArticle, err)
+	}
+
+	var (
+		article Article
+		text    strings.Builder
+		walk    func(n *html.Node)
+	)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.ElementNode && n.DataAtom == atom.Title:
+			if article.Title == "" && n.FirstChild != nil {
+				article.Title = collapse(n.FirstChild.Data)
+			}
+			return
+		case n.Type == html.ElementNode && skipped[n.DataAtom]:
+			return
+		case n.Type == html.TextNode:
+			text.WriteString(n.Data)
+			text.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	article.Text = collapse(text.String())
+	return &article, nil
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
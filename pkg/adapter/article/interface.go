@@ -0,0 +1,13 @@
+// Package article provides an adapter for fetching the text of web pages.
+package article
+
+// ArticleAdapter defines the interface for fetching articles.
+type ArticleAdapter interface {
+	Fetch(url string) (*Article, error)
+}
+
+// Article represents the readable text of a fetched web page
+type Article struct {
+	Title string
+	Text  string // Visible text of the page, whitespace collapsed
+}
@@ -4,20 +4,27 @@ package dify
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+// ChatTimeout bounds a whole chat request, including reading the streamed answer.
+const ChatTimeout = 5 * time.Minute
+
 // Dify represents a client for interacting with the Dify API.
 type Dify struct {
+	client *http.Client
 }
 
-// New creates a new instance of DifyAdapter with the provided base URL and summarizer app token.
-func New() DifyAdapter {
-	return &Dify{}
+// New creates a new instance of DifyAdapter sending its requests with client,
+// which is expected to enforce the outbound request policy.
+func New(client *http.Client) DifyAdapter {
+	return &Dify{client: client}
 }
 
 // BaseEvent represents the common fields in the event returned by the Dify API.
@@ -48,49 +55,89 @@ type AgentMessage struct {
 	Answer string `json:"answer,omitempty"`
 }
 
-// Chat returns the chat response from the Dify API.
-func (d *Dify) Chat(msg, url, token string) (content string, err error) {
+// Usage represents the token usage reported by the Dify API at the end of a message.
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	TotalTokens      int     `json:"total_tokens,omitempty"`
+	TotalPrice       string  `json:"total_price,omitempty"`
+	Currency         string  `json:"currency,omitempty"`
+	Latency          float64 `json:"latency,omitempty"`
+}
+
+// Cost returns the total price of the message as a number.
+func (u Usage) Cost() float64 {
+	cost, err := strconv.ParseFloat(u.TotalPrice, 64)
+	if err != nil {
+		return 0
+	}
+	return cost
+}
+
+// MessageEnd represents the specific fields for message_end events returned by the Dify API.
+type MessageEnd struct {
+	BaseEvent
+	Metadata struct {
+		Usage Usage `json:"usage,omitempty"`
+	} `json:"metadata,omitempty"`
+}
+
+// ChatResponse represents the result of a chat request to the Dify API.
+type ChatResponse struct {
+	Answer string
+	Usage  Usage
+	Cached bool // Whether the answer was reused from an identical invocation, without usage
+}
+
+// Chat returns the chat response from the Dify API. inputs are the app variables
+// sent with the message, nil sends none.
+func (d *Dify) Chat(msg, url, token string, inputs map[string]any) (*ChatResponse, error) {
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+
 	// Define the URL and request body
 	requestBody, err := json.Marshal(map[string]interface{}{
-		"inputs":          map[string]interface{}{},
+		"inputs":          inputs,
 		"query":           msg,
 		"response_mode":   "streaming",
 		"conversation_id": "",
 		"user":            "ask",
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), ChatTimeout)
+	defer cancel()
+
 	// Create the HTTP request
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", nil
+		return nil, err
 	}
 
 	// Set the required headers
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	// Create an HTTP client with a timeout
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
-	}
-
 	// Send the request
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// Check the response status
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	// Handle streaming response
-	var thoughts []AgentThought
+	var (
+		thoughts []AgentThought
+		usage    Usage
+	)
 	reader := bufio.NewReader(resp.Body)
 	for {
 		line, err := reader.ReadBytes('\n')
@@ -98,7 +145,7 @@ func (d *Dify) Chat(msg, url, token string) (content string, err error) {
 			if err == io.EOF {
 				break
 			}
-			return "", err
+			return nil, err
 		}
 
 		// Remove the "data: " prefix and trim whitespace
@@ -134,6 +181,14 @@ func (d *Dify) Chat(msg, url, token string) (content string, err error) {
 			thoughts = append(thoughts, event)
 		case "agent_message":
 			// just ignore agent_message event
+		case "message_end":
+			var event MessageEnd
+			err = json.Unmarshal(line, &event)
+			if err != nil {
+				fmt.Printf("Error parsing message_end JSON: %v\n", err)
+				continue
+			}
+			usage = event.Metadata.Usage
 		default:
 			// Ignore other event types
 		}
@@ -141,15 +196,16 @@ func (d *Dify) Chat(msg, url, token string) (content string, err error) {
 
 	// Get the last non-empty thought
 	if len(thoughts) == 0 {
-		return "", fmt.Errorf("no thought found")
+		return nil, fmt.Errorf("no thought found")
 	}
 
+	response := &ChatResponse{Usage: usage}
 	for i := len(thoughts) - 1; i >= 0; i-- {
 		if thoughts[i].Thought != "" {
-			content = thoughts[i].Thought
+			response.Answer = thoughts[i].Thought
 			break
 		}
 	}
 
-	return content, nil
+	return response, nil
 }
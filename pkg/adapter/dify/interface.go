@@ -3,5 +3,6 @@ package dify
 
 // DifyAdapter defines the interface for interacting with the Dify service.
 type DifyAdapter interface {
-	Chat(msg, url, token string) (content string, err error)
+	Chat(msg, url, token string, inputs map[string]any) (*ChatResponse, error)
+	Probe(url, token string) (*AppInfo, error)
 }
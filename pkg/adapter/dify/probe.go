@@ -0,0 +1,169 @@
+package dify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProbeTimeout bounds the requests made to probe an endpoint during registration.
+const ProbeTimeout = 10 * time.Second
+
+// ErrUnauthorized is returned by Probe when the endpoint rejects the API key.
+var ErrUnauthorized = errors.New("the API key was rejected by the endpoint")
+
+// endpointSuffixes are the message endpoints a config may point to, stripped to find the API base URL.
+var endpointSuffixes = []string{"/chat-messages", "/completion-messages", "/workflows/run"}
+
+// AppInfo describes the Dify app behind an endpoint, as reported by its /info and /parameters APIs.
+type AppInfo struct {
+	Name         string
+	Description  string
+	Mode         string
+	Capabilities []string
+}
+
+// appInfoResponse is the body of the Dify /info API.
+type appInfoResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Mode        string `json:"mode"`
+}
+
+// feature is an optional app feature reported by the Dify /parameters API.
+type feature struct {
+	Enabled bool `json:"enabled"`
+}
+
+// parametersResponse is the body of the Dify /parameters API.
+type parametersResponse struct {
+	OpeningStatement   string            `json:"opening_statement"`
+	SuggestedQuestions []string          `json:"suggested_questions"`
+	SpeechToText       feature           `json:"speech_to_text"`
+	TextToSpeech       feature           `json:"text_to_speech"`
+	RetrieverResource  feature           `json:"retriever_resource"`
+	FileUpload         map[string]any    `json:"file_upload"`
+	UserInputForm      []json.RawMessage `json:"user_input_form"`
+}
+
+// Probe checks that endpoint is reachable and accepts token by calling the
+// Dify /parameters API, and the /info API when the server provides it.
+func (d *Dify) Probe(endpoint, token string) (*AppInfo, error) {
+	base, err := baseURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ProbeTimeout)
+	defer cancel()
+
+	var params parametersResponse
+	found, err := getJSON(ctx, d.client, base+"/parameters", token, &params)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no Dify app API found at %s", base)
+	}
+
+	info := &AppInfo{Capabilities: capabilities(params)}
+
+	// Older Dify versions don't have /info, the app is still usable without it
+	var app appInfoResponse
+	if found, err := getJSON(ctx, d.client, base+"/info", token, &app); err == nil && found {
+		info.Name = app.Name
+		info.Description = app.Description
+		info.Mode = app.Mode
+	}
+
+	return info, nil
+}
+
+// baseURL returns the API base URL of a message endpoint, e.g. https://api.dify.ai/v1
+func baseURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid endpoint URL %q", endpoint)
+	}
+
+	path := strings.TrimRight(u.Path, "/")
+	for _, suffix := range endpointSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			path = strings.TrimSuffix(path, suffix)
+			break
+		}
+	}
+
+	u.Path = path
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// getJSON sends an authenticated GET request and decodes the JSON response into out.
+// It reports false when the API doesn't exist.
+func getJSON(ctx context.Context, client *http.Client, url, token string, out any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return false, ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return false, fmt.Errorf("unexpected response from %s: %w", url, err)
+	}
+	return true, nil
+}
+
+// capabilities lists the features enabled in the app parameters.
+func capabilities(params parametersResponse) []string {
+	var caps []string
+	if len(params.UserInputForm) > 0 {
+		caps = append(caps, fmt.Sprintf("%d input fields", len(params.UserInputForm)))
+	}
+	if enabled, ok := params.FileUpload["enabled"].(bool); ok && enabled {
+		caps = append(caps, "file upload")
+	} else if image, ok := params.FileUpload["image"].(map[string]any); ok && image["enabled"] == true {
+		caps = append(caps, "image upload")
+	}
+	if params.SpeechToText.Enabled {
+		caps = append(caps, "speech to text")
+	}
+	if params.TextToSpeech.Enabled {
+		caps = append(caps, "text to speech")
+	}
+	if params.RetrieverResource.Enabled {
+		caps = append(caps, "citations")
+	}
+	if params.OpeningStatement != "" {
+		caps = append(caps, "opening statement")
+	}
+	if len(params.SuggestedQuestions) > 0 {
+		caps = append(caps, "suggested questions")
+	}
+	return caps
+}
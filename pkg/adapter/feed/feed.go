@@ -0,0 +1,156 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// FetchTimeout bounds a whole feed request, including reading the feed.
+const FetchTimeout = 30 * time.Second
+
+// ErrNotFeed is returned when the document fetched isn't an RSS or Atom feed
+var ErrNotFeed = errors.New("not an RSS or Atom feed")
+
+// Client represents a client fetching feeds.
+type Client struct {
+	client *http.Client
+}
+
+// New creates a new instance of FeedAdapter sending its requests with client,
+// which is expected to enforce the outbound request policy.
+func New(client *http.Client) FeedAdapter {
+	return &Client{client: client}
+}
+
+// document is an RSS 2.0, RSS 1.0 or Atom document, the elements match in any namespace
+type document struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`  // RSS 1.0 items are siblings of the channel
+	Entries []atomEntry `xml:"entry"` // Atom
+}
+
+type rssItem struct {
+	GUID  string `xml:"guid"`
+	About string `xml:"about,attr"`
+	Title string `xml:"title"`
+	Links []link `xml:"link"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []link `xml:"link"`
+}
+
+// link is an RSS link, given as text, or an Atom link, given as attributes
+type link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Fetch downloads and parses the feed at url
+func (c *Client) Fetch(url string) (*Feed, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	client := *c.client
+	client.Timeout = FetchTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return Parse(resp.Body)
+}
+
+// Parse parses an RSS or Atom feed
+func Parse(r io.Reader) (*Feed, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported charset %q", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+
+	var doc document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotFeed, err)
+	}
+
+	feed := &Feed{}
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss":
+		feed.Title = doc.Channel.Title
+		feed.Items = rssItems(doc.Channel.Items)
+	case "rdf":
+		feed.Title = doc.Channel.Title
+		feed.Items = rssItems(doc.Items)
+	case "feed":
+		feed.Title = doc.Title
+		for _, entry := range doc.Entries {
+			feed.Items = append(feed.Items, item(entry.ID, entry.Title, entry.Links))
+		}
+	default:
+		return nil, ErrNotFeed
+	}
+
+	feed.Title = strings.TrimSpace(feed.Title)
+	return feed, nil
+}
+
+func rssItems(items []rssItem) []Item {
+	result := make([]Item, 0, len(items))
+	for _, i := range items {
+		guid := i.GUID
+		if guid == "" {
+			guid = i.About
+		}
+		result = append(result, item(guid, i.Title, i.Links))
+	}
+	return result
+}
+
+// item builds an item, the alternate link of Atom entries is preferred
+func item(guid, title string, links []link) Item {
+	i := Item{GUID: strings.TrimSpace(guid), Title: strings.TrimSpace(title)}
+	for _, l := range links {
+		href := strings.TrimSpace(l.Href)
+		if href == "" {
+			href = strings.TrimSpace(l.Text)
+		}
+		if href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			i.Link = href
+			break
+		}
+	}
+	if i.GUID == "" {
+		i.GUID = i.Link
+	}
+	if i.GUID == "" {
+		i.GUID = i.Title
+	}
+	return i
+}
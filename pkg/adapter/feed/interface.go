@@ -0,0 +1,20 @@
+// Package feed provides an adapter for fetching RSS and Atom feeds.
+package feed
+
+// FeedAdapter defines the interface for fetching feeds.
+type FeedAdapter interface {
+	Fetch(url string) (*Feed, error)
+}
+
+// Feed represents a fetched RSS or Atom feed
+type Feed struct {
+	Title string
+	Items []Item // In the order of the feed, usually the newest first
+}
+
+// Item represents an entry of a feed
+type Item struct {
+	GUID  string // GUID or ID of the entry, its link when it has none
+	Title string
+	Link  string
+}
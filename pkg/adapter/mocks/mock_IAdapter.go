@@ -3,7 +3,9 @@
 package mocks
 
 import (
+	article "sum/pkg/adapter/article"
 	dify "sum/pkg/adapter/dify"
+	feed "sum/pkg/adapter/feed"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -21,6 +23,53 @@ func (_m *MockIAdapter) EXPECT() *MockIAdapter_Expecter {
 	return &MockIAdapter_Expecter{mock: &_m.Mock}
 }
 
+// Article provides a mock function with given fields:
+func (_m *MockIAdapter) Article() article.ArticleAdapter {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Article")
+	}
+
+	var r0 article.ArticleAdapter
+	if rf, ok := ret.Get(0).(func() article.ArticleAdapter); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(article.ArticleAdapter)
+		}
+	}
+
+	return r0
+}
+
+// MockIAdapter_Article_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Article'
+type MockIAdapter_Article_Call struct {
+	*mock.Call
+}
+
+// Article is a helper method to define mock.On call
+func (_e *MockIAdapter_Expecter) Article() *MockIAdapter_Article_Call {
+	return &MockIAdapter_Article_Call{Call: _e.mock.On("Article")}
+}
+
+func (_c *MockIAdapter_Article_Call) Run(run func()) *MockIAdapter_Article_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockIAdapter_Article_Call) Return(_a0 article.ArticleAdapter) *MockIAdapter_Article_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockIAdapter_Article_Call) RunAndReturn(run func() article.ArticleAdapter) *MockIAdapter_Article_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Dify provides a mock function with given fields:
 func (_m *MockIAdapter) Dify() dify.DifyAdapter {
 	ret := _m.Called()
@@ -68,6 +117,53 @@ func (_c *MockIAdapter_Dify_Call) RunAndReturn(run func() dify.DifyAdapter) *Moc
 	return _c
 }
 
+// Feed provides a mock function with given fields:
+func (_m *MockIAdapter) Feed() feed.FeedAdapter {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Feed")
+	}
+
+	var r0 feed.FeedAdapter
+	if rf, ok := ret.Get(0).(func() feed.FeedAdapter); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(feed.FeedAdapter)
+		}
+	}
+
+	return r0
+}
+
+// MockIAdapter_Feed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Feed'
+type MockIAdapter_Feed_Call struct {
+	*mock.Call
+}
+
+// Feed is a helper method to define mock.On call
+func (_e *MockIAdapter_Expecter) Feed() *MockIAdapter_Feed_Call {
+	return &MockIAdapter_Feed_Call{Call: _e.mock.On("Feed")}
+}
+
+func (_c *MockIAdapter_Feed_Call) Run(run func()) *MockIAdapter_Feed_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockIAdapter_Feed_Call) Return(_a0 feed.FeedAdapter) *MockIAdapter_Feed_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockIAdapter_Feed_Call) RunAndReturn(run func() feed.FeedAdapter) *MockIAdapter_Feed_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockIAdapter creates a new instance of MockIAdapter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockIAdapter(t interface {
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/ratelimit"
+	"sum/pkg/repo"
+)
+
+// BuiltinCommand is the name of the built-in agent configured by the operator, the one answering /sum
+const BuiltinCommand = "sum"
+
+// ErrUnverified is returned when a config didn't pass the registration probe yet
+var ErrUnverified = errors.New("command config is not verified")
+
+// LimitError is returned when an invocation exceeds a rate limit or quota
+type LimitError struct {
+	Decision ratelimit.Decision
+}
+
+func (e *LimitError) Error() string {
+	return "limit exceeded: " + e.Decision.Reason
+}
+
+// RunError is returned when the agent fails to answer an invocation
+type RunError struct {
+	Err error
+}
+
+func (e *RunError) Error() string {
+	return "agent failed: " + e.Err.Error()
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// Target is the agent answering an invocation: a user or server config, or the built-in agent
+type Target struct {
+	ID          int64
+	Type        models.ConfigType
+	Command     string // Name of the config, the invocation may have used an alias
+	EndpointURL string
+	APIKey      string // Encrypted, except the token of the built-in agent
+	Inputs      string
+	VerifiedAt  *time.Time                // Nil until the config passes the registration probe, and for the built-in agent
+	Server      *models.ServerAdminConfig // Nil for user configs and the built-in agent
+}
+
+// UserTarget returns the target of a user config
+func UserTarget(c models.UserAgentConfig) Target {
+	return Target{c.ID, models.ConfigTypeUser, c.Command, c.EndpointURL, c.APIKey, c.Inputs, c.VerifiedAt, nil}
+}
+
+// ServerTarget returns the target of a server config
+func ServerTarget(c models.ServerAdminConfig) Target {
+	return Target{c.ID, models.ConfigTypeServer, c.Command, c.EndpointURL, c.APIKey, c.Inputs, c.VerifiedAt, &c}
+}
+
+// Caller is the user invoking an agent and the chat the invocation comes from, the
+// user ID in private chats
+type Caller struct {
+	Platform models.PlatformType
+	UserID   string
+	ChatID   string
+}
+
+// Invoker checks the rate limits and quotas of invocations and sends them to the agent of
+// their target. It is shared by the agent commands, the scheduler and the link summaries.
+type Invoker struct {
+	repo       repo.Repository
+	runner     IRunner
+	limiter    ratelimit.ILimiter
+	agentURL   string
+	agentToken string
+	logger     logger.Logger
+}
+
+// NewInvoker creates an Invoker running the invocations with runner, the built-in agent
+// is the one of the configuration
+func NewInvoker(repo repo.Repository, runner IRunner, limiter ratelimit.ILimiter, cfg config.Config, logger logger.Logger) *Invoker {
+	return &Invoker{
+		repo:       repo,
+		runner:     runner,
+		limiter:    limiter,
+		agentURL:   cfg.AgentURL,
+		agentToken: cfg.AgentToken,
+		logger:     logger,
+	}
+}
+
+// Builtin returns the target of the built-in agent
+func (i *Invoker) Builtin() Target {
+	return Target{Type: models.ConfigTypeBuiltin, Command: BuiltinCommand, EndpointURL: i.agentURL, APIKey: i.agentToken}
+}
+
+// Load returns the target of a config, it returns gorm.ErrRecordNotFound once the config is removed
+func (i *Invoker) Load(configType models.ConfigType, configID int64) (Target, error) {
+	id := strconv.FormatInt(configID, 10)
+
+	switch configType {
+	case models.ConfigTypeBuiltin:
+		return i.Builtin(), nil
+	case models.ConfigTypeUser:
+		c, err := i.repo.UserConfig().GetByID(id)
+		if err != nil {
+			return Target{}, err
+		}
+		return UserTarget(c), nil
+	case models.ConfigTypeServer:
+		c, err := i.repo.ServerConfig().GetByID(id)
+		if err != nil {
+			return Target{}, err
+		}
+		return ServerTarget(c), nil
+	}
+	return Target{}, fmt.Errorf("unknown config type %q", configType)
+}
+
+// Request checks the rate limits and quotas of an invocation and builds the request to
+// the agent of t, with its inputs and decrypted API key. Configs that didn't finish
+// registration are refused with ErrUnverified.
+func (i *Invoker) Request(c Caller, t Target, message string) (Request, error) {
+	if t.Type != models.ConfigTypeBuiltin && t.VerifiedAt == nil {
+		return Request{}, ErrUnverified
+	}
+
+	// Aliases share the limits of the command
+	decision, err := i.limiter.Allow(ratelimit.Request{
+		UserID:  c.UserID,
+		ChatID:  c.ChatID,
+		Command: t.Command,
+		Config:  t.Server,
+	})
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if !decision.Allowed {
+		return Request{}, &LimitError{Decision: decision}
+	}
+
+	req := Request{
+		Platform:   c.Platform,
+		UserID:     c.UserID,
+		ServerID:   c.ChatID,
+		ConfigID:   t.ID,
+		ConfigType: t.Type,
+		Command:    t.Command,
+		Message:    message,
+		URL:        t.EndpointURL,
+		Token:      t.APIKey,
+	}
+	if t.Type == models.ConfigTypeBuiltin {
+		return req, nil
+	}
+
+	// Inputs are validated when edited, a broken value is sent as no inputs
+	if req.Inputs, err = models.DecodeInputs(t.Inputs); err != nil {
+		i.logger.Error(err, "Failed to decode config inputs")
+	}
+
+	req.Token, err = i.repo.Secret().Decrypt(t.APIKey, strconv.FormatInt(t.ID, 10))
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to decrypt API key: %w", err)
+	}
+	return req, nil
+}
+
+// Send runs a request built by Request. Failures of the agent and empty answers are returned as a RunError.
+func (i *Invoker) Send(req Request) (*dify.ChatResponse, error) {
+	resp, err := i.runner.Run(req)
+	if err != nil {
+		return nil, &RunError{Err: err}
+	}
+	if strings.TrimSpace(resp.Answer) == "" {
+		return nil, &RunError{Err: errors.New("empty response from the agent")}
+	}
+	return resp, nil
+}
+
+// Run checks the limits of an invocation and sends message to the agent of t
+func (i *Invoker) Run(c Caller, t Target, message string) (*dify.ChatResponse, error) {
+	req, err := i.Request(c, t, message)
+	if err != nil {
+		return nil, err
+	}
+	return i.Send(req)
+}
+
+// Failure returns the message explaining a failed invocation to the user, unexpected errors are logged
+func (i *Invoker) Failure(err error) string {
+	var (
+		limit *LimitError
+		run   *RunError
+	)
+	switch {
+	case errors.Is(err, ErrUnverified):
+		return "This command hasn't finished registration. Its owner can complete it with /reg, or /reg server for group commands."
+	case errors.As(err, &limit):
+		return limit.Decision.Message()
+	case errors.As(err, &run):
+		i.logger.Error(err, "Error executing command")
+		return fmt.Sprintf("Error executing command: %v", run.Err)
+	}
+
+	i.logger.Error(err, "Failed to invoke command")
+	return "An error occurred. Please try again."
+}
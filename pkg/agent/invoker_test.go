@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"testing"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner answers with answer, and records the requests it was sent
+type fakeRunner struct {
+	answer   string
+	requests []Request
+}
+
+func (r *fakeRunner) Run(req Request) (*dify.ChatResponse, error) {
+	r.requests = append(r.requests, req)
+	return &dify.ChatResponse{Answer: r.answer}, nil
+}
+
+// newInvoker creates an invoker allowing a single invocation per user and minute
+func newInvoker(runner IRunner) *Invoker {
+	cfg := config.Config{AgentURL: "http://agent.test/v1", AgentToken: "token"}
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), config.RateLimitConfig{UserPerMinute: 1})
+	return NewInvoker(nil, runner, limiter, cfg, logger.NewLogrusLogger())
+}
+
+func TestInvokerBuiltin(t *testing.T) {
+	runner := &fakeRunner{answer: "summary"}
+	i := newInvoker(runner)
+	caller := Caller{Platform: models.PlatformTelegram, UserID: "1", ChatID: "-100"}
+
+	resp, err := i.Run(caller, i.Builtin(), "https://news.test/a")
+	require.NoError(t, err)
+	assert.Equal(t, "summary", resp.Answer)
+	require.Len(t, runner.requests, 1)
+	assert.Equal(t, Request{
+		Platform:   models.PlatformTelegram,
+		UserID:     "1",
+		ServerID:   "-100",
+		ConfigType: models.ConfigTypeBuiltin,
+		Command:    BuiltinCommand,
+		Message:    "https://news.test/a",
+		URL:        "http://agent.test/v1",
+		Token:      "token",
+	}, runner.requests[0])
+
+	// The limits are checked before the agent is asked
+	_, err = i.Run(caller, i.Builtin(), "https://news.test/b")
+	var limit *LimitError
+	require.ErrorAs(t, err, &limit)
+	assert.Equal(t, limit.Decision.Message(), i.Failure(err))
+	assert.Len(t, runner.requests, 1)
+}
+
+func TestInvokerUnverified(t *testing.T) {
+	runner := &fakeRunner{answer: "answer"}
+	i := newInvoker(runner)
+
+	target := UserTarget(models.UserAgentConfig{ID: 7, Command: "ask", EndpointURL: "https://agent.test/v1", APIKey: "encrypted"})
+	_, err := i.Run(Caller{Platform: models.PlatformTelegram, UserID: "1", ChatID: "1"}, target, "hello")
+	assert.ErrorIs(t, err, ErrUnverified)
+	assert.Contains(t, i.Failure(err), "/reg")
+	assert.Empty(t, runner.requests)
+}
+
+func TestInvokerEmptyAnswer(t *testing.T) {
+	i := newInvoker(&fakeRunner{answer: " \n"})
+
+	_, err := i.Run(Caller{Platform: models.PlatformTelegram, UserID: "1", ChatID: "1"}, i.Builtin(), "hello")
+	var run *RunError
+	require.ErrorAs(t, err, &run)
+	assert.Equal(t, "Error executing command: empty response from the agent", i.Failure(err))
+}
@@ -0,0 +1,139 @@
+// Package agent checks the limits of agent invocations, runs them through the adapter and records their usage.
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"sum/pkg/adapter"
+	"sum/pkg/adapter/dify"
+	"sum/pkg/cache"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo/invocation"
+)
+
+// FreshFlag starts or ends a message whose answer may not come from the cache
+const FreshFlag = "--fresh"
+
+// CachedNote marks the replies answered from the cache
+const CachedNote = "⚡ Cached answer, add --fresh to the command for a new one."
+
+// IRunner defines the interface for running agent invocations
+type IRunner interface {
+	Run(req Request) (*dify.ChatResponse, error)
+}
+
+// Request describes a single agent invocation
+type Request struct {
+	Platform   models.PlatformType // Platform the invocation originates from
+	UserID     string              // Platform-specific user identifier
+	ServerID   string              // Platform-specific chat identifier
+	ConfigID   int64               // ID of the user or server config, 0 for built-in commands
+	ConfigType models.ConfigType   // Kind of config serving the invocation
+	Command    string              // Command name
+	Message    string              // Message sent to the agent
+	URL        string              // Agent endpoint URL
+	Token      string              // Decrypted agent API key
+	Inputs     map[string]any      // Inputs of the config sent with the message, nil for none
+	Fresh      bool                // Whether to run the agent even when an answer is cached, also set by FreshFlag
+}
+
+// runner implements IRunner
+type runner struct {
+	adapter     adapter.IAdapter
+	invocations invocation.IInvocation
+	cache       cache.ICache
+	logger      logger.Logger
+}
+
+// New creates a new runner. Usage is not recorded when invocations is nil, and answers
+// are not cached when cache is nil.
+func New(adapter adapter.IAdapter, invocations invocation.IInvocation, cache cache.ICache, logger logger.Logger) IRunner {
+	return &runner{
+		adapter:     adapter,
+		invocations: invocations,
+		cache:       cache,
+		logger:      logger,
+	}
+}
+
+// Run sends the request to the agent, unless an identical request was answered recently,
+// and records the invocation with its latency, usage and outcome
+func (r *runner) Run(req Request) (*dify.ChatResponse, error) {
+	if message, ok := stripFresh(req.Message); ok {
+		req.Message, req.Fresh = message, true
+	}
+
+	start := time.Now()
+	key := cache.Key(req.URL, req.Token, req.Message, req.Inputs)
+	if r.cache != nil && !req.Fresh {
+		answer, err := r.cache.Get(key)
+		if err != nil {
+			r.logger.Error(err, "Failed to read cached answer")
+		}
+		if answer != "" {
+			resp := &dify.ChatResponse{Answer: answer, Cached: true}
+			r.record(req, resp, time.Since(start), nil)
+			return resp, nil
+		}
+	}
+
+	resp, err := r.adapter.Dify().Chat(req.Message, req.URL, req.Token, req.Inputs)
+	latency := time.Since(start)
+
+	r.record(req, resp, latency, err)
+
+	if err == nil && r.cache != nil && resp.Answer != "" {
+		if err := r.cache.Set(key, resp.Answer); err != nil {
+			r.logger.Error(err, "Failed to cache answer")
+		}
+	}
+	return resp, err
+}
+
+// stripFresh removes FreshFlag from the start or the end of a message
+func stripFresh(message string) (string, bool) {
+	trimmed := strings.TrimSpace(message)
+	switch {
+	case trimmed == FreshFlag:
+		return "", true
+	case strings.HasPrefix(trimmed, FreshFlag+" "), strings.HasPrefix(trimmed, FreshFlag+"\n"):
+		return strings.TrimSpace(trimmed[len(FreshFlag):]), true
+	case strings.HasSuffix(trimmed, " "+FreshFlag), strings.HasSuffix(trimmed, "\n"+FreshFlag):
+		return strings.TrimSpace(trimmed[:len(trimmed)-len(FreshFlag)]), true
+	}
+	return message, false
+}
+
+func (r *runner) record(req Request, resp *dify.ChatResponse, latency time.Duration, chatErr error) {
+	if r.invocations == nil {
+		return
+	}
+
+	inv := models.Invocation{
+		Platform:   req.Platform,
+		UserID:     req.UserID,
+		ServerID:   req.ServerID,
+		ConfigID:   req.ConfigID,
+		ConfigType: req.ConfigType,
+		Command:    req.Command,
+		LatencyMS:  latency.Milliseconds(),
+		Success:    chatErr == nil,
+	}
+	if chatErr != nil {
+		inv.Error = chatErr.Error()
+	}
+	if resp != nil {
+		inv.Cached = resp.Cached
+		inv.PromptTokens = resp.Usage.PromptTokens
+		inv.CompletionTokens = resp.Usage.CompletionTokens
+		inv.TotalTokens = resp.Usage.TotalTokens
+		inv.Cost = resp.Usage.Cost()
+		inv.Currency = resp.Usage.Currency
+	}
+
+	if err := r.invocations.Create(inv); err != nil {
+		r.logger.Error(err, "Failed to record invocation")
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"testing"
+
+	"sum/pkg/adapter/article"
+	"sum/pkg/adapter/dify"
+	"sum/pkg/adapter/feed"
+	"sum/pkg/cache"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAdapter answers every chat from the message it was sent, and records the messages
+type fakeAdapter struct {
+	messages []string
+}
+
+func (a *fakeAdapter) Dify() dify.DifyAdapter          { return a }
+func (a *fakeAdapter) Feed() feed.FeedAdapter          { return nil }
+func (a *fakeAdapter) Article() article.ArticleAdapter { return nil }
+
+func (a *fakeAdapter) Chat(msg, url, token string, inputs map[string]any) (*dify.ChatResponse, error) {
+	a.messages = append(a.messages, msg)
+	return &dify.ChatResponse{Answer: "answer to " + msg}, nil
+}
+
+func (a *fakeAdapter) Probe(url, token string) (*dify.AppInfo, error) {
+	return &dify.AppInfo{}, nil
+}
+
+func TestRunCache(t *testing.T) {
+	adapter := &fakeAdapter{}
+	r := New(adapter, nil, cache.New(cache.NewMemoryStore(), config.CacheConfig{TTLMinutes: 10}), logger.NewLogrusLogger())
+	req := Request{URL: "https://agent.test/v1", Token: "token", Message: "https://news.test/a"}
+
+	resp, err := r.Run(req)
+	require.NoError(t, err)
+	assert.False(t, resp.Cached)
+
+	resp, err = r.Run(req)
+	require.NoError(t, err)
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "answer to https://news.test/a", resp.Answer)
+	assert.Len(t, adapter.messages, 1)
+
+	// Another app behind the same endpoint isn't answered from the cache
+	other := req
+	other.Token = "other"
+	resp, err = r.Run(other)
+	require.NoError(t, err)
+	assert.False(t, resp.Cached)
+	assert.Len(t, adapter.messages, 2)
+}
+
+func TestRunFresh(t *testing.T) {
+	adapter := &fakeAdapter{}
+	r := New(adapter, nil, cache.New(cache.NewMemoryStore(), config.CacheConfig{TTLMinutes: 10}), logger.NewLogrusLogger())
+	req := Request{URL: "https://agent.test/v1", Token: "token", Message: "https://news.test/a"}
+	_, err := r.Run(req)
+	require.NoError(t, err)
+
+	// The flag is dropped from the message sent, at either end of it
+	for _, message := range []string{"--fresh https://news.test/a", "https://news.test/a --fresh", "https://news.test/a\n--fresh"} {
+		t.Run(message, func(t *testing.T) {
+			fresh := req
+			fresh.Message = message
+			resp, err := r.Run(fresh)
+			require.NoError(t, err)
+			assert.False(t, resp.Cached)
+			assert.Equal(t, "https://news.test/a", adapter.messages[len(adapter.messages)-1])
+		})
+	}
+
+	fresh := req
+	fresh.Fresh = true
+	resp, err := r.Run(fresh)
+	require.NoError(t, err)
+	assert.False(t, resp.Cached)
+	assert.Len(t, adapter.messages, 5)
+
+	// A flag inside the message is part of it
+	inside := req
+	inside.Message = "what does --fresh mean"
+	_, err = r.Run(inside)
+	require.NoError(t, err)
+	assert.Equal(t, "what does --fresh mean", adapter.messages[len(adapter.messages)-1])
+
+	// Fresh answers are cached for the next invocations
+	resp, err = r.Run(req)
+	require.NoError(t, err)
+	assert.True(t, resp.Cached)
+	assert.Len(t, adapter.messages, 6)
+}
+
+func TestRunWithoutCache(t *testing.T) {
+	adapter := &fakeAdapter{}
+	r := New(adapter, nil, nil, logger.NewLogrusLogger())
+	req := Request{URL: "https://agent.test/v1", Token: "token", Message: "hello"}
+
+	for range 2 {
+		resp, err := r.Run(req)
+		require.NoError(t, err)
+		assert.False(t, resp.Cached)
+	}
+	assert.Len(t, adapter.messages, 2)
+}
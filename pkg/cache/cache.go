@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/models"
+)
+
+// trackingParams are the query parameters dropped from links, they don't change the page
+var trackingParams = []string{"utm_", "fbclid", "gclid", "mc_cid", "mc_eid"}
+
+// cache implements ICache with the answers kept in a store
+type cache struct {
+	store      IStore
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// New creates a new cache with the provided store, it returns nil when the TTL of cfg
+// disables the cache
+func New(store IStore, cfg config.CacheConfig) ICache {
+	return NewWithClock(store, cfg, time.Now)
+}
+
+// NewWithClock creates a new cache using a custom clock, mainly useful for tests
+func NewWithClock(store IStore, cfg config.CacheConfig, now func() time.Time) ICache {
+	if cfg.TTLMinutes <= 0 {
+		return nil
+	}
+	return &cache{
+		store:      store,
+		ttl:        time.Duration(cfg.TTLMinutes) * time.Minute,
+		maxEntries: cfg.MaxEntries,
+		now:        now,
+	}
+}
+
+func (c *cache) Get(key string) (string, error) {
+	entry, err := c.store.Get(key, c.now().UTC())
+	if err != nil || entry == nil {
+		return "", err
+	}
+	return entry.Answer, nil
+}
+
+func (c *cache) Set(key, answer string) error {
+	now := c.now().UTC()
+	if err := c.store.Save(models.CachedResponse{Key: key, Answer: answer, CreatedAt: now, ExpiresAt: now.Add(c.ttl)}); err != nil {
+		return err
+	}
+	return c.store.Prune(now, c.maxEntries)
+}
+
+// Key returns the cache key of an invocation. The API key is part of it since agents
+// of different apps share their endpoint, and the message is normalized so that spacing
+// and tracking parameters of links don't matter.
+func Key(endpoint, token, message string, inputs map[string]any) string {
+	encodedInputs, _ := json.Marshal(inputs) // Map keys are sorted
+	h := sha256.New()
+	for _, part := range []string{endpoint, token, Normalize(message), string(encodedInputs)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Normalize collapses the whitespace of a message and normalizes its links: the scheme
+// and host are lowercased, the fragment and tracking parameters dropped
+func Normalize(message string) string {
+	words := strings.Fields(message)
+	for i, word := range words {
+		u, err := url.Parse(word)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+
+		u.Host = strings.ToLower(u.Host)
+		u.Fragment = ""
+		if u.RawQuery != "" {
+			query := u.Query()
+			for name := range query {
+				if isTracking(name) {
+					query.Del(name)
+				}
+			}
+			u.RawQuery = query.Encode()
+		}
+		words[i] = u.String()
+	}
+	return strings.Join(words, " ")
+}
+
+func isTracking(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range trackingParams {
+		if strings.HasPrefix(name, param) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for the cache
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// stores creates the stores the cache tests run against
+var stores = map[string]func(t *testing.T) IStore{
+	"memory": func(t *testing.T) IStore { return NewMemoryStore() },
+	"sqlite": func(t *testing.T) IStore {
+		require.NoError(t, models.InitIDGenerators())
+		db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+		require.NoError(t, err)
+		_, err = database.Migrate(db)
+		require.NoError(t, err)
+		return NewPostgresStore(db)
+	},
+}
+
+// forEachStore runs test with a cache of cfg, over each store
+func forEachStore(t *testing.T, cfg config.CacheConfig, test func(t *testing.T, c ICache, clock *clock)) {
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			clock := &clock{now: time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)}
+			test(t, NewWithClock(store(t), cfg, clock.Now), clock)
+		})
+	}
+}
+
+// assertCached checks the answers cached under each key, an empty one for none
+func assertCached(t *testing.T, c ICache, answers map[string]string) {
+	t.Helper()
+	for key, want := range answers {
+		answer, err := c.Get(key)
+		require.NoError(t, err)
+		assert.Equal(t, want, answer, key)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	assert.Nil(t, New(NewMemoryStore(), config.CacheConfig{}))
+}
+
+func TestExpiry(t *testing.T) {
+	forEachStore(t, config.CacheConfig{TTLMinutes: 10}, func(t *testing.T, c ICache, clock *clock) {
+		require.NoError(t, c.Set("a", "first"))
+
+		clock.Advance(9 * time.Minute)
+		assertCached(t, c, map[string]string{"a": "first", "b": ""})
+
+		clock.Advance(time.Minute)
+		assertCached(t, c, map[string]string{"a": ""})
+	})
+}
+
+func TestOverwrite(t *testing.T) {
+	forEachStore(t, config.CacheConfig{TTLMinutes: 10}, func(t *testing.T, c ICache, clock *clock) {
+		require.NoError(t, c.Set("a", "first"))
+
+		// A new answer replaces the cached one and waits for its own TTL
+		clock.Advance(5 * time.Minute)
+		require.NoError(t, c.Set("a", "second"))
+		clock.Advance(9 * time.Minute)
+		assertCached(t, c, map[string]string{"a": "second"})
+
+		clock.Advance(time.Minute)
+		assertCached(t, c, map[string]string{"a": ""})
+	})
+}
+
+func TestMaxEntries(t *testing.T) {
+	forEachStore(t, config.CacheConfig{TTLMinutes: 60, MaxEntries: 2}, func(t *testing.T, c ICache, clock *clock) {
+		for _, key := range []string{"a", "b", "c"} {
+			require.NoError(t, c.Set(key, "answer "+key))
+			clock.Advance(time.Minute)
+		}
+		assertCached(t, c, map[string]string{"a": "", "b": "answer b", "c": "answer c"})
+
+		// An answer cached again counts as the newest
+		require.NoError(t, c.Set("b", "answer b"))
+		clock.Advance(time.Minute)
+		require.NoError(t, c.Set("d", "answer d"))
+		assertCached(t, c, map[string]string{"b": "answer b", "c": "", "d": "answer d"})
+	})
+}
+
+func TestKey(t *testing.T) {
+	key := Key("https://agent.test/v1", "token", "https://news.test/article?id=1", nil)
+
+	same := []string{
+		"  https://news.test/article?id=1 ",
+		"https://NEWS.test/article?id=1#comments",
+		"https://news.test/article?id=1&utm_source=feed&fbclid=x",
+	}
+	for _, message := range same {
+		assert.Equal(t, key, Key("https://agent.test/v1", "token", message, nil), message)
+	}
+
+	different := []string{
+		Key("https://agent.test/v1", "other", "https://news.test/article?id=1", nil),
+		Key("https://agent.test/v2", "token", "https://news.test/article?id=1", nil),
+		Key("https://agent.test/v1", "token", "https://news.test/article?id=2", nil),
+		Key("https://agent.test/v1", "token", "https://news.test/Article?id=1", nil),
+		Key("https://agent.test/v1", "token", "https://news.test/article?id=1", map[string]any{"lang": "en"}),
+	}
+	for _, other := range different {
+		assert.NotEqual(t, key, other)
+	}
+}
@@ -0,0 +1,25 @@
+// Package cache keeps the answers of agents, so identical invocations of the same agent
+// are answered without running it again.
+package cache
+
+import (
+	"time"
+
+	"sum/pkg/models"
+)
+
+// ICache defines the interface for looking up and storing the answers of agents
+type ICache interface {
+	// Get returns the answer cached under key, or an empty string when there is none
+	Get(key string) (string, error)
+	// Set caches the answer under key until the TTL of the cache passes
+	Set(key, answer string) error
+}
+
+// IStore defines the persistence of the cached answers
+type IStore interface {
+	Get(key string, now time.Time) (*models.CachedResponse, error) // Returns nil when there is no unexpired answer
+	Save(entry models.CachedResponse) error
+	// Prune drops the expired answers, then the oldest ones beyond maxEntries
+	Prune(now time.Time, maxEntries int) error
+}
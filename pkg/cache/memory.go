@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// memoryStore is an in-memory IStore, suitable for tests and deployments without a database.
+// Answers are listed from the oldest, since they share a TTL the oldest expire first.
+type memoryStore struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() IStore {
+	return &memoryStore{
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (s *memoryStore) Get(key string, now time.Time) (*models.CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := element.Value.(models.CachedResponse)
+	if !entry.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (s *memoryStore) Save(entry models.CachedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[entry.Key]; ok {
+		s.order.Remove(element)
+	}
+	s.entries[entry.Key] = s.order.PushBack(entry)
+	return nil
+}
+
+func (s *memoryStore) Prune(now time.Time, maxEntries int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for element := s.order.Front(); element != nil; element = s.order.Front() {
+		entry := element.Value.(models.CachedResponse)
+		if entry.ExpiresAt.After(now) && (maxEntries <= 0 || s.order.Len() <= maxEntries) {
+			break
+		}
+		s.order.Remove(element)
+		delete(s.entries, entry.Key)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postgresStore is an IStore backed by the cached_responses table
+type postgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a new database backed store, it also works on SQLite
+func NewPostgresStore(db *gorm.DB) IStore {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Get(key string, now time.Time) (*models.CachedResponse, error) {
+	var entry models.CachedResponse
+	err := s.db.Where("key = ? AND expires_at > ?", key, now).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *postgresStore) Save(entry models.CachedResponse) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"answer", "created_at", "expires_at"}),
+	}).Create(&entry).Error
+}
+
+func (s *postgresStore) Prune(now time.Time, maxEntries int) error {
+	if err := s.db.Delete(&models.CachedResponse{}, "expires_at <= ?", now).Error; err != nil {
+		return err
+	}
+	if maxEntries <= 0 {
+		return nil
+	}
+
+	// The answers cached at or before the first one beyond the bound are dropped
+	var cutoff []time.Time
+	err := s.db.Model(&models.CachedResponse{}).
+		Order("created_at DESC").
+		Offset(maxEntries).
+		Limit(1).
+		Pluck("created_at", &cutoff).Error
+	if err != nil || len(cutoff) == 0 {
+		return err
+	}
+	return s.db.Delete(&models.CachedResponse{}, "created_at <= ?", cutoff[0]).Error
+}
@@ -0,0 +1,48 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+)
+
+// parseAction validates the action argument of an /acl rule
+func parseAction(s string) (permission.Action, error) {
+	switch action := permission.Action(strings.ToLower(s)); action {
+	case permission.ActionUse, permission.ActionRegister, permission.ActionRemove, permission.ActionAll:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown action '%s', expected use, register, remove or *", s)
+}
+
+// parseSubject parses the subject argument of an /acl rule:
+// everyone, admins, user:<id|@username> or role:<name>
+func parseSubject(s string) (models.PermissionSubjectType, string, error) {
+	switch strings.ToLower(s) {
+	case string(models.SubjectEveryone):
+		return models.SubjectEveryone, "", nil
+	case string(models.SubjectAdmins):
+		return models.SubjectAdmins, "", nil
+	}
+
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok || value == "" {
+		return "", "", fmt.Errorf("unknown subject '%s', expected everyone, admins, user:<id|@username> or role:<name>", s)
+	}
+
+	switch models.PermissionSubjectType(strings.ToLower(kind)) {
+	case models.SubjectUser:
+		return models.SubjectUser, value, nil
+	case models.SubjectRole:
+		return models.SubjectRole, value, nil
+	}
+	return "", "", fmt.Errorf("unknown subject '%s', expected everyone, admins, user:<id|@username> or role:<name>", s)
+}
+
+func formatSubject(rule models.PermissionRule) string {
+	if rule.Subject == "" {
+		return string(rule.SubjectType)
+	}
+	return fmt.Sprintf("%s:%s", rule.SubjectType, rule.Subject)
+}
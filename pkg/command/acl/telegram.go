@@ -0,0 +1,187 @@
+package acl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+	"sum/pkg/repo/audit"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+const usageText = `Usage:
+/acl - List the permission rules of this group
+/acl allow <action> <command|*> <subject> - Add an allow rule
+/acl deny <action> <command|*> <subject> - Add a deny rule
+/acl rm <rule-id> - Remove a rule
+
+Actions: use, register, remove, *
+Subjects: everyone, admins, user:<id|@username>, role:<member|administrator|creator>`
+
+// Command describes /acl in help messages and command menus
+var Command = registry.Command{
+	Name:        "acl",
+	Usage:       "[allow|deny|rm ...]",
+	Description: "Manage who may use the group commands",
+	Details:     usageText,
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatGroup,
+	Access:      registry.AccessAdmin,
+}
+
+type Telegram struct {
+	repo   repo.Repository
+	logger logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Handle executes the /acl command. Only group admins may manage rules.
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+
+	if update.Message.Chat.Type == "private" {
+		t.sendMessage(ctx, b, update, "Permission rules apply to groups. Please use /acl in a group.")
+		return
+	}
+
+	if !permission.IsTelegramAdmin(ctx, b, update.Message.Chat.ID, update.Message.From) {
+		t.sendMessage(ctx, b, update, "You don't have permission to manage permission rules.")
+		return
+	}
+
+	server, err := t.repo.Server().GetByPlatformID(fmt.Sprintf("%d", update.Message.Chat.ID), string(models.PlatformTelegram))
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server")
+		t.sendMessage(ctx, b, update, "This group is not registered. Please use /reg server first.")
+		return
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	switch {
+	case len(parts) == 1:
+		t.listRules(ctx, b, update, server)
+	case (parts[1] == "allow" || parts[1] == "deny") && len(parts) == 5:
+		t.addRule(ctx, b, update, server, models.PermissionEffect(parts[1]), parts[2], parts[3], parts[4])
+	case parts[1] == "rm" && len(parts) == 3:
+		t.removeRule(ctx, b, update, server, parts[2])
+	default:
+		t.sendMessage(ctx, b, update, usageText)
+	}
+}
+
+func (t *Telegram) listRules(ctx context.Context, b *bot.Bot, update *telegramMod.Update, server models.Server) {
+	rules, err := t.repo.PermissionRule().ListByServerID(server.ID)
+	if err != nil {
+		t.logger.Error(err, "Failed to list permission rules")
+		t.sendMessage(ctx, b, update, "Failed to retrieve permission rules. Please try again.")
+		return
+	}
+
+	if len(rules) == 0 {
+		t.sendMessage(ctx, b, update, "No permission rules. Everyone may use commands, admins may register and remove them.\n\n"+usageText)
+		return
+	}
+
+	configs, err := t.repo.ServerConfig().ListByServerID(server.ID)
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server commands")
+		t.sendMessage(ctx, b, update, "Failed to retrieve server commands. Please try again.")
+		return
+	}
+	commands := make(map[int64]string, len(configs))
+	for _, config := range configs {
+		commands[config.ID] = config.Command
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔐 Permission rules\n\n")
+	for _, rule := range rules {
+		command := "*"
+		if rule.ConfigID != 0 {
+			command = commands[rule.ConfigID]
+		}
+		sb.WriteString(fmt.Sprintf("#%d: %s %s %s %s\n", rule.ID, rule.Effect, rule.Action, command, formatSubject(rule)))
+	}
+
+	t.sendMessage(ctx, b, update, sb.String())
+}
+
+func (t *Telegram) addRule(ctx context.Context, b *bot.Bot, update *telegramMod.Update, server models.Server, effect models.PermissionEffect, actionArg, command, subjectArg string) {
+	action, err := parseAction(actionArg)
+	if err != nil {
+		t.sendMessage(ctx, b, update, err.Error())
+		return
+	}
+
+	subjectType, subject, err := parseSubject(subjectArg)
+	if err != nil {
+		t.sendMessage(ctx, b, update, err.Error())
+		return
+	}
+
+	rule := models.PermissionRule{
+		ServerID:    server.ID,
+		Action:      string(action),
+		Effect:      effect,
+		SubjectType: subjectType,
+		Subject:     subject,
+		CreatedBy:   fmt.Sprintf("%d", update.Message.From.ID),
+	}
+
+	if command != "*" {
+		config, err := t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, command)
+		if err != nil {
+			t.sendMessage(ctx, b, update, fmt.Sprintf("Command '%s' not found.", command))
+			return
+		}
+		rule.ConfigID = config.ID
+	}
+
+	rule, err = t.repo.As(actor(update)).PermissionRule().Create(rule)
+	if err != nil {
+		t.logger.Error(err, "Failed to create permission rule")
+		t.sendMessage(ctx, b, update, "Failed to save permission rule. Please try again.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, fmt.Sprintf("Rule #%d added: %s %s %s %s", rule.ID, rule.Effect, rule.Action, command, formatSubject(rule)))
+}
+
+func (t *Telegram) removeRule(ctx context.Context, b *bot.Bot, update *telegramMod.Update, server models.Server, id string) {
+	if err := t.repo.As(actor(update)).PermissionRule().RemoveByID(server.ID, strings.TrimPrefix(id, "#")); err != nil {
+		t.logger.Error(err, "Failed to remove permission rule")
+		t.sendMessage(ctx, b, update, "Failed to remove permission rule. Please check the rule ID.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, "Permission rule removed.")
+}
+
+// actor returns the audit log actor of the sender of update
+func actor(update *telegramMod.Update) audit.Actor {
+	return audit.Actor{Platform: models.PlatformTelegram, UserID: fmt.Sprintf("%d", update.Message.From.ID)}
+}
+
+func (t Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
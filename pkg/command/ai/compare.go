@@ -0,0 +1,350 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/agent"
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// compareKeyword follows /ai to compare commands, configs can't take it as their name
+const compareKeyword = "compare"
+
+// Keywords are the words following /ai that aren't the names of commands
+var Keywords = []string{compareKeyword}
+
+// compareUsage explains /ai compare
+const compareUsage = `Usage: /ai compare <command>,<command>[,...] <message>
+/ai compare votes - Show the votes of the comparisons of this chat`
+
+// Bounds of the commands compared at once
+const (
+	minCompared = 2
+	maxCompared = 4
+)
+
+// votePrefix starts the data of the vote buttons, followed by the comparison ID and the index of the answer
+const votePrefix = "compare:"
+
+// comparedAnswer is the answer of one of the compared commands
+type comparedAnswer struct {
+	command string
+	answer  string
+	latency time.Duration
+	usage   dify.Usage
+	cached  bool
+	err     error
+}
+
+// compare sends an invocation to the agents of several configs at once. Each answer has
+// its own limits and failures.
+func (i invoker) compare(inv Invocation, targets []agent.Target) []comparedAnswer {
+	answers := make([]comparedAnswer, len(targets))
+
+	var wg sync.WaitGroup
+	for n, t := range targets {
+		wg.Add(1)
+		go func(n int, t agent.Target) {
+			defer wg.Done()
+			answers[n] = i.ask(inv, t)
+		}(n, t)
+	}
+	wg.Wait()
+
+	return answers
+}
+
+// ask sends an invocation to the agent of t and measures how long the answer took
+func (i invoker) ask(inv Invocation, t agent.Target) comparedAnswer {
+	answer := comparedAnswer{command: t.Command}
+
+	req, err := i.Request(inv.caller(), t, inv.Message)
+	if err != nil {
+		answer.err = err
+		return answer
+	}
+
+	start := time.Now()
+	resp, err := i.Send(req)
+	answer.latency = time.Since(start)
+	switch {
+	case err != nil:
+		answer.err = err
+	default:
+		answer.answer = strings.TrimSpace(resp.Answer)
+		answer.usage = resp.Usage
+		answer.cached = resp.Cached
+	}
+	return answer
+}
+
+// compare executes /ai compare <command>,<command> <message>, and /ai compare votes
+func (t *Telegram) compare(ctx context.Context, b *bot.Bot, message *telegramMod.Message, args []string) {
+	chatID := message.Chat.ID
+	if len(args) == 1 && args[0] == "votes" {
+		t.standings(ctx, b, message)
+		return
+	}
+	if len(args) < 2 {
+		t.send(ctx, b, chatID, compareUsage)
+		return
+	}
+
+	names := parseCompared(args[0])
+	if len(names) < minCompared || len(names) > maxCompared {
+		t.send(ctx, b, chatID, fmt.Sprintf("Please compare between %d and %d commands.\n\n%s", minCompared, maxCompared, compareUsage))
+		return
+	}
+	text := strings.Join(args[1:], " ")
+
+	var targets []agent.Target
+	seen := map[string]bool{}
+	for _, name := range names {
+		inv := t.invocation(message, name, text)
+		target, err := t.invoker.resolve(inv)
+		if errors.Is(err, ErrNotFound) {
+			t.send(ctx, b, chatID, fmt.Sprintf("Command '%s' not found. Try /ls or /ls server to check if the command is set up.", name))
+			return
+		}
+		if err != nil {
+			t.send(ctx, b, chatID, t.invoker.failure(err))
+			return
+		}
+
+		allowed, err := t.allowed(ctx, b, message, inv, target)
+		if err != nil {
+			t.logger.Error(err, "Failed to check permission")
+			t.send(ctx, b, chatID, "An error occurred. Please try again.")
+			return
+		}
+		if !allowed {
+			t.send(ctx, b, chatID, fmt.Sprintf("You don't have permission to use /%s.", name))
+			return
+		}
+
+		// Aliases of the same config are compared once
+		if seen[target.Command] {
+			continue
+		}
+		seen[target.Command] = true
+		targets = append(targets, target)
+	}
+	if len(targets) < minCompared {
+		t.send(ctx, b, chatID, "These names are aliases of the same command, please compare different commands.")
+		return
+	}
+
+	if _, err := b.SendChatAction(ctx, &bot.SendChatActionParams{ChatID: chatID, Action: telegramMod.ChatActionTyping}); err != nil {
+		t.logger.Error(err, "Failed to send chat action")
+	}
+
+	answers := t.invoker.compare(t.invocation(message, "", text), targets)
+
+	commands := make([]string, len(targets))
+	for n, target := range targets {
+		commands[n] = target.Command
+	}
+	params := &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   t.formatComparison(answers),
+		ReplyParameters: &telegramMod.ReplyParameters{
+			ChatID:    chatID,
+			MessageID: message.ID,
+		},
+	}
+
+	// The answers are posted without vote buttons when the comparison can't be stored
+	comparison, err := t.repo.Comparison().Create(models.Comparison{
+		Platform:  models.PlatformTelegram,
+		ChatID:    fmt.Sprintf("%d", chatID),
+		CreatedBy: fmt.Sprintf("%d", message.From.ID),
+		Commands:  strings.Join(commands, ","),
+		Prompt:    text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to create comparison")
+	} else {
+		params.ReplyMarkup = voteKeyboard(comparison, nil)
+	}
+
+	sent, err := b.SendMessage(ctx, params)
+	if err != nil {
+		t.logger.Error(err, "Failed to send comparison")
+		t.send(ctx, b, chatID, "An error occurred while sending the message. Please try again.")
+		return
+	}
+
+	// Votes are only counted from the message posting the answers
+	if params.ReplyMarkup != nil {
+		if err := t.repo.Comparison().SaveMessage(comparison.ID, strconv.Itoa(sent.ID)); err != nil {
+			t.logger.Error(err, "Failed to save comparison message")
+		}
+	}
+}
+
+// HandleVote records the answer of a comparison the user clicking its button found best,
+// and shows the votes on the buttons
+func (t *Telegram) HandleVote(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	query := update.CallbackQuery
+
+	id, n, ok := parseVote(query.Data)
+	if !ok {
+		t.answerQuery(ctx, b, query, "This vote is no longer available.")
+		return
+	}
+	comparison, err := t.repo.Comparison().Get(id)
+	if err != nil {
+		t.logger.Error(err, "Failed to get comparison")
+		t.answerQuery(ctx, b, query, "This vote is no longer available.")
+		return
+	}
+	message := query.Message.Message
+	commands := comparison.CommandList()
+	if !posted(comparison, message) || n >= len(commands) {
+		t.answerQuery(ctx, b, query, "This vote is no longer available.")
+		return
+	}
+
+	err = t.repo.Comparison().Vote(models.ComparisonVote{
+		ComparisonID: comparison.ID,
+		UserID:       fmt.Sprintf("%d", query.From.ID),
+		Command:      commands[n],
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to record vote")
+		t.answerQuery(ctx, b, query, "Failed to record your vote. Please try again.")
+		return
+	}
+	t.answerQuery(ctx, b, query, fmt.Sprintf("You voted for /%s.", commands[n]))
+
+	votes, err := t.repo.Comparison().CountVotes(comparison.ID)
+	if err != nil {
+		t.logger.Error(err, "Failed to count votes")
+		return
+	}
+	if _, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:      message.Chat.ID,
+		MessageID:   message.ID,
+		ReplyMarkup: voteKeyboard(comparison, votes),
+	}); err != nil {
+		t.logger.Error(err, "Failed to update vote buttons")
+	}
+}
+
+// posted reports whether message is the one posting the answers of comparison, votes
+// referencing a comparison from another message or chat aren't counted
+func posted(comparison models.Comparison, message *telegramMod.Message) bool {
+	return message != nil &&
+		comparison.Platform == models.PlatformTelegram &&
+		comparison.ChatID == strconv.FormatInt(message.Chat.ID, 10) &&
+		comparison.MessageID == strconv.Itoa(message.ID)
+}
+
+// standings shows the votes of the comparisons of the chat by command
+func (t *Telegram) standings(ctx context.Context, b *bot.Bot, message *telegramMod.Message) {
+	standings, err := t.repo.Comparison().Standings(models.PlatformTelegram, fmt.Sprintf("%d", message.Chat.ID))
+	if err != nil {
+		t.logger.Error(err, "Failed to get comparison votes")
+		t.send(ctx, b, message.Chat.ID, "Failed to retrieve the votes. Please try again.")
+		return
+	}
+	if len(standings) == 0 {
+		t.send(ctx, b, message.Chat.ID, "No votes yet.\n\n"+compareUsage)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🗳 Votes of the comparisons of this chat\n")
+	for _, s := range standings {
+		sb.WriteString(fmt.Sprintf("\n/%s: %d", s.Command, s.Votes))
+	}
+	t.send(ctx, b, message.Chat.ID, sb.String())
+}
+
+// formatComparison lists the answers one after the other, each headed by its command, latency
+// and token usage. The answers share the length of a message.
+func (t *Telegram) formatComparison(answers []comparedAnswer) string {
+	limit := (maxInlineMessageLength - 100*len(answers)) / len(answers)
+
+	var sections []string
+	for n, answer := range answers {
+		if answer.err != nil {
+			sections = append(sections, fmt.Sprintf("%d. /%s · failed\n%s", n+1, answer.command, t.invoker.failure(answer.err)))
+			continue
+		}
+
+		usage := fmt.Sprintf("%d tokens", answer.usage.TotalTokens)
+		if answer.cached {
+			usage = "⚡ cached"
+		}
+		sections = append(sections, fmt.Sprintf("%d. /%s · %.1fs · %s\n%s", n+1, answer.command, answer.latency.Seconds(), usage, truncateInline(answer.answer, limit)))
+	}
+	return fmt.Sprintf("⚖️ %d answers\n\n%s", len(answers), strings.Join(sections, "\n\n"))
+}
+
+// voteKeyboard returns a vote button per answer, with the votes counted so far
+func voteKeyboard(comparison models.Comparison, votes map[string]int) *telegramMod.InlineKeyboardMarkup {
+	var rows [][]telegramMod.InlineKeyboardButton
+	for n, command := range comparison.CommandList() {
+		label := fmt.Sprintf("👍 %d. /%s", n+1, command)
+		if votes[command] > 0 {
+			label += fmt.Sprintf(" (%d)", votes[command])
+		}
+		rows = append(rows, []telegramMod.InlineKeyboardButton{
+			{Text: label, CallbackData: fmt.Sprintf("%s%d:%d", votePrefix, comparison.ID, n)},
+		})
+	}
+	return &telegramMod.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// parseCompared splits the comma separated commands of /ai compare, without duplicates
+func parseCompared(list string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// parseVote returns the comparison ID and the index of the answer of a vote button
+func parseVote(data string) (int64, int, bool) {
+	id, index, ok := strings.Cut(strings.TrimPrefix(data, votePrefix), ":")
+	if !ok || !strings.HasPrefix(data, votePrefix) {
+		return 0, 0, false
+	}
+
+	comparisonID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 || n >= maxCompared {
+		return 0, 0, false
+	}
+	return comparisonID, n, true
+}
+
+func (t *Telegram) answerQuery(ctx context.Context, b *bot.Bot, query *telegramMod.CallbackQuery, text string) {
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            text,
+	}); err != nil {
+		t.logger.Error(err, "Failed to answer callback query")
+	}
+}
@@ -0,0 +1,24 @@
+package ai
+
+import (
+	"testing"
+
+	"sum/pkg/models"
+
+	telegramMod "github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPosted(t *testing.T) {
+	comparison := models.Comparison{ID: 1, Platform: models.PlatformTelegram, ChatID: "-100", MessageID: "42"}
+
+	assert.True(t, posted(comparison, &telegramMod.Message{ID: 42, Chat: telegramMod.Chat{ID: -100}}))
+
+	// Votes forged with the ID of a comparison of another chat or message, or of an
+	// unposted one, aren't counted
+	assert.False(t, posted(comparison, &telegramMod.Message{ID: 42, Chat: telegramMod.Chat{ID: -200}}))
+	assert.False(t, posted(comparison, &telegramMod.Message{ID: 43, Chat: telegramMod.Chat{ID: -100}}))
+	assert.False(t, posted(comparison, nil))
+	comparison.MessageID = ""
+	assert.False(t, posted(comparison, &telegramMod.Message{ID: 42, Chat: telegramMod.Chat{ID: -100}}))
+}
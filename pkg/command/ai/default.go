@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sum/pkg/command/registry"
+
+	"sum/pkg/models"
+	"sum/pkg/permission"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// DefaultCommand describes /default in help messages and command menus
+var DefaultCommand = registry.Command{
+	Name:        "default",
+	Usage:       "[command|off]",
+	Description: "Choose the agent answering messages without a command",
+	Details: `Usage: /default <command> or /default off
+Chooses the command answering private messages, or in a group the messages mentioning or replying to me. Without a default command the active configuration answers.
+Only the commands of a group can answer everyone in it, and choosing them needs the register permission of the group.`,
+	Platforms: []models.PlatformType{models.PlatformTelegram, models.PlatformDiscord},
+	Chats:     registry.ChatAll,
+	Action:    permission.ActionRegister,
+}
+
+// defaultOff is the argument of /default removing the default command
+const defaultOff = "off"
+
+// defaultUsage explains the /default command
+const defaultUsage = "Usage: /default <command> or /default off"
+
+// describeDefault describes the default command of a chat
+func (i invoker) describeDefault(inv Invocation) (string, error) {
+	setting, err := i.repo.ChatSetting().Get(inv.Platform, inv.ChatID)
+	if err != nil {
+		return "", err
+	}
+	if setting.DefaultCommand == "" {
+		return "No default command is set, the active configuration answers.", nil
+	}
+	return fmt.Sprintf("The default command is /%s.", setting.DefaultCommand), nil
+}
+
+// chosen confirms the default command of a chat
+func chosen(inv Invocation, command string) string {
+	switch {
+	case command == "":
+		return "✅ Default command removed, the active configuration answers."
+	case inv.Private:
+		return fmt.Sprintf("✅ /%s now answers your messages.", command)
+	}
+	return fmt.Sprintf("✅ /%s now answers the messages mentioning me.", command)
+}
+
+// HandleDefault executes /default [command|off], choosing the command answering private
+// messages, or the mentions of the bot in a group. Without a default command the active
+// configuration answers.
+func (t *Telegram) HandleDefault(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	chatID := update.Message.Chat.ID
+	inv := t.invocation(update.Message, "", "")
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) < 2 {
+		text, err := t.invoker.describeDefault(inv)
+		if err != nil {
+			t.logger.Error(err, "Failed to get chat settings")
+			t.send(ctx, b, chatID, "Failed to retrieve the default command. Please try again.")
+			return
+		}
+		t.send(ctx, b, chatID, text+"\n\n"+defaultUsage)
+		return
+	}
+
+	command := strings.TrimPrefix(parts[1], "/")
+	if command == defaultOff {
+		command = ""
+	}
+
+	command, err := t.invoker.setDefault(inv, command)
+	if err != nil {
+		t.send(ctx, b, chatID, t.invoker.failure(err))
+		return
+	}
+	t.send(ctx, b, chatID, chosen(inv, command))
+}
+
+// DefaultPermission returns the permission check of a /default message. Private chats
+// are personal and need no check.
+func DefaultPermission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.Message == nil || update.Message.Chat.Type == telegramMod.ChatTypePrivate {
+		return permission.Check{}, false
+	}
+	return permission.TelegramCheck(update, permission.ActionRegister), true
+}
+
+// DefaultInfo returns the /default slash command
+func (d *Discord) DefaultInfo() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "default",
+		Description: "Choose the command answering mentions and direct messages",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "command",
+				Description: "Name of the command, or off to answer with the active configuration",
+				Required:    false,
+			},
+		},
+	}
+}
+
+// HandleDefault executes /default on Discord, in a server or in direct messages
+func (d *Discord) HandleDefault(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name != "default" {
+		return
+	}
+
+	inv := Invocation{Platform: models.PlatformDiscord, ChatID: i.GuildID}
+	switch {
+	case i.Member != nil && i.Member.User != nil:
+		inv.UserID = i.Member.User.ID
+	case i.User != nil:
+		inv.UserID, inv.ChatID, inv.Private = i.User.ID, i.User.ID, true
+	default:
+		return
+	}
+
+	var command string
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "command" {
+			command = strings.TrimPrefix(strings.TrimSpace(option.StringValue()), "/")
+		}
+	}
+
+	if command == "" {
+		text, err := d.invoker.describeDefault(inv)
+		if err != nil {
+			d.logger.Error(err, "Failed to get chat settings")
+			text = "Failed to retrieve the default command. Please try again."
+		}
+		d.respond(s, i, text)
+		return
+	}
+	if command == defaultOff {
+		command = ""
+	}
+
+	check := permission.Check{Action: permission.ActionRegister, Platform: models.PlatformDiscord, ChatID: inv.ChatID}
+	if !inv.Private && !d.isAllowed(check, permission.DiscordSubject(i)) {
+		d.respond(s, i, "You don't have permission to choose the default command of this server.")
+		return
+	}
+
+	command, err := d.invoker.setDefault(inv, command)
+	if err != nil {
+		d.respond(s, i, d.invoker.failure(err))
+		return
+	}
+	d.respond(s, i, chosen(inv, command))
+}
+
+func (t *Telegram) send(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+	}); err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
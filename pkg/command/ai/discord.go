@@ -0,0 +1,289 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sum/pkg/agent"
+	"sum/pkg/command/registry"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+)
+
+// maxMessageLength is the length of the longest message Discord accepts
+const maxMessageLength = 2000
+
+// maxGuildCommands is the number of slash commands a guild can hold
+const maxGuildCommands = 100
+
+// maxDescriptionLength bounds the description of a slash command
+const maxDescriptionLength = 100
+
+// messageOption is the option of the agent slash commands holding the message
+const messageOption = "message"
+
+// slashCommandName matches the names Discord accepts for slash commands
+var slashCommandName = regexp.MustCompile(`^[-_\p{Ll}\p{Lo}\p{N}]{1,32}$`)
+
+// Discord answers agent commands on Discord: the commands of a server and their aliases
+// are its slash commands, direct messages and messages mentioning the bot are sent to
+// the default command of the chat
+type Discord struct {
+	repo    repo.Repository
+	guard   permission.IGuard
+	invoker invoker
+	logger  logger.Logger
+}
+
+func NewDiscord(repo repo.Repository, agents *agent.Invoker, guard permission.IGuard, commands registry.IRegistry, logger logger.Logger) *Discord {
+	return &Discord{
+		repo:    repo,
+		guard:   guard,
+		invoker: invoker{Invoker: agents, repo: repo, commands: commands, logger: logger},
+		logger:  logger,
+	}
+}
+
+// SyncGuild replaces the slash commands of a guild with the commands of its configs
+func (d *Discord) SyncGuild(s *discordgo.Session, guildID string) {
+	commands, err := d.guildCommands(guildID)
+	if err != nil {
+		d.logger.Error(err, "Failed to list guild commands")
+		return
+	}
+
+	if _, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, guildID, commands); err != nil {
+		d.logger.Errorf(err, "Failed to sync the commands of guild %s", guildID)
+	}
+}
+
+// HandleGuildCreate syncs the slash commands of a guild when it becomes available
+func (d *Discord) HandleGuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	d.SyncGuild(s, g.ID)
+}
+
+// guildCommands returns the slash commands of the configs of a guild. Names Discord
+// doesn't accept and names of built-in commands are left out.
+func (d *Discord) guildCommands(guildID string) ([]*discordgo.ApplicationCommand, error) {
+	// An empty list removes the commands of guilds that are no longer registered
+	commands := []*discordgo.ApplicationCommand{}
+
+	server, err := d.repo.Server().GetByPlatformID(guildID, string(models.PlatformDiscord))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return commands, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := d.repo.ServerConfig().ListByServerID(server.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, config := range configs {
+		description := config.Description
+		if description == "" {
+			description = fmt.Sprintf("Ask the %s agent", config.Command)
+		}
+		if runes := []rune(description); len(runes) > maxDescriptionLength {
+			description = string(runes[:maxDescriptionLength-1]) + "…"
+		}
+
+		for _, name := range append([]string{config.Command}, models.SplitAliases(config.Aliases)...) {
+			if !slashCommandName.MatchString(name) || d.invoker.commands.Reserved(name) || seen[name] || len(commands) == maxGuildCommands {
+				continue
+			}
+			seen[name] = true
+
+			commands = append(commands, &discordgo.ApplicationCommand{
+				Name:        name,
+				Description: description,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        messageOption,
+						Description: "Message sent to the agent",
+						Required:    true,
+					},
+				},
+			})
+		}
+	}
+	return commands, nil
+}
+
+// Handle executes the slash command of a server config
+func (d *Discord) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand || i.GuildID == "" || i.Member == nil {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	if d.invoker.commands.Reserved(data.Name) {
+		return
+	}
+
+	var message string
+	for _, option := range data.Options {
+		if option.Name == messageOption {
+			message = option.StringValue()
+		}
+	}
+
+	inv := Invocation{
+		Platform: models.PlatformDiscord,
+		UserID:   i.Member.User.ID,
+		ChatID:   i.GuildID,
+		Command:  data.Name,
+		Message:  message,
+	}
+	target, err := d.invoker.resolve(inv)
+	if err != nil {
+		d.respond(s, i, d.invoker.failure(err))
+		return
+	}
+
+	if !d.isAllowed(inv.check(target), permission.DiscordSubject(i)) {
+		d.respond(s, i, fmt.Sprintf("You don't have permission to %s this command.", permission.ActionUse))
+		return
+	}
+
+	// Agents may take longer to answer than Discord waits for a response
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to defer command response")
+		return
+	}
+
+	var content string
+	if response, err := d.invoker.run(inv, target); err != nil {
+		content = d.invoker.failure(err)
+	} else {
+		content = truncateMessage(response.Summary)
+	}
+
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content}); err != nil {
+		d.logger.Error(err, "Failed to edit command response")
+	}
+}
+
+// HandleMessage sends direct messages, and messages mentioning the bot in a server, to
+// the default command of the chat
+func (d *Discord) HandleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	private := m.GuildID == ""
+	text := m.Content
+	if !private {
+		if !mentions(m.Message, s.State.User.ID) {
+			return
+		}
+		text = strings.NewReplacer("<@"+s.State.User.ID+">", "", "<@!"+s.State.User.ID+">", "").Replace(text)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		d.reply(s, m, fmt.Sprintf("Mention me with a message, e.g. <@%s> hello", s.State.User.ID))
+		return
+	}
+
+	// Direct messages are identified by their author, like private chats on Telegram
+	inv := Invocation{
+		Platform: models.PlatformDiscord,
+		UserID:   m.Author.ID,
+		ChatID:   m.GuildID,
+		Private:  private,
+		Message:  text,
+	}
+	if private {
+		inv.ChatID = m.Author.ID
+	}
+
+	target, err := d.invoker.resolve(inv)
+	if err != nil {
+		d.reply(s, m, d.invoker.failure(err))
+		return
+	}
+
+	if !private && !d.isAllowed(inv.check(target), permission.DiscordMessageSubject(s, m)) {
+		d.reply(s, m, fmt.Sprintf("You don't have permission to %s this command.", permission.ActionUse))
+		return
+	}
+
+	if err := s.ChannelTyping(m.ChannelID); err != nil {
+		d.logger.Error(err, "Failed to send typing indicator")
+	}
+
+	response, err := d.invoker.run(inv, target)
+	if err != nil {
+		d.reply(s, m, d.invoker.failure(err))
+		return
+	}
+	d.reply(s, m, response.Summary)
+}
+
+// mentions reports whether message mentions the user
+func mentions(message *discordgo.Message, userID string) bool {
+	for _, user := range message.Mentions {
+		if user.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateMessage shortens text to the length of a Discord message
+func truncateMessage(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxMessageLength {
+		return text
+	}
+	return string(runes[:maxMessageLength-1]) + "…"
+}
+
+func (d *Discord) isAllowed(check permission.Check, subject permission.Subject) bool {
+	target, err := d.guard.Resolve(check)
+	if err != nil {
+		d.logger.Error(err, "Failed to resolve permission check")
+		return false
+	}
+
+	allowed, err := d.guard.Allowed(target, check.Action, subject)
+	if err != nil {
+		d.logger.Error(err, "Failed to evaluate permission rules")
+		return false
+	}
+	return allowed
+}
+
+func (d *Discord) reply(s *discordgo.Session, m *discordgo.MessageCreate, text string) {
+	if _, err := s.ChannelMessageSendReply(m.ChannelID, truncateMessage(text), m.Reference()); err != nil {
+		d.logger.Error(err, "Failed to send message")
+	}
+}
+
+func (d *Discord) respond(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to respond to interaction")
+	}
+}
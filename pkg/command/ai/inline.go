@@ -0,0 +1,351 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sum/pkg/agent"
+	"sum/pkg/command/registry"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// inlineDebounce is how long a query waits for the user to stop typing before the agent
+// is asked, Telegram sends a query for every change
+const inlineDebounce = 700 * time.Millisecond
+
+// inlineFinishTimeout bounds how long a chosen result waits for its answer
+const inlineFinishTimeout = 2 * time.Minute
+
+// maxInlineMessageLength is the length of the longest message Telegram accepts
+const maxInlineMessageLength = 4096
+
+// maxInlineDescriptionLength bounds the preview of an answer in the results
+const maxInlineDescriptionLength = 100
+
+// maxInlineAnswers bounds the answers kept, a burst of queries evicts the oldest ones
+const maxInlineAnswers = 1000
+
+// inlineHelpParameter is the /start parameter of the button shown with the results, it
+// opens the private chat on the help message
+const inlineHelpParameter = "help"
+
+// Inline answers inline queries, @bot <command> <message> typed in any chat, with the
+// commands of the caller. Answers slower than the deadline are offered as a placeholder
+// that is edited once the answer is ready, when the placeholder is chosen.
+type Inline struct {
+	invoker   invoker
+	deadline  time.Duration
+	cacheTime time.Duration
+	logger    logger.Logger
+
+	mu      sync.Mutex
+	latest  map[int64]string         // ID of the latest query of each user
+	answers map[string]*inlineAnswer // Answers by result ID, pending or cached, at most maxInlineAnswers
+}
+
+// inlineAnswer is the answer of an agent to a query, shared by the queries asking the same
+type inlineAnswer struct {
+	command string
+	started time.Time
+	done    chan struct{} // Closed once text is set
+	text    string
+	failed  bool      // Failures are answered once and not cached
+	expires time.Time // Zero until the answer is done
+}
+
+func NewInline(repo repo.Repository, config config.Config, agents *agent.Invoker, commands registry.IRegistry, logger logger.Logger) *Inline {
+	return &Inline{
+		invoker:   invoker{Invoker: agents, repo: repo, commands: commands, logger: logger},
+		deadline:  time.Duration(config.InlineDeadlineSeconds) * time.Second,
+		cacheTime: time.Duration(config.InlineCacheSeconds) * time.Second,
+		logger:    logger,
+		latest:    map[int64]string{},
+		answers:   map[string]*inlineAnswer{},
+	}
+}
+
+// Query reports whether update is an inline query
+func (i *Inline) Query(update *telegramMod.Update) bool {
+	return update.InlineQuery != nil && update.InlineQuery.From != nil
+}
+
+// Chosen reports whether update is an inline result chosen by a user
+func (i *Inline) Chosen(update *telegramMod.Update) bool {
+	return update.ChosenInlineResult != nil
+}
+
+// HandleQuery answers an inline query with the answer of the command of the caller, or
+// a placeholder when the agent takes longer than the deadline
+func (i *Inline) HandleQuery(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	query := update.InlineQuery
+
+	command, message := parseInlineQuery(query.Query)
+	if message == "" {
+		i.answerHelp(ctx, b, query, "Type a command and a message, e.g. translate hello")
+		return
+	}
+
+	if !i.wait(ctx, query) {
+		return
+	}
+
+	userID := strconv.FormatInt(query.From.ID, 10)
+	inv := Invocation{
+		Platform: models.PlatformTelegram,
+		UserID:   userID,
+		ChatID:   userID,
+		Private:  true, // Inline answers come from the commands of the caller
+		Command:  command,
+		Message:  message,
+	}
+
+	target, err := i.invoker.resolve(inv)
+	if err != nil {
+		i.answerHelp(ctx, b, query, i.invoker.failure(err))
+		return
+	}
+
+	id := inlineResultID(userID, target.Command, message)
+	answer := i.start(id, inv, target)
+
+	timer := time.NewTimer(i.deadline)
+	defer timer.Stop()
+
+	select {
+	case <-answer.done:
+		i.answerDone(ctx, b, query, id, answer)
+	case <-timer.C:
+		i.answerPending(ctx, b, query, id, answer)
+	case <-ctx.Done():
+	}
+}
+
+// HandleChosen edits a chosen placeholder with the answer once it is ready. Telegram
+// only reports chosen results when inline feedback is enabled for the bot.
+func (i *Inline) HandleChosen(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	result := update.ChosenInlineResult
+
+	// Only placeholders carry a keyboard, and so an inline message ID
+	if result.InlineMessageID == "" {
+		return
+	}
+
+	i.mu.Lock()
+	answer, ok := i.answers[result.ResultID]
+	i.mu.Unlock()
+
+	text := "⌛ The answer expired. Please ask again."
+	if ok {
+		timer := time.NewTimer(inlineFinishTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-answer.done:
+			text = answer.text
+		case <-timer.C:
+			text = fmt.Sprintf("⌛ /%s took too long to answer. Please ask again.", answer.command)
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if _, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		InlineMessageID: result.InlineMessageID,
+		Text:            truncateInline(text, maxInlineMessageLength),
+	}); err != nil {
+		i.logger.Error(err, "Failed to edit inline message")
+	}
+}
+
+// wait lets the user finish typing, it reports whether query is still the latest query of the user
+func (i *Inline) wait(ctx context.Context, query *telegramMod.InlineQuery) bool {
+	i.mu.Lock()
+	i.latest[query.From.ID] = query.ID
+	i.mu.Unlock()
+
+	timer := time.NewTimer(inlineDebounce)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.latest[query.From.ID] != query.ID {
+		return false
+	}
+	delete(i.latest, query.From.ID)
+	return true
+}
+
+// start returns the answer of id, asking the agent unless the answer is pending or cached
+func (i *Inline) start(id string, inv Invocation, t agent.Target) *inlineAnswer {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	for key, answer := range i.answers {
+		if !answer.expires.IsZero() && now.After(answer.expires) {
+			delete(i.answers, key)
+		}
+	}
+
+	if answer, ok := i.answers[id]; ok && !answer.failed {
+		return answer
+	}
+	if _, ok := i.answers[id]; !ok && len(i.answers) >= maxInlineAnswers {
+		i.evict()
+	}
+
+	answer := &inlineAnswer{command: t.Command, started: now, done: make(chan struct{})}
+	i.answers[id] = answer
+
+	// The answer outlives the query, a placeholder may be chosen before it is ready
+	go func() {
+		text, failed := i.run(inv, t)
+
+		i.mu.Lock()
+		answer.text, answer.failed = text, failed
+		answer.expires = time.Now().Add(i.cacheTime)
+		if failed {
+			// Placeholders waiting for the answer still get the failure
+			answer.expires = time.Now().Add(inlineFinishTimeout)
+		}
+		i.mu.Unlock()
+		close(answer.done)
+	}()
+	return answer
+}
+
+// evict drops the oldest answer that is done, or the oldest pending one when none is.
+// A placeholder chosen after its answer was evicted is told to ask again.
+func (i *Inline) evict() {
+	var (
+		oldest string
+		done   bool
+	)
+	for key, answer := range i.answers {
+		answerDone := !answer.expires.IsZero()
+		if oldest == "" || (answerDone && !done) ||
+			(answerDone == done && answer.started.Before(i.answers[oldest].started)) {
+			oldest, done = key, answerDone
+		}
+	}
+	delete(i.answers, oldest)
+}
+
+// run asks the agent, it returns the answer or the explanation of the failure
+func (i *Inline) run(inv Invocation, t agent.Target) (string, bool) {
+	response, err := i.invoker.run(inv, t)
+	if err != nil {
+		return i.invoker.failure(err), true
+	}
+	return response.Summary, false
+}
+
+// answerDone answers a query with the answer of the agent
+func (i *Inline) answerDone(ctx context.Context, b *bot.Bot, query *telegramMod.InlineQuery, id string, answer *inlineAnswer) {
+	if answer.failed {
+		i.answerHelp(ctx, b, query, answer.text)
+		return
+	}
+
+	i.answer(ctx, b, &bot.AnswerInlineQueryParams{
+		InlineQueryID: query.ID,
+		Results: []telegramMod.InlineQueryResult{
+			&telegramMod.InlineQueryResultArticle{
+				ID:          id,
+				Title:       "/" + answer.command,
+				Description: truncateInline(answer.text, maxInlineDescriptionLength),
+				InputMessageContent: &telegramMod.InputTextMessageContent{
+					MessageText: truncateInline(answer.text, maxInlineMessageLength),
+				},
+			},
+		},
+		CacheTime:  max(int(i.cacheTime.Seconds()), 1),
+		IsPersonal: true,
+	})
+}
+
+// answerPending answers a query with a placeholder, edited with the answer once it is chosen
+func (i *Inline) answerPending(ctx context.Context, b *bot.Bot, query *telegramMod.InlineQuery, id string, answer *inlineAnswer) {
+	i.answer(ctx, b, &bot.AnswerInlineQueryParams{
+		InlineQueryID: query.ID,
+		Results: []telegramMod.InlineQueryResult{
+			&telegramMod.InlineQueryResultArticle{
+				ID:          id,
+				Title:       "/" + answer.command,
+				Description: "⏳ Still answering, choose to send the answer once it's ready",
+				InputMessageContent: &telegramMod.InputTextMessageContent{
+					MessageText: fmt.Sprintf("⏳ /%s is answering…", answer.command),
+				},
+				// Telegram only reports the inline message ID of messages with a keyboard
+				ReplyMarkup: &telegramMod.InlineKeyboardMarkup{
+					InlineKeyboard: [][]telegramMod.InlineKeyboardButton{
+						{{Text: "🔁 Ask again", SwitchInlineQueryCurrentChat: query.Query}},
+					},
+				},
+			},
+		},
+		// The next query should find the answer rather than this placeholder
+		CacheTime:  1,
+		IsPersonal: true,
+	})
+}
+
+// answerHelp answers a query without results, text is shown on a button opening the help message
+func (i *Inline) answerHelp(ctx context.Context, b *bot.Bot, query *telegramMod.InlineQuery, text string) {
+	i.answer(ctx, b, &bot.AnswerInlineQueryParams{
+		InlineQueryID: query.ID,
+		Results:       []telegramMod.InlineQueryResult{},
+		CacheTime:     1,
+		IsPersonal:    true,
+		Button: &telegramMod.InlineQueryResultsButton{
+			Text:           truncateInline(text, maxInlineDescriptionLength),
+			StartParameter: inlineHelpParameter,
+		},
+	})
+}
+
+func (i *Inline) answer(ctx context.Context, b *bot.Bot, params *bot.AnswerInlineQueryParams) {
+	if _, err := b.AnswerInlineQuery(ctx, params); err != nil {
+		i.logger.Error(err, "Failed to answer inline query")
+	}
+}
+
+// parseInlineQuery splits an inline query into the command and the message
+func parseInlineQuery(query string) (command, message string) {
+	command, message, _ = strings.Cut(strings.TrimSpace(query), " ")
+	return strings.TrimPrefix(command, "/"), strings.TrimSpace(message)
+}
+
+// inlineResultID identifies the answer of a command to a message of a user, result IDs
+// are at most 64 bytes
+func inlineResultID(userID, command, message string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + command + "\x00" + message))
+	return hex.EncodeToString(sum[:16])
+}
+
+// truncateInline shortens text to limit characters
+func truncateInline(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
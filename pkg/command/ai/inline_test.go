@@ -0,0 +1,32 @@
+package ai
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineEvict(t *testing.T) {
+	start := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	i := &Inline{answers: map[string]*inlineAnswer{}}
+	for n := 0; n < 4; n++ {
+		answer := &inlineAnswer{started: start.Add(time.Duration(n) * time.Second)}
+		if n%2 == 1 {
+			answer.expires = start.Add(time.Hour)
+		}
+		i.answers[fmt.Sprint(n)] = answer
+	}
+
+	// Answers that are done go first, the oldest first
+	i.evict()
+	assert.NotContains(t, i.answers, "1")
+	i.evict()
+	assert.NotContains(t, i.answers, "3")
+
+	// Then the oldest pending ones
+	i.evict()
+	assert.NotContains(t, i.answers, "0")
+	assert.Contains(t, i.answers, "2")
+}
@@ -0,0 +1,174 @@
+package ai
+
+import (
+	"errors"
+
+	"sum/pkg/agent"
+	"sum/pkg/command/registry"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+
+	"gorm.io/gorm"
+)
+
+// Errors of invocations refused before the agent is called, the invoker of the agent
+// package refuses the others
+var (
+	ErrNotFound  = errors.New("command config not found")
+	ErrNoDefault = errors.New("no default command config")
+
+	// ErrPersonalCommand is returned when a command of a user is chosen to answer a group
+	ErrPersonalCommand = errors.New("personal command can't be the default command of a group")
+)
+
+// Invocation is a message sent to an agent command, by name, alias or as the default command of the chat
+type Invocation struct {
+	Platform models.PlatformType
+	UserID   string // Platform-specific user identifier of the caller
+	ChatID   string // Platform-specific chat identifier, the user ID in private chats
+	Private  bool   // Whether the message was sent in a private chat
+	Command  string // Name or alias of the command, empty for the default command of the chat
+	Message  string // Message sent to the agent
+}
+
+// caller returns who sends the invocation, and from which chat
+func (inv Invocation) caller() agent.Caller {
+	return agent.Caller{Platform: inv.Platform, UserID: inv.UserID, ChatID: inv.ChatID}
+}
+
+// check returns the permission check of the invocation of t, server configs are checked
+// against their rules and other commands against the rules of the chat
+func (inv Invocation) check(t agent.Target) permission.Check {
+	check := permission.Check{Action: permission.ActionUse, Platform: inv.Platform, ChatID: inv.ChatID}
+	if t.Server != nil {
+		check.ConfigID = t.Server.ID
+	}
+	return check
+}
+
+// invoker resolves invocations to configs and runs them with the shared agent invoker,
+// it is shared by the platforms
+type invoker struct {
+	*agent.Invoker
+	repo     repo.Repository
+	commands registry.IRegistry // Built-in commands, configs and pipelines can't take their names
+	logger   logger.Logger
+}
+
+// resolve finds the config answering an invocation, configs that didn't finish
+// registration are refused with ErrUnverified
+func (i invoker) resolve(inv Invocation) (agent.Target, error) {
+	t, err := i.lookup(inv)
+	if err == nil && t.VerifiedAt == nil {
+		return agent.Target{}, agent.ErrUnverified
+	}
+	return t, err
+}
+
+// lookup finds the config of an invocation. In private chats the commands of
+// the caller answer, in groups the commands of the group and then those of the caller.
+func (i invoker) lookup(inv Invocation) (agent.Target, error) {
+	if inv.Command == "" {
+		return i.resolveDefault(inv)
+	}
+
+	user, _ := i.repo.User().GetByPlatformID(inv.UserID, string(inv.Platform))
+	if !inv.Private {
+		server, _ := i.repo.Server().GetByPlatformID(inv.ChatID, string(inv.Platform))
+		config, err := i.repo.ServerConfig().GetByServerIDAndCommand(server.ID, inv.Command)
+		if err == nil {
+			return agent.ServerTarget(config), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return agent.Target{}, err
+		}
+	}
+
+	config, err := i.repo.UserConfig().GetByUserIDAndCommand(user.ID, inv.Command)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return agent.Target{}, ErrNotFound
+	}
+	if err != nil {
+		return agent.Target{}, err
+	}
+	return agent.UserTarget(config), nil
+}
+
+// resolveDefault finds the config answering messages without a command: the default
+// command chosen for the chat, otherwise the active config of the group or of the caller
+func (i invoker) resolveDefault(inv Invocation) (agent.Target, error) {
+	setting, err := i.repo.ChatSetting().Get(inv.Platform, inv.ChatID)
+	if err != nil {
+		return agent.Target{}, err
+	}
+	if setting.DefaultCommand != "" {
+		inv.Command = setting.DefaultCommand
+		t, err := i.lookup(inv)
+		if errors.Is(err, ErrNotFound) {
+			return agent.Target{}, ErrNoDefault
+		}
+		return t, err
+	}
+
+	if !inv.Private {
+		config, err := i.repo.ServerConfig().GetActiveByServerPlatformID(inv.ChatID, string(inv.Platform))
+		if err == nil {
+			return agent.ServerTarget(config), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return agent.Target{}, err
+		}
+	}
+
+	config, err := i.repo.UserConfig().GetActiveByUserPlatformID(inv.UserID, string(inv.Platform))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return agent.Target{}, ErrNoDefault
+	}
+	if err != nil {
+		return agent.Target{}, err
+	}
+	return agent.UserTarget(config), nil
+}
+
+// setDefault makes command the default command of the chat of inv, an empty command
+// removes it. It returns the name of the chosen config, command may be an alias.
+func (i invoker) setDefault(inv Invocation, command string) (string, error) {
+	if command != "" {
+		inv.Command = command
+		t, err := i.resolve(inv)
+		if err != nil {
+			return "", err
+		}
+
+		// Personal commands only answer their owner
+		if !inv.Private && t.Server == nil {
+			return "", ErrPersonalCommand
+		}
+		command = t.Command
+	}
+	return command, i.repo.ChatSetting().SaveDefaultCommand(inv.Platform, inv.ChatID, command)
+}
+
+// run checks the rate limits and quotas of an invocation and sends it to the agent of t
+func (i invoker) run(inv Invocation, t agent.Target) (*Sum, error) {
+	resp, err := i.Run(inv.caller(), t, inv.Message)
+	if err != nil {
+		return nil, err
+	}
+	return summary(resp, t.EndpointURL), nil
+}
+
+// failure returns the message explaining a failed invocation to the user, unexpected errors are logged
+func (i invoker) failure(err error) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "Command configuration not found. Try /ls or /ls server to check if the command is set up."
+	case errors.Is(err, ErrNoDefault):
+		return "No agent answers this chat yet. Register one with /reg, or choose the default command with /default."
+	case errors.Is(err, ErrPersonalCommand):
+		return "Only the commands of this group can answer everyone. Try /ls server to see them."
+	}
+	return i.Failure(err)
+}
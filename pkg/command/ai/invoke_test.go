@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"strconv"
+	"testing"
+
+	"sum/pkg/agent"
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+	"sum/pkg/repo/secret"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newInvoker returns an invoker over an in-memory SQLite repository
+func newInvoker(t *testing.T) invoker {
+	t.Helper()
+	require.NoError(t, models.InitIDGenerators())
+	db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+	require.NoError(t, err)
+	_, err = database.Migrate(db)
+	require.NoError(t, err)
+	secrets, err := secret.NewFromConfig(config.LoadTestConfig())
+	require.NoError(t, err)
+	r := repo.NewRepository(db, secrets)
+	return invoker{Invoker: agent.NewInvoker(r, nil, nil, config.Config{}, logger.NewLogrusLogger()), repo: r, logger: logger.NewLogrusLogger()}
+}
+
+func TestResolveUnverified(t *testing.T) {
+	i := newInvoker(t)
+	user, err := i.repo.User().Create(models.User{
+		UserID:           "1",
+		Platform:         models.PlatformTelegram,
+		UserAgentConfigs: []models.UserAgentConfig{{Command: "ask", EndpointURL: "https://agent.test/v1", APIKey: "key"}},
+	})
+	require.NoError(t, err)
+	id := strconv.FormatInt(user.UserAgentConfigs[0].ID, 10)
+	inv := Invocation{Platform: models.PlatformTelegram, UserID: "1", ChatID: "1", Private: true, Command: "ask"}
+
+	// A config that didn't pass the registration probe isn't sent anything, by name or as the default
+	_, err = i.resolve(inv)
+	assert.ErrorIs(t, err, agent.ErrUnverified)
+	assert.Contains(t, i.failure(err), "/reg")
+
+	require.NoError(t, i.repo.ChatSetting().SaveDefaultCommand(models.PlatformTelegram, "1", "ask"))
+	_, err = i.resolve(Invocation{Platform: models.PlatformTelegram, UserID: "1", ChatID: "1", Private: true})
+	assert.ErrorIs(t, err, agent.ErrUnverified)
+
+	require.NoError(t, i.repo.UserConfig().MarkVerified(id))
+	target, err := i.resolve(inv)
+	require.NoError(t, err)
+	assert.Equal(t, "ask", target.Command)
+}
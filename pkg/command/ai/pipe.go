@@ -0,0 +1,256 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"sum/pkg/command/edit"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// pipeUsage explains the /pipe command
+const pipeUsage = `Usage:
+/pipe - List your pipelines, and in a group those of the group
+/pipe save <name> <command> | <command> [key=value...] - Save a pipeline of yours
+/pipe save server <name> <command> | <command> [key=value...] - Save a pipeline of the group
+/pipe rm <name> - Remove a pipeline of yours
+/pipe rm server <name> - Remove a pipeline of the group`
+
+// PipeCommand describes /pipe in help messages and command menus
+var PipeCommand = registry.Command{
+	Name:        "pipe",
+	Usage:       "[save|rm ...]",
+	Description: "Save chains of agent commands",
+	Details: pipeUsage + `
+
+A pipeline sends the message to its first command, then the answer of each command to the next one, with the inputs given as key=value. Run it as /<name> <message> or /ai <name> <message>, or write it out: /ai summarize | translate lang=vi <message>.
+Pipelines of a group need the group to be registered with /reg server, saving and removing them needs the register and remove permissions of the group.`,
+	Platforms: []models.PlatformType{models.PlatformTelegram},
+	Chats:     registry.ChatAll,
+}
+
+// pipeServer is the argument of /pipe save and /pipe rm choosing the pipelines of the group
+const pipeServer = "server"
+
+// errNotRegistered is returned when the owner of a pipeline isn't registered
+var errNotRegistered = errors.New("owner not registered")
+
+// owner returns the user of inv, or the server of its chat, owning pipelines
+func (i invoker) owner(inv Invocation, server bool) (models.ConfigType, int64, error) {
+	if server {
+		s, err := i.repo.Server().GetByPlatformID(inv.ChatID, string(inv.Platform))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", 0, errNotRegistered
+		}
+		return models.ConfigTypeServer, s.ID, err
+	}
+
+	u, err := i.repo.User().GetByPlatformID(inv.UserID, string(inv.Platform))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, errNotRegistered
+	}
+	return models.ConfigTypeUser, u.ID, err
+}
+
+// savePipeline checks and stores a pipeline of the caller or of the group of inv, its
+// name can't be one of a command the caller could invoke
+func (i invoker) savePipeline(inv Invocation, server bool, name, expression string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if !edit.ValidCommandName(i.commands, name) {
+		return "", fmt.Errorf("'%s' can't name a pipeline, please choose a single word that isn't a built-in command", name)
+	}
+	inv.Command = name
+	if _, err := i.lookup(inv); err == nil {
+		return "", fmt.Errorf("/%s is already a command, please choose another name", name)
+	}
+
+	steps, text, err := parsePipeline(expression)
+	if err != nil {
+		return "", err
+	}
+	if text != "" {
+		return "", fmt.Errorf("'%s' isn't an input, inputs are written key=value", strings.Fields(text)[0])
+	}
+	for n, step := range steps {
+		if step.command == name {
+			return "", fmt.Errorf("step %d runs the pipeline itself", n+1)
+		}
+	}
+
+	ownerType, ownerID, err := i.owner(inv, server)
+	if err != nil {
+		return "", err
+	}
+	pipeline := models.Pipeline{
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+		Name:      name,
+		Steps:     formatSteps(steps),
+		CreatedBy: inv.UserID,
+	}
+	if err := i.repo.Pipeline().Save(pipeline); err != nil {
+		return "", fmt.Errorf("failed to save pipeline: %w", err)
+	}
+	return pipeline.Steps, nil
+}
+
+// HandlePipe executes /pipe, /pipe save [server] <name> <steps> and /pipe rm [server] <name>
+func (t *Telegram) HandlePipe(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	chatID := update.Message.Chat.ID
+	inv := t.invocation(update.Message, "", "")
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) < 2 || parts[1] == "ls" {
+		t.listPipelines(ctx, b, chatID, inv)
+		return
+	}
+
+	args := parts[2:]
+	server := len(args) > 0 && args[0] == pipeServer
+	if server {
+		if inv.Private {
+			t.send(ctx, b, chatID, "Pipelines of a group are saved in the group.")
+			return
+		}
+		args = args[1:]
+	}
+
+	switch {
+	case parts[1] == "save" && len(args) >= 2:
+		steps, err := t.invoker.savePipeline(inv, server, args[0], strings.Join(args[1:], " "))
+		if errors.Is(err, errNotRegistered) {
+			t.send(ctx, b, chatID, notRegistered(server))
+			return
+		}
+		if err != nil {
+			t.send(ctx, b, chatID, fmt.Sprintf("Failed to save the pipeline: %v.", err))
+			return
+		}
+		t.send(ctx, b, chatID, fmt.Sprintf("✅ Pipeline /%s saved: %s", strings.TrimPrefix(args[0], "/"), steps))
+	case parts[1] == "rm" && len(args) == 1:
+		name := strings.TrimPrefix(args[0], "/")
+		ownerType, ownerID, err := t.invoker.owner(inv, server)
+		if errors.Is(err, errNotRegistered) {
+			t.send(ctx, b, chatID, notRegistered(server))
+			return
+		}
+		if err == nil {
+			err = t.repo.Pipeline().Remove(ownerType, ownerID, name)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			t.send(ctx, b, chatID, fmt.Sprintf("Pipeline '%s' not found.", name))
+			return
+		}
+		if err != nil {
+			t.logger.Error(err, "Failed to remove pipeline")
+			t.send(ctx, b, chatID, "Failed to remove the pipeline. Please try again.")
+			return
+		}
+		t.send(ctx, b, chatID, fmt.Sprintf("✅ Pipeline /%s removed.", name))
+	default:
+		t.send(ctx, b, chatID, pipeUsage)
+	}
+}
+
+// listPipelines lists the pipelines of the caller, and in a group those of the group
+func (t *Telegram) listPipelines(ctx context.Context, b *bot.Bot, chatID int64, inv Invocation) {
+	var sections []string
+	owners := []bool{false}
+	if !inv.Private {
+		owners = []bool{true, false}
+	}
+	for _, server := range owners {
+		ownerType, ownerID, err := t.invoker.owner(inv, server)
+		if errors.Is(err, errNotRegistered) {
+			continue
+		}
+		var pipelines []models.Pipeline
+		if err == nil {
+			pipelines, err = t.repo.Pipeline().List(ownerType, ownerID)
+		}
+		if err != nil {
+			t.logger.Error(err, "Failed to list pipelines")
+			t.send(ctx, b, chatID, "Failed to retrieve the pipelines. Please try again.")
+			return
+		}
+		if len(pipelines) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		if server {
+			sb.WriteString("⛓ Pipelines of this group")
+		} else {
+			sb.WriteString("⛓ Your pipelines")
+		}
+		for _, pipeline := range pipelines {
+			sb.WriteString(fmt.Sprintf("\n/%s: %s", pipeline.Name, pipeline.Steps))
+		}
+		sections = append(sections, sb.String())
+	}
+
+	if len(sections) == 0 {
+		t.send(ctx, b, chatID, "No pipelines yet.\n\n"+pipeUsage)
+		return
+	}
+	t.send(ctx, b, chatID, strings.Join(sections, "\n\n"))
+}
+
+// runSaved runs the saved pipeline named by the command of inv with its message. It
+// returns false when there is no such pipeline.
+func (t *Telegram) runSaved(ctx context.Context, b *bot.Bot, message *telegramMod.Message, inv Invocation) bool {
+	pipeline, err := t.invoker.resolvePipeline(inv)
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		t.sendError(ctx, b, message.Chat.ID, t.invoker.failure(err))
+		return true
+	}
+
+	steps, _, err := parsePipeline(pipeline.Steps)
+	if err != nil {
+		t.logger.Errorf(err, "Failed to parse pipeline %d", pipeline.ID)
+		t.send(ctx, b, message.Chat.ID, fmt.Sprintf("The pipeline /%s is broken, please save it again with /pipe save.", pipeline.Name))
+		return true
+	}
+	t.pipe(ctx, b, message, steps, inv.Message)
+	return true
+}
+
+// notRegistered explains that pipelines need their owner to be registered
+func notRegistered(server bool) string {
+	if server {
+		return "This group is not registered. Please use /reg server first."
+	}
+	return "You have no commands yet. Please register one with /reg first."
+}
+
+// PipePermission returns the permission check of a /pipe message, saving and removing
+// the pipelines of a group need the register and remove permissions of the group.
+// Personal pipelines need no check.
+func PipePermission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.Message == nil || update.Message.Chat.Type == telegramMod.ChatTypePrivate {
+		return permission.Check{}, false
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) < 3 || parts[2] != pipeServer {
+		return permission.Check{}, false
+	}
+	switch parts[1] {
+	case "save":
+		return permission.TelegramCheck(update, permission.ActionRegister), true
+	case "rm":
+		return permission.TelegramCheck(update, permission.ActionRemove), true
+	}
+	return permission.Check{}, false
+}
@@ -0,0 +1,291 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/agent"
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// pipeSeparator separates the steps of a pipeline
+const pipeSeparator = "|"
+
+// maxSteps bounds the commands of a pipeline
+const maxSteps = 5
+
+// inputPattern matches the inputs a step overrides, as key=value
+var inputPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=\S*$`)
+
+// pipeStep is a command of a pipeline with the inputs it overrides
+type pipeStep struct {
+	command string
+	inputs  map[string]string
+}
+
+// String formats a step as it is written, e.g. translate lang=vi
+func (s pipeStep) String() string {
+	keys := make([]string, 0, len(s.inputs))
+	for key := range s.inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{s.command}
+	for _, key := range keys {
+		parts = append(parts, key+"="+s.inputs[key])
+	}
+	return strings.Join(parts, " ")
+}
+
+// parsePipeline splits a pipeline such as summarize | translate lang=vi <text> into its
+// steps and text. Each step is a command followed by the inputs it overrides, the first
+// other word starts the text, which runs to the end of the message and may contain |.
+func parsePipeline(expression string) ([]pipeStep, string, error) {
+	var steps []pipeStep
+	rest := expression
+	for {
+		segment, next, more := strings.Cut(rest, pipeSeparator)
+		words := strings.Fields(segment)
+		if len(words) == 0 {
+			return nil, "", fmt.Errorf("step %d has no command", len(steps)+1)
+		}
+
+		step := pipeStep{command: strings.TrimPrefix(words[0], "/")}
+		n := 1
+		for ; n < len(words) && inputPattern.MatchString(words[n]); n++ {
+			if step.inputs == nil {
+				step.inputs = map[string]string{}
+			}
+			key, value, _ := strings.Cut(words[n], "=")
+			step.inputs[key] = value
+		}
+		steps = append(steps, step)
+
+		if n < len(words) {
+			// The text starts in this segment, the pipes after it are part of it
+			text := strings.Join(words[n:], " ")
+			if more {
+				text += " " + pipeSeparator + next
+			}
+			return steps, strings.TrimSpace(text), checkSteps(steps)
+		}
+		if !more {
+			return steps, "", checkSteps(steps)
+		}
+		rest = next
+	}
+}
+
+func checkSteps(steps []pipeStep) error {
+	if len(steps) > maxSteps {
+		return fmt.Errorf("a pipeline has at most %d steps", maxSteps)
+	}
+	return nil
+}
+
+// formatSteps writes the steps of a pipeline as they are saved
+func formatSteps(steps []pipeStep) string {
+	parts := make([]string, len(steps))
+	for n, step := range steps {
+		parts[n] = step.String()
+	}
+	return strings.Join(parts, " "+pipeSeparator+" ")
+}
+
+// resolvePipeline finds a saved pipeline by name, in groups those of the group and then
+// those of the caller, in private chats those of the caller
+func (i invoker) resolvePipeline(inv Invocation) (models.Pipeline, error) {
+	if !inv.Private {
+		server, err := i.repo.Server().GetByPlatformID(inv.ChatID, string(inv.Platform))
+		if err == nil {
+			pipeline, err := i.repo.Pipeline().Get(models.ConfigTypeServer, server.ID, inv.Command)
+			if err == nil {
+				return pipeline, nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.Pipeline{}, err
+			}
+		}
+	}
+
+	user, err := i.repo.User().GetByPlatformID(inv.UserID, string(inv.Platform))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Pipeline{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Pipeline{}, err
+	}
+	pipeline, err := i.repo.Pipeline().Get(models.ConfigTypeUser, user.ID, inv.Command)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Pipeline{}, ErrNotFound
+	}
+	return pipeline, err
+}
+
+// step sends the output of the previous step to the agent of a step, with the inputs of
+// its config overridden by those of the step
+func (i invoker) step(inv Invocation, t agent.Target, inputs map[string]string) (*dify.ChatResponse, error) {
+	req, err := i.Request(inv.caller(), t, inv.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inputs) > 0 && req.Inputs == nil {
+		req.Inputs = map[string]any{}
+	}
+	for key, value := range inputs {
+		req.Inputs[key] = value
+	}
+
+	return i.Send(req)
+}
+
+// pipe runs the steps of a pipeline one after the other, each answering the answer of the
+// previous one. The progress of the steps is shown in a message edited as they finish.
+func (t *Telegram) pipe(ctx context.Context, b *bot.Bot, message *telegramMod.Message, steps []pipeStep, text string) {
+	chatID := message.Chat.ID
+	if text == "" && message.ReplyToMessage != nil {
+		text = message.ReplyToMessage.Text
+	}
+	if text == "" {
+		t.send(ctx, b, chatID, "Usage: /ai <command> | <command> [key=value...] <message>, or reply to a message")
+		return
+	}
+
+	// Every step is resolved and checked before the first one runs
+	targets := make([]agent.Target, len(steps))
+	for n, step := range steps {
+		inv := t.invocation(message, step.command, "")
+		target, err := t.invoker.resolve(inv)
+		if errors.Is(err, ErrNotFound) {
+			t.send(ctx, b, chatID, fmt.Sprintf("Step %d: command '%s' not found. Try /ls or /ls server to check if the command is set up.", n+1, step.command))
+			return
+		}
+		if err != nil {
+			t.send(ctx, b, chatID, fmt.Sprintf("Step %d: %s", n+1, t.invoker.failure(err)))
+			return
+		}
+
+		allowed, err := t.allowed(ctx, b, message, inv, target)
+		if err != nil {
+			t.logger.Error(err, "Failed to check permission")
+			t.send(ctx, b, chatID, "An error occurred. Please try again.")
+			return
+		}
+		if !allowed {
+			t.send(ctx, b, chatID, fmt.Sprintf("Step %d: you don't have permission to use /%s.", n+1, step.command))
+			return
+		}
+		targets[n] = target
+	}
+
+	progress := newPipeProgress(steps)
+	status, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   progress.String(),
+		ReplyParameters: &telegramMod.ReplyParameters{
+			ChatID:    chatID,
+			MessageID: message.ID,
+		},
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send pipeline progress")
+	}
+	update := func() {
+		if status == nil {
+			return
+		}
+		if _, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: status.ID,
+			Text:      progress.String(),
+		}); err != nil {
+			t.logger.Error(err, "Failed to update pipeline progress")
+		}
+	}
+
+	var resp *dify.ChatResponse
+	for n, step := range steps {
+		if _, err := b.SendChatAction(ctx, &bot.SendChatActionParams{ChatID: chatID, Action: telegramMod.ChatActionTyping}); err != nil {
+			t.logger.Error(err, "Failed to send chat action")
+		}
+
+		start := time.Now()
+		resp, err = t.invoker.step(t.invocation(message, step.command, text), targets[n], step.inputs)
+		if err != nil {
+			progress.fail(n, t.invoker.failure(err))
+			if status == nil {
+				t.send(ctx, b, chatID, progress.failure)
+				return
+			}
+			update()
+			return
+		}
+
+		progress.done(n, time.Since(start))
+		update()
+		text = strings.TrimSpace(resp.Answer)
+	}
+
+	answer := text
+	if resp.Cached {
+		answer += "\n\n" + agent.CachedNote
+	}
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      answer,
+		ParseMode: telegramMod.ParseModeMarkdownV1,
+	}); err != nil {
+		t.logger.Error(err, "Failed to send message")
+		t.sendError(ctx, b, chatID, "An error occurred while sending the message. Please try again.")
+	}
+}
+
+// pipeProgress is the state of the steps of a running pipeline
+type pipeProgress struct {
+	steps   []pipeStep
+	lines   []string
+	failure string // Why the pipeline stopped, empty while it runs
+}
+
+func newPipeProgress(steps []pipeStep) *pipeProgress {
+	p := &pipeProgress{steps: steps, lines: make([]string, len(steps))}
+	for n, step := range steps {
+		p.lines[n] = fmt.Sprintf("▫️ %d. /%s", n+1, step.command)
+	}
+	p.lines[0] = fmt.Sprintf("⏳ 1. /%s", steps[0].command)
+	return p
+}
+
+// done marks a step as answered and the next one as running
+func (p *pipeProgress) done(n int, latency time.Duration) {
+	p.lines[n] = fmt.Sprintf("✅ %d. /%s · %.1fs", n+1, p.steps[n].command, latency.Seconds())
+	if n+1 < len(p.steps) {
+		p.lines[n+1] = fmt.Sprintf("⏳ %d. /%s", n+2, p.steps[n+1].command)
+	}
+}
+
+// fail marks a step as failed and explains why, the steps after it don't run
+func (p *pipeProgress) fail(n int, reason string) {
+	p.lines[n] = fmt.Sprintf("❌ %d. /%s", n+1, p.steps[n].command)
+	p.failure = fmt.Sprintf("Step %d (/%s) failed: %s", n+1, p.steps[n].command, reason)
+}
+
+func (p *pipeProgress) String() string {
+	text := "⛓ Pipeline\n" + strings.Join(p.lines, "\n")
+	if p.failure != "" {
+		text += "\n\n" + p.failure
+	}
+	return text
+}
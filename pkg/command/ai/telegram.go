@@ -4,152 +4,296 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
-	"sum/pkg/adapter"
+	"sum/pkg/adapter/dify"
+	"sum/pkg/agent"
+	"sum/pkg/command/registry"
 	"sum/pkg/config"
 	"sum/pkg/logger"
 	"sum/pkg/models"
+	"sum/pkg/permission"
 	"sum/pkg/repo"
-	"sum/pkg/utils/encryptutils"
+	"sync"
 
 	"github.com/go-telegram/bot"
 	telegramMod "github.com/go-telegram/bot/models"
-	"gorm.io/gorm"
 )
 
+// Command describes /ai in help messages and command menus
+var Command = registry.Command{
+	Name:        "ai",
+	Usage:       "<command> <message>",
+	Description: "Ask an agent",
+	Details: `Usage: /ai <command> <message>
+Sends the message to the agent command, by name or alias. Agent commands can also be sent as /<command> <message>, and replying to a message sends its text.
+
+In a group, the commands of the group answer first, then your own. Private messages, and group messages mentioning or replying to me, go to the default command of the chat, see /help default.
+
+In any chat, type @<bot> <command> <message> and pick the answer of your command.
+
+/ai <command> | <command> [key=value...] <message> chains commands: each one answers the answer of the previous one, with the inputs given as key=value, e.g. /ai summarize | translate lang=vi <message>. A single command takes inputs the same way, e.g. /ai translate lang=vi <message>. Save pipelines with /pipe and run them like commands.
+
+/ai compare <command>,<command> <message> sends the message to 2 to 4 commands at once and shows their answers with their latency and tokens, vote for the best one with its button. /ai compare votes shows the votes of the chat.
+
+A message asked recently is answered from the cache, marked with ⚡. Add --fresh to the message for a new answer.`,
+	Platforms: []models.PlatformType{models.PlatformTelegram},
+	Chats:     registry.ChatAll,
+}
+
+// Telegram answers messages with agent commands: /ai <command> <message>, the
+// command as a native /<command> <message>, and, through the default command of the
+// chat, private messages and group messages mentioning or replying to the bot
 type Telegram struct {
 	repo    repo.Repository
 	logger  logger.Logger
-	adapter adapter.IAdapter
 	config  config.Config
+	guard   permission.IGuard
+	invoker invoker
+
+	mu       sync.RWMutex
+	username string // Username of the bot, empty until Identify succeeds
 }
 
-func NewTelegram(repo repo.Repository, config config.Config, adapter adapter.IAdapter, logger logger.Logger) *Telegram {
+func NewTelegram(repo repo.Repository, config config.Config, agents *agent.Invoker, guard permission.IGuard, commands registry.IRegistry, logger logger.Logger) *Telegram {
 	return &Telegram{
 		repo:    repo,
 		logger:  logger,
-		adapter: adapter,
 		config:  config,
+		guard:   guard,
+		invoker: invoker{Invoker: agents, repo: repo, commands: commands, logger: logger},
 	}
 }
 
+// Identify looks up the username of the bot, which group messages mention to address it
+func (t *Telegram) Identify(ctx context.Context, b *bot.Bot) {
+	me, err := b.GetMe(ctx)
+	if err != nil {
+		t.logger.Error(err, "Failed to get bot username, mentions won't be answered")
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.username = me.Username
+}
+
+func (t *Telegram) botUsername() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.username
+}
+
+// Handle executes /ai <command> <message>, the pipelines /ai <command> | <command> <message>
+// and /ai <pipeline> <message>, and /ai compare
 func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
 	parts := strings.Fields(update.Message.Text)
-	if len(parts) < 3 {
+	if len(parts) >= 2 && parts[1] == compareKeyword {
+		t.compare(ctx, b, update.Message, parts[2:])
+		return
+	}
+	if len(parts) < 2 {
+		t.sendError(ctx, b, update.Message.Chat.ID, "Usage: /ai <command> <message>")
 		return
 	}
 
-	subcommand := parts[1]
-	message := strings.Join(parts[2:], " ")
+	// A pipe right after the first command chains it, later pipes are part of the message
+	steps, text, err := parsePipeline(strings.Join(parts[1:], " "))
+	if err != nil {
+		t.send(ctx, b, update.Message.Chat.ID, fmt.Sprintf("Invalid pipeline: %v.\n\nUsage: /ai <command> | <command> [key=value...] <message>", err))
+		return
+	}
+	// A single command given inputs runs as a pipeline of one step, so they aren't sent as text
+	if len(steps) > 1 || len(steps[0].inputs) > 0 {
+		t.pipe(ctx, b, update.Message, steps, text)
+		return
+	}
 
-	userID := fmt.Sprintf("%d", update.Message.From.ID)
-	user, _ := t.repo.User().GetByPlatformID(userID, "telegram")
+	inv := t.invocation(update.Message, parts[1], strings.Join(parts[2:], " "))
+	target, err := t.invoker.resolve(inv)
+	if errors.Is(err, ErrNotFound) && t.runSaved(ctx, b, update.Message, inv) {
+		return
+	}
+	if err != nil {
+		t.sendError(ctx, b, update.Message.Chat.ID, t.invoker.failure(err))
+		return
+	}
+	if inv.Message == "" {
+		t.sendError(ctx, b, update.Message.Chat.ID, "Usage: /ai <command> <message>")
+		return
+	}
+	t.answer(ctx, b, update.Message, inv, target)
+}
 
-	chatID := fmt.Sprintf("%d", update.Message.Chat.ID)
-	server, _ := t.repo.Server().GetByPlatformID(chatID, "telegram")
+// Native reports whether update invokes an agent command by its name or an alias, as /<command>
+func (t *Telegram) Native(update *telegramMod.Update) bool {
+	if update.Message == nil || update.Message.From == nil {
+		return false
+	}
+	name, _, ok := t.parseCommand(update.Message.Text)
+	return ok && !t.invoker.commands.Reserved(name)
+}
 
-	var config interface{}
-	var err error
-	if update.Message.Chat.Type == "private" {
-		config, err = t.repo.UserConfig().GetByUserIDAndCommand(user.ID, subcommand)
-	} else {
-		config, err = t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, subcommand)
-		if err != nil {
-			config, err = t.repo.UserConfig().GetByUserIDAndCommand(user.ID, subcommand)
-		}
+// HandleNative executes /<command> <message>. Without a message, the message replied to is sent.
+func (t *Telegram) HandleNative(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	name, message, _ := t.parseCommand(update.Message.Text)
+	if message == "" && update.Message.ReplyToMessage != nil {
+		message = update.Message.ReplyToMessage.Text
 	}
 
+	inv := t.invocation(update.Message, name, message)
+	target, err := t.invoker.resolve(inv)
+	if errors.Is(err, ErrNotFound) && t.runSaved(ctx, b, update.Message, inv) {
+		return
+	}
+	if errors.Is(err, ErrNotFound) && !inv.Private {
+		// Other bots of the group may answer the command
+		return
+	}
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   escapeSpecialChars("Command configuration not found. Try /ls or /ls server to check if the command is set up."),
-			}); err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-		} else {
-			t.logger.Error(err, "Failed to retrieve command config")
-			if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   escapeSpecialChars("Failed to retrieve command configuration. Please try again."),
-			}); err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-		}
+		t.sendError(ctx, b, update.Message.Chat.ID, t.invoker.failure(err))
 		return
 	}
 
-	if config == nil {
-		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   escapeSpecialChars(fmt.Sprintf("Command '%s' not found.", subcommand)),
-		}); err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
+	if message == "" {
+		t.sendError(ctx, b, update.Message.Chat.ID, fmt.Sprintf("Usage: /%s <message>", name))
 		return
 	}
+	t.answer(ctx, b, update.Message, inv, target)
+}
 
-	// Execute the command
-	url := ""
-	encryptedToken := ""
-	switch c := config.(type) {
-	case models.UserAgentConfig:
-		url = c.EndpointURL
-		encryptedToken = c.APIKey
-	case models.ServerAdminConfig:
-		url = c.EndpointURL
-		encryptedToken = c.APIKey
+// Natural reports whether update is a message for the default command of the chat: any
+// private message that isn't a command, or a group message mentioning or replying to the bot
+func (t *Telegram) Natural(update *telegramMod.Update) bool {
+	message := update.Message
+	if message == nil || message.From == nil || message.From.IsBot || message.Text == "" || strings.HasPrefix(message.Text, "/") {
+		return false
+	}
+	if message.Chat.Type == telegramMod.ChatTypePrivate {
+		return true
 	}
 
-	// Decrypt the API key
-	encryptionKey, err := encryptutils.NewEncryptionKey(t.config.EncryptionKey)
+	username := t.botUsername()
+	if username == "" {
+		return false
+	}
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && strings.EqualFold(reply.From.Username, username) {
+		return true
+	}
+	return mentionPattern(username).MatchString(message.Text)
+}
+
+// HandleNatural sends a message addressed to the bot to the default command of the chat
+func (t *Telegram) HandleNatural(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	text := update.Message.Text
+	if username := t.botUsername(); username != "" {
+		text = strings.TrimSpace(mentionPattern(username).ReplaceAllString(text, ""))
+	}
+	if text == "" {
+		t.sendError(ctx, b, update.Message.Chat.ID, fmt.Sprintf("Mention me with a message, e.g. @%s hello", t.botUsername()))
+		return
+	}
+
+	inv := t.invocation(update.Message, "", text)
+	target, err := t.invoker.resolve(inv)
 	if err != nil {
-		t.logger.Error(err, "Failed to create encryption key")
-		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   escapeSpecialChars("An error occurred. Please try again."),
-		}); err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
+		t.sendError(ctx, b, update.Message.Chat.ID, t.invoker.failure(err))
 		return
 	}
+	t.answer(ctx, b, update.Message, inv, target)
+}
+
+// parseCommand splits a message invoking /<name>[@bot] [message]. It returns false when
+// the message isn't a command, or is addressed to another bot.
+func (t *Telegram) parseCommand(text string) (name, message string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false
+	}
+
+	command, message, _ := strings.Cut(text, " ")
+	name, addressee, addressed := strings.Cut(strings.TrimPrefix(command, "/"), "@")
+	if name == "" {
+		return "", "", false
+	}
+	if addressed && !strings.EqualFold(addressee, t.botUsername()) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(message), true
+}
+
+// mentionPattern matches the mentions of the bot in a message
+func mentionPattern(username string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(username) + `\b`)
+}
 
-	token, err := encryptutils.DecryptAPIKey(encryptionKey, encryptedToken)
+// invocation builds the invocation of command by the sender of message
+func (t *Telegram) invocation(message *telegramMod.Message, command, text string) Invocation {
+	return Invocation{
+		Platform: models.PlatformTelegram,
+		UserID:   fmt.Sprintf("%d", message.From.ID),
+		ChatID:   fmt.Sprintf("%d", message.Chat.ID),
+		Private:  message.Chat.Type == telegramMod.ChatTypePrivate,
+		Command:  command,
+		Message:  text,
+	}
+}
+
+// answer checks the permission of the sender, runs the invocation and sends the answer
+func (t *Telegram) answer(ctx context.Context, b *bot.Bot, message *telegramMod.Message, inv Invocation, target agent.Target) {
+	chatID := message.Chat.ID
+
+	allowed, err := t.allowed(ctx, b, message, inv, target)
 	if err != nil {
-		t.logger.Error(err, "Failed to decrypt API key")
-		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   escapeSpecialChars("An error occurred. Please try again."),
-		}); err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
+		t.logger.Error(err, "Failed to check permission")
+		t.sendError(ctx, b, chatID, "An error occurred. Please try again.")
+		return
+	}
+	if !allowed {
+		t.sendError(ctx, b, chatID, fmt.Sprintf("You don't have permission to %s this command.", permission.ActionUse))
 		return
 	}
 
-	response, err := chat(t.adapter, message, url, token)
+	if _, err := b.SendChatAction(ctx, &bot.SendChatActionParams{ChatID: chatID, Action: telegramMod.ChatActionTyping}); err != nil {
+		t.logger.Error(err, "Failed to send chat action")
+	}
+
+	response, err := t.invoker.run(inv, target)
 	if err != nil {
-		t.logger.Error(err, "Error executing command")
-		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   escapeSpecialChars(fmt.Sprintf("Error executing command: %v", err)),
-		}); err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
+		t.sendError(ctx, b, chatID, t.invoker.failure(err))
 		return
 	}
+
 	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:    update.Message.Chat.ID,
+		ChatID:    chatID,
 		Text:      response.Summary,
 		ParseMode: telegramMod.ParseModeMarkdownV1,
 	}); err != nil {
 		t.logger.Error(err, "Failed to send message")
 		// Attempt to send an error message to the user
-		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   escapeSpecialChars("An error occurred while sending the message. Please try again."),
-		}); err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
+		t.sendError(ctx, b, chatID, "An error occurred while sending the message. Please try again.")
+	}
+}
+
+// allowed reports whether the sender of message may use the command of target. Commands
+// used in private chats are personal and need no check.
+func (t *Telegram) allowed(ctx context.Context, b *bot.Bot, message *telegramMod.Message, inv Invocation, target agent.Target) (bool, error) {
+	if inv.Private {
+		return true, nil
+	}
+
+	allowed, err := permission.AllowedTelegram(ctx, b, t.guard, inv.check(target), message.From, message.Chat.ID)
+	if err == nil && !allowed {
+		t.logger.Warnf("Permission denied for user %d to %s", message.From.ID, permission.ActionUse)
+	}
+	return allowed, err
+}
+
+func (t *Telegram) sendError(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   escapeSpecialChars(text),
+	}); err != nil {
+		t.logger.Error(err, "Failed to send error message")
 	}
 }
 
@@ -160,28 +304,23 @@ type Sum struct {
 	Summary string
 }
 
-func chat(a adapter.IAdapter, msg, url, token string) (*Sum, error) {
-	data, err := a.Dify().Chat(msg, url, token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to summarize the article: %w", err)
-	}
-
-	if data == "" {
-		return nil, errors.New("empty response from LLM")
-	}
-
+// summary returns the answer of the agent at url, noting answers from the cache
+func summary(resp *dify.ChatResponse, url string) *Sum {
 	// Process the response
-	lines := strings.Split(data, "\n")
+	lines := strings.Split(resp.Answer, "\n")
 	for i, line := range lines {
 		lines[i] = strings.TrimSpace(line)
 	}
 
 	processedData := strings.Join(lines, "\n")
+	if resp.Cached {
+		processedData += "\n\n" + agent.CachedNote
+	}
 
 	return &Sum{
 		URL:     url,
 		Summary: processedData,
-	}, nil
+	}
 }
 
 func escapeSpecialChars(s string) string {
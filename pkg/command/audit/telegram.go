@@ -0,0 +1,282 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// pageSize is the number of events shown per page
+const pageSize = 10
+
+// maxValueLength bounds the length of the before and after values shown, to fit a page in a message
+const maxValueLength = 200
+
+// callbackPrefix is the prefix of the callback data of the audit log buttons, followed by <server-id>:<page>
+const callbackPrefix = "audit_page:"
+
+// Command describes /audit in help messages and command menus
+var Command = registry.Command{
+	Name:        "audit",
+	Usage:       "[page]",
+	Description: "Read the audit log of the group",
+	Details:     "Usage: /audit [page]\nIn a group, pages through the changes to its commands and permission rules. In a private chat, lists the groups you registered.\nOnly the owner of a group's registration may read its audit log.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatAll,
+	Access:      registry.AccessOwner,
+}
+
+type Telegram struct {
+	repo   repo.Repository
+	logger logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Handle executes the /audit [page] command. In a group it pages through the audit log
+// of the group, in a private chat it lists the groups of the caller. Only the owner of
+// a group, who registered it, may read its audit log.
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.CallbackQuery != nil {
+		t.handleCallbackQuery(ctx, b, update)
+		return
+	}
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+
+	chatID := update.Message.Chat.ID
+	if update.Message.Chat.Type == "private" {
+		t.listServers(ctx, b, update.Message.From.ID)
+		return
+	}
+
+	page := 0
+	if parts := strings.Fields(update.Message.Text); len(parts) > 1 {
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 1 {
+			t.send(ctx, b, chatID, "Usage: /audit [page]")
+			return
+		}
+		page = n - 1
+	}
+
+	server, err := t.repo.Server().GetByPlatformID(fmt.Sprintf("%d", chatID), string(models.PlatformTelegram))
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server")
+		t.send(ctx, b, chatID, "This group is not registered. Please use /reg server first.")
+		return
+	}
+
+	if !t.isOwner(update.Message.From.ID, server) {
+		t.send(ctx, b, chatID, "Only the owner of this group's registration may read its audit log.")
+		return
+	}
+
+	text, keyboard, err := t.renderPage(server, page)
+	if err != nil {
+		t.logger.Error(err, "Failed to list audit events")
+		t.send(ctx, b, chatID, "Failed to retrieve the audit log. Please try again.")
+		return
+	}
+
+	params := &bot.SendMessageParams{ChatID: chatID, Text: text}
+	if keyboard != nil {
+		params.ReplyMarkup = keyboard
+	}
+	if _, err := b.SendMessage(ctx, params); err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
+
+// listServers sends the groups owned by the caller, with buttons opening their audit log
+func (t *Telegram) listServers(ctx context.Context, b *bot.Bot, userID int64) {
+	user, err := t.repo.User().GetByPlatformID(fmt.Sprintf("%d", userID), string(models.PlatformTelegram))
+	if err != nil {
+		t.send(ctx, b, userID, "You don't have any groups registered. Please use /reg server in a group first.")
+		return
+	}
+
+	servers, err := t.repo.Server().ListByUserID(user.ID)
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve user servers")
+		t.send(ctx, b, userID, "Failed to retrieve your groups. Please try again.")
+		return
+	}
+
+	if len(servers) == 0 {
+		t.send(ctx, b, userID, "You don't have any groups registered. Please use /reg server in a group first.")
+		return
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, server := range servers {
+		callbackData := fmt.Sprintf("%s%d:0", callbackPrefix, server.ID)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(server.ServerName, callbackData)))
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        "Select a group to read its audit log:",
+		ReplyMarkup: tgbotapi.NewInlineKeyboardMarkup(keyboard...),
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
+
+// handleCallbackQuery shows another page of an audit log in place of the current one
+func (t *Telegram) handleCallbackQuery(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	query := update.CallbackQuery
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID}); err != nil {
+		t.logger.Error(err, "Failed to answer callback query")
+	}
+
+	serverArg, pageArg, ok := strings.Cut(strings.TrimPrefix(query.Data, callbackPrefix), ":")
+	if !ok {
+		return
+	}
+	serverID, err := strconv.ParseInt(serverArg, 10, 64)
+	if err != nil {
+		return
+	}
+	page, err := strconv.Atoi(pageArg)
+	if err != nil || page < 0 {
+		return
+	}
+
+	message := query.Message.Message
+	if message == nil {
+		return
+	}
+
+	server, err := t.repo.Server().GetByID(serverID)
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server")
+		return
+	}
+
+	// Anyone in a group can tap the buttons of a message sent there
+	if !t.isOwner(query.From.ID, server) {
+		t.send(ctx, b, message.Chat.ID, "Only the owner of this group's registration may read its audit log.")
+		return
+	}
+
+	text, keyboard, err := t.renderPage(server, page)
+	if err != nil {
+		t.logger.Error(err, "Failed to list audit events")
+		t.send(ctx, b, message.Chat.ID, "Failed to retrieve the audit log. Please try again.")
+		return
+	}
+
+	params := &bot.EditMessageTextParams{
+		ChatID:    message.Chat.ID,
+		MessageID: message.ID,
+		Text:      text,
+	}
+	if keyboard != nil {
+		params.ReplyMarkup = keyboard
+	}
+	if _, err := b.EditMessageText(ctx, params); err != nil {
+		t.logger.Error(err, "Failed to edit message")
+	}
+}
+
+// isOwner reports whether the Telegram user registered the server
+func (t *Telegram) isOwner(userID int64, server models.Server) bool {
+	user, err := t.repo.User().GetByPlatformID(fmt.Sprintf("%d", userID), string(models.PlatformTelegram))
+	if err != nil {
+		return false
+	}
+	return server.OwnerID == fmt.Sprintf("%d", user.ID)
+}
+
+// renderPage formats a page of the audit log of a server, newest events first, with
+// buttons to the previous and next pages. The keyboard is nil when there is a single page.
+func (t *Telegram) renderPage(server models.Server, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	total, err := t.repo.Audit().CountByServerID(server.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return fmt.Sprintf("📜 Audit log of %s\n\nNo changes recorded yet.", server.ServerName), nil, nil
+	}
+
+	pages := int((total + pageSize - 1) / pageSize)
+	page = min(page, pages-1)
+
+	events, err := t.repo.Audit().ListByServerID(server.ID, pageSize, page*pageSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📜 Audit log of %s, page %d of %d\n", server.ServerName, page+1, pages))
+	for _, event := range events {
+		sb.WriteString("\n" + formatEvent(event))
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️ Newer", fmt.Sprintf("%s%d:%d", callbackPrefix, server.ID, page-1)))
+	}
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Older ▶️", fmt.Sprintf("%s%d:%d", callbackPrefix, server.ID, page+1)))
+	}
+	if len(row) == 0 {
+		return sb.String(), nil, nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return sb.String(), &keyboard, nil
+}
+
+// formatEvent formats an audit event on a few lines
+func formatEvent(event models.AuditEvent) string {
+	actor := event.ActorID
+	if event.Platform != "" {
+		actor = fmt.Sprintf("%s:%s", event.Platform, event.ActorID)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🕒 %s · %s · %s #%d by %s\n", event.CreatedAt.UTC().Format("2006-01-02 15:04"), event.Action, event.EntityType, event.EntityID, actor))
+	if event.Before != "" {
+		sb.WriteString("  before: " + truncate(event.Before) + "\n")
+	}
+	if event.After != "" {
+		sb.WriteString("  after: " + truncate(event.After) + "\n")
+	}
+	return sb.String()
+}
+
+// truncate shortens a recorded value to maxValueLength runes
+func truncate(value string) string {
+	runes := []rune(value)
+	if len(runes) <= maxValueLength {
+		return value
+	}
+	return string(runes[:maxValueLength]) + "…"
+}
+
+func (t *Telegram) send(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
@@ -0,0 +1,340 @@
+// Package autosum summarizes the links posted in the servers that opted in, without /sum.
+package autosum
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"sum/pkg/adapter/article"
+	"sum/pkg/agent"
+	"sum/pkg/command/registry"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+
+	"gorm.io/gorm"
+)
+
+const usageText = `Usage:
+/autosum - Show how links posted here are summarized
+/autosum on - Answer links with their summary
+/autosum button - Answer links with a button summarizing them
+/autosum off - Stop summarizing links
+/autosum allow <domain>... - Only summarize links to these domains and their subdomains, - for every domain
+/autosum deny <domain>... - Never summarize links to these domains, - for none
+/autosum min <characters> - Skip pages with less text, 0 for no minimum`
+
+// Command describes /autosum in help messages and command menus
+var Command = registry.Command{
+	Name:        "autosum",
+	Usage:       "[on|button|off|allow|deny|min ...]",
+	Description: "Summarize the links posted in the group",
+	Details:     usageText + "\n\nThe group needs to be registered with /reg server, and changing the setting needs the register permission of the group. A link is summarized once a day per chat, and at most 3 links per message. On Telegram the bot only sees the links when its privacy mode is off or it is an admin of the group.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram, models.PlatformDiscord},
+	Chats:       registry.ChatGroup,
+	Action:      permission.ActionRegister,
+}
+
+// maxLinks bounds the links of a message summarized
+const maxLinks = 3
+
+// maxDomains bounds the domains of an allow or deny list
+const maxDomains = 50
+
+// maxMinLength bounds the minimum length of an article
+const maxMinLength = 100000
+
+// maxTitleLength bounds the title of a page heading its summary
+const maxTitleLength = 200
+
+// maxSummaryLength bounds the summaries posted, they are meant to be read in passing
+const maxSummaryLength = 1000
+
+// dedupeWindow is how long a link summarized in a chat isn't summarized again
+const dedupeWindow = 24 * time.Hour
+
+// buttonPrefix starts the data of the summarize buttons, followed by the index of the link
+const buttonPrefix = "autosum:"
+
+// clearList is the argument emptying an allow or deny list
+const clearList = "-"
+
+// errTooShort is returned for pages with less text than the minimum of the server
+var errTooShort = errors.New("the page is too short to summarize")
+
+// summarizer summarizes links with the built-in /sum agent, on behalf of the members
+// posting or clicking them
+type summarizer struct {
+	repo     repo.Repository
+	articles article.ArticleAdapter
+	invoker  *agent.Invoker
+	now      func() time.Time
+	logger   logger.Logger
+}
+
+func newSummarizer(repo repo.Repository, articles article.ArticleAdapter, invoker *agent.Invoker, logger logger.Logger) summarizer {
+	return summarizer{
+		repo:     repo,
+		articles: articles,
+		invoker:  invoker,
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+// setting returns the auto summary setting of a registered server, ok is false when its
+// links aren't summarized
+func (s summarizer) setting(platform models.PlatformType, chatID string) (models.AutoSummary, bool) {
+	server, err := s.repo.Server().GetByPlatformID(chatID, string(platform))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Error(err, "Failed to retrieve server")
+		}
+		return models.AutoSummary{}, false
+	}
+
+	setting, err := s.repo.AutoSummary().Get(server.ID)
+	if err != nil {
+		s.logger.Error(err, "Failed to get auto summary setting")
+		return models.AutoSummary{}, false
+	}
+	return setting, setting.Mode == models.AutoSummaryAuto || setting.Mode == models.AutoSummaryButton
+}
+
+// claim returns the indexes of the links to summarize: those the setting allows and not
+// summarized in the chat recently. They are recorded as summarized right away, so a link
+// posted twice at once is summarized once.
+func (s summarizer) claim(platform models.PlatformType, chatID string, setting models.AutoSummary, links []string) []int {
+	var claimed []int
+	for i, link := range links {
+		u, err := url.Parse(link)
+		if err != nil || !setting.Allows(u.Hostname()) {
+			continue
+		}
+
+		ok, err := s.repo.AutoSummary().Claim(platform, chatID, link, dedupeWindow, s.now())
+		if err != nil {
+			s.logger.Error(err, "Failed to record summarized link")
+			continue
+		}
+		if ok {
+			claimed = append(claimed, i)
+		}
+	}
+	return claimed
+}
+
+// summarize fetches a link, checks it has the minimum length of text, and returns a
+// compact summary of it
+func (s summarizer) summarize(platform models.PlatformType, userID, chatID, link string, minLength int) (string, error) {
+	page, err := s.articles.Fetch(link)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the page: %w", err)
+	}
+	if len([]rune(page.Text)) < minLength {
+		return "", errTooShort
+	}
+
+	resp, err := s.invoker.Run(agent.Caller{Platform: platform, UserID: userID, ChatID: chatID}, s.invoker.Builtin(), link)
+	if err != nil {
+		return "", err
+	}
+
+	title := page.Title
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength-1]) + "…"
+	}
+	if title == "" {
+		if u, err := url.Parse(link); err == nil {
+			title = u.Hostname()
+		}
+	}
+	summary := fmt.Sprintf("🔗 %s\n\n%s", title, compact(resp.Answer))
+	if resp.Cached {
+		summary += "\n\n⚡ Cached"
+	}
+	return summary, nil
+}
+
+// failure explains why a link clicked wasn't summarized
+func (s summarizer) failure(err error) string {
+	if errors.Is(err, errTooShort) {
+		return "The page is too short to summarize."
+	}
+	return s.invoker.Failure(err)
+}
+
+// collect normalizes the links of a message, drops duplicates and keeps the first few.
+// The index of a link in the result identifies its button.
+func collect(raw []string) []string {
+	var links []string
+	seen := map[string]bool{}
+	for _, link := range raw {
+		u, err := url.Parse(strings.TrimSpace(link))
+		if err != nil || u.Host == "" {
+			// Telegram detects links without a scheme
+			if u, err = url.Parse("https://" + strings.TrimSpace(link)); err != nil || u.Host == "" {
+				continue
+			}
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		u.Fragment = ""
+
+		normalized := u.String()
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		links = append(links, normalized)
+		if len(links) == maxLinks {
+			break
+		}
+	}
+	return links
+}
+
+// buttonLabel labels the summarize button of a link, naming its host when a message has several
+func buttonLabel(links []string, i int) string {
+	if len(links) == 1 {
+		return "🔽 summarize"
+	}
+	if u, err := url.Parse(links[i]); err == nil {
+		return "🔽 summarize " + u.Hostname()
+	}
+	return "🔽 summarize"
+}
+
+// offerText names the hosts of the links offered with buttons
+func offerText(links []string, claimed []int) string {
+	var hosts []string
+	for _, i := range claimed {
+		if u, err := url.Parse(links[i]); err == nil {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return "🔗 " + strings.Join(hosts, ", ")
+}
+
+// parseButton returns the index of the link of a summarize button
+func parseButton(data string) (int, bool) {
+	i, err := strconv.Atoi(strings.TrimPrefix(data, buttonPrefix))
+	return i, err == nil && strings.HasPrefix(data, buttonPrefix) && i >= 0 && i < maxLinks
+}
+
+// compact shortens a summary at a word boundary
+func compact(summary string) string {
+	summary = strings.TrimSpace(summary)
+	runes := []rune(summary)
+	if len(runes) <= maxSummaryLength {
+		return summary
+	}
+
+	cut := string(runes[:maxSummaryLength-1])
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n.,;:") + "…"
+}
+
+// describe describes how the links of a server are summarized
+func describe(setting models.AutoSummary) string {
+	var sb strings.Builder
+	switch setting.Mode {
+	case models.AutoSummaryAuto:
+		sb.WriteString("🔗 Links posted here are answered with their summary.")
+	case models.AutoSummaryButton:
+		sb.WriteString("🔗 Links posted here are answered with a button summarizing them.")
+	default:
+		return "🔗 Links posted here are not summarized."
+	}
+
+	if setting.AllowDomains != "" {
+		sb.WriteString("\nAllowed domains: " + setting.AllowDomains)
+	}
+	if setting.DenyDomains != "" {
+		sb.WriteString("\nDenied domains: " + setting.DenyDomains)
+	}
+	if setting.MinLength > 0 {
+		sb.WriteString(fmt.Sprintf("\nPages with less than %d characters of text are skipped.", setting.MinLength))
+	}
+	return sb.String()
+}
+
+// apply changes a setting with the arguments of /autosum, e.g. allow example.com
+func apply(setting *models.AutoSummary, key string, args []string) error {
+	switch key {
+	case "on", "auto":
+		setting.Mode = models.AutoSummaryAuto
+	case "button":
+		setting.Mode = models.AutoSummaryButton
+	case "off":
+		setting.Mode = models.AutoSummaryOff
+	case "allow", "deny":
+		domains, err := parseDomains(args)
+		if err != nil {
+			return err
+		}
+		if key == "allow" {
+			setting.AllowDomains = domains
+		} else {
+			setting.DenyDomains = domains
+		}
+	case "min":
+		if len(args) != 1 {
+			return errors.New("please give the minimum number of characters")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 || n > maxMinLength {
+			return fmt.Errorf("please choose a number between 0 and %d", maxMinLength)
+		}
+		setting.MinLength = n
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+	return nil
+}
+
+// parseDomains joins the domains of an allow or deny list, - empties the list
+func parseDomains(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("please give one or more domains, or - to clear the list")
+	}
+	if len(args) == 1 && args[0] == clearList {
+		return "", nil
+	}
+
+	var domains []string
+	for _, arg := range args {
+		for _, domain := range strings.Split(arg, ",") {
+			domain = strings.ToLower(strings.TrimSpace(domain))
+			if strings.Contains(domain, "://") {
+				if u, err := url.Parse(domain); err == nil {
+					domain = u.Hostname()
+				}
+			}
+			domain = strings.Trim(strings.TrimPrefix(domain, "*."), ".")
+			if domain == "" {
+				continue
+			}
+			if strings.ContainsAny(domain, "/:@ ") {
+				return "", fmt.Errorf("invalid domain '%s'", domain)
+			}
+			domains = append(domains, domain)
+		}
+	}
+
+	if len(domains) == 0 {
+		return "", errors.New("please give one or more domains, or - to clear the list")
+	}
+	if len(domains) > maxDomains {
+		return "", fmt.Errorf("please give at most %d domains", maxDomains)
+	}
+	return strings.Join(domains, ","), nil
+}
@@ -0,0 +1,319 @@
+package autosum
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sum/pkg/adapter/article"
+	"sum/pkg/agent"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// linkPattern matches the links of a Discord message, the trailing punctuation of a sentence excluded
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+[^\s<>"'.,;:!?)\]]`)
+
+type Discord struct {
+	repo       repo.Repository
+	guard      permission.IGuard
+	summarizer summarizer
+	logger     logger.Logger
+}
+
+func NewDiscord(repo repo.Repository, articles article.ArticleAdapter, invoker *agent.Invoker, guard permission.IGuard, logger logger.Logger) *Discord {
+	return &Discord{
+		repo:       repo,
+		guard:      guard,
+		summarizer: newSummarizer(repo, articles, invoker, logger),
+		logger:     logger,
+	}
+}
+
+// Info returns the /autosum slash command
+func (d *Discord) Info() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "autosum",
+		Description: "Show or change how the links posted in this server are summarized",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "mode",
+				Description: "Answer links with their summary, with a summarize button, or not at all",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "on", Value: "on"},
+					{Name: "button", Value: "button"},
+					{Name: "off", Value: "off"},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "allow",
+				Description: "Only summarize links to these domains, - for every domain",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "deny",
+				Description: "Never summarize links to these domains, - for none",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "min",
+				Description: "Skip pages with less characters of text, 0 for no minimum",
+			},
+		},
+	}
+}
+
+// Handle executes /autosum in a registered server, without options it shows the setting
+func (d *Discord) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name != "autosum" {
+		return
+	}
+
+	if i.Member == nil {
+		d.respond(s, i, "Links are summarized in servers. Please use /autosum in a server.")
+		return
+	}
+
+	server, err := d.repo.Server().GetByPlatformID(i.GuildID, string(models.PlatformDiscord))
+	if err != nil {
+		d.logger.Error(err, "Failed to retrieve server")
+		d.respond(s, i, "This server is not registered. Please use /reg server first.")
+		return
+	}
+
+	setting, err := d.repo.AutoSummary().Get(server.ID)
+	if err != nil {
+		d.logger.Error(err, "Failed to get auto summary setting")
+		d.respond(s, i, "Failed to retrieve the setting. Please try again.")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		d.respond(s, i, describe(setting))
+		return
+	}
+
+	if !d.isAllowed(i, permission.ActionRegister) {
+		d.respond(s, i, "You don't have permission to change how links are summarized in this server.")
+		return
+	}
+
+	for _, option := range options {
+		var key string
+		var args []string
+		switch option.Name {
+		case "mode":
+			key = option.StringValue()
+		case "min":
+			key, args = "min", []string{fmt.Sprintf("%d", option.IntValue())}
+		default:
+			key, args = option.Name, strings.Fields(option.StringValue())
+		}
+
+		if err := apply(&setting, key, args); err != nil {
+			d.respond(s, i, fmt.Sprintf("Error: %v", err))
+			return
+		}
+	}
+
+	if err := d.repo.AutoSummary().Save(setting); err != nil {
+		d.logger.Error(err, "Failed to save auto summary setting")
+		d.respond(s, i, "Failed to save the setting. Please try again.")
+		return
+	}
+	d.respond(s, i, "✅ "+describe(setting))
+}
+
+// HandleMessage answers the links of a server message with their summary or with summarize
+// buttons. Messages mentioning the bot are answered by their default command instead.
+func (d *Discord) HandleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot || m.GuildID == "" {
+		return
+	}
+	for _, user := range m.Mentions {
+		if user.ID == s.State.User.ID {
+			return
+		}
+	}
+
+	links := collect(linkPattern.FindAllString(m.Content, -1))
+	if len(links) == 0 {
+		return
+	}
+
+	setting, ok := d.summarizer.setting(models.PlatformDiscord, m.GuildID)
+	if !ok {
+		return
+	}
+
+	claimed := d.summarizer.claim(models.PlatformDiscord, m.GuildID, setting, links)
+	if len(claimed) == 0 {
+		return
+	}
+
+	if setting.Mode == models.AutoSummaryButton {
+		var buttons []discordgo.MessageComponent
+		for _, i := range claimed {
+			buttons = append(buttons, discordgo.Button{
+				Label:    buttonLabel(links, i),
+				Style:    discordgo.SecondaryButton,
+				CustomID: fmt.Sprintf("%s%d", buttonPrefix, i),
+			})
+		}
+		d.reply(s, m.Message, &discordgo.MessageSend{
+			Content:    offerText(links, claimed),
+			Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}},
+		})
+		return
+	}
+
+	for _, i := range claimed {
+		// Automatic summaries stay quiet when they fail, nobody asked for them
+		summary, err := d.summarizer.summarize(models.PlatformDiscord, m.Author.ID, m.GuildID, links[i], setting.MinLength)
+		if err != nil {
+			d.logger.Warnf("Skipped summary of %s: %v", links[i], err)
+			continue
+		}
+		d.reply(s, m.Message, &discordgo.MessageSend{Content: summary})
+	}
+}
+
+// HandleButton summarizes the link of a summarize button, on behalf of the member clicking it.
+// The link is read from the message the buttons answer, and its button is removed.
+func (d *Discord) HandleButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent || i.Member == nil || i.Member.User == nil {
+		return
+	}
+	data := i.MessageComponentData()
+	if !strings.HasPrefix(data.CustomID, buttonPrefix) {
+		return
+	}
+
+	ref := i.Message.MessageReference
+	if ref == nil {
+		d.respond(s, i, "The message with the link is no longer available.")
+		return
+	}
+	original, err := s.ChannelMessage(ref.ChannelID, ref.MessageID)
+	if err != nil {
+		d.respond(s, i, "The message with the link is no longer available.")
+		return
+	}
+
+	links := collect(linkPattern.FindAllString(original.Content, -1))
+	index, ok := parseButton(data.CustomID)
+	if !ok || index >= len(links) {
+		d.respond(s, i, "The link is no longer available.")
+		return
+	}
+
+	setting, ok := d.summarizer.setting(models.PlatformDiscord, i.GuildID)
+	if !ok {
+		d.respond(s, i, "Links are no longer summarized here.")
+		return
+	}
+
+	// Summaries take longer than Discord waits for a response
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to defer button response")
+		return
+	}
+	d.removeButton(s, i.Message, data.CustomID)
+
+	summary, err := d.summarizer.summarize(models.PlatformDiscord, i.Member.User.ID, i.GuildID, links[index], setting.MinLength)
+	if err != nil {
+		d.logger.Warnf("Failed to summarize %s: %v", links[index], err)
+		summary = d.summarizer.failure(err)
+	}
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &summary}); err != nil {
+		d.logger.Error(err, "Failed to edit button response")
+	}
+}
+
+// removeButton removes a clicked button, and the message once it has none left
+func (d *Discord) removeButton(s *discordgo.Session, message *discordgo.Message, customID string) {
+	var rows []discordgo.MessageComponent
+	for _, component := range message.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+
+		var kept []discordgo.MessageComponent
+		for _, c := range row.Components {
+			if button, ok := c.(*discordgo.Button); ok && button.CustomID != customID {
+				kept = append(kept, button)
+			}
+		}
+		if len(kept) > 0 {
+			rows = append(rows, discordgo.ActionsRow{Components: kept})
+		}
+	}
+
+	if len(rows) == 0 {
+		if err := s.ChannelMessageDelete(message.ChannelID, message.ID); err != nil {
+			d.logger.Error(err, "Failed to delete summarize buttons")
+		}
+		return
+	}
+
+	if _, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         message.ID,
+		Channel:    message.ChannelID,
+		Components: &rows,
+	}); err != nil {
+		d.logger.Error(err, "Failed to remove summarize button")
+	}
+}
+
+func (d *Discord) isAllowed(i *discordgo.InteractionCreate, action permission.Action) bool {
+	target, err := d.guard.Resolve(permission.Check{
+		Action:   action,
+		Platform: models.PlatformDiscord,
+		ChatID:   i.GuildID,
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to resolve permission check")
+		return false
+	}
+
+	allowed, err := d.guard.Allowed(target, action, permission.DiscordSubject(i))
+	if err != nil {
+		d.logger.Error(err, "Failed to evaluate permission rules")
+		return false
+	}
+	return allowed
+}
+
+// reply answers a message quietly: without pinging its author and without link previews
+func (d *Discord) reply(s *discordgo.Session, m *discordgo.Message, message *discordgo.MessageSend) {
+	message.Reference = m.Reference()
+	message.AllowedMentions = &discordgo.MessageAllowedMentions{}
+	message.Flags = discordgo.MessageFlagsSuppressEmbeds
+	if _, err := s.ChannelMessageSendComplex(m.ChannelID, message); err != nil {
+		d.logger.Error(err, "Failed to send message")
+	}
+}
+
+func (d *Discord) respond(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to respond to interaction")
+	}
+}
@@ -0,0 +1,282 @@
+package autosum
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"sum/pkg/adapter/article"
+	"sum/pkg/agent"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+type Telegram struct {
+	repo       repo.Repository
+	summarizer summarizer
+	logger     logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, articles article.ArticleAdapter, invoker *agent.Invoker, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:       repo,
+		summarizer: newSummarizer(repo, articles, invoker, logger),
+		logger:     logger,
+	}
+}
+
+// Handle executes the /autosum command, showing or changing how the links posted in a
+// registered group are summarized
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+
+	if update.Message.Chat.Type == telegramMod.ChatTypePrivate {
+		t.sendMessage(ctx, b, update, "Links are summarized in groups. Please use /autosum in a group, or /sum here.")
+		return
+	}
+
+	server, err := t.repo.Server().GetByPlatformID(fmt.Sprintf("%d", update.Message.Chat.ID), string(models.PlatformTelegram))
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server")
+		t.sendMessage(ctx, b, update, "This group is not registered. Please use /reg server first.")
+		return
+	}
+
+	setting, err := t.repo.AutoSummary().Get(server.ID)
+	if err != nil {
+		t.logger.Error(err, "Failed to get auto summary setting")
+		t.sendMessage(ctx, b, update, "Failed to retrieve the setting. Please try again.")
+		return
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) == 1 {
+		t.sendMessage(ctx, b, update, describe(setting)+"\n\n"+usageText)
+		return
+	}
+
+	if err := apply(&setting, strings.ToLower(parts[1]), parts[2:]); err != nil {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("Error: %v\n\n%s", err, usageText))
+		return
+	}
+	if err := t.repo.AutoSummary().Save(setting); err != nil {
+		t.logger.Error(err, "Failed to save auto summary setting")
+		t.sendMessage(ctx, b, update, "Failed to save the setting. Please try again.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, "✅ "+describe(setting))
+}
+
+// Permission returns the permission check of an /autosum update, showing the setting isn't checked
+func Permission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.Message == nil || update.Message.Chat.Type == telegramMod.ChatTypePrivate || len(strings.Fields(update.Message.Text)) < 2 {
+		return permission.Check{}, false
+	}
+	return permission.TelegramCheck(update, permission.ActionRegister), true
+}
+
+// Links matches the group messages with links, other than commands and the messages of bots.
+// The setting of the group is read by HandleLinks, most groups don't summarize links.
+func (t *Telegram) Links(update *telegramMod.Update) bool {
+	message := update.Message
+	if message == nil || message.From == nil || message.From.IsBot || strings.HasPrefix(message.Text, "/") {
+		return false
+	}
+	if message.Chat.Type != telegramMod.ChatTypeGroup && message.Chat.Type != telegramMod.ChatTypeSupergroup {
+		return false
+	}
+	return len(telegramLinks(message)) > 0
+}
+
+// HandleLinks answers the links of a message with their summary or with summarize buttons
+func (t *Telegram) HandleLinks(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	message := update.Message
+	chatID := fmt.Sprintf("%d", message.Chat.ID)
+
+	setting, ok := t.summarizer.setting(models.PlatformTelegram, chatID)
+	if !ok {
+		return
+	}
+
+	links := collect(telegramLinks(message))
+	claimed := t.summarizer.claim(models.PlatformTelegram, chatID, setting, links)
+	if len(claimed) == 0 {
+		return
+	}
+
+	if setting.Mode == models.AutoSummaryButton {
+		t.offer(ctx, b, message, links, claimed)
+		return
+	}
+
+	userID := fmt.Sprintf("%d", message.From.ID)
+	for _, i := range claimed {
+		// Automatic summaries stay quiet when they fail, nobody asked for them
+		summary, err := t.summarizer.summarize(models.PlatformTelegram, userID, chatID, links[i], setting.MinLength)
+		if err != nil {
+			t.logger.Warnf("Skipped summary of %s: %v", links[i], err)
+			continue
+		}
+		t.reply(ctx, b, message, summary)
+	}
+}
+
+// offer answers a message with a button per link
+func (t *Telegram) offer(ctx context.Context, b *bot.Bot, message *telegramMod.Message, links []string, claimed []int) {
+	var buttons [][]telegramMod.InlineKeyboardButton
+	for _, i := range claimed {
+		buttons = append(buttons, []telegramMod.InlineKeyboardButton{
+			{Text: buttonLabel(links, i), CallbackData: fmt.Sprintf("%s%d", buttonPrefix, i)},
+		})
+	}
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:              message.Chat.ID,
+		Text:                offerText(links, claimed),
+		DisableNotification: true,
+		ReplyParameters: &telegramMod.ReplyParameters{
+			ChatID:    message.Chat.ID,
+			MessageID: message.ID,
+		},
+		ReplyMarkup: &telegramMod.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send summarize buttons")
+	}
+}
+
+// HandleButton summarizes the link of a summarize button, on behalf of the member clicking it.
+// The link is read from the message the buttons answer, and its button is removed.
+func (t *Telegram) HandleButton(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	query := update.CallbackQuery
+	buttons := query.Message.Message
+	if buttons == nil || buttons.ReplyToMessage == nil {
+		t.answer(ctx, b, query, "The message with the link is no longer available.")
+		return
+	}
+	original := buttons.ReplyToMessage
+
+	links := collect(telegramLinks(original))
+	i, ok := parseButton(query.Data)
+	if !ok || i >= len(links) {
+		t.answer(ctx, b, query, "The link is no longer available.")
+		return
+	}
+
+	chatID := fmt.Sprintf("%d", buttons.Chat.ID)
+	setting, ok := t.summarizer.setting(models.PlatformTelegram, chatID)
+	if !ok {
+		t.answer(ctx, b, query, "Links are no longer summarized here.")
+		return
+	}
+
+	t.removeButton(ctx, b, buttons, query.Data)
+	t.answer(ctx, b, query, "Summarizing…")
+
+	summary, err := t.summarizer.summarize(models.PlatformTelegram, fmt.Sprintf("%d", query.From.ID), chatID, links[i], setting.MinLength)
+	if err != nil {
+		t.logger.Warnf("Failed to summarize %s: %v", links[i], err)
+		summary = t.summarizer.failure(err)
+	}
+	t.reply(ctx, b, original, summary)
+}
+
+// removeButton removes a clicked button, and the message once it has none left
+func (t *Telegram) removeButton(ctx context.Context, b *bot.Bot, message *telegramMod.Message, data string) {
+	var rows [][]telegramMod.InlineKeyboardButton
+	for _, row := range message.ReplyMarkup.InlineKeyboard {
+		var kept []telegramMod.InlineKeyboardButton
+		for _, button := range row {
+			if button.CallbackData != data {
+				kept = append(kept, button)
+			}
+		}
+		if len(kept) > 0 {
+			rows = append(rows, kept)
+		}
+	}
+
+	if len(rows) == 0 {
+		if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: message.Chat.ID, MessageID: message.ID}); err != nil {
+			t.logger.Error(err, "Failed to delete summarize buttons")
+		}
+		return
+	}
+
+	_, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:      message.Chat.ID,
+		MessageID:   message.ID,
+		ReplyMarkup: &telegramMod.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to remove summarize button")
+	}
+}
+
+// telegramLinks returns the links of the text or the caption of a message, in order
+func telegramLinks(message *telegramMod.Message) []string {
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+
+	// Entity offsets count UTF-16 code units
+	units := utf16.Encode([]rune(text))
+
+	var links []string
+	for _, entity := range entities {
+		switch entity.Type {
+		case telegramMod.MessageEntityTypeTextLink:
+			links = append(links, entity.URL)
+		case telegramMod.MessageEntityTypeURL:
+			if entity.Offset >= 0 && entity.Length > 0 && entity.Offset+entity.Length <= len(units) {
+				links = append(links, string(utf16.Decode(units[entity.Offset:entity.Offset+entity.Length])))
+			}
+		}
+	}
+	return links
+}
+
+func (t *Telegram) reply(ctx context.Context, b *bot.Bot, message *telegramMod.Message, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:              message.Chat.ID,
+		Text:                text,
+		DisableNotification: true,
+		LinkPreviewOptions:  &telegramMod.LinkPreviewOptions{IsDisabled: bot.True()},
+		ReplyParameters: &telegramMod.ReplyParameters{
+			ChatID:    message.Chat.ID,
+			MessageID: message.ID,
+		},
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send summary")
+	}
+}
+
+func (t *Telegram) answer(ctx context.Context, b *bot.Bot, query *telegramMod.CallbackQuery, text string) {
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            text,
+	}); err != nil {
+		t.logger.Error(err, "Failed to answer callback query")
+	}
+}
+
+func (t *Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
@@ -2,10 +2,36 @@
 package command
 
 import (
+	"time"
+
 	"sum/pkg/adapter"
+	"sum/pkg/agent"
+	"sum/pkg/cache"
+	"sum/pkg/command/acl"
+	"sum/pkg/command/ai"
+	"sum/pkg/command/audit"
+	"sum/pkg/command/autosum"
+	"sum/pkg/command/ls"
+	"sum/pkg/command/quota"
+	"sum/pkg/command/reg"
+	"sum/pkg/command/registry"
+	"sum/pkg/command/schedule"
+	"sum/pkg/command/start"
+	"sum/pkg/command/sum"
+	"sum/pkg/command/usage"
+	"sum/pkg/command/watch"
 	"sum/pkg/config"
 	"sum/pkg/logger"
+	"sum/pkg/menu"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/queue"
+	"sum/pkg/ratelimit"
 	"sum/pkg/repo"
+	"sum/pkg/repo/invocation"
+	"sum/pkg/repo/secret"
+	"sum/pkg/scheduler"
+	"sum/pkg/wizard"
 
 	"gorm.io/gorm"
 
@@ -17,16 +43,94 @@ import (
 type Command struct {
 	Discord  ICommand // Discord command handler
 	Telegram ICommand // Telegram command handler
+
+	Scheduler scheduler.IScheduler // Worker firing the schedules and polling the feeds, nil without a database or Telegram bot
+	Queue     queue.IQueue         // Workers running the queued summaries, nil without a Telegram bot
+}
+
+// builtins are the built-in commands of every platform, configs can't take their names
+// even when a command isn't enabled
+var builtins = []registry.Command{
+	ai.Command, ai.DefaultCommand, ai.PipeCommand,
+	reg.Command, reg.CancelCommand,
+	ls.Command,
+	start.StartCommand, start.HelpCommand,
+	sum.Command,
+	quota.Command,
+	usage.Command,
+	acl.Command,
+	audit.Command,
+	schedule.Command,
+	watch.Command,
+	autosum.Command,
 }
 
 // New creates a new Command instance with initialized Discord and Telegram handlers.
 // It takes the application configuration, Discord session, and Telegram bot as parameters.
+// Commands backed by the repository are only usable when db is not nil.
 func New(cfg config.Config, d *discordgo.Session, t *bot.Bot, logger logger.Logger, db *gorm.DB) Command {
-	a := adapter.New(cfg)
-	repo := repo.NewRepository(db)
+	a := adapter.New(cfg, logger)
+	secrets, err := secret.NewFromConfig(cfg)
+	if err != nil && db != nil {
+		logger.Error(err, "Invalid encryption keys, API keys can't be stored or read")
+	}
+	repo := repo.NewRepository(db, secrets)
+
+	// Fall back to in-memory limits when no database is configured
+	store := ratelimit.NewMemoryStore()
+	if db != nil {
+		store = ratelimit.NewPostgresStore(db)
+	}
+	limiter := ratelimit.New(store, cfg.RateLimit)
+
+	// Usage is only recorded when a database is configured
+	var invocations invocation.IInvocation
+	if db != nil {
+		invocations = repo.Invocation()
+	}
+	// Registration steps survive restarts when a database is configured
+	sessions := wizard.NewMemoryStore()
+	if db != nil {
+		sessions = wizard.NewPostgresStore(db)
+	}
+	wizards := wizard.New(sessions, time.Duration(cfg.WizardTimeoutMinutes)*time.Minute)
+
+	// Identical invocations are answered from the cache, shared between instances with a database
+	responses := cache.NewMemoryStore()
+	if db != nil {
+		responses = cache.NewPostgresStore(db)
+	}
+	runner := agent.New(a, invocations, cache.New(responses, cfg.Cache), logger)
+	invoker := agent.NewInvoker(repo, runner, limiter, cfg, logger)
+	guard := permission.New(repo)
+
+	// Help messages and menus list the built-in commands once they are registered
+	commands := registry.New(builtins...)
+	commands.Reserve(ai.Keywords...)
+	menus := menu.NewTelegram(repo, commands)
+
+	// Schedules and feeds are added with /schedule and /watch and post to Telegram chats
+	var worker scheduler.IScheduler
+	if db != nil && t != nil {
+		senders := map[models.PlatformType]scheduler.ISender{models.PlatformTelegram: scheduler.NewTelegramSender(t)}
+		worker = scheduler.New(repo, cfg, invoker, a.Feed(), senders, logger)
+	}
+
+	// Summaries are queued, jobs survive restarts when a database is configured
+	var jobs queue.IQueue
+	if t != nil {
+		store := queue.NewMemoryStore()
+		if db != nil {
+			store = queue.NewPostgresStore(db)
+		}
+		deliverers := map[models.PlatformType]queue.IDeliverer{models.PlatformTelegram: queue.NewTelegramDeliverer(t)}
+		jobs = queue.New(store, cfg, runner, deliverers, logger)
+	}
 
 	return Command{
-		Discord:  NewDiscord(repo, d, a, logger),
-		Telegram: NewTelegram(repo, t, cfg, a, logger),
+		Discord:   NewDiscord(repo, d, a, invoker, guard, commands, logger),
+		Telegram:  NewTelegram(repo, t, cfg, a, invoker, jobs, limiter, guard, wizards, commands, menus, logger),
+		Scheduler: worker,
+		Queue:     jobs,
 	}
 }
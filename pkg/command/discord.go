@@ -2,8 +2,16 @@ package command
 
 import (
 	"sum/pkg/adapter"
+	"sum/pkg/agent"
+	"sum/pkg/command/ai"
+	"sum/pkg/command/autosum"
+	"sum/pkg/command/edit"
+	"sum/pkg/command/ls"
 	"sum/pkg/command/reg"
+	"sum/pkg/command/registry"
 	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
 	"sum/pkg/repo"
 
 	"github.com/bwmarrin/discordgo"
@@ -11,15 +19,24 @@ import (
 
 // discord represents a Discord command handler
 type discord struct {
-	session *discordgo.Session
-	reg     *reg.Discord
+	session  *discordgo.Session
+	commands registry.IRegistry
+	reg      *reg.Discord
+	ls       *ls.Discord
+	ai       *ai.Discord
+	autosum  *autosum.Discord
 }
 
 // NewDiscord creates a new Discord command handler
-func NewDiscord(repo repo.Repository, s *discordgo.Session, a adapter.IAdapter, logger logger.Logger) ICommand {
+func NewDiscord(repo repo.Repository, s *discordgo.Session, a adapter.IAdapter, invoker *agent.Invoker, guard permission.IGuard, commands registry.IRegistry, logger logger.Logger) ICommand {
+	agents := ai.NewDiscord(repo, invoker, guard, commands, logger)
 	return &discord{
-		session: s,
-		reg:     reg.NewDiscord(repo, logger),
+		session:  s,
+		commands: commands,
+		reg:      reg.NewDiscord(repo, a.Dify(), guard, logger),
+		ls:       ls.NewDiscord(repo, edit.New(repo, a.Dify(), commands), agents, guard, logger),
+		ai:       agents,
+		autosum:  autosum.NewDiscord(repo, a.Article(), invoker, guard, logger),
 	}
 }
 
@@ -27,21 +44,73 @@ func NewDiscord(repo repo.Repository, s *discordgo.Session, a adapter.IAdapter,
 func (d *discord) AddHandler() {
 	d.session.AddHandler(d.reg.Handle)
 	d.session.AddHandler(d.reg.HandleSubmit)
+	d.session.AddHandler(d.ls.Handle)
+	d.session.AddHandler(d.ls.HandleEdit)
+	d.session.AddHandler(d.ls.HandleSubmit)
 }
 
 // RegisterReg registers the reg command with the Discord API
 func (d *discord) RegisterReg() {
 	d.session.ApplicationCommandCreate(d.session.State.User.ID, "", d.reg.Info())
+	d.commands.Enable(models.PlatformDiscord, reg.Command)
 }
 
 // RegisterLs registers the ls command with the Discord API
-func (d *discord) RegisterLs() {}
+func (d *discord) RegisterLs() {
+	d.session.ApplicationCommandCreate(d.session.State.User.ID, "", d.ls.Info())
+	d.commands.Enable(models.PlatformDiscord, ls.Command)
+}
+
+// RegisterAi registers the default command with the Discord API, and the slash commands
+// of the server configs of every guild. The handlers read the database, so they are added
+// here rather than in AddHandler.
+func (d *discord) RegisterAi() {
+	d.session.AddHandler(d.ai.Handle)
+	d.session.AddHandler(d.ai.HandleDefault)
+	d.session.AddHandler(d.ai.HandleMessage)
+	d.session.AddHandler(d.ai.HandleGuildCreate)
+	d.session.ApplicationCommandCreate(d.session.State.User.ID, "", d.ai.DefaultInfo())
+	d.commands.Enable(models.PlatformDiscord, ai.DefaultCommand)
 
-// RegisterAi registers the ai command with the Discord API
-func (d *discord) RegisterAi() {}
+	// Guilds that became available before the handler was added
+	for _, guild := range d.session.State.Guilds {
+		d.ai.SyncGuild(d.session, guild.ID)
+	}
+}
 
 // RegisterStart registers the start command with the Discord API
 func (d *discord) RegisterStart() {}
 
 // RegisterSum registers the sum command with the Discord API
 func (d *discord) RegisterSum() {}
+
+// RegisterQuota registers the quota command with the Discord API
+func (d *discord) RegisterQuota() {}
+
+// RegisterUsage registers the usage command with the Discord API
+func (d *discord) RegisterUsage() {}
+
+// RegisterAcl registers the acl command with the Discord API
+func (d *discord) RegisterAcl() {}
+
+// RegisterAudit registers the audit command with the Discord API
+func (d *discord) RegisterAudit() {}
+
+// RegisterSchedule registers the schedule command with the Discord API
+func (d *discord) RegisterSchedule() {}
+
+// RegisterWatch registers the watch command with the Discord API
+func (d *discord) RegisterWatch() {}
+
+// RegisterAutoSum registers the autosum command with the Discord API, along with the
+// server messages with links. Their links are only seen with the message content intent.
+func (d *discord) RegisterAutoSum() {
+	d.session.AddHandler(d.autosum.Handle)
+	d.session.AddHandler(d.autosum.HandleMessage)
+	d.session.AddHandler(d.autosum.HandleButton)
+	d.session.ApplicationCommandCreate(d.session.State.User.ID, "", d.autosum.Info())
+	d.commands.Enable(models.PlatformDiscord, autosum.Command)
+}
+
+// RegisterMenu sets the command menus, Discord lists the slash commands instead
+func (d *discord) RegisterMenu() {}
@@ -0,0 +1,401 @@
+package edit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/outbound"
+	"sum/pkg/repo"
+	"sum/pkg/repo/audit"
+
+	"gorm.io/gorm"
+)
+
+// maxCommandLength bounds the length of command names
+const maxCommandLength = 32
+
+// Errors returned for edits refused because of their values
+var (
+	ErrInvalidCommand = errors.New("invalid command name")
+	ErrInvalidAliases = errors.New("invalid aliases")
+	ErrCommandTaken   = errors.New("command name already used")
+	ErrInvalidURL     = errors.New("invalid endpoint URL")
+	ErrEmptyAPIKey    = errors.New("empty API key")
+	ErrAPIKeyRequired = errors.New("API key required with a new endpoint URL")
+	ErrInvalidInputs  = errors.New("inputs are not a JSON object")
+	ErrUnknownField   = errors.New("unknown field")
+)
+
+// messages explain the errors of refused edits to the user
+var messages = map[error]string{
+	ErrInvalidCommand: "The command name must be a single word of at most 32 characters, other than a built-in command like /ai or /help.",
+	ErrInvalidAliases: "Aliases must be at most 10 single words of at most 32 characters, separated by commas, other than built-in commands.",
+	ErrCommandTaken:   "Another configuration already uses this command name.",
+	ErrInvalidURL:     "Invalid endpoint URL.",
+	ErrEmptyAPIKey:    "The API key can't be empty.",
+	ErrAPIKeyRequired: "A new endpoint URL needs the API key again.",
+	ErrInvalidInputs:  "The inputs must be a JSON object, e.g. {\"language\": \"en\"}.",
+	ErrUnknownField:   "This field can't be edited.",
+}
+
+// ProbeError is returned when the edited endpoint URL or API key fail the endpoint check
+type ProbeError struct {
+	Err error
+}
+
+func (e *ProbeError) Error() string {
+	return "endpoint check failed: " + e.Err.Error()
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// Edit is a change of one or more fields of a config
+type Edit struct {
+	Kind     string            // KindUser or KindServer
+	ConfigID string            // ID of the config
+	Actor    audit.Actor       // Who makes the change, recorded in the audit log
+	Values   map[string]string // New values by field name
+
+	// KeyLater saves a new endpoint URL without the API key, which is asked next. The
+	// current key is cleared and the endpoint is checked once the new key is saved.
+	KeyLater bool
+}
+
+// snapshot holds the editable fields of a user or server config
+type snapshot struct {
+	ID          int64
+	OwnerID     int64 // User of a user config, server of a server config
+	Command     string
+	EndpointURL string
+	APIKey      string // Encrypted
+	Description string
+	Inputs      string
+	Aliases     string // Comma separated
+}
+
+// value returns the current value of a non-secret field
+func (s snapshot) value(field string) string {
+	switch field {
+	case FieldCommand:
+		return s.Command
+	case FieldEndpointURL:
+		return s.EndpointURL
+	case FieldDescription:
+		return s.Description
+	case FieldInputs:
+		return s.Inputs
+	case FieldAliases:
+		return s.Aliases
+	}
+	return ""
+}
+
+// Editor validates edits, saves them and records them in the audit log
+type Editor struct {
+	repo     repo.Repository
+	dify     dify.DifyAdapter
+	commands registry.IRegistry
+}
+
+// New creates an Editor. Changed endpoints are checked with dify before they are saved,
+// and names can't be those of the built-in commands of commands.
+func New(repo repo.Repository, dify dify.DifyAdapter, commands registry.IRegistry) *Editor {
+	return &Editor{repo: repo, dify: dify, commands: commands}
+}
+
+// Values returns the current values of the fields of a config, secrets are left empty
+func (e *Editor) Values(kind, id string) (map[string]string, error) {
+	s, err := e.load(e.repo, kind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, f := range Fields {
+		if !f.Secret {
+			values[f.Name] = s.value(f.Name)
+		}
+	}
+	return values, nil
+}
+
+// Apply validates and saves an edit. When the API key changes, the endpoint is checked
+// first and the returned AppInfo describes it, otherwise it is nil. A new endpoint URL
+// needs the API key in the same edit, unless KeyLater is set. Values equal to the current
+// ones are ignored.
+func (e *Editor) Apply(edit Edit) (*dify.AppInfo, error) {
+	current, err := e.load(e.repo, edit.Kind, edit.ConfigID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := e.validate(edit, current)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	var info *dify.AppInfo
+	_, urlChanged := values[FieldEndpointURL]
+	_, keyChanged := values[FieldAPIKey]
+
+	// The current key is never sent to a new endpoint
+	if urlChanged && !keyChanged && !edit.KeyLater {
+		return nil, ErrAPIKeyRequired
+	}
+	checked := keyChanged
+	if checked {
+		if info, err = e.probe(edit.ConfigID, current, values); err != nil {
+			return nil, err
+		}
+	}
+
+	// The repository records every saved field in the audit log
+	err = e.repo.As(edit.Actor).WithTx(func(tx repo.Repository) error {
+		store := configStore(tx, edit.Kind)
+		for _, f := range Fields {
+			value, ok := values[f.Name]
+			if !ok {
+				continue
+			}
+			if err := save(store, f.Name, edit.ConfigID, value); err != nil {
+				return err
+			}
+		}
+
+		// The endpoint passed the check above
+		if checked {
+			if err := store.MarkVerified(edit.ConfigID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// validate normalizes the edited values, dropping unchanged ones, and checks them
+func (e *Editor) validate(edit Edit, current snapshot) (map[string]string, error) {
+	values := map[string]string{}
+	for name, value := range edit.Values {
+		f, ok := FieldByName(name)
+		if !ok {
+			return nil, ErrUnknownField
+		}
+
+		value = strings.TrimSpace(value)
+		switch name {
+		case FieldCommand:
+			value = strings.TrimPrefix(value, "/")
+			if value != current.Command {
+				if !ValidCommandName(e.commands, value) {
+					return nil, ErrInvalidCommand
+				}
+				taken, err := e.commandTaken(edit.Kind, current, value)
+				if err != nil {
+					return nil, err
+				}
+				if taken {
+					return nil, ErrCommandTaken
+				}
+			}
+		case FieldAliases:
+			command := current.Command
+			if edited, ok := edit.Values[FieldCommand]; ok {
+				command = strings.TrimPrefix(strings.TrimSpace(edited), "/")
+			}
+			if value == "-" {
+				value = ""
+			}
+
+			var ok bool
+			if value, ok = NormalizeAliases(e.commands, value, command); !ok {
+				return nil, ErrInvalidAliases
+			}
+			for _, alias := range models.SplitAliases(value) {
+				taken, err := e.commandTaken(edit.Kind, current, alias)
+				if err != nil {
+					return nil, err
+				}
+				if taken {
+					return nil, ErrCommandTaken
+				}
+			}
+		case FieldEndpointURL:
+			if _, err := url.ParseRequestURI(value); err != nil {
+				return nil, ErrInvalidURL
+			}
+		case FieldAPIKey:
+			if value == "" {
+				return nil, ErrEmptyAPIKey
+			}
+		case FieldInputs:
+			inputs, err := models.DecodeInputs(value)
+			if err != nil {
+				return nil, ErrInvalidInputs
+			}
+			normalized, err := json.Marshal(inputs)
+			if err != nil {
+				return nil, ErrInvalidInputs
+			}
+			value = string(normalized)
+		}
+
+		if !f.Secret && value == current.value(name) {
+			continue
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// commandTaken reports whether another config of the same user or server uses command, as its name or an alias
+func (e *Editor) commandTaken(kind string, current snapshot, command string) (bool, error) {
+	var (
+		id  int64
+		err error
+	)
+	if kind == KindUser {
+		var c models.UserAgentConfig
+		c, err = e.repo.UserConfig().GetByUserIDAndCommand(current.OwnerID, command)
+		id = c.ID
+	} else {
+		var c models.ServerAdminConfig
+		c, err = e.repo.ServerConfig().GetByServerIDAndCommand(current.OwnerID, command)
+		id = c.ID
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return id != current.ID, nil
+}
+
+// probe checks the endpoint with the edited URL and API key, falling back to the current ones
+func (e *Editor) probe(id string, current snapshot, values map[string]string) (*dify.AppInfo, error) {
+	endpointURL, ok := values[FieldEndpointURL]
+	if !ok {
+		endpointURL = current.EndpointURL
+	}
+
+	apiKey, ok := values[FieldAPIKey]
+	if !ok {
+		if current.APIKey == "" {
+			return nil, &ProbeError{Err: ErrEmptyAPIKey}
+		}
+		var err error
+		if apiKey, err = e.repo.Secret().Decrypt(current.APIKey, id); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := e.dify.Probe(endpointURL, apiKey)
+	if err != nil {
+		return nil, &ProbeError{Err: err}
+	}
+	return info, nil
+}
+
+// load reads the editable fields of a config
+func (e *Editor) load(r repo.Repository, kind, id string) (snapshot, error) {
+	switch kind {
+	case KindUser:
+		c, err := r.UserConfig().GetByID(id)
+		return snapshot{c.ID, c.UserID, c.Command, c.EndpointURL, c.APIKey, c.Description, c.Inputs, c.Aliases}, err
+	case KindServer:
+		c, err := r.ServerConfig().GetByID(id)
+		return snapshot{c.ID, c.ServerID, c.Command, c.EndpointURL, c.APIKey, c.Description, c.Inputs, c.Aliases}, err
+	}
+	return snapshot{}, fmt.Errorf("unknown config kind %q", kind)
+}
+
+// store saves the fields of a user or server config
+type store interface {
+	SaveCommand(id string, command string) error
+	SaveEndpointURL(id string, endpointURL string) error
+	SaveAPIKey(id string, apiKey string) error
+	SaveDescription(id string, description string) error
+	SaveInputs(id string, inputs string) error
+	SaveAliases(id string, aliases string) error
+	MarkVerified(id string) error
+}
+
+// configStore returns the repository saving configs of kind
+func configStore(r repo.Repository, kind string) store {
+	if kind == KindUser {
+		return r.UserConfig()
+	}
+	return r.ServerConfig()
+}
+
+// save stores one field, the repository encrypts the API key
+func save(s store, field, id, value string) error {
+	switch field {
+	case FieldCommand:
+		return s.SaveCommand(id, value)
+	case FieldEndpointURL:
+		return s.SaveEndpointURL(id, value)
+	case FieldAPIKey:
+		return s.SaveAPIKey(id, value)
+	case FieldDescription:
+		return s.SaveDescription(id, value)
+	case FieldInputs:
+		return s.SaveInputs(id, value)
+	case FieldAliases:
+		return s.SaveAliases(id, value)
+	}
+	return ErrUnknownField
+}
+
+// Describe returns the message explaining why an edit failed, for errors caused by the user
+func Describe(err error) (string, bool) {
+	var probe *ProbeError
+	switch {
+	case errors.As(err, &probe):
+		if errors.Is(err, dify.ErrUnauthorized) {
+			return "❌ Endpoint check failed: the API key was rejected. Nothing was changed.", true
+		}
+		if errors.Is(err, outbound.ErrBlocked) {
+			return "❌ Endpoint check failed: the endpoint URL points to an address that isn't allowed. Nothing was changed.", true
+		}
+		return fmt.Sprintf("❌ Endpoint check failed: %v. Nothing was changed.", probe.Err), true
+	}
+
+	for target, message := range messages {
+		if errors.Is(err, target) {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// Summary describes the result of an applied edit
+func Summary(info *dify.AppInfo) string {
+	if info == nil {
+		return "✅ Configuration updated."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("✅ Configuration updated. Endpoint check passed.")
+	if info.Name != "" {
+		sb.WriteString("\nApp: " + info.Name)
+	}
+	if info.Mode != "" {
+		sb.WriteString("\nType: " + info.Mode)
+	}
+	return sb.String()
+}
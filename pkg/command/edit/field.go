@@ -0,0 +1,47 @@
+// Package edit changes the fields of existing user and server configs. It is
+// shared by the Telegram and Discord /ls flows.
+package edit
+
+// Config kinds
+const (
+	KindUser   = "user"
+	KindServer = "server"
+)
+
+// Editable fields
+const (
+	FieldCommand     = "command"
+	FieldEndpointURL = "endpoint_url"
+	FieldAPIKey      = "api_key"
+	FieldDescription = "description"
+	FieldInputs      = "inputs"
+	FieldAliases     = "aliases"
+)
+
+// Field is an editable config field
+type Field struct {
+	Name   string
+	Label  string // Button and form label
+	Prompt string // Question asking for the new value
+	Secret bool   // The value is never shown and the message holding it is deleted
+}
+
+// Fields are the editable config fields, in display order
+var Fields = []Field{
+	{Name: FieldCommand, Label: "Command name", Prompt: "Please enter the new command name:"},
+	{Name: FieldEndpointURL, Label: "Endpoint URL", Prompt: "Please enter the new endpoint URL:"},
+	{Name: FieldAPIKey, Label: "API key", Prompt: "Please enter the new API key:", Secret: true},
+	{Name: FieldAliases, Label: "Aliases", Prompt: "Please enter other names for this command, separated by commas, e.g. tr, translate. Send - to remove them:"},
+	{Name: FieldDescription, Label: "Description", Prompt: "Please enter the new description:"},
+	{Name: FieldInputs, Label: "Inputs", Prompt: "Please enter the inputs sent with every request, as a JSON object, e.g. {\"language\": \"en\"}. Send {} to remove them:"},
+}
+
+// FieldByName returns the editable field named name
+func FieldByName(name string) (Field, bool) {
+	for _, f := range Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
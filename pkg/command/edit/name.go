@@ -0,0 +1,37 @@
+package edit
+
+import (
+	"slices"
+	"strings"
+
+	"sum/pkg/command/registry"
+)
+
+// maxAliases bounds the number of aliases of a config
+const maxAliases = 10
+
+// ValidCommandName reports whether name can name a config or one of its aliases:
+// a single word of at most 32 characters that isn't a built-in command of commands
+func ValidCommandName(commands registry.IRegistry, name string) bool {
+	return name != "" && len(name) <= maxCommandLength && !strings.ContainsAny(name, " \t\n,/@") && !commands.Reserved(name)
+}
+
+// NormalizeAliases parses a comma separated list of aliases, dropping duplicates and
+// the command itself. It returns false when an alias isn't a valid command name.
+func NormalizeAliases(commands registry.IRegistry, raw, command string) (string, bool) {
+	var aliases []string
+	for _, alias := range strings.Split(raw, ",") {
+		alias = strings.TrimPrefix(strings.TrimSpace(alias), "/")
+		if alias == "" || alias == command || slices.Contains(aliases, alias) {
+			continue
+		}
+		if !ValidCommandName(commands, alias) {
+			return "", false
+		}
+		aliases = append(aliases, alias)
+	}
+	if len(aliases) > maxAliases {
+		return "", false
+	}
+	return strings.Join(aliases, ","), true
+}
@@ -8,4 +8,12 @@ type ICommand interface {
 	RegisterAi()
 	RegisterStart()
 	RegisterSum()
+	RegisterQuota()
+	RegisterUsage()
+	RegisterAcl()
+	RegisterAudit()
+	RegisterSchedule()
+	RegisterWatch()
+	RegisterAutoSum()
+	RegisterMenu()
 }
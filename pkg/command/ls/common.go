@@ -1,47 +1,35 @@
 package ls
 
 import (
-	"context"
 	"net/url"
+	"strconv"
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/permission"
 
-	"github.com/go-telegram/bot"
 	telegramMod "github.com/go-telegram/bot/models"
 )
 
+// Command describes /ls in help messages and command menus
+var Command = registry.Command{
+	Name:        "ls",
+	Usage:       "[server]",
+	Description: "List and edit the commands",
+	Details: `Usage:
+/ls - List your personal commands
+/ls server - List the commands of this group, or in a private chat the groups you registered
+
+Each command can be edited or removed from the list. Editing or removing a group command needs the register or remove permission of the group.`,
+	Platforms: []models.PlatformType{models.PlatformTelegram, models.PlatformDiscord},
+	Chats:     registry.ChatAll,
+}
+
 func isValidURL(s string) bool {
 	_, err := url.ParseRequestURI(s)
 	return err == nil
 }
 
-func isPermissable(b *bot.Bot, update *telegramMod.Update) bool {
-	var fromID, chatID int64
-	if update.CallbackQuery != nil {
-		if update.CallbackQuery.Message.Message == nil {
-			return false
-		}
-
-		fromID = update.CallbackQuery.From.ID
-		chatID = update.CallbackQuery.Message.Message.Chat.ID
-	} else {
-		fromID = update.Message.From.ID
-		chatID = update.Message.Chat.ID
-	}
-
-	if fromID == chatID {
-		return true
-	}
-
-	member, err := b.GetChatMember(context.Background(), &bot.GetChatMemberParams{
-		ChatID: chatID,
-		UserID: fromID,
-	})
-	if err != nil {
-		return false
-	}
-
-	return member.Type == telegramMod.ChatMemberTypeAdministrator || member.Type == telegramMod.ChatMemberTypeOwner
-}
-
 func getUserID(update *telegramMod.Update) int64 {
 	if update.Message != nil {
 		return update.Message.From.ID
@@ -59,3 +47,48 @@ func getChatID(update *telegramMod.Update) int64 {
 	}
 	return 0
 }
+
+// Permission returns the permission check of an /ls update. Only removing or
+// editing a server command needs a check, personal commands are checked for ownership.
+func Permission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.CallbackQuery == nil {
+		return permission.Check{}, false
+	}
+
+	parts := strings.SplitN(update.CallbackQuery.Data, ":", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "server_") {
+		return permission.Check{}, false
+	}
+
+	var action permission.Action
+	switch parts[0] {
+	case "ls_remove_command":
+		action = permission.ActionRemove
+	case "ls_edit", "ls_field":
+		action = permission.ActionRegister
+	default:
+		return permission.Check{}, false
+	}
+
+	// Field callbacks carry the field name after the config ID
+	configID, _, _ := strings.Cut(strings.TrimPrefix(parts[1], "server_"), ":")
+	id, err := strconv.ParseInt(configID, 10, 64)
+	if err != nil {
+		return permission.Check{}, false
+	}
+
+	return permission.Check{
+		Action:   action,
+		Platform: models.PlatformTelegram,
+		ConfigID: id,
+	}, true
+}
+
+func getUser(update *telegramMod.Update) *telegramMod.User {
+	if update.Message != nil {
+		return update.Message.From
+	} else if update.CallbackQuery != nil {
+		return &update.CallbackQuery.From
+	}
+	return nil
+}
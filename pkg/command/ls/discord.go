@@ -0,0 +1,406 @@
+package ls
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sum/pkg/command/edit"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/repo"
+	"sum/pkg/repo/audit"
+
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+)
+
+// editPrefix is the custom ID prefix of the edit buttons and forms, followed by <kind>_<id>
+const editPrefix = "ls_edit_"
+
+// maxButtons is the number of buttons a Discord message can hold
+const maxButtons = 25
+
+// CommandSyncer updates the slash commands of a guild once its configs change
+type CommandSyncer interface {
+	SyncGuild(s *discordgo.Session, guildID string)
+}
+
+// Discord handles /ls on Discord: it lists the commands of the caller or of the
+// server with buttons opening a form to edit them
+type Discord struct {
+	repo     repo.Repository
+	editor   *edit.Editor
+	commands CommandSyncer
+	guard    permission.IGuard
+	logger   logger.Logger
+}
+
+func NewDiscord(repo repo.Repository, editor *edit.Editor, commands CommandSyncer, guard permission.IGuard, logger logger.Logger) *Discord {
+	return &Discord{
+		repo:     repo,
+		editor:   editor,
+		commands: commands,
+		guard:    guard,
+		logger:   logger,
+	}
+}
+
+func (d *Discord) Info() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "ls",
+		Description: "List and edit your commands or the commands of this server",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "server",
+				Description: "List the commands of this server",
+				Required:    false,
+			},
+		},
+	}
+}
+
+// listItem is a command shown by /ls
+type listItem struct {
+	kind        string
+	id          int64
+	command     string
+	aliases     string
+	description string
+}
+
+func (d *Discord) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name != "ls" {
+		return
+	}
+
+	if i.Member == nil {
+		d.respondWithError(s, i, "This command can only be used in a server.")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	isServer := len(options) > 0 && options[0].Name == "server" && options[0].BoolValue()
+
+	var (
+		items   []listItem
+		canEdit bool
+		err     error
+	)
+	if isServer {
+		items, err = d.serverCommands(i.GuildID)
+		canEdit = d.isAllowed(i, permission.Check{Action: permission.ActionRegister, Platform: models.PlatformDiscord, ChatID: i.GuildID})
+	} else {
+		items, err = d.userCommands(i.Member.User.ID)
+		canEdit = true
+	}
+	if err != nil {
+		d.logger.Error(err, "Failed to retrieve commands")
+		d.respondWithError(s, i, "Failed to retrieve commands. Please try again.")
+		return
+	}
+
+	if len(items) == 0 {
+		text := "You don't have any commands set up. Please use /reg to set up a command."
+		if isServer {
+			text = "No commands found for this server. Please use /reg server to set up commands."
+		}
+		d.respond(s, i, &discordgo.InteractionResponseData{Content: text, Flags: discordgo.MessageFlagsEphemeral})
+		return
+	}
+
+	var (
+		sb      strings.Builder
+		buttons []discordgo.MessageComponent
+	)
+	for _, item := range items {
+		command := item.command
+		if command == "" {
+			command = "(pending)"
+		}
+		fmt.Fprintf(&sb, "🤖 **Command:** `%s`\n", command)
+		if aliases := models.SplitAliases(item.aliases); len(aliases) > 0 {
+			fmt.Fprintf(&sb, "🔁 **Aliases:** `%s`\n", strings.Join(aliases, "`, `"))
+		}
+		fmt.Fprintf(&sb, "📝 **Description:** %s\n\n", item.description)
+
+		if canEdit && len(buttons) < maxButtons {
+			buttons = append(buttons, discordgo.Button{
+				Label:    "✏️ " + command,
+				Style:    discordgo.SecondaryButton,
+				CustomID: fmt.Sprintf("%s%s_%d", editPrefix, item.kind, item.id),
+			})
+		}
+	}
+
+	var rows []discordgo.MessageComponent
+	for start := 0; start < len(buttons); start += 5 {
+		end := min(start+5, len(buttons))
+		rows = append(rows, discordgo.ActionsRow{Components: buttons[start:end]})
+	}
+
+	d.respond(s, i, &discordgo.InteractionResponseData{
+		Content:    strings.TrimSpace(sb.String()),
+		Components: rows,
+		Flags:      discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// userCommands returns the configs of a Discord user, none when the user isn't registered
+func (d *Discord) userCommands(userID string) ([]listItem, error) {
+	user, err := d.repo.User().GetByPlatformID(userID, string(models.PlatformDiscord))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := d.repo.UserConfig().ListByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]listItem, 0, len(configs))
+	for _, c := range configs {
+		items = append(items, listItem{edit.KindUser, c.ID, c.Command, c.Aliases, c.Description})
+	}
+	return items, nil
+}
+
+// serverCommands returns the configs of a guild, none when the guild isn't registered
+func (d *Discord) serverCommands(guildID string) ([]listItem, error) {
+	server, err := d.repo.Server().GetByPlatformID(guildID, string(models.PlatformDiscord))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := d.repo.ServerConfig().ListByServerID(server.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]listItem, 0, len(configs))
+	for _, c := range configs {
+		items = append(items, listItem{edit.KindServer, c.ID, c.Command, c.Aliases, c.Description})
+	}
+	return items, nil
+}
+
+// HandleEdit opens the edit form of a command, prefilled with its current values
+func (d *Discord) HandleEdit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	kind, id, ok := parseEditID(i.MessageComponentData().CustomID)
+	if !ok || i.Member == nil {
+		return
+	}
+
+	if !d.canEdit(i, kind, id) {
+		d.respondWithError(s, i, "You don't have permission to edit this command.")
+		return
+	}
+
+	values, err := d.editor.Values(kind, id)
+	if err != nil {
+		d.logger.Error(err, "Failed to get command")
+		d.respondWithError(s, i, "Failed to get command. Please try again.")
+		return
+	}
+
+	input := func(field, label, placeholder string, style discordgo.TextInputStyle, maxLength int) discordgo.MessageComponent {
+		return discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:    field,
+					Label:       label,
+					Style:       style,
+					Value:       values[field],
+					Placeholder: placeholder,
+					Required:    false,
+					MaxLength:   maxLength,
+				},
+			},
+		}
+	}
+
+	// Forms hold at most 5 inputs, the aliases follow the command name in the first one
+	if aliases := models.SplitAliases(values[edit.FieldAliases]); len(aliases) > 0 {
+		values[edit.FieldCommand] = strings.Join(append([]string{values[edit.FieldCommand]}, aliases...), ", ")
+	}
+
+	title := "Edit command"
+	if command, _, _ := strings.Cut(values[edit.FieldCommand], ","); command != "" {
+		title = fmt.Sprintf("Edit \"%s\"", command)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: fmt.Sprintf("%s%s_%s", editPrefix, kind, id),
+			Title:    title,
+			Components: []discordgo.MessageComponent{
+				input(edit.FieldCommand, "Command name, then aliases", "translate, tr", discordgo.TextInputShort, 200),
+				input(edit.FieldEndpointURL, "Endpoint URL", "https://example.com/api", discordgo.TextInputShort, 200),
+				input(edit.FieldAPIKey, "API key", "Leave empty to keep the current key, required with a new URL", discordgo.TextInputShort, 100),
+				input(edit.FieldDescription, "Description", "What the command does", discordgo.TextInputParagraph, 1000),
+				input(edit.FieldInputs, "Inputs (JSON object)", "{\"language\": \"en\"}", discordgo.TextInputParagraph, 2000),
+			},
+		},
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to show edit modal")
+	}
+}
+
+// HandleSubmit applies the values of a submitted edit form
+func (d *Discord) HandleSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionModalSubmit {
+		return
+	}
+
+	kind, id, ok := parseEditID(i.ModalSubmitData().CustomID)
+	if !ok || i.Member == nil {
+		return
+	}
+
+	// Permissions may have changed since the form was opened
+	if !d.canEdit(i, kind, id) {
+		d.respondWithError(s, i, "You don't have permission to edit this command.")
+		return
+	}
+
+	values := map[string]string{}
+	for _, component := range i.ModalSubmitData().Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok || len(row.Components) == 0 {
+			continue
+		}
+		input, ok := row.Components[0].(*discordgo.TextInput)
+		if !ok {
+			continue
+		}
+		values[input.CustomID] = input.Value
+	}
+
+	// The first name is the command, the others are its aliases
+	command, aliases, _ := strings.Cut(values[edit.FieldCommand], ",")
+	values[edit.FieldCommand] = strings.TrimSpace(command)
+	values[edit.FieldAliases] = aliases
+
+	// An empty API key keeps the current one
+	if strings.TrimSpace(values[edit.FieldAPIKey]) == "" {
+		delete(values, edit.FieldAPIKey)
+	}
+
+	// The endpoint probe may take longer than Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to defer edit response")
+		return
+	}
+
+	info, err := d.editor.Apply(edit.Edit{
+		Kind:     kind,
+		ConfigID: id,
+		Actor:    audit.Actor{Platform: models.PlatformDiscord, UserID: i.Member.User.ID},
+		Values:   values,
+	})
+	if text, ok := edit.Describe(err); ok {
+		d.editResponse(s, i, text)
+		return
+	}
+	if err != nil {
+		d.logger.Error(err, "Failed to edit command")
+		d.editResponse(s, i, "Error: Failed to save the changes. Please try again.")
+		return
+	}
+
+	// The names and descriptions of server configs are the slash commands of the guild
+	if kind == edit.KindServer {
+		d.commands.SyncGuild(s, i.GuildID)
+	}
+
+	d.editResponse(s, i, edit.Summary(info))
+}
+
+// parseEditID splits the custom ID of an edit button or form into the config kind and ID
+func parseEditID(customID string) (kind, id string, ok bool) {
+	if !strings.HasPrefix(customID, editPrefix) {
+		return "", "", false
+	}
+	return parseCommandID(strings.TrimPrefix(customID, editPrefix))
+}
+
+// canEdit reports whether the member may edit a config: its owner for a user config,
+// or a member allowed to register commands of the server the config belongs to
+func (d *Discord) canEdit(i *discordgo.InteractionCreate, kind, id string) bool {
+	if kind == edit.KindUser {
+		user, err := d.repo.User().GetByPlatformID(i.Member.User.ID, string(models.PlatformDiscord))
+		if err != nil {
+			return false
+		}
+		config, err := d.repo.UserConfig().GetByID(id)
+		if err != nil {
+			return false
+		}
+		return config.UserID == user.ID
+	}
+
+	config, err := d.repo.ServerConfig().GetByID(id)
+	if err != nil || config.Server == nil || config.Server.ServerID != i.GuildID {
+		return false
+	}
+	return d.isAllowed(i, permission.Check{Action: permission.ActionRegister, Platform: models.PlatformDiscord, ConfigID: config.ID})
+}
+
+func (d *Discord) isAllowed(i *discordgo.InteractionCreate, check permission.Check) bool {
+	target, err := d.guard.Resolve(check)
+	if err != nil {
+		d.logger.Error(err, "Failed to resolve permission check")
+		return false
+	}
+
+	allowed, err := d.guard.Allowed(target, check.Action, permission.DiscordSubject(i))
+	if err != nil {
+		d.logger.Error(err, "Failed to evaluate permission rules")
+		return false
+	}
+	return allowed
+}
+
+func (d *Discord) respond(s *discordgo.Session, i *discordgo.InteractionCreate, data *discordgo.InteractionResponseData) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to respond to interaction")
+	}
+}
+
+func (d *Discord) respondWithError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	d.respond(s, i, &discordgo.InteractionResponseData{
+		Content: fmt.Sprintf("Error: %s", message),
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// editResponse replaces the deferred response of an interaction
+func (d *Discord) editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &message}); err != nil {
+		d.logger.Error(err, "Failed to edit interaction response")
+	}
+}
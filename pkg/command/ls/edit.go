@@ -0,0 +1,236 @@
+package ls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sum/pkg/command/edit"
+	"sum/pkg/models"
+	"sum/pkg/repo/audit"
+	"sum/pkg/wizard"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// editFlowPrefix is the prefix of the wizard flows waiting for a new field value
+const editFlowPrefix = "edit_"
+
+// parseCommandID splits a user_<id> or server_<id> callback identifier
+func parseCommandID(commandID string) (kind, id string, ok bool) {
+	kind, id, ok = strings.Cut(commandID, "_")
+	if !ok || (kind != edit.KindUser && kind != edit.KindServer) {
+		return "", "", false
+	}
+	return kind, id, true
+}
+
+// showEditFields lists the fields of a command that can be edited. Server commands are
+// checked by the permission guard before the handler runs.
+func (t *Telegram) showEditFields(ctx context.Context, b *bot.Bot, update *telegramMod.Update, commandID string) {
+	kind, id, ok := parseCommandID(commandID)
+	if !ok {
+		return
+	}
+	if kind == edit.KindUser && !t.ownsUserCommand(update, id) {
+		t.sendErrorMessage(ctx, b, update, "You don't have permission to edit this command\\.")
+		return
+	}
+
+	values, err := t.editor.Values(kind, id)
+	if err != nil {
+		t.logger.Error(err, "Failed to get command")
+		t.sendErrorMessage(ctx, b, update, "Failed to get command\\. Please try again\\.")
+		return
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, f := range edit.Fields {
+		callbackData := fmt.Sprintf("ls_field:%s:%s", commandID, f.Name)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(f.Label, callbackData)))
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      getChatID(update),
+		Text:        fmt.Sprintf("✏️ Editing \"%s\". Choose the field to change:", values[edit.FieldCommand]),
+		ReplyMarkup: tgbotapi.NewInlineKeyboardMarkup(keyboard...),
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
+
+// startEdit waits for the new value of a field in the private chat with the caller,
+// so that API keys are never sent in a group
+func (t *Telegram) startEdit(ctx context.Context, b *bot.Bot, update *telegramMod.Update, data string) {
+	commandID, name, _ := strings.Cut(data, ":")
+	kind, id, ok := parseCommandID(commandID)
+	if !ok {
+		return
+	}
+	field, ok := edit.FieldByName(name)
+	if !ok {
+		return
+	}
+	if kind == edit.KindUser && !t.ownsUserCommand(update, id) {
+		t.sendErrorMessage(ctx, b, update, "You don't have permission to edit this command\\.")
+		return
+	}
+
+	values, err := t.editor.Values(kind, id)
+	if err != nil {
+		t.logger.Error(err, "Failed to get command")
+		t.sendErrorMessage(ctx, b, update, "Failed to get command\\. Please try again\\.")
+		return
+	}
+
+	userID := getUserID(update)
+	key := wizard.TelegramKey(userID)
+	unlock := t.wizard.Lock(key)
+	defer unlock()
+
+	if _, err := t.wizard.Start(key, editFlowPrefix+kind, id, wizard.Step{Name: field.Name}); err != nil {
+		t.logger.Error(err, "Failed to start edit")
+		t.sendErrorMessage(ctx, b, update, "An error occurred\\. Please try again\\.")
+		return
+	}
+
+	prompt := field.Prompt
+	if !field.Secret {
+		current := values[field.Name]
+		if current == "" {
+			current = "(empty)"
+		}
+		prompt = fmt.Sprintf("%s: %s\n\n%s", field.Label, current, field.Prompt)
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: userID,
+		Text:   prompt + "\nSend /cancel to stop.",
+	})
+	if err != nil {
+		// The user never opened a private chat with the bot
+		t.logger.Error(err, "Failed to send edit prompt")
+		if _, err := t.wizard.Cancel(key); err != nil {
+			t.logger.Error(err, "Failed to end wizard session")
+		}
+		t.sendErrorMessage(ctx, b, update, "I couldn't message you privately\\. Start a private chat with me, then tap the button again\\.")
+		return
+	}
+
+	if getChatID(update) != userID {
+		t.sendMessage(ctx, b, update, "I sent you a private message to continue editing\\.")
+	}
+}
+
+// Waiting reports whether update answers an edit prompt. Commands are never answers.
+func (t *Telegram) Waiting(update *telegramMod.Update) bool {
+	if update.Message == nil || update.Message.From == nil || update.Message.Text == "" {
+		return false
+	}
+	if strings.HasPrefix(update.Message.Text, "/") {
+		return false
+	}
+	return strings.HasPrefix(t.wizard.WaitingFlow(wizard.MessageKey(update.Message)), editFlowPrefix)
+}
+
+// HandleAnswer applies the new value of the field the sender is editing
+func (t *Telegram) HandleAnswer(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	message := update.Message
+	key := wizard.MessageKey(message)
+
+	unlock := t.wizard.Lock(key)
+	defer unlock()
+
+	session, err := t.wizard.Active(key)
+	if errors.Is(err, wizard.ErrExpired) {
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "⌛ The edit timed out. Tap ✏️ Edit in /ls to try again.")
+		return
+	}
+	if err != nil {
+		t.logger.Error(err, "Failed to get wizard session")
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "An error occurred. Please try again.")
+		return
+	}
+	if session == nil {
+		return
+	}
+
+	field, ok := edit.FieldByName(session.Step)
+	if !ok {
+		t.logger.Errorf(fmt.Errorf("unknown field %q", session.Step), "Invalid edit session")
+		if _, err := t.wizard.Cancel(key); err != nil {
+			t.logger.Error(err, "Failed to end wizard session")
+		}
+		return
+	}
+
+	if field.Secret {
+		// Delete the message holding the secret
+		_, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+			ChatID:    message.Chat.ID,
+			MessageID: message.ID,
+		})
+		if err != nil {
+			t.logger.Error(err, "Failed to delete message")
+		}
+		if message.Chat.Type != telegramMod.ChatTypePrivate {
+			wizard.Send(ctx, b, t.logger, message.Chat.ID, "⚠️ Never send API keys in a group. The message was deleted, but others may have seen it: revoke the key and send a new one to me in a private chat.")
+			return
+		}
+	}
+
+	kind := strings.TrimPrefix(session.Flow, editFlowPrefix)
+	before, err := t.editor.Values(kind, session.SubjectID)
+	if err != nil {
+		t.logger.Error(err, "Failed to get command")
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "An error occurred. Please try again.")
+		return
+	}
+
+	// A new endpoint URL clears the API key, which is asked next
+	info, err := t.editor.Apply(edit.Edit{
+		Kind:     kind,
+		ConfigID: session.SubjectID,
+		Actor:    audit.Actor{Platform: models.PlatformTelegram, UserID: strconv.FormatInt(message.From.ID, 10)},
+		Values:   map[string]string{field.Name: message.Text},
+		KeyLater: true,
+	})
+	if text, ok := edit.Describe(err); ok {
+		// Keep waiting for a valid value
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, text+"\nPlease try again, or send /cancel to stop.")
+		return
+	}
+	if err != nil {
+		t.logger.Errorf(err, "Failed to edit %s", field.Name)
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "Failed to save the change. Please try again.")
+		return
+	}
+
+	if field.Name == edit.FieldEndpointURL && strings.TrimSpace(message.Text) != before[edit.FieldEndpointURL] {
+		apiKey, _ := edit.FieldByName(edit.FieldAPIKey)
+		if _, err := t.wizard.Start(key, session.Flow, session.SubjectID, wizard.Step{Name: apiKey.Name}); err != nil {
+			t.logger.Error(err, "Failed to start edit")
+			wizard.Send(ctx, b, t.logger, message.Chat.ID, "Endpoint URL saved. Edit the API key from /ls before using the command.")
+			return
+		}
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "Endpoint URL saved. The new endpoint needs the API key again.\n"+apiKey.Prompt+"\nSend /cancel to stop.")
+		return
+	}
+
+	if _, err := t.wizard.Cancel(key); err != nil {
+		t.logger.Error(err, "Failed to end wizard session")
+	}
+	wizard.Send(ctx, b, t.logger, message.Chat.ID, edit.Summary(info))
+
+	scope, err := t.menus.ScopeOf(models.ConfigType(kind), session.SubjectID)
+	if err != nil {
+		t.logger.Errorf(err, "Failed to get the menus of %s config %s", kind, session.SubjectID)
+		return
+	}
+	t.syncMenu(ctx, b, scope)
+}
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sum/pkg/command/edit"
 	"sum/pkg/logger"
+	"sum/pkg/menu"
 	"sum/pkg/models"
+	"sum/pkg/permission"
 	"sum/pkg/repo"
+	"sum/pkg/repo/audit"
+	"sum/pkg/wizard"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/go-telegram/bot"
@@ -16,12 +21,22 @@ import (
 
 type Telegram struct {
 	repo   repo.Repository
+	editor *edit.Editor
+	guard  permission.IGuard
+	wizard wizard.IManager
+	menus  menu.ISyncer
 	logger logger.Logger
 }
 
-func NewTelegram(repo repo.Repository, logger logger.Logger) *Telegram {
+// NewTelegram creates the /ls handler. The answers of its edit flow are tracked by wizard per chat and user,
+// and menus updates the command menus when a command is edited or removed.
+func NewTelegram(repo repo.Repository, editor *edit.Editor, guard permission.IGuard, wizard wizard.IManager, menus menu.ISyncer, logger logger.Logger) *Telegram {
 	return &Telegram{
 		repo:   repo,
+		editor: editor,
+		guard:  guard,
+		wizard: wizard,
+		menus:  menus,
 		logger: logger,
 	}
 }
@@ -35,10 +50,15 @@ func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.U
 }
 
 func (t *Telegram) handleMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-	command := update.Message.Text
+	// The name may be addressed to the bot, as in /ls@bot server
+	fields := strings.Fields(update.Message.Text)
+	if len(fields) == 0 {
+		return
+	}
+	fields[0], _, _ = strings.Cut(fields[0], "@")
 	userID := getUserID(update)
 
-	switch command {
+	switch strings.Join(fields, " ") {
 	case "/ls":
 		t.listUserCommands(ctx, b, update, userID)
 	case "/ls server":
@@ -66,7 +86,7 @@ func (t *Telegram) listUserCommands(ctx context.Context, b *bot.Bot, update *tel
 		return
 	}
 
-	t.displayCommands(ctx, b, update, commands, "user")
+	t.displayCommands(ctx, b, update, commands, "user", true, true)
 }
 
 func (t *Telegram) listServers(ctx context.Context, b *bot.Bot, update *telegramMod.Update, userID int64) {
@@ -129,8 +149,9 @@ func (t *Telegram) listServerCommands(ctx context.Context, b *bot.Bot, update *t
 		return
 	}
 
-	hasPermission := isPermissable(b, update)
-	t.displayCommands(ctx, b, update, commands, "server", hasPermission)
+	canRemove := t.allowed(ctx, b, update, serverID, permission.ActionRemove)
+	canEdit := t.allowed(ctx, b, update, serverID, permission.ActionRegister)
+	t.displayCommands(ctx, b, update, commands, "server", canRemove, canEdit)
 }
 
 func (t *Telegram) handleCallbackQuery(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
@@ -154,6 +175,10 @@ func (t *Telegram) handleCallbackQuery(ctx context.Context, b *bot.Bot, update *
 		t.listServerCommandsCallback(ctx, b, update, idInt)
 	case "ls_remove_command":
 		t.removeCommand(ctx, b, update, id)
+	case "ls_edit":
+		t.showEditFields(ctx, b, update, id)
+	case "ls_field":
+		t.startEdit(ctx, b, update, id)
 	}
 }
 
@@ -170,11 +195,26 @@ func (t *Telegram) listServerCommandsCallback(ctx context.Context, b *bot.Bot, u
 		return
 	}
 
-	hasPermission := isPermissable(b, update)
-	t.displayCommands(ctx, b, update, commands, "server", hasPermission)
+	canRemove := t.allowed(ctx, b, update, serverID, permission.ActionRemove)
+	canEdit := t.allowed(ctx, b, update, serverID, permission.ActionRegister)
+	t.displayCommands(ctx, b, update, commands, "server", canRemove, canEdit)
 }
 
-func (t *Telegram) displayCommands(ctx context.Context, b *bot.Bot, update *telegramMod.Update, commands interface{}, commandType string, hasPermission ...bool) {
+// allowed reports whether the caller may perform action on the commands of the server
+func (t *Telegram) allowed(ctx context.Context, b *bot.Bot, update *telegramMod.Update, serverID int64, action permission.Action) bool {
+	allowed, err := permission.AllowedTelegram(ctx, b, t.guard, permission.Check{
+		Action:   action,
+		Platform: models.PlatformTelegram,
+		ServerID: serverID,
+	}, getUser(update), getChatID(update))
+	if err != nil {
+		t.logger.Errorf(err, "Failed to check %s permission", action)
+		return false
+	}
+	return allowed
+}
+
+func (t *Telegram) displayCommands(ctx context.Context, b *bot.Bot, update *telegramMod.Update, commands interface{}, commandType string, canRemove, canEdit bool) {
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 	var messageText string
 
@@ -185,26 +225,23 @@ func (t *Telegram) displayCommands(ctx context.Context, b *bot.Bot, update *tele
 	case "user":
 		userCommands := commands.([]models.UserAgentConfig)
 		for i, command := range userCommands {
-			messageText += formatCommandInfo(command.Command, command.Description)
+			messageText += formatCommandInfo(command.Command, command.Aliases, command.Description)
 			if i < len(userCommands)-1 {
 				messageText += "\n\\-\\-\\-\n\n"
 			}
-			removeCallbackData := fmt.Sprintf("ls_remove_command:user_%d", command.ID)
-			removeButton := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑️ Remove \"%s\"", command.Command), removeCallbackData)
-			keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(removeButton))
+			if row := commandButtons(fmt.Sprintf("user_%d", command.ID), command.Command, canRemove, canEdit); len(row) > 0 {
+				keyboard = append(keyboard, row)
+			}
 		}
 	case "server":
 		serverCommands := commands.([]models.ServerAdminConfig)
-		showRemoveButtons := len(hasPermission) > 0 && hasPermission[0]
 		for i, command := range serverCommands {
-			messageText += formatCommandInfo(command.Command, command.Description)
+			messageText += formatCommandInfo(command.Command, command.Aliases, command.Description)
 			if i < len(serverCommands)-1 {
 				messageText += "\n\\-\\-\\-\n\n"
 			}
-			if showRemoveButtons {
-				removeCallbackData := fmt.Sprintf("ls_remove_command:server_%d", command.ID)
-				removeButton := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑️ Remove \"%s\"", command.Command), removeCallbackData)
-				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(removeButton))
+			if row := commandButtons(fmt.Sprintf("server_%d", command.ID), command.Command, canRemove, canEdit); len(row) > 0 {
+				keyboard = append(keyboard, row)
 			}
 		}
 	}
@@ -214,12 +251,9 @@ func (t *Telegram) displayCommands(ctx context.Context, b *bot.Bot, update *tele
 	t.sendMessage(ctx, b, update, messageText, inlineKeyboard)
 }
 
+// removeCommand removes a user or server command. Server commands are checked
+// by the permission guard before the handler runs.
 func (t *Telegram) removeCommand(ctx context.Context, b *bot.Bot, update *telegramMod.Update, commandID string) {
-	if !isPermissable(b, update) {
-		t.sendErrorMessage(ctx, b, update, "You don't have permission to remove this command\\.")
-		return
-	}
-
 	parts := strings.SplitN(commandID, "_", 2)
 	if len(parts) != 2 {
 		return
@@ -227,12 +261,25 @@ func (t *Telegram) removeCommand(ctx context.Context, b *bot.Bot, update *telegr
 
 	commandType, id := parts[0], parts[1]
 
+	r := t.repo.As(audit.Actor{Platform: models.PlatformTelegram, UserID: fmt.Sprintf("%d", getUserID(update))})
+
+	if commandType == "user" && !t.ownsUserCommand(update, id) {
+		t.sendErrorMessage(ctx, b, update, "You don't have permission to remove this command\\.")
+		return
+	}
+
+	// The menus listing the command are found before it is removed
+	scope, scopeErr := t.menus.ScopeOf(models.ConfigType(commandType), id)
+	if scopeErr != nil {
+		t.logger.Errorf(scopeErr, "Failed to get the menus of %s config %s", commandType, id)
+	}
+
 	var err error
 	switch commandType {
 	case "user":
-		err = t.repo.UserConfig().RemoveByID(id)
+		err = r.UserConfig().RemoveByID(id)
 	case "server":
-		err = t.repo.ServerConfig().RemoveByID(id)
+		err = r.ServerConfig().RemoveByID(id)
 	}
 
 	if err != nil {
@@ -242,10 +289,49 @@ func (t *Telegram) removeCommand(ctx context.Context, b *bot.Bot, update *telegr
 	}
 
 	t.sendMessage(ctx, b, update, "Command removed successfully\\.")
+	t.syncMenu(ctx, b, scope)
 }
 
-func formatCommandInfo(command, description string) string {
-	return fmt.Sprintf("🤖 *Command:* `%s`\n📝 *Description:* %s", command, strings.ReplaceAll(description, "-", "\\-"))
+// syncMenu updates the command menus of scope, logging failures
+func (t *Telegram) syncMenu(ctx context.Context, b *bot.Bot, scope menu.Scope) {
+	if err := t.menus.Sync(ctx, b, scope); err != nil {
+		t.logger.Error(err, "Failed to sync command menus")
+	}
+}
+
+// ownsUserCommand reports whether the user config belongs to the caller
+func (t *Telegram) ownsUserCommand(update *telegramMod.Update, id string) bool {
+	user, err := t.repo.User().GetByPlatformID(fmt.Sprintf("%d", getUserID(update)), string(models.PlatformTelegram))
+	if err != nil {
+		return false
+	}
+
+	config, err := t.repo.UserConfig().GetByID(id)
+	if err != nil {
+		return false
+	}
+
+	return config.UserID == user.ID
+}
+
+// commandButtons returns the row of actions the caller may take on a command, identified as user_<id> or server_<id>
+func commandButtons(commandID, command string, canRemove, canEdit bool) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	if canEdit {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✏️ Edit \"%s\"", command), "ls_edit:"+commandID))
+	}
+	if canRemove {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑️ Remove \"%s\"", command), "ls_remove_command:"+commandID))
+	}
+	return row
+}
+
+func formatCommandInfo(command, aliases, description string) string {
+	info := fmt.Sprintf("🤖 *Command:* `%s`\n", command)
+	if names := models.SplitAliases(aliases); len(names) > 0 {
+		info += fmt.Sprintf("🔁 *Aliases:* `%s`\n", strings.Join(names, "`, `"))
+	}
+	return info + fmt.Sprintf("📝 *Description:* %s", strings.ReplaceAll(description, "-", "\\-"))
 }
 
 func (t Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string, replyMarkup ...interface{}) {
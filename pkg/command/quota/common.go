@@ -0,0 +1,10 @@
+package quota
+
+import "fmt"
+
+func formatLimit(count, limit int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d/∞", count)
+	}
+	return fmt.Sprintf("%d/%d", count, limit)
+}
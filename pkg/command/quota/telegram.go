@@ -0,0 +1,219 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/ratelimit"
+	"sum/pkg/repo"
+	"sum/pkg/repo/audit"
+	"time"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+const usageText = `Usage:
+/quota - Show today's and this month's usage of the group commands
+/quota reset <command> - Let a command run again up to its quotas, the recorded usage is kept
+/quota set <command> <daily|monthly|user> <limit> - Set a quota, 0 for unlimited`
+
+// Command describes /quota in help messages and command menus
+var Command = registry.Command{
+	Name:        "quota",
+	Usage:       "[reset|set ...]",
+	Description: "Show or set the quotas of the group commands",
+	Details:     usageText + "\n\nEveryone may read the usage, only admins may change quotas.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatGroup,
+}
+
+type Telegram struct {
+	repo    repo.Repository
+	limiter ratelimit.ILimiter
+	loc     *time.Location // Time zone of the quota days and months
+	logger  logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, cfg config.Config, limiter ratelimit.ILimiter, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:    repo,
+		limiter: limiter,
+		loc:     ratelimit.Location(cfg.RateLimit.Timezone),
+		logger:  logger,
+	}
+}
+
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+
+	if update.Message.Chat.Type == "private" {
+		t.sendMessage(ctx, b, update, "Quotas apply to group commands. Please use /quota in a group.")
+		return
+	}
+
+	server, err := t.repo.Server().GetByPlatformID(fmt.Sprintf("%d", update.Message.Chat.ID), string(models.PlatformTelegram))
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server")
+		t.sendMessage(ctx, b, update, "This group is not registered. Please use /reg server first.")
+		return
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) == 1 {
+		t.showUsage(ctx, b, update, server)
+		return
+	}
+
+	if !permission.IsTelegramAdmin(ctx, b, update.Message.Chat.ID, update.Message.From) {
+		t.sendMessage(ctx, b, update, "You don't have permission to change quotas.")
+		return
+	}
+
+	switch {
+	case parts[1] == "reset" && len(parts) == 3:
+		t.reset(ctx, b, update, server, parts[2])
+	case parts[1] == "set" && len(parts) == 5:
+		t.set(ctx, b, update, server, parts[2], parts[3], parts[4])
+	default:
+		t.sendMessage(ctx, b, update, usageText)
+	}
+}
+
+func (t *Telegram) showUsage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, server models.Server) {
+	configs, err := t.repo.ServerConfig().ListByServerID(server.ID)
+	if err != nil {
+		t.logger.Error(err, "Failed to retrieve server commands")
+		t.sendMessage(ctx, b, update, "Failed to retrieve server commands. Please try again.")
+		return
+	}
+
+	if len(configs) == 0 {
+		t.sendMessage(ctx, b, update, "No commands found for this server. Please use /reg server to set up commands.")
+		return
+	}
+
+	userID := fmt.Sprintf("%d", update.Message.From.ID)
+
+	// Counts and totals come from the recorded invocations, over the calendar day and
+	// month of the quota time zone
+	now := time.Now()
+	dayStart := ratelimit.DayStart(now, t.loc)
+	monthStart := ratelimit.MonthStart(now, t.loc)
+	summaries, err := t.repo.Invocation().SummarizeByServer(server.ServerID, string(models.PlatformTelegram), monthStart)
+	if err != nil {
+		t.logger.Error(err, "Failed to summarize server invocations")
+		t.sendMessage(ctx, b, update, "Failed to retrieve usage. Please try again.")
+		return
+	}
+	totals := make(map[string]models.InvocationSummary, len(summaries))
+	for _, s := range summaries {
+		totals[s.Command] = s
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 Command usage\n")
+	for _, config := range configs {
+		var daily, monthly, mine int
+		for _, c := range []struct {
+			count  *int
+			userID string
+			since  time.Time
+		}{
+			{&daily, "", dayStart},
+			{&monthly, "", monthStart},
+			{&mine, userID, dayStart},
+		} {
+			*c.count, err = t.repo.Invocation().CountByServerConfig(config.ID, c.userID, c.since)
+			if err != nil {
+				t.logger.Error(err, "Failed to count invocations")
+				t.sendMessage(ctx, b, update, "Failed to retrieve usage. Please try again.")
+				return
+			}
+		}
+
+		sb.WriteString(fmt.Sprintf("\n🤖 %s\n", config.Command))
+		sb.WriteString(fmt.Sprintf("   Today: %s\n", formatLimit(daily, config.DailyQuota)))
+		sb.WriteString(fmt.Sprintf("   This month: %s\n", formatLimit(monthly, config.MonthlyQuota)))
+		sb.WriteString(fmt.Sprintf("   You today: %s\n", formatLimit(mine, config.UserDailyQuota)))
+		if total, ok := totals[config.Command]; ok {
+			sb.WriteString(fmt.Sprintf("   Tokens this month: %d ($%.4f)\n", total.TotalTokens, total.Cost))
+		}
+	}
+
+	t.sendMessage(ctx, b, update, sb.String())
+}
+
+func (t *Telegram) reset(ctx context.Context, b *bot.Bot, update *telegramMod.Update, server models.Server, command string) {
+	config, err := t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, command)
+	if err != nil {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("Command '%s' not found.", command))
+		return
+	}
+
+	if err := t.limiter.Reset(config.ID); err != nil {
+		t.logger.Error(err, "Failed to reset quota usage")
+		t.sendMessage(ctx, b, update, "Failed to reset usage. Please try again.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, fmt.Sprintf("The quotas of '%s' start over, the recorded usage is kept.", command))
+}
+
+func (t *Telegram) set(ctx context.Context, b *bot.Bot, update *telegramMod.Update, server models.Server, command, period, value string) {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		t.sendMessage(ctx, b, update, "Limit must be a non-negative number.")
+		return
+	}
+
+	config, err := t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, command)
+	if err != nil {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("Command '%s' not found.", command))
+		return
+	}
+
+	id := fmt.Sprintf("%d", config.ID)
+	configs := t.repo.As(audit.Actor{Platform: models.PlatformTelegram, UserID: fmt.Sprintf("%d", update.Message.From.ID)}).ServerConfig()
+	switch period {
+	case "daily":
+		err = configs.SaveDailyQuota(id, limit)
+	case "monthly":
+		err = configs.SaveMonthlyQuota(id, limit)
+	case "user":
+		err = configs.SaveUserDailyQuota(id, limit)
+	default:
+		t.sendMessage(ctx, b, update, usageText)
+		return
+	}
+
+	if err != nil {
+		t.logger.Error(err, "Failed to save quota")
+		t.sendMessage(ctx, b, update, "Failed to save quota. Please try again.")
+		return
+	}
+
+	limitText := "unlimited"
+	if limit > 0 {
+		limitText = fmt.Sprintf("%d", limit)
+	}
+	t.sendMessage(ctx, b, update, fmt.Sprintf("The %s quota of '%s' is now %s.", period, command, limitText))
+}
+
+func (t Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
@@ -1,52 +1,91 @@
 package reg
 
 import (
-	"context"
 	"net/url"
+	"strconv"
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/permission"
 
-	"github.com/go-telegram/bot"
 	telegramMod "github.com/go-telegram/bot/models"
 )
 
+// Command describes /reg in help messages and command menus
+var Command = registry.Command{
+	Name:        "reg",
+	Usage:       "[server]",
+	Description: "Register an agent",
+	Details: `Usage:
+/reg - Register a personal agent command, set up in a private chat
+/reg server - Register an agent command for this group, in the group. In a private chat, lists the group configurations waiting to be set up
+
+The setup asks for the command name, the endpoint URL, the API key and a description. API keys are only accepted in a private chat.`,
+	Platforms: []models.PlatformType{models.PlatformTelegram, models.PlatformDiscord},
+	Chats:     registry.ChatAll,
+}
+
+// CancelCommand describes /cancel in help messages and command menus
+var CancelCommand = registry.Command{
+	Name:        "cancel",
+	Description: "Stop the current setup",
+	Details:     "Stops the /reg setup or /ls edit waiting for your answer in this chat. /reg continues a registration where you left off.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatPrivate,
+}
+
 func isValidURL(s string) bool {
 	_, err := url.ParseRequestURI(s)
 	return err == nil
 }
 
-func isPermissable(b *bot.Bot, update *telegramMod.Update) bool {
-	var fromID, chatID int64
-	if update.CallbackQuery != nil {
-		if update.CallbackQuery.Message.Message == nil {
-			return false
-		}
+func getUserID(update *telegramMod.Update) int64 {
+	if update.Message != nil {
+		return update.Message.From.ID
+	} else if update.CallbackQuery != nil {
+		return update.CallbackQuery.From.ID
+	}
+	return 0
+}
 
-		fromID = update.CallbackQuery.From.ID
-		chatID = update.CallbackQuery.Message.Message.Chat.ID
-	} else {
-		fromID = update.Message.From.ID
-		chatID = update.Message.Chat.ID
+// Permission returns the permission check of a /reg update. Registering a
+// server and setting up or removing its pending configs are checked, personal
+// registrations are not.
+func Permission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.Message != nil {
+		if strings.TrimSpace(update.Message.Text) != "/reg server" || update.Message.Chat.Type == "private" {
+			return permission.Check{}, false
+		}
+		return permission.TelegramCheck(update, permission.ActionRegister), true
 	}
 
-	if fromID == chatID {
-		return true
+	if update.CallbackQuery == nil {
+		return permission.Check{}, false
 	}
 
-	member, err := b.GetChatMember(context.Background(), &bot.GetChatMemberParams{
-		ChatID: chatID,
-		UserID: fromID,
-	})
-	if err != nil {
-		return false
+	parts := strings.SplitN(update.CallbackQuery.Data, ":", 2)
+	if len(parts) != 2 {
+		return permission.Check{}, false
 	}
 
-	return member.Type == telegramMod.ChatMemberTypeAdministrator || member.Type == telegramMod.ChatMemberTypeOwner
-}
+	var action permission.Action
+	switch parts[0] {
+	case "reg_setup_server", "reg_skip_server", "reg_url_server":
+		action = permission.ActionRegister
+	case "reg_remove_server":
+		action = permission.ActionRemove
+	default:
+		return permission.Check{}, false
+	}
 
-func getUserID(update *telegramMod.Update) int64 {
-	if update.Message != nil {
-		return update.Message.From.ID
-	} else if update.CallbackQuery != nil {
-		return update.CallbackQuery.From.ID
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return permission.Check{}, false
 	}
-	return 0
+
+	return permission.Check{
+		Action:   action,
+		Platform: models.PlatformTelegram,
+		ConfigID: id,
+	}, true
 }
@@ -4,21 +4,29 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sum/pkg/adapter/dify"
 	"sum/pkg/logger"
 	"sum/pkg/models"
+	"sum/pkg/permission"
 	"sum/pkg/repo"
+	"sum/pkg/repo/audit"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 type Discord struct {
 	repo   repo.Repository
+	dify   dify.DifyAdapter
+	guard  permission.IGuard
 	logger logger.Logger
 }
 
-func NewDiscord(repo repo.Repository, logger logger.Logger) *Discord {
+func NewDiscord(repo repo.Repository, dify dify.DifyAdapter, guard permission.IGuard, logger logger.Logger) *Discord {
 	return &Discord{
 		repo:   repo,
+		dify:   dify,
+		guard:  guard,
 		logger: logger,
 	}
 }
@@ -39,22 +47,16 @@ func (d *Discord) Info() *discordgo.ApplicationCommand {
 }
 
 func (d *Discord) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	// Check if the user is not the server owner
-	if i.Member.Permissions&discordgo.PermissionAdministrator == 0 {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "You must be a server administrator to use this command.",
-			},
-		})
+	if i.Type != discordgo.InteractionApplicationCommand {
 		return
 	}
 
-	if i.Type != discordgo.InteractionApplicationCommand {
+	if i.ApplicationCommandData().Name != "reg" {
 		return
 	}
 
-	if i.ApplicationCommandData().Name != "reg" {
+	if i.Member == nil {
+		d.respondWithError(s, i, "This command can only be used in a server.")
 		return
 	}
 
@@ -64,6 +66,12 @@ func (d *Discord) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		regType = "server"
 	}
 
+	// Check if the member may register server commands
+	if regType == "server" && !d.isAllowed(i, permission.ActionRegister) {
+		d.respondWithError(s, i, "You don't have permission to register server commands.")
+		return
+	}
+
 	// Create and show modal
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseModal,
@@ -95,6 +103,18 @@ func (d *Discord) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
 						},
 					},
 				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "skip_check",
+							Label:       "Skip endpoint check",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "Type \"skip\" if the agent is offline",
+							Required:    false,
+							MaxLength:   10,
+						},
+					},
+				},
 			},
 		},
 	})
@@ -123,19 +143,24 @@ func (d *Discord) HandleSubmit(s *discordgo.Session, i *discordgo.InteractionCre
 		return
 	}
 
-	var agentURL, apiToken string
-	if len(i.ModalSubmitData().Components) > 0 {
-		if row, ok := i.ModalSubmitData().Components[0].(*discordgo.ActionsRow); ok && len(row.Components) > 0 {
-			if input, ok := row.Components[0].(*discordgo.TextInput); ok {
-				agentURL = input.Value
-			}
+	var agentURL, apiToken, skipCheck string
+	for _, component := range i.ModalSubmitData().Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok || len(row.Components) == 0 {
+			continue
 		}
-	}
-	if len(i.ModalSubmitData().Components) > 1 {
-		if row, ok := i.ModalSubmitData().Components[1].(*discordgo.ActionsRow); ok && len(row.Components) > 0 {
-			if input, ok := row.Components[0].(*discordgo.TextInput); ok {
-				apiToken = input.Value
-			}
+		input, ok := row.Components[0].(*discordgo.TextInput)
+		if !ok {
+			continue
+		}
+
+		switch input.CustomID {
+		case "agent_url":
+			agentURL = input.Value
+		case "api_token":
+			apiToken = input.Value
+		case "skip_check":
+			skipCheck = input.Value
 		}
 	}
 
@@ -148,10 +173,60 @@ func (d *Discord) HandleSubmit(s *discordgo.Session, i *discordgo.InteractionCre
 	}
 
 	isServer := submitIDParts[2] == "server"
-	d.handleRegistration(s, i, agentURL, apiToken, isServer)
+	if isServer && !d.isAllowed(i, permission.ActionRegister) {
+		d.respondWithError(s, i, "You don't have permission to register server commands.")
+		return
+	}
+
+	// The endpoint probe may take longer than Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to defer registration response")
+		return
+	}
+
+	var (
+		verifiedAt *time.Time
+		summary    string
+	)
+	if agentURL != "" && apiToken != "" {
+		skip := strings.EqualFold(strings.TrimSpace(skipCheck), "skip")
+		var ok bool
+		summary, ok = d.verify(i, agentURL, apiToken, skip)
+		if !ok {
+			d.editResponse(s, i, summary+"\nPlease check the URL and token and run /reg again, or type \"skip\" in the last field if the agent is offline.")
+			return
+		}
+		now := time.Now()
+		verifiedAt = &now
+	}
+
+	d.handleRegistration(s, i, agentURL, apiToken, verifiedAt, summary, isServer)
 }
 
-func (d *Discord) handleRegistration(s *discordgo.Session, i *discordgo.InteractionCreate, agentURL, apiToken string, isServer bool) {
+// verify probes the endpoint unless skip is set, and returns the message describing the result
+func (d *Discord) verify(i *discordgo.InteractionCreate, agentURL, apiToken string, skip bool) (string, bool) {
+	if skip {
+		d.logger.Fields(logger.Fields{"user_id": i.Member.User.ID, "guild_id": i.GuildID}).Info("Endpoint probe skipped")
+		return "Endpoint check skipped. Requests will fail until the agent is reachable.", true
+	}
+
+	info, err := d.dify.Probe(agentURL, apiToken)
+	if err != nil {
+		d.logger.Fields(logger.Fields{"user_id": i.Member.User.ID, "guild_id": i.GuildID}).Warnf("Endpoint probe failed: %v", err)
+		return describeProbeError(err), false
+	}
+	return describeApp(info), true
+}
+
+// handleRegistration creates the user or server with its config and edits the
+// deferred response, prefixed with the summary of the endpoint check
+func (d *Discord) handleRegistration(s *discordgo.Session, i *discordgo.InteractionCreate, agentURL, apiToken string, verifiedAt *time.Time, summary string, isServer bool) {
 	user := models.User{
 		UserID:   i.Member.User.ID,
 		Username: i.Member.User.Username,
@@ -161,6 +236,7 @@ func (d *Discord) handleRegistration(s *discordgo.Session, i *discordgo.Interact
 	thisGuild, err := s.State.Guild(i.GuildID)
 	if err != nil {
 		d.logger.Error(err, "Failed to get guild state")
+		d.editResponse(s, i, "Error: Failed to read the server. Please try again.")
 		return
 	}
 
@@ -177,6 +253,7 @@ func (d *Discord) handleRegistration(s *discordgo.Session, i *discordgo.Interact
 				{
 					APIKey:      apiToken,
 					EndpointURL: agentURL,
+					VerifiedAt:  verifiedAt,
 				},
 			}
 		}
@@ -187,18 +264,42 @@ func (d *Discord) handleRegistration(s *discordgo.Session, i *discordgo.Interact
 			{
 				APIKey:      apiToken,
 				EndpointURL: agentURL,
+				VerifiedAt:  verifiedAt,
 			},
 		}
 	}
 
-	_, err = d.repo.User().Create(user)
+	_, err = d.repo.As(audit.Actor{Platform: models.PlatformDiscord, UserID: i.Member.User.ID}).User().Create(user)
 	if err != nil {
 		d.logger.Error(err, "Failed to create or update user/server with config")
-		d.respondWithError(s, i, fmt.Sprintf("Failed to register %s. Please try again.", map[bool]string{true: "server", false: "user"}[isServer]))
+		d.editResponse(s, i, fmt.Sprintf("Error: Failed to register %s. Please try again.", map[bool]string{true: "server", false: "user"}[isServer]))
 		return
 	}
 
-	d.respondWithSuccess(s, i, fmt.Sprintf("%s registered successfully!", map[bool]string{true: "Server", false: "User"}[isServer]))
+	message := fmt.Sprintf("%s registered successfully!", map[bool]string{true: "Server", false: "User"}[isServer])
+	if summary != "" {
+		message = summary + "\n" + message
+	}
+	d.editResponse(s, i, message)
+}
+
+func (d *Discord) isAllowed(i *discordgo.InteractionCreate, action permission.Action) bool {
+	target, err := d.guard.Resolve(permission.Check{
+		Action:   action,
+		Platform: models.PlatformDiscord,
+		ChatID:   i.GuildID,
+	})
+	if err != nil {
+		d.logger.Error(err, "Failed to resolve permission check")
+		return false
+	}
+
+	allowed, err := d.guard.Allowed(target, action, permission.DiscordSubject(i))
+	if err != nil {
+		d.logger.Error(err, "Failed to evaluate permission rules")
+		return false
+	}
+	return allowed
 }
 
 func (d *Discord) respondWithError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
@@ -211,12 +312,9 @@ func (d *Discord) respondWithError(s *discordgo.Session, i *discordgo.Interactio
 	})
 }
 
-func (d *Discord) respondWithSuccess(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: message,
-			Flags:   discordgo.MessageFlagsEphemeral,
-		},
-	})
+// editResponse replaces the deferred response of an interaction
+func (d *Discord) editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &message}); err != nil {
+		d.logger.Error(err, "Failed to edit interaction response")
+	}
 }
@@ -0,0 +1,159 @@
+package reg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sum/pkg/wizard"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// deepLinkPrefix is the /start payload prefix of the private chat links of the wizard
+const deepLinkPrefix = "reg_"
+
+// stepLink is the wizard step of a session waiting for its private chat link to be opened
+const stepLink = "link"
+
+// apiKeyPattern matches Dify API keys, to catch keys pasted in groups
+var apiKeyPattern = regexp.MustCompile(`\bapp-[A-Za-z0-9]{16,}\b`)
+
+// sendPrivateLink replies to a group message with a one-time link continuing the setup
+// of a config in a private chat, where the API key can be sent safely
+func (t *Telegram) sendPrivateLink(ctx context.Context, b *bot.Bot, message *telegramMod.Message, kind, id, text string) {
+	token, err := t.wizard.Link(wizard.TelegramKey(message.From.ID), flowOf(kind), id, wizard.Step{Name: stepLink})
+	if err != nil {
+		t.logger.Error(err, "Failed to create private chat link")
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "An error occurred. Please try again.")
+		return
+	}
+
+	url, err := t.botURL(ctx, b)
+	if err != nil {
+		t.logger.Error(err, "Failed to get bot username")
+		wizard.Send(ctx, b, t.logger, message.Chat.ID, "An error occurred. Please try again.")
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("🔒 Continue in private chat", url+"?start="+deepLinkPrefix+token),
+		),
+	)
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      message.Chat.ID,
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send private chat link")
+	}
+}
+
+// HandleDeepLink resumes the setup started in a group once its private chat link is opened
+func (t *Telegram) HandleDeepLink(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.From == nil || update.Message.Chat.Type != telegramMod.ChatTypePrivate {
+		return
+	}
+
+	chatID := update.Message.Chat.ID
+	token := strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/start")), deepLinkPrefix)
+
+	key := wizard.TelegramKey(update.Message.From.ID)
+	unlock := t.wizard.Lock(key)
+	defer unlock()
+
+	session, err := t.wizard.Redeem(key, token)
+	switch {
+	case errors.Is(err, wizard.ErrExpired):
+		wizard.Send(ctx, b, t.logger, chatID, "⌛ This link has expired. Send the /reg command in the group again.")
+		return
+	case errors.Is(err, wizard.ErrInvalidToken):
+		wizard.Send(ctx, b, t.logger, chatID, "This link is invalid or was already used. Send the /reg command in the group again.")
+		return
+	case err != nil:
+		t.logger.Error(err, "Failed to redeem private chat link")
+		wizard.Send(ctx, b, t.logger, chatID, "An error occurred. Please try again.")
+		return
+	}
+
+	t.handleSetup(ctx, b, update, kindOf(session.Flow), session.SubjectID)
+}
+
+// SecretInGroup reports whether a group message looks like an API key: it matches the
+// Dify key format, or is a single word sent while the wizard of its sender waits for an API key
+func (t *Telegram) SecretInGroup(update *telegramMod.Update) bool {
+	message := update.Message
+	if message == nil || message.From == nil || message.Chat.Type == telegramMod.ChatTypePrivate {
+		return false
+	}
+
+	text := strings.TrimSpace(message.Text)
+	if text == "" || strings.HasPrefix(text, "/") {
+		return false
+	}
+	if apiKeyPattern.MatchString(text) {
+		return true
+	}
+	if len(text) < 16 || strings.ContainsAny(text, " \n\t") {
+		return false
+	}
+
+	session, err := t.wizard.Active(wizard.TelegramKey(message.From.ID))
+	return err == nil && session != nil && session.Step == stepAPIKey
+}
+
+// HandleSecretInGroup deletes an API key pasted in a group and warns its sender
+func (t *Telegram) HandleSecretInGroup(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	t.refuseSecret(ctx, b, update.Message)
+}
+
+// refuseSecret deletes a message holding an API key sent outside of a private chat and warns its sender
+func (t *Telegram) refuseSecret(ctx context.Context, b *bot.Bot, message *telegramMod.Message) {
+	_, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+		ChatID:    message.Chat.ID,
+		MessageID: message.ID,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to delete message")
+	}
+
+	name := message.From.FirstName
+	if message.From.Username != "" {
+		name = "@" + message.From.Username
+	}
+	text := fmt.Sprintf("⚠️ %s, never send API keys in a group. The message was deleted, but others may have seen it: revoke the key and send a new one to me in a private chat.", name)
+
+	params := &bot.SendMessageParams{
+		ChatID: message.Chat.ID,
+		Text:   text,
+	}
+	if url, err := t.botURL(ctx, b); err == nil {
+		params.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("🔒 Open private chat", url)),
+		)
+	}
+	if _, err := b.SendMessage(ctx, params); err != nil {
+		t.logger.Error(err, "Failed to send API key warning")
+	}
+}
+
+// botURL returns the t.me URL of the bot
+func (t *Telegram) botURL(ctx context.Context, b *bot.Bot) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.username == "" {
+		me, err := b.GetMe(ctx)
+		if err != nil {
+			return "", err
+		}
+		t.username = me.Username
+	}
+	return "https://t.me/" + t.username, nil
+}
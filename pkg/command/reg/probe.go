@@ -0,0 +1,156 @@
+package reg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sum/pkg/adapter/dify"
+	"sum/pkg/logger"
+	"sum/pkg/outbound"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// Config kinds used in the callback data of the probe buttons
+const (
+	kindUser   = "user"
+	kindServer = "server"
+)
+
+// describeApp formats the result of a successful probe
+func describeApp(info *dify.AppInfo) string {
+	var sb strings.Builder
+	sb.WriteString("✅ Endpoint check passed.")
+	if info.Name != "" {
+		sb.WriteString("\nApp: " + info.Name)
+	}
+	if info.Mode != "" {
+		sb.WriteString("\nType: " + info.Mode)
+	}
+	if len(info.Capabilities) > 0 {
+		sb.WriteString("\nCapabilities: " + strings.Join(info.Capabilities, ", "))
+	}
+	return sb.String()
+}
+
+// describeProbeError explains why a probe failed
+func describeProbeError(err error) string {
+	if errors.Is(err, dify.ErrUnauthorized) {
+		return "❌ Endpoint check failed: the API key was rejected."
+	}
+	if errors.Is(err, outbound.ErrBlocked) {
+		return "❌ Endpoint check failed: the endpoint URL points to an address that isn't allowed."
+	}
+	return fmt.Sprintf("❌ Endpoint check failed: %v", err)
+}
+
+// verify probes the endpoint with the API key and reports the result to chatID, the
+// private chat of the user, whose ID it shares.
+// When the probe fails, the user may send another API key, change the
+// endpoint URL or skip the check for an offline agent.
+func (t *Telegram) verify(ctx context.Context, b *bot.Bot, chatID int64, kind, id, endpointURL, apiKey string) bool {
+	info, err := t.dify.Probe(endpointURL, apiKey)
+	if err != nil {
+		t.logger.Fields(logger.Fields{"config_id": id, "kind": kind}).Warnf("Endpoint probe failed: %v", err)
+
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Change URL", fmt.Sprintf("reg_url_%s:%s", kind, id)),
+				tgbotapi.NewInlineKeyboardButtonData("Skip check (offline agent)", fmt.Sprintf("reg_skip_%s:%s", kind, id)),
+			),
+		)
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        describeProbeError(err) + "\nSend the API key again, change the endpoint URL, or skip the check if the agent is offline.",
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			t.logger.Error(err, "Failed to send probe failure message")
+		}
+		return false
+	}
+
+	if err := t.markVerified(chatID, kind, id); err != nil {
+		t.logger.Error(err, "Failed to mark config as verified")
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "An error occurred. Please try again.",
+		})
+		if err != nil {
+			t.logger.Error(err, "Failed to send error message")
+		}
+		return false
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   describeApp(info),
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send probe result message")
+	}
+	return true
+}
+
+// verifyStored probes a config whose API key is already stored, then continues its setup
+func (t *Telegram) verifyStored(ctx context.Context, b *bot.Bot, update *telegramMod.Update, kind, id, endpointURL, encryptedAPIKey string) {
+	apiKey, err := t.repo.Secret().Decrypt(encryptedAPIKey, id)
+	if err != nil {
+		t.logger.Error(err, "Failed to decrypt API key")
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: getUserID(update),
+			Text:   "An error occurred. Please try again.",
+		})
+		if err != nil {
+			t.logger.Error(err, "Failed to send error message")
+		}
+		return
+	}
+
+	if !t.verify(ctx, b, getUserID(update), kind, id, endpointURL, apiKey) {
+		s, _ := stepByName(stepAPIKey)
+		t.ask(ctx, b, getUserID(update), kind, id, s)
+		return
+	}
+
+	t.continueSetup(ctx, b, update, kind, id)
+}
+
+// handleSkipProbe marks a config as verified without probing it, for agents that are offline during registration
+func (t *Telegram) handleSkipProbe(ctx context.Context, b *bot.Bot, update *telegramMod.Update, kind, id string) {
+	if err := t.markVerified(getUserID(update), kind, id); err != nil {
+		t.logger.Error(err, "Failed to mark config as verified")
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: getUserID(update),
+			Text:   "An error occurred. Please try again.",
+		})
+		if err != nil {
+			t.logger.Error(err, "Failed to send error message")
+		}
+		return
+	}
+
+	t.logger.Fields(logger.Fields{"config_id": id, "kind": kind}).Info("Endpoint probe skipped")
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: getUserID(update),
+		Text:   "Endpoint check skipped. Requests will fail until the agent is reachable.",
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send probe skipped message")
+	}
+
+	t.continueSetup(ctx, b, update, kind, id)
+}
+
+// continueSetup asks for the next missing field of a config once a step is done
+func (t *Telegram) continueSetup(ctx context.Context, b *bot.Bot, update *telegramMod.Update, kind, id string) {
+	t.setup(ctx, b, update, kind, id, "Setup complete.")
+}
+
+// markVerified records that a config passed the endpoint check, or that userID skipped it
+func (t *Telegram) markVerified(userID int64, kind, id string) error {
+	return t.configStore(userID, kind).MarkVerified(id)
+}
@@ -2,49 +2,57 @@ package reg
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sum/pkg/adapter/dify"
+	"sum/pkg/command/registry"
 	"sum/pkg/config"
 	"sum/pkg/logger"
+	"sum/pkg/menu"
 	"sum/pkg/models"
 	"sum/pkg/repo"
-	"sum/pkg/utils/encryptutils"
+	"sum/pkg/wizard"
+	"sync"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/go-telegram/bot"
 	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
 )
 
 type Telegram struct {
-	config        config.Config
-	repo          repo.Repository
-	logger        logger.Logger
-	lastHandlerID string
+	config   config.Config
+	repo     repo.Repository
+	dify     dify.DifyAdapter
+	wizard   wizard.IManager
+	menus    menu.ISyncer
+	commands registry.IRegistry // Built-in commands, configs can't take their names
+	logger   logger.Logger
+
+	mu       sync.Mutex
+	username string // Username of the bot, used in private chat links
 }
 
-func NewTelegram(repo repo.Repository, config config.Config, logger logger.Logger) *Telegram {
+// NewTelegram creates the /reg handler. The answers to its setup steps are
+// tracked by wizard per chat and user, and completed configs are listed in
+// the command menus by menus. Commands can't be named after the built-in commands.
+func NewTelegram(repo repo.Repository, config config.Config, dify dify.DifyAdapter, wizard wizard.IManager, menus menu.ISyncer, commands registry.IRegistry, logger logger.Logger) *Telegram {
 	return &Telegram{
-		repo:   repo,
-		config: config,
-		logger: logger,
+		repo:     repo,
+		config:   config,
+		dify:     dify,
+		wizard:   wizard,
+		menus:    menus,
+		commands: commands,
+		logger:   logger,
 	}
 }
 
+// Handle executes the /reg command and its callbacks. Permissions are checked
+// by the permission guard before the handler runs.
 func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-	b.UnregisterHandler(t.lastHandlerID)
-
-	if !isPermissable(b, update) {
-		t.logger.Warn("Unauthorized access attempt")
-		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "You don't have permission to perform this action.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send permission denied message")
-		}
-		return
-	}
-
 	if update.Message != nil && update.Message.Text != "" {
 		t.handleCommand(ctx, b, update)
 	} else if update.CallbackQuery != nil {
@@ -53,9 +61,14 @@ func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.U
 }
 
 func (t *Telegram) handleCommand(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-	command := update.Message.Text
+	// The name may be addressed to the bot, as in /reg@bot server
+	fields := strings.Fields(update.Message.Text)
+	if len(fields) == 0 {
+		return
+	}
+	fields[0], _, _ = strings.Cut(fields[0], "@")
 
-	switch command {
+	switch strings.Join(fields, " ") {
 	case "/reg":
 		t.handleUserRegistration(ctx, b, update)
 	case "/reg server":
@@ -75,506 +88,68 @@ func (t *Telegram) handleCallbackQuery(ctx context.Context, b *bot.Bot, update *
 
 	action, configID := parts[0], parts[1]
 
+	// Server configs are checked by the permission guard, user configs only answer their owner
+	if strings.HasSuffix(action, "_user") && !t.ownsUserConfig(update.CallbackQuery.From.ID, configID) {
+		wizard.Send(ctx, b, t.logger, update.CallbackQuery.From.ID, "You don't have permission to change this configuration.")
+		return
+	}
+
 	switch action {
 	case "reg_setup_user":
-		t.handleUserSetup(ctx, b, update, configID)
+		t.handleSetup(ctx, b, update, kindUser, configID)
 	case "reg_setup_server":
-		t.handleServerSetup(ctx, b, update, configID)
+		t.handleSetup(ctx, b, update, kindServer, configID)
 	case "reg_remove_user":
 		t.handleUserRemoval(ctx, b, update, configID)
 	case "reg_remove_server":
 		t.handleServerRemoval(ctx, b, update, configID)
+	case "reg_skip_user":
+		t.handleSkipProbe(ctx, b, update, kindUser, configID)
+	case "reg_skip_server":
+		t.handleSkipProbe(ctx, b, update, kindServer, configID)
+	case "reg_url_user":
+		t.changeEndpointURL(ctx, b, update, kindUser, configID)
+	case "reg_url_server":
+		t.changeEndpointURL(ctx, b, update, kindServer, configID)
 	}
 }
 
-func (t *Telegram) handleUserSetup(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	config, err := t.repo.UserConfig().GetByID(id)
+// ownsUserConfig reports whether the user config belongs to the Telegram user
+func (t *Telegram) ownsUserConfig(userID int64, id string) bool {
+	user, err := t.repo.User().GetByPlatformID(strconv.FormatInt(userID, 10), string(models.PlatformTelegram))
 	if err != nil {
-		t.logger.Error(err, "Failed to get user config")
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "An error occurred. Please try again.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
-		return
-	}
-
-	if config.Command == "" {
-		t.fillUserCommand(ctx, b, update, id)
-		return
+		return false
 	}
 
-	if config.EndpointURL == "" {
-		t.fillUserEndpointURL(ctx, b, update, id)
-		return
-	}
-
-	if config.APIKey == "" {
-		t.fillUserApiKey(ctx, b, update, id)
-		return
-	}
-
-	if config.Description == "" {
-		t.fillUserDescription(ctx, b, update, id)
-		return
-	}
-
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Setup is already complete.",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send setup complete message")
-	}
-}
-
-func (t *Telegram) fillUserCommand(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		command := update.Message.Text
-		err := t.repo.UserConfig().SaveCommand(id, command)
-		if err != nil {
-			t.logger.Error(err, "Failed to save user command")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Failed to save command. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "Command saved.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send command saved message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-		t.fillUserEndpointURL(ctx, b, update, id)
-	})
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Please enter the command for this configuration:",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send command prompt")
-	}
-}
-
-func (t *Telegram) fillUserEndpointURL(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		endpointURL := update.Message.Text
-		if !isValidURL(endpointURL) {
-			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Invalid endpoint URL. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send invalid URL message")
-			}
-			return
-		}
-
-		err := t.repo.UserConfig().SaveEndpointURL(id, endpointURL)
-		if err != nil {
-			t.logger.Error(err, "Failed to save user endpoint URL")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Failed to save endpoint URL. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "Endpoint URL saved.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send URL saved message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-		t.fillUserApiKey(ctx, b, update, id)
-	})
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Please enter the endpoint URL:",
-	})
+	config, err := t.repo.UserConfig().GetByID(id)
 	if err != nil {
-		t.logger.Error(err, "Failed to send endpoint URL prompt")
+		return false
 	}
+	return config.UserID == user.ID
 }
 
-func (t *Telegram) fillUserApiKey(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		apiKey := update.Message.Text
-
-		// Encrypt the API key before saving
-		encryptionKey, err := encryptutils.NewEncryptionKey(t.config.EncryptionKey)
-		if err != nil {
-			t.logger.Error(err, "Failed to create encryption key")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "An error occurred. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		encryptedAPIKey, err := encryptutils.EncryptAPIKey(encryptionKey, apiKey)
-		if err != nil {
-			t.logger.Error(err, "Failed to encrypt API key")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "An error occurred. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		err = t.repo.UserConfig().SaveAPIKey(id, encryptedAPIKey)
-		if err != nil {
-			t.logger.Error(err, "Failed to save user API key")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Failed to save API key. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "API key saved.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send API key saved message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-		t.fillUserDescription(ctx, b, update, id)
-
-		// delete update message
-		_, err = b.DeleteMessage(ctx, &bot.DeleteMessageParams{
-			ChatID:    update.Message.Chat.ID,
-			MessageID: update.Message.ID,
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to delete message")
-		}
-	})
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Please enter your API key:",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send API key prompt")
-	}
+// handleSetup continues the setup of a pending config from its first missing field
+func (t *Telegram) handleSetup(ctx context.Context, b *bot.Bot, update *telegramMod.Update, kind, id string) {
+	t.setup(ctx, b, update, kind, id, "Setup is already complete.")
 }
 
-func (t *Telegram) fillUserDescription(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		description := update.Message.Text
-		err := t.repo.UserConfig().SaveDescription(id, description)
-		if err != nil {
-			t.logger.Error(err, "Failed to save user description")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Failed to save description. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "Description saved. Setup complete.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send setup complete message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-	})
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Please enter a description for this configuration:",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send description prompt")
-	}
+// changeEndpointURL asks for a new endpoint URL after a failed endpoint check
+func (t *Telegram) changeEndpointURL(ctx context.Context, b *bot.Bot, update *telegramMod.Update, kind, id string) {
+	s, _ := stepByName(stepEndpointURL)
+	t.ask(ctx, b, getUserID(update), kind, id, s)
 }
 
-func (t *Telegram) handleServerSetup(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	config, err := t.repo.ServerConfig().GetByID(id)
-	if err != nil {
-		t.logger.Error(err, "Failed to get server config")
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "An error occurred. Please try again.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send error message")
-		}
-		return
+// handleUserRemoval removes a pending config of the caller, configs of other users are refused
+func (t *Telegram) handleUserRemoval(ctx context.Context, b *bot.Bot, update *telegramMod.Update, configID string) {
+	from := update.CallbackQuery.From.ID
+	user, err := t.repo.User().GetByPlatformID(strconv.FormatInt(from, 10), string(models.PlatformTelegram))
+	if err == nil {
+		err = t.repo.As(actor(from)).UserConfig().RemoveByIDForUser(configID, user.ID)
 	}
-
-	if config.Command == "" {
-		t.fillServerCommand(ctx, b, update, id)
-		return
-	}
-
-	if config.EndpointURL == "" {
-		t.fillServerApiUrl(ctx, b, update, id)
-		return
-	}
-
-	if config.APIKey == "" {
-		t.fillServerApiKey(ctx, b, update, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		wizard.Send(ctx, b, t.logger, from, "You don't have permission to remove this configuration.")
 		return
 	}
-
-	if config.Description == "" {
-		t.fillServerDescription(ctx, b, update, id)
-		return
-	}
-
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Setup is already complete.",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send setup complete message")
-	}
-}
-
-func (t *Telegram) fillServerCommand(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		command := update.Message.Text
-		err := t.repo.ServerConfig().SaveCommand(id, command)
-		if err != nil {
-			t.logger.Error(err, "Failed to save server command")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Failed to save command. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "Command saved.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send command saved message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-		t.fillServerApiUrl(ctx, b, update, id)
-	})
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Please enter the command for this server configuration:",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send command prompt")
-	}
-}
-
-func (t *Telegram) fillServerDescription(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		description := update.Message.Text
-		err := t.repo.ServerConfig().SaveDescription(id, description)
-		if err != nil {
-			t.logger.Error(err, "Failed to save config description")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: getUserID(update),
-				Text:   "Failed to save config description. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: getUserID(update),
-			Text:   "Description saved. Setup complete.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send setup complete message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-	})
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: getUserID(update),
-		Text:   "Please enter a description for this configuration:",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send description prompt")
-	}
-}
-
-func (t *Telegram) fillServerApiUrl(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		endpointURL := update.Message.Text
-		if !isValidURL(endpointURL) {
-			b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   "Invalid endpoint URL. Please try again.",
-			})
-			return
-		}
-
-		err := t.repo.ServerConfig().SaveEndpointURL(id, endpointURL)
-		if err != nil {
-			t.logger.Error(err, "Failed to save server endpoint URL")
-			b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   "Failed to save endpoint URL. Please try again.",
-			})
-			return
-		}
-
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "Endpoint URL saved.",
-		})
-
-		b.UnregisterHandler(t.lastHandlerID)
-		t.fillServerApiKey(ctx, b, update, id)
-	})
-
-	var chatID int64
-	if update.CallbackQuery != nil {
-		chatID = update.CallbackQuery.From.ID
-	} else if update.Message != nil {
-		chatID = update.Message.Chat.ID
-	}
-
-	b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: chatID,
-		Text:   "Please enter the endpoint URL:",
-	})
-}
-
-func (t *Telegram) fillServerApiKey(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
-	b.UnregisterHandler(t.lastHandlerID)
-	t.lastHandlerID = b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypeContains, func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-		apiKey := update.Message.Text
-
-		// Encrypt the API key before saving
-		encryptionKey, err := encryptutils.NewEncryptionKey(t.config.EncryptionKey)
-		if err != nil {
-			t.logger.Error(err, "Failed to create encryption key")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   "An error occurred. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		encryptedAPIKey, err := encryptutils.EncryptAPIKey(encryptionKey, apiKey)
-		if err != nil {
-			t.logger.Error(err, "Failed to encrypt API key")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   "An error occurred. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		err = t.repo.ServerConfig().SaveAPIKey(id, encryptedAPIKey)
-		if err != nil {
-			t.logger.Error(err, "Failed to save server API key")
-			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: update.Message.Chat.ID,
-				Text:   "Failed to save API key. Please try again.",
-			})
-			if err != nil {
-				t.logger.Error(err, "Failed to send error message")
-			}
-			return
-		}
-
-		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "API key saved.",
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send API key saved message")
-		}
-
-		b.UnregisterHandler(t.lastHandlerID)
-		t.fillServerDescription(ctx, b, update, id)
-
-		// delete update message
-		_, err = b.DeleteMessage(ctx, &bot.DeleteMessageParams{
-			ChatID:    update.Message.Chat.ID,
-			MessageID: update.Message.ID,
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to delete message")
-		}
-	})
-
-	var chatID int64
-	if update.CallbackQuery != nil {
-		chatID = update.CallbackQuery.From.ID
-	} else if update.Message != nil {
-		chatID = update.Message.Chat.ID
-	}
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: chatID,
-		Text:   "Please enter the secret token:",
-	})
-	if err != nil {
-		t.logger.Error(err, "Failed to send secret token prompt")
-	}
-}
-
-func (t *Telegram) handleUserRemoval(ctx context.Context, b *bot.Bot, update *telegramMod.Update, userID string) {
-	err := t.repo.UserConfig().RemoveByID(userID)
 	if err != nil {
 		t.logger.Error(err, "Failed to remove user config")
 		b.SendMessage(ctx, &bot.SendMessageParams{
@@ -591,7 +166,7 @@ func (t *Telegram) handleUserRemoval(ctx context.Context, b *bot.Bot, update *te
 }
 
 func (t *Telegram) handleServerRemoval(ctx context.Context, b *bot.Bot, update *telegramMod.Update, serverID string) {
-	err := t.repo.ServerConfig().RemoveByID(serverID)
+	err := t.repo.As(actor(update.CallbackQuery.From.ID)).ServerConfig().RemoveByID(serverID)
 	if err != nil {
 		t.logger.Error(err, "Failed to remove server config")
 		b.SendMessage(ctx, &bot.SendMessageParams{
@@ -611,7 +186,7 @@ func (t *Telegram) handleUserRegistration(ctx context.Context, b *bot.Bot, updat
 	chatID := update.Message.Chat.ID
 	userID := fmt.Sprintf("%d", update.Message.From.ID)
 
-	pendingConfigs, err := t.repo.UserConfig().ListPendingByUserID(userID)
+	pendingConfigs, err := t.repo.UserConfig().ListPendingByUserID(userID, string(models.PlatformTelegram))
 	if err != nil {
 		t.logger.Error(err, "Failed to get pending user configs")
 		b.SendMessage(ctx, &bot.SendMessageParams{
@@ -621,6 +196,22 @@ func (t *Telegram) handleUserRegistration(ctx context.Context, b *bot.Bot, updat
 		return
 	}
 
+	isDM := update.Message.Chat.Type == telegramMod.ChatTypePrivate
+
+	// The menu of the group lists the commands of its members once they use /reg there
+	if !isDM {
+		if err := t.menus.Track(ctx, b, strconv.FormatInt(chatID, 10), userID); err != nil {
+			t.logger.Error(err, "Failed to sync member menu")
+		}
+	}
+
+	// The API key must not be sent in a group, continue in a private chat
+	if len(pendingConfigs) > 0 && !isDM {
+		t.sendPrivateLink(ctx, b, update.Message, kindUser, strconv.FormatInt(pendingConfigs[0].ID, 10),
+			"You have a pending configuration. Please complete it in a private chat:")
+		return
+	}
+
 	if len(pendingConfigs) > 0 {
 		// Prepare inline keyboard with pending configurations
 		var keyboard [][]tgbotapi.InlineKeyboardButton
@@ -654,7 +245,7 @@ func (t *Telegram) handleUserRegistration(ctx context.Context, b *bot.Bot, updat
 		},
 	}
 
-	user, err = t.repo.User().Create(user)
+	user, err = t.repo.As(actor(update.Message.From.ID)).User().Create(user)
 	if err != nil {
 		t.logger.Error(err, "Failed to create user")
 		b.SendMessage(ctx, &bot.SendMessageParams{
@@ -664,6 +255,12 @@ func (t *Telegram) handleUserRegistration(ctx context.Context, b *bot.Bot, updat
 		return
 	}
 
+	if !isDM {
+		t.sendPrivateLink(ctx, b, update.Message, kindUser, strconv.FormatInt(user.UserAgentConfigs[0].ID, 10),
+			"User registered successfully. Please complete your configuration in a private chat:")
+		return
+	}
+
 	// Create inline keyboard for user configuration
 	setupCallbackData := fmt.Sprintf("reg_setup_user:%d", user.UserAgentConfigs[0].ID)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -702,7 +299,7 @@ func (t *Telegram) handleServerRegistration(ctx context.Context, b *bot.Bot, upd
 				},
 			},
 		}
-		_, err = t.repo.User().Create(user)
+		user, err = t.repo.As(actor(update.Message.From.ID)).User().Create(user)
 		if err != nil {
 			t.logger.Error(err, "Failed to create server")
 			b.SendMessage(ctx, &bot.SendMessageParams{
@@ -711,14 +308,15 @@ func (t *Telegram) handleServerRegistration(ctx context.Context, b *bot.Bot, upd
 			})
 			return
 		}
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: chatID,
-			Text:   "Server configuration has been initiated successfully for this chat.",
-		})
+
+		// The API key must not be sent in the group, continue in a private chat
+		t.sendPrivateLink(ctx, b, update.Message, kindServer, strconv.FormatInt(user.Servers[0].ServerAdminConfig[0].ID, 10),
+			"Server configuration has been initiated successfully for this chat. Please complete it in a private chat:")
+		return
 	}
 
 	// Get pending configurations
-	pendingConfigs, err := t.repo.ServerConfig().ListPendingByUserID(userID)
+	pendingConfigs, err := t.repo.ServerConfig().ListPendingByUserID(userID, string(models.PlatformTelegram))
 	if err != nil {
 		t.logger.Error(err, "Failed to get pending servers")
 		b.SendMessage(ctx, &bot.SendMessageParams{
@@ -728,7 +326,7 @@ func (t *Telegram) handleServerRegistration(ctx context.Context, b *bot.Bot, upd
 		return
 	}
 
-	if len(pendingConfigs) == 0 && isDM {
+	if len(pendingConfigs) == 0 {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: chatID,
 			Text:   "You have no pending server configurations. If you want to setup a new server, please move to the server chat and type the command `/reg server`.",
@@ -750,13 +348,21 @@ func (t *Telegram) handleServerRegistration(ctx context.Context, b *bot.Bot, upd
 	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(keyboard...)
 
 	// Send setup message with inline keyboard
-	recipientID := chatID
-	if !isDM {
-		recipientID = update.Message.From.ID
-	}
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		Text:        "Please select a server to complete its configuration or remove it:",
-		ChatID:      recipientID,
+		ChatID:      chatID,
 		ReplyMarkup: inlineKeyboard,
 	})
 }
+
+// syncMenu updates the command menus listing a config, logging failures
+func (t *Telegram) syncMenu(ctx context.Context, b *bot.Bot, kind, id string) {
+	scope, err := t.menus.ScopeOf(models.ConfigType(kind), id)
+	if err != nil {
+		t.logger.Errorf(err, "Failed to get the menus of %s config %s", kind, id)
+		return
+	}
+	if err := t.menus.Sync(ctx, b, scope); err != nil {
+		t.logger.Error(err, "Failed to sync command menus")
+	}
+}
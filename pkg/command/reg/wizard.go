@@ -0,0 +1,309 @@
+package reg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sum/pkg/command/edit"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/repo/audit"
+	"sum/pkg/wizard"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// Steps of the registration wizard, stored in the wizard session
+const (
+	stepCommand     = "command"
+	stepEndpointURL = "endpoint_url"
+	stepAPIKey      = "api_key"
+	stepDescription = "description"
+)
+
+// configFields are the fields of a user or server config filled by the wizard
+type configFields struct {
+	Command     string
+	EndpointURL string
+	APIKey      string
+	Description string
+	VerifiedAt  *time.Time
+}
+
+// configStore saves the fields of a user or server config
+type configStore interface {
+	SaveCommand(id string, command string) error
+	SaveEndpointURL(id string, endpointURL string) error
+	SaveAPIKey(id string, apiKey string) error
+	SaveDescription(id string, description string) error
+	MarkVerified(id string) error
+}
+
+// step is a registration wizard step asking for one config field
+type step struct {
+	wizard.Step
+	prompt  string                                                // Question sent when the step starts
+	saved   string                                                // Reply once the answer is saved
+	invalid string                                                // Reply when valid rejects the answer
+	secret  bool                                                  // The answer is deleted from the chat and checked against the endpoint
+	missing func(f configFields) bool                             // Whether the step still needs an answer
+	valid   func(commands registry.IRegistry, answer string) bool // Optional check of the answer before it is saved
+	save    func(s configStore, id, answer string) error          // Saves the answer to the config
+}
+
+// steps are the registration wizard steps, in the order they are asked
+var steps = []step{
+	{
+		Step:    wizard.Step{Name: stepCommand},
+		prompt:  "Please enter the command for this configuration:",
+		saved:   "Command saved.",
+		invalid: "The command name must be a single word of at most 32 characters, other than a built-in command like /ai or /help. Please try again.",
+		missing: func(f configFields) bool { return f.Command == "" },
+		valid:   edit.ValidCommandName,
+		save:    func(s configStore, id, answer string) error { return s.SaveCommand(id, answer) },
+	},
+	{
+		Step:    wizard.Step{Name: stepEndpointURL},
+		prompt:  "Please enter the endpoint URL:",
+		saved:   "Endpoint URL saved.",
+		invalid: "Invalid endpoint URL. Please try again.",
+		missing: func(f configFields) bool { return f.EndpointURL == "" },
+		valid:   func(_ registry.IRegistry, answer string) bool { return isValidURL(answer) },
+		save:    func(s configStore, id, answer string) error { return s.SaveEndpointURL(id, answer) },
+	},
+	{
+		Step:    wizard.Step{Name: stepAPIKey},
+		prompt:  "Please enter your API key:",
+		saved:   "API key saved.",
+		secret:  true,
+		missing: func(f configFields) bool { return f.APIKey == "" },
+		save:    func(s configStore, id, answer string) error { return s.SaveAPIKey(id, answer) },
+	},
+	{
+		Step:    wizard.Step{Name: stepDescription},
+		prompt:  "Please enter a description for this configuration:",
+		saved:   "Description saved.",
+		missing: func(f configFields) bool { return f.Description == "" },
+		save:    func(s configStore, id, answer string) error { return s.SaveDescription(id, answer) },
+	},
+}
+
+// stepByName returns the wizard step named name
+func stepByName(name string) (step, bool) {
+	for _, s := range steps {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return step{}, false
+}
+
+// flowPrefix is the prefix of the registration wizard flows
+const flowPrefix = "reg_"
+
+// flowOf returns the wizard flow filling configs of kind
+func flowOf(kind string) string {
+	return flowPrefix + kind
+}
+
+// kindOf returns the config kind filled by a wizard flow
+func kindOf(flow string) string {
+	return strings.TrimPrefix(flow, flowPrefix)
+}
+
+// Waiting reports whether update answers a registration wizard step. Commands are never answers.
+func (t *Telegram) Waiting(update *telegramMod.Update) bool {
+	if update.Message == nil || update.Message.From == nil || update.Message.Text == "" {
+		return false
+	}
+	if strings.HasPrefix(update.Message.Text, "/") {
+		return false
+	}
+	return strings.HasPrefix(t.wizard.WaitingFlow(wizard.MessageKey(update.Message)), flowPrefix)
+}
+
+// HandleAnswer saves the answer to the current wizard step of the sender, then asks the next one
+func (t *Telegram) HandleAnswer(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	key := wizard.MessageKey(update.Message)
+	chatID := update.Message.Chat.ID
+
+	// Answers of one conversation are handled in order, other conversations aren't held up
+	unlock := t.wizard.Lock(key)
+	defer unlock()
+
+	session, err := t.wizard.Active(key)
+	if errors.Is(err, wizard.ErrExpired) {
+		wizard.Send(ctx, b, t.logger, chatID, "⌛ The registration step timed out. Send /reg to continue where you left off.")
+		return
+	}
+	if err != nil {
+		t.logger.Error(err, "Failed to get wizard session")
+		wizard.Send(ctx, b, t.logger, chatID, "An error occurred. Please try again.")
+		return
+	}
+	if session == nil {
+		return
+	}
+
+	s, ok := stepByName(session.Step)
+	if !ok {
+		t.logger.Errorf(fmt.Errorf("unknown step %q", session.Step), "Invalid registration wizard session")
+		t.cancel(key)
+		return
+	}
+
+	kind, id, answer := kindOf(session.Flow), session.SubjectID, update.Message.Text
+
+	if s.secret && update.Message.Chat.Type != telegramMod.ChatTypePrivate {
+		t.refuseSecret(ctx, b, update.Message)
+		return
+	}
+
+	if s.secret {
+		// Delete the message holding the secret
+		_, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+			ChatID:    chatID,
+			MessageID: update.Message.ID,
+		})
+		if err != nil {
+			t.logger.Error(err, "Failed to delete message")
+		}
+	}
+
+	if s.valid != nil && !s.valid(t.commands, answer) {
+		wizard.Send(ctx, b, t.logger, chatID, s.invalid)
+		return
+	}
+
+	// The repository encrypts the API key before saving
+	if err := s.save(t.configStore(update.Message.From.ID, kind), id, answer); err != nil {
+		t.logger.Errorf(err, "Failed to save %s %s", kind, s.Name)
+		wizard.Send(ctx, b, t.logger, chatID, "Failed to save your answer. Please try again.")
+		return
+	}
+	wizard.Send(ctx, b, t.logger, chatID, s.saved)
+
+	if s.secret {
+		fields, err := t.loadFields(kind, id)
+		if err != nil {
+			t.logger.Errorf(err, "Failed to get %s config", kind)
+			wizard.Send(ctx, b, t.logger, chatID, "An error occurred. Please try again.")
+			return
+		}
+
+		// Keep waiting for another API key until the endpoint accepts one
+		if !t.verify(ctx, b, chatID, kind, id, fields.EndpointURL, answer) {
+			if _, err := t.wizard.Start(key, session.Flow, id, s.Step); err != nil {
+				t.logger.Error(err, "Failed to restart wizard step")
+			}
+			return
+		}
+	}
+
+	t.continueSetup(ctx, b, update, kind, id)
+}
+
+// HandleCancel ends the wizard of the sender in the chat, registration or /ls edit
+func (t *Telegram) HandleCancel(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+
+	key := wizard.MessageKey(update.Message)
+	unlock := t.wizard.Lock(key)
+	defer unlock()
+
+	// The /ls edit flow shares the wizard sessions and is cancelled the same way
+	registration := strings.HasPrefix(t.wizard.WaitingFlow(key), flowPrefix)
+
+	cancelled, err := t.wizard.Cancel(key)
+	if err != nil {
+		t.logger.Error(err, "Failed to cancel wizard session")
+		wizard.Send(ctx, b, t.logger, update.Message.Chat.ID, "An error occurred. Please try again.")
+		return
+	}
+
+	if !cancelled {
+		wizard.Send(ctx, b, t.logger, update.Message.Chat.ID, "There is nothing to cancel.")
+		return
+	}
+	if !registration {
+		wizard.Send(ctx, b, t.logger, update.Message.Chat.ID, "Cancelled.")
+		return
+	}
+	wizard.Send(ctx, b, t.logger, update.Message.Chat.ID, "Registration cancelled. Send /reg to continue where you left off.")
+}
+
+// setup asks for the first missing field of a config, or sends done once every field is filled
+func (t *Telegram) setup(ctx context.Context, b *bot.Bot, update *telegramMod.Update, kind, id, done string) {
+	userID := getUserID(update)
+
+	fields, err := t.loadFields(kind, id)
+	if err != nil {
+		t.logger.Errorf(err, "Failed to get %s config", kind)
+		wizard.Send(ctx, b, t.logger, userID, "An error occurred. Please try again.")
+		return
+	}
+
+	for _, s := range steps {
+		if s.missing(fields) {
+			t.ask(ctx, b, userID, kind, id, s)
+			return
+		}
+		if s.secret && fields.VerifiedAt == nil {
+			t.verifyStored(ctx, b, update, kind, id, fields.EndpointURL, fields.APIKey)
+			return
+		}
+	}
+
+	t.cancel(wizard.TelegramKey(userID))
+	wizard.Send(ctx, b, t.logger, userID, done)
+	t.syncMenu(ctx, b, kind, id)
+}
+
+// ask starts waiting for the answer to a wizard step and sends its prompt
+func (t *Telegram) ask(ctx context.Context, b *bot.Bot, userID int64, kind, id string, s step) {
+	if _, err := t.wizard.Start(wizard.TelegramKey(userID), flowOf(kind), id, s.Step); err != nil {
+		t.logger.Error(err, "Failed to start wizard step")
+		wizard.Send(ctx, b, t.logger, userID, "An error occurred. Please try again.")
+		return
+	}
+
+	wizard.Send(ctx, b, t.logger, userID, s.prompt+"\nSend /cancel to stop.")
+}
+
+// cancel ends a conversation, logging failures
+func (t *Telegram) cancel(key wizard.Key) {
+	if _, err := t.wizard.Cancel(key); err != nil {
+		t.logger.Error(err, "Failed to end wizard session")
+	}
+}
+
+// loadFields returns the wizard fields of a user or server config
+func (t *Telegram) loadFields(kind, id string) (configFields, error) {
+	if kind == kindUser {
+		c, err := t.repo.UserConfig().GetByID(id)
+		return configFields{c.Command, c.EndpointURL, c.APIKey, c.Description, c.VerifiedAt}, err
+	}
+	c, err := t.repo.ServerConfig().GetByID(id)
+	return configFields{c.Command, c.EndpointURL, c.APIKey, c.Description, c.VerifiedAt}, err
+}
+
+// configStore returns the repository saving configs of kind on behalf of userID
+func (t *Telegram) configStore(userID int64, kind string) configStore {
+	r := t.repo.As(actor(userID))
+	if kind == kindUser {
+		return r.UserConfig()
+	}
+	return r.ServerConfig()
+}
+
+// actor returns the audit log actor of a Telegram user
+func actor(userID int64) audit.Actor {
+	return audit.Actor{Platform: models.PlatformTelegram, UserID: strconv.FormatInt(userID, 10)}
+}
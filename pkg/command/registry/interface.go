@@ -0,0 +1,69 @@
+// Package registry describes the built-in commands, so that help messages and command
+// menus list the commands that are enabled rather than a fixed text.
+package registry
+
+import (
+	"slices"
+
+	"sum/pkg/models"
+	"sum/pkg/permission"
+)
+
+// Chat is a kind of chat where a command is available
+type Chat int
+
+const (
+	ChatPrivate Chat = 1 << iota // Private chats with the bot
+	ChatGroup                    // Groups, Discord servers
+	ChatAll     = ChatPrivate | ChatGroup
+)
+
+// Access restricts who may use a command in a group, private chats belong to the caller
+type Access int
+
+const (
+	AccessEveryone Access = iota // Everyone in the group
+	AccessAdmin                  // The administrators of the group
+	AccessOwner                  // The user who registered the group
+)
+
+// Command describes a built-in command
+type Command struct {
+	Name        string
+	Usage       string // Arguments after the name, e.g. "[server]"
+	Description string // One line, shown in command lists and menus
+	Details     string // Detailed usage, shown by /help <command>
+	Platforms   []models.PlatformType
+	Chats       Chat
+	Access      Access
+	Action      permission.Action // Checked against the rules of a group, empty when the command isn't guarded
+}
+
+// AvailableOn reports whether the command is available on the platform
+func (c Command) AvailableOn(platform models.PlatformType) bool {
+	return slices.Contains(c.Platforms, platform)
+}
+
+// AvailableIn reports whether the command is available in a private chat or in a group
+func (c Command) AvailableIn(private bool) bool {
+	if private {
+		return c.Chats&ChatPrivate != 0
+	}
+	return c.Chats&ChatGroup != 0
+}
+
+// IRegistry defines the interface for enabling built-in commands and listing them
+type IRegistry interface {
+	// Enable records that commands are registered on the platform. Commands not available
+	// on the platform, or already enabled, are ignored.
+	Enable(platform models.PlatformType, commands ...Command)
+	// Commands returns the commands enabled on the platform, in the order they were enabled
+	Commands(platform models.PlatformType) []Command
+	// Lookup returns the command enabled on the platform under name
+	Lookup(platform models.PlatformType, name string) (Command, bool)
+	// Reserve keeps configs from taking names that aren't commands but are parsed as
+	// words of one, e.g. /ai compare
+	Reserve(names ...string)
+	// Reserved reports whether name is the name of a built-in command, enabled or not, or a reserved word
+	Reserved(name string) bool
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"strings"
+	"sync"
+
+	"sum/pkg/models"
+)
+
+type registry struct {
+	mu       sync.RWMutex
+	commands map[models.PlatformType][]Command
+	reserved map[string]bool // Names configs can't take
+}
+
+// New creates a registry of the built-in commands. Their names are reserved on every
+// platform whether they are enabled or not, so configs keep their names across platforms
+// and aren't shadowed when a command is enabled later. Commands are listed once enabled.
+func New(builtins ...Command) IRegistry {
+	r := &registry{
+		commands: map[models.PlatformType][]Command{},
+		reserved: map[string]bool{},
+	}
+	for _, c := range builtins {
+		r.reserved[c.Name] = true
+	}
+	return r
+}
+
+func (r *registry) Enable(platform models.PlatformType, commands ...Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range commands {
+		r.reserved[c.Name] = true
+		if !c.AvailableOn(platform) || r.find(platform, c.Name) >= 0 {
+			continue
+		}
+		r.commands[platform] = append(r.commands[platform], c)
+	}
+}
+
+func (r *registry) Commands(platform models.PlatformType) []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Command(nil), r.commands[platform]...)
+}
+
+func (r *registry) Lookup(platform models.PlatformType, name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.find(platform, normalize(name))
+	if i < 0 {
+		return Command{}, false
+	}
+	return r.commands[platform][i], true
+}
+
+func (r *registry) Reserve(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		r.reserved[normalize(name)] = true
+	}
+}
+
+func (r *registry) Reserved(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.reserved[normalize(name)]
+}
+
+// find returns the index of the command enabled on the platform under name, or -1
+func (r *registry) find(platform models.PlatformType, name string) int {
+	for i, c := range r.commands[platform] {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalize returns a command name without its leading slash, in lower case
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "/"))
+}
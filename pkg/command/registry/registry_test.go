@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"testing"
+
+	"sum/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserved(t *testing.T) {
+	sum := Command{Name: "sum", Platforms: []models.PlatformType{models.PlatformTelegram}, Chats: ChatAll}
+	r := New(sum)
+
+	// Built-in commands are reserved before they are enabled, on every platform
+	assert.True(t, r.Reserved("sum"))
+	assert.True(t, r.Reserved("/SUM"))
+	assert.False(t, r.Reserved("ask"))
+	assert.Empty(t, r.Commands(models.PlatformTelegram))
+
+	r.Enable(models.PlatformDiscord, sum, Command{Name: "extra", Platforms: []models.PlatformType{models.PlatformDiscord}})
+	assert.True(t, r.Reserved("extra"))
+	assert.Empty(t, r.Commands(models.PlatformTelegram))
+	assert.Len(t, r.Commands(models.PlatformDiscord), 1)
+
+	// Reserved words aren't listed
+	r.Reserve("Compare")
+	assert.True(t, r.Reserved("compare"))
+	assert.Len(t, r.Commands(models.PlatformDiscord), 1)
+
+	// Registries don't share their names
+	assert.False(t, New().Reserved("extra"))
+}
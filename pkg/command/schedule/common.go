@@ -0,0 +1,63 @@
+package schedule
+
+import (
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+const usageText = `Usage:
+/schedule - List the schedules posting to this chat
+/schedule add <cron> <timezone> <command> <prompt> - Run a command on a schedule and post its answer here
+/schedule rm <schedule-id> - Remove a schedule
+
+<cron> is five fields, minute hour day month weekday, or @hourly, @daily, @weekly, @monthly or @yearly.
+<timezone> is an IANA time zone such as Europe/Berlin or UTC.
+Example: /schedule add 0 9 * * mon-fri Europe/Berlin news_digest What happened yesterday?`
+
+// Command describes /schedule in help messages and command menus
+var Command = registry.Command{
+	Name:        "schedule",
+	Usage:       "[add|rm ...]",
+	Description: "Run commands on a schedule",
+	Details:     usageText + "\n\nIn a group, adding a schedule needs the register permission of the group and removing one the remove permission.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatAll,
+}
+
+// Permission returns the permission check of a /schedule update. Adding and removing
+// the schedules of a group are checked, listing them and private schedules are not.
+func Permission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.Message == nil || update.Message.Chat.Type == "private" {
+		return permission.Check{}, false
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) < 2 {
+		return permission.Check{}, false
+	}
+
+	switch parts[1] {
+	case "add":
+		return permission.TelegramCheck(update, permission.ActionRegister), true
+	case "rm":
+		return permission.TelegramCheck(update, permission.ActionRemove), true
+	}
+	return permission.Check{}, false
+}
+
+// skipFields returns s without its first n fields
+func skipFields(s string, n int) string {
+	for i := 0; i < n; i++ {
+		s = strings.TrimSpace(s)
+		end := strings.IndexAny(s, " \t\n")
+		if end < 0 {
+			return ""
+		}
+		s = s[end:]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,225 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+	"sum/pkg/scheduler"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// maxSchedules bounds the schedules posting to a chat
+const maxSchedules = 20
+
+// maxPromptPreview bounds the prompt shown when listing schedules
+const maxPromptPreview = 60
+
+type Telegram struct {
+	repo   repo.Repository
+	logger logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Handle executes the /schedule command. Schedules post to the chat they were added
+// in, and run a command of the group or of the caller like /ai does.
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.Text == "" || update.Message.From == nil {
+		return
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	switch {
+	case len(parts) == 1 || parts[1] == "ls":
+		t.listSchedules(ctx, b, update)
+	case parts[1] == "add":
+		t.addSchedule(ctx, b, update, skipFields(update.Message.Text, 2))
+	case parts[1] == "rm" && len(parts) == 3:
+		t.removeSchedule(ctx, b, update, parts[2])
+	default:
+		t.sendMessage(ctx, b, update, usageText)
+	}
+}
+
+func (t *Telegram) listSchedules(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	schedules, err := t.repo.Schedule().ListByChatID(models.PlatformTelegram, chatID(update))
+	if err != nil {
+		t.logger.Error(err, "Failed to list schedules")
+		t.sendMessage(ctx, b, update, "Failed to retrieve schedules. Please try again.")
+		return
+	}
+
+	if len(schedules) == 0 {
+		t.sendMessage(ctx, b, update, "No schedules post to this chat.\n\n"+usageText)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🗓 Schedules\n\n")
+	for _, s := range schedules {
+		sb.WriteString(fmt.Sprintf("#%d: /%s at %s (%s), next %s\n   %s\n", s.ID, s.Command, s.Cron, s.Timezone, formatRun(s.NextRunAt, s.Timezone), preview(s.Prompt)))
+	}
+
+	t.sendMessage(ctx, b, update, sb.String())
+}
+
+func (t *Telegram) addSchedule(ctx context.Context, b *bot.Bot, update *telegramMod.Update, args string) {
+	cron, timezone, command, prompt, ok := parseAdd(args)
+	if !ok {
+		t.sendMessage(ctx, b, update, usageText)
+		return
+	}
+
+	next, err := scheduler.NextRun(cron, timezone, time.Now())
+	if err != nil {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("Invalid schedule: %v", err))
+		return
+	}
+
+	count, err := t.repo.Schedule().CountByChatID(models.PlatformTelegram, chatID(update))
+	if err != nil {
+		t.logger.Error(err, "Failed to count schedules")
+		t.sendMessage(ctx, b, update, "Failed to save schedule. Please try again.")
+		return
+	}
+	if count >= maxSchedules {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("This chat already has %d schedules. Please remove one with /schedule rm first.", maxSchedules))
+		return
+	}
+
+	schedule := models.Schedule{
+		Platform:  models.PlatformTelegram,
+		ChatID:    chatID(update),
+		CreatedBy: fmt.Sprintf("%d", update.Message.From.ID),
+		Cron:      cron,
+		Timezone:  timezone,
+		Prompt:    prompt,
+		NextRunAt: next.UTC(),
+	}
+	if err := t.resolve(update, command, &schedule); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			t.sendMessage(ctx, b, update, fmt.Sprintf("Command '%s' not found. Try /ls or /ls server to check if the command is set up.", command))
+			return
+		}
+		t.logger.Error(err, "Failed to resolve command")
+		t.sendMessage(ctx, b, update, "Failed to save schedule. Please try again.")
+		return
+	}
+
+	schedule, err = t.repo.Schedule().Create(schedule)
+	if err != nil {
+		t.logger.Error(err, "Failed to create schedule")
+		t.sendMessage(ctx, b, update, "Failed to save schedule. Please try again.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, fmt.Sprintf("Schedule #%d added: /%s at %s (%s), next %s", schedule.ID, schedule.Command, schedule.Cron, schedule.Timezone, formatRun(schedule.NextRunAt, schedule.Timezone)))
+}
+
+func (t *Telegram) removeSchedule(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
+	if err := t.repo.Schedule().RemoveByID(models.PlatformTelegram, chatID(update), strings.TrimPrefix(id, "#")); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.logger.Error(err, "Failed to remove schedule")
+		}
+		t.sendMessage(ctx, b, update, "Failed to remove schedule. Please check the schedule ID.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, "Schedule removed.")
+}
+
+// resolve sets the config run by a schedule: in groups a command of the group and then
+// one of the caller, in private chats a command of the caller
+func (t *Telegram) resolve(update *telegramMod.Update, command string, schedule *models.Schedule) error {
+	if update.Message.Chat.Type != "private" {
+		server, err := t.repo.Server().GetByPlatformID(schedule.ChatID, string(models.PlatformTelegram))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			config, err := t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, command)
+			if err == nil {
+				schedule.ConfigType, schedule.ConfigID, schedule.Command = models.ConfigTypeServer, config.ID, config.Command
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+	}
+
+	user, err := t.repo.User().GetByPlatformID(schedule.CreatedBy, string(models.PlatformTelegram))
+	if err != nil {
+		return err
+	}
+	config, err := t.repo.UserConfig().GetByUserIDAndCommand(user.ID, command)
+	if err != nil {
+		return err
+	}
+	schedule.ConfigType, schedule.ConfigID, schedule.Command = models.ConfigTypeUser, config.ID, config.Command
+	return nil
+}
+
+// parseAdd splits the arguments of /schedule add, a macro replaces the five cron fields
+func parseAdd(args string) (cron, timezone, command, prompt string, ok bool) {
+	fields := strings.Fields(args)
+
+	n := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		n = 1
+	}
+	if len(fields) < n+3 {
+		return "", "", "", "", false
+	}
+
+	cron = strings.Join(fields[:n], " ")
+	timezone = fields[n]
+	command = strings.TrimPrefix(fields[n+1], "/")
+	prompt = skipFields(args, n+2)
+	return cron, timezone, command, prompt, true
+}
+
+// formatRun formats a run in the time zone of its schedule
+func formatRun(run time.Time, timezone string) string {
+	if loc, err := time.LoadLocation(timezone); err == nil {
+		run = run.In(loc)
+	}
+	return run.Format("Mon 2 Jan 15:04 MST")
+}
+
+// preview shortens a prompt to a line
+func preview(prompt string) string {
+	prompt = strings.Join(strings.Fields(prompt), " ")
+	if runes := []rune(prompt); len(runes) > maxPromptPreview {
+		return string(runes[:maxPromptPreview-1]) + "…"
+	}
+	return prompt
+}
+
+func chatID(update *telegramMod.Update) string {
+	return fmt.Sprintf("%d", update.Message.Chat.ID)
+}
+
+func (t *Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
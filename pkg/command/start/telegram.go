@@ -2,70 +2,387 @@ package start
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"sum/pkg/command/registry"
 	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/permission"
 	"sum/pkg/repo"
 
 	"github.com/go-telegram/bot"
 	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
 )
 
+// StartCommand describes /start in help messages and command menus
+var StartCommand = registry.Command{
+	Name:        "start",
+	Description: "Show the welcome message and the available commands",
+	Details:     "Shows the welcome message and the commands you can use in this chat, like /help.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatPrivate,
+}
+
+// HelpCommand describes /help in help messages and command menus
+var HelpCommand = registry.Command{
+	Name:        "help",
+	Usage:       "[command]",
+	Description: "Show the available commands",
+	Details: `Usage:
+/help - List the commands you can use in this chat, with the agent commands of the group and your own
+/help <command> - Show the detailed usage of a command`,
+	Platforms: []models.PlatformType{models.PlatformTelegram},
+	Chats:     registry.ChatAll,
+}
+
+// welcomeText opens the answer to /start
+const welcomeText = "👋 Welcome to ask Bot!"
+
+// Telegram answers /start and /help with the commands enabled in the registry that the
+// caller may use in the chat, followed by the agent commands of the group and their own
 type Telegram struct {
-	repo   repo.Repository
-	logger logger.Logger
+	repo     repo.Repository
+	commands registry.IRegistry
+	guard    permission.IGuard
+	logger   logger.Logger
 }
 
-func NewTelegram(repo repo.Repository, logger logger.Logger) *Telegram {
+func NewTelegram(repo repo.Repository, commands registry.IRegistry, guard permission.IGuard, logger logger.Logger) *Telegram {
 	return &Telegram{
-		repo:   repo,
-		logger: logger,
+		repo:     repo,
+		commands: commands,
+		guard:    guard,
+		logger:   logger,
 	}
 }
 
+// Handle executes /start, and /help [command]
 func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-	if update.Message == nil || update.Message.Text == "" {
+	if update.Message == nil || update.Message.Text == "" || update.Message.From == nil {
 		return
 	}
 
-	command := strings.ToLower(update.Message.Text)
-	if command == "/start" || command == "/help" {
-		t.sendHelpMessage(ctx, b, update)
+	parts := strings.Fields(update.Message.Text)
+	name, _, _ := strings.Cut(strings.ToLower(parts[0]), "@")
+
+	c := t.caller(ctx, b, update.Message)
+	switch {
+	case name == "/help" && len(parts) > 1:
+		t.send(ctx, b, update.Message.Chat.ID, t.commandHelp(c, parts[1]))
+	case name == "/start":
+		t.send(ctx, b, update.Message.Chat.ID, welcomeText+"\n\n"+t.overview(c))
+	default:
+		t.send(ctx, b, update.Message.Chat.ID, t.overview(c))
+	}
+}
+
+// caller is the user asking for help in a chat
+type caller struct {
+	userID  string
+	chatID  string
+	private bool
+	subject permission.Subject // Member status in the group, unused in private chats
+}
+
+func (t *Telegram) caller(ctx context.Context, b *bot.Bot, message *telegramMod.Message) caller {
+	c := caller{
+		userID:  strconv.FormatInt(message.From.ID, 10),
+		chatID:  strconv.FormatInt(message.Chat.ID, 10),
+		private: message.Chat.Type == telegramMod.ChatTypePrivate,
+	}
+	if !c.private {
+		c.subject = permission.TelegramSubject(ctx, b, message.Chat.ID, message.From)
+	}
+	return c
+}
+
+// overview lists the built-in commands the caller may use in the chat, then the agent
+// commands of the group and theirs
+func (t *Telegram) overview(c caller) string {
+	var sb strings.Builder
+	sb.WriteString("📖 Commands:\n")
+	for _, command := range t.commands.Commands(models.PlatformTelegram) {
+		if t.allowed(c, command) {
+			sb.WriteString(fmt.Sprintf("%s - %s\n", usageLine(command), command.Description))
+		}
+	}
+
+	if !c.private {
+		configs, err := t.groupCommands(c)
+		if err != nil {
+			t.logger.Error(err, "Failed to list server commands")
+		}
+		if len(configs) > 0 {
+			sb.WriteString("\n🤖 Agent commands of this group:\n")
+			sb.WriteString(formatAgents(configs))
+		}
+	}
+
+	configs, err := t.userCommands(c)
+	if err != nil {
+		t.logger.Error(err, "Failed to list user commands")
+	}
+	if len(configs) > 0 {
+		sb.WriteString("\n🙋 Your agent commands:\n")
+		sb.WriteString(formatAgents(configs))
+	} else if _, ok := t.commands.Lookup(models.PlatformTelegram, "reg"); ok {
+		sb.WriteString("\n🙋 You have no agent commands yet, register one with /reg.\n")
+	}
+
+	sb.WriteString("\nSend /help <command> for details.")
+	return sb.String()
+}
+
+// commandHelp returns the detailed usage of a built-in or agent command
+func (t *Telegram) commandHelp(c caller, name string) string {
+	name = strings.TrimPrefix(strings.ToLower(name), "/")
+
+	if command, ok := t.commands.Lookup(models.PlatformTelegram, name); ok {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("%s\n%s\n", usageLine(command), command.Description))
+		if command.Details != "" {
+			sb.WriteString("\n" + command.Details + "\n")
+		}
+		sb.WriteString("\n" + availability(command))
+		if !t.allowed(c, command) {
+			sb.WriteString("\n⚠️ You can't use it in this chat.")
+		}
+		return sb.String()
+	}
+
+	agent, err := t.findAgent(c, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Sprintf("Unknown command /%s. Send /help to list the commands.", name)
+	}
+	if err != nil {
+		t.logger.Error(err, "Failed to find command config")
+		return "Failed to retrieve the command. Please try again."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("/%s <message>\n%s\n", agent.command, describe(agent)))
+	if aliases := models.SplitAliases(agent.aliases); len(aliases) > 0 {
+		sb.WriteString(fmt.Sprintf("\nAliases: /%s\n", strings.Join(aliases, ", /")))
+	}
+	if agent.group {
+		sb.WriteString("\nAgent command of this group. ")
+	} else {
+		sb.WriteString("\nYour agent command. ")
+	}
+	sb.WriteString(fmt.Sprintf("Send /%s followed by your message, or reply to a message with /%s to send its text.", agent.command, agent.command))
+	return sb.String()
+}
+
+// allowed reports whether the caller may use a built-in command in the chat. Private
+// chats belong to the caller, in groups the access of the command and the permission
+// rules of the group apply.
+func (t *Telegram) allowed(c caller, command registry.Command) bool {
+	if !command.AvailableIn(c.private) {
+		return false
+	}
+	if c.private {
+		return true
+	}
+
+	switch command.Access {
+	case registry.AccessAdmin:
+		if !c.subject.IsAdmin {
+			return false
+		}
+	case registry.AccessOwner:
+		if !t.isOwner(c) {
+			return false
+		}
+	}
+
+	if command.Action == "" {
+		return true
+	}
+	return t.permitted(permission.Check{Action: command.Action, Platform: models.PlatformTelegram, ChatID: c.chatID}, c.subject)
+}
+
+// permitted evaluates a check against the permission rules, failures deny
+func (t *Telegram) permitted(check permission.Check, subject permission.Subject) bool {
+	target, err := t.guard.Resolve(check)
+	if err != nil {
+		t.logger.Error(err, "Failed to resolve permission check")
+		return false
+	}
+
+	allowed, err := t.guard.Allowed(target, check.Action, subject)
+	if err != nil {
+		t.logger.Error(err, "Failed to evaluate permission rules")
+		return false
 	}
+	return allowed
 }
 
-func (t *Telegram) sendHelpMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
-	helpText := `👋 Welcome to ask Bot! Here are the available commands:
+// isOwner reports whether the caller registered the group
+func (t *Telegram) isOwner(c caller) bool {
+	server, err := t.repo.Server().GetByPlatformID(c.chatID, string(models.PlatformTelegram))
+	if err != nil {
+		return false
+	}
+	user, err := t.repo.User().GetByPlatformID(c.userID, string(models.PlatformTelegram))
+	if err != nil {
+		return false
+	}
+	return server.OwnerID == strconv.FormatInt(user.ID, 10)
+}
 
-🚀 /start or /help
-   Show this help message
+// agentCommand is a configured agent command listed by /help
+type agentCommand struct {
+	command     string
+	aliases     string
+	description string
+	group       bool // Whether the command belongs to the group rather than the caller
+}
 
-📝 /reg
-   Register a new user or server configuration
-   • Set up a new user configuration
-   • /reg server - Set up a new server configuration
+// groupCommands returns the commands of the group the caller may use, none when the
+// group isn't registered. Configs still being set up are left out.
+func (t *Telegram) groupCommands(c caller) ([]agentCommand, error) {
+	server, err := t.repo.Server().GetByPlatformID(c.chatID, string(models.PlatformTelegram))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-📋 /ls
-   List your commands or server configurations
-   • List your personal commands
-   • /ls server - List server configurations (in private chat) or server commands (in group chat)
+	configs, err := t.repo.ServerConfig().ListByServerID(server.ID)
+	if err != nil {
+		return nil, err
+	}
 
-🤖 /ai <subcommand> <message>
-   Execute an AI command
-   • Format: /ai <subcommand> <message>
-   • The subcommand should match one of your configured commands
+	var commands []agentCommand
+	for _, config := range configs {
+		if config.Command == "" {
+			continue
+		}
+		check := permission.Check{Action: permission.ActionUse, Platform: models.PlatformTelegram, ConfigID: config.ID}
+		if !t.permitted(check, c.subject) {
+			continue
+		}
+		commands = append(commands, agentCommand{config.Command, config.Aliases, config.Description, true})
+	}
+	return commands, nil
+}
 
-📌 Example: /ai summarize Please summarize this text for me.
+// userCommands returns the commands of the caller, none when they aren't registered.
+// Configs still being set up are left out.
+func (t *Telegram) userCommands(c caller) ([]agentCommand, error) {
+	user, err := t.repo.User().GetByPlatformID(c.userID, string(models.PlatformTelegram))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
--------------------------------------------
+	configs, err := t.repo.UserConfig().ListByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
 
-❓ Need more help? Feel free to ask!`
+	var commands []agentCommand
+	for _, config := range configs {
+		if config.Command == "" {
+			continue
+		}
+		commands = append(commands, agentCommand{config.Command, config.Aliases, config.Description, false})
+	}
+	return commands, nil
+}
 
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: update.Message.Chat.ID,
-		Text:   helpText,
-	})
+// findAgent returns the agent command answering name for the caller, by name or alias:
+// the commands of the group first, then theirs
+func (t *Telegram) findAgent(c caller, name string) (agentCommand, error) {
+	if !c.private {
+		server, err := t.repo.Server().GetByPlatformID(c.chatID, string(models.PlatformTelegram))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return agentCommand{}, err
+		}
+		if err == nil {
+			config, err := t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, name)
+			if err == nil {
+				return agentCommand{config.Command, config.Aliases, config.Description, true}, nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return agentCommand{}, err
+			}
+		}
+	}
 
+	user, err := t.repo.User().GetByPlatformID(c.userID, string(models.PlatformTelegram))
 	if err != nil {
+		return agentCommand{}, err
+	}
+	config, err := t.repo.UserConfig().GetByUserIDAndCommand(user.ID, name)
+	if err != nil {
+		return agentCommand{}, err
+	}
+	return agentCommand{config.Command, config.Aliases, config.Description, false}, nil
+}
+
+// usageLine returns the name of a command followed by its arguments
+func usageLine(command registry.Command) string {
+	if command.Usage == "" {
+		return "/" + command.Name
+	}
+	return fmt.Sprintf("/%s %s", command.Name, command.Usage)
+}
+
+// availability describes the chats and platforms where a command is available
+func availability(command registry.Command) string {
+	var chats string
+	switch command.Chats {
+	case registry.ChatPrivate:
+		chats = "private chats"
+	case registry.ChatGroup:
+		chats = "groups"
+	default:
+		chats = "private chats and groups"
+	}
+
+	platforms := make([]string, 0, len(command.Platforms))
+	for _, platform := range command.Platforms {
+		switch platform {
+		case models.PlatformTelegram:
+			platforms = append(platforms, "Telegram")
+		case models.PlatformDiscord:
+			platforms = append(platforms, "Discord")
+		default:
+			platforms = append(platforms, string(platform))
+		}
+	}
+	return fmt.Sprintf("Available in %s, on %s.", chats, strings.Join(platforms, " and "))
+}
+
+// formatAgents lists agent commands, one per line
+func formatAgents(commands []agentCommand) string {
+	var sb strings.Builder
+	for _, c := range commands {
+		sb.WriteString(fmt.Sprintf("/%s - %s\n", c.command, describe(c)))
+	}
+	return sb.String()
+}
+
+// describe returns the description of an agent command
+func describe(c agentCommand) string {
+	if c.description == "" {
+		return fmt.Sprintf("Ask the %s agent", c.command)
+	}
+	return c.description
+}
+
+func (t *Telegram) send(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+	}); err != nil {
 		t.logger.Error(err, "Failed to send help message")
 	}
 }
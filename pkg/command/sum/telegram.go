@@ -2,34 +2,44 @@ package sum
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"strings"
-	"sum/pkg/adapter"
-	"sum/pkg/config"
+	"sum/pkg/command/registry"
 	"sum/pkg/logger"
-	"time"
+	"sum/pkg/models"
+	"sum/pkg/queue"
+	"sum/pkg/ratelimit"
 
 	"github.com/go-telegram/bot"
 	telegramMod "github.com/go-telegram/bot/models"
 )
 
+// Command describes /sum in help messages and command menus
+var Command = registry.Command{
+	Name:        "sum",
+	Usage:       "<url>",
+	Description: "Summarize an article",
+	Details:     "Send /sum followed by the URL of an article to get its summary. Articles summarized recently are answered from the cache, add --fresh for a new summary.\nExample: /sum https://example.com/article",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatAll,
+}
+
 type Telegram struct {
 	logger  logger.Logger
-	adapter adapter.IAdapter
-	config  config.Config
+	jobs    queue.IQueue
+	limiter ratelimit.ILimiter
 }
 
-func NewTelegram(config config.Config, adapter adapter.IAdapter, logger logger.Logger) *Telegram {
+func NewTelegram(jobs queue.IQueue, limiter ratelimit.ILimiter, logger logger.Logger) *Telegram {
 	return &Telegram{
 		logger:  logger,
-		adapter: adapter,
-		config:  config,
+		jobs:    jobs,
+		limiter: limiter,
 	}
 }
 
-// Handle executes the /sum command and sends the response to the user.
-// It also logs any errors that occur while executing the command.
+// Handle executes the /sum command. The summary is queued and posted by a worker as a
+// reply, meanwhile a notice tells the user it is on its way.
 func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
 	parts := strings.Fields(update.Message.Text)
 	if len(parts) < 2 {
@@ -37,79 +47,67 @@ func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.U
 	}
 
 	message := strings.Join(parts[1:], " ")
-
-	// Create a channel to signal when the response is ready
-	responseChan := make(chan *Sum, 1)
-	errChan := make(chan error, 1)
-
-	// Start a goroutine to fetch the response
-	go func() {
-		response, err := chat(t.adapter, message, t.config.AgentURL, t.config.AgentToken)
-		if err != nil {
-			errChan <- err
-		} else {
-			responseChan <- response
-		}
-	}()
-
-	// Send a "thinking" message after 1 second if no response yet
-	var thinkingMsg *telegramMod.Message
-	select {
-	case <-time.After(1 * time.Second):
-		var err error
-		thinkingMsg, err = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:              update.Message.Chat.ID,
-			Text:                "🤔 Thinking...",
-			ProtectContent:      true,
-			DisableNotification: true,
+	userID := fmt.Sprintf("%d", update.Message.From.ID)
+	chatID := fmt.Sprintf("%d", update.Message.Chat.ID)
+
+	decision, err := t.limiter.Allow(ratelimit.Request{
+		UserID:  userID,
+		ChatID:  chatID,
+		Command: "sum",
+	})
+	if err != nil {
+		sendErrorMessage(ctx, b, update, err, t.logger)
+		return
+	}
+	if !decision.Allowed {
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   decision.Message(),
 			ReplyParameters: &telegramMod.ReplyParameters{
 				ChatID:    update.Message.Chat.ID,
 				MessageID: update.Message.ID,
 			},
-		})
-		if err != nil {
-			t.logger.Error(err, "Failed to send thinking message")
+		}); err != nil {
+			t.logger.Error(err, "Failed to send rate limit message")
 		}
-	case response := <-responseChan:
-		sendResponse(ctx, b, update, response, t.logger)
-		return
-	case err := <-errChan:
-		sendErrorMessage(ctx, b, update, err, t.logger)
 		return
 	}
 
-	// Wait for the actual response
-	select {
-	case response := <-responseChan:
-		// Delete the "thinking" message if it was sent
-		if thinkingMsg != nil {
-			if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
-				ChatID:    update.Message.Chat.ID,
-				MessageID: thinkingMsg.ID,
-			}); err != nil {
-				t.logger.Error(err, "Failed to delete thinking message")
-			}
-		}
-		sendResponse(ctx, b, update, response, t.logger)
-	case err := <-errChan:
-		sendErrorMessage(ctx, b, update, err, t.logger)
+	job := models.Job{
+		Platform:  models.PlatformTelegram,
+		ChatID:    chatID,
+		UserID:    userID,
+		MessageID: fmt.Sprintf("%d", update.Message.ID),
+		Command:   "sum",
+		Message:   message,
 	}
-}
 
-func sendResponse(ctx context.Context, b *bot.Bot, update *telegramMod.Update, response *Sum, logger logger.Logger) {
-	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+	notice, err := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:              update.Message.Chat.ID,
-		Text:                response.Summary,
-		ParseMode:           telegramMod.ParseModeMarkdownV1,
+		Text:                "🤔 Thinking...",
 		ProtectContent:      true,
 		DisableNotification: true,
 		ReplyParameters: &telegramMod.ReplyParameters{
 			ChatID:    update.Message.Chat.ID,
 			MessageID: update.Message.ID,
 		},
-	}); err != nil {
-		logger.Error(err, "Failed to send message")
-		sendErrorMessage(ctx, b, update, errors.New("An error occurred while sending the message. Please try again."), logger)
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send thinking message")
+	} else {
+		job.NoticeID = fmt.Sprintf("%d", notice.ID)
+	}
+
+	if err := t.jobs.Enqueue(job); err != nil {
+		if notice != nil {
+			if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+				ChatID:    update.Message.Chat.ID,
+				MessageID: notice.ID,
+			}); err != nil {
+				t.logger.Error(err, "Failed to delete thinking message")
+			}
+		}
+		sendErrorMessage(ctx, b, update, err, t.logger)
 	}
 }
 
@@ -128,26 +126,3 @@ func sendErrorMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Updat
 		logger.Error(err, "Failed to send error message")
 	}
 }
-
-// Sum represents the structure of a summarized article
-type Sum struct {
-	URL     string
-	Title   string
-	Summary string
-}
-
-func chat(a adapter.IAdapter, msg, url, token string) (*Sum, error) {
-	data, err := a.Dify().Chat(msg, url, token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to summarize the article: %w", err)
-	}
-
-	if data == "" {
-		return nil, errors.New("empty response from LLM")
-	}
-
-	return &Sum{
-		URL:     url,
-		Summary: data,
-	}, nil
-}
@@ -2,69 +2,194 @@
 package command
 
 import (
+	"context"
+	"regexp"
 	"sum/pkg/adapter"
+	"sum/pkg/agent"
+	"sum/pkg/command/acl"
 	"sum/pkg/command/ai"
+	"sum/pkg/command/audit"
+	"sum/pkg/command/autosum"
+	"sum/pkg/command/edit"
 	"sum/pkg/command/ls"
+	"sum/pkg/command/quota"
 	"sum/pkg/command/reg"
+	"sum/pkg/command/registry"
+	"sum/pkg/command/schedule"
 	"sum/pkg/command/start"
 	"sum/pkg/command/sum"
+	"sum/pkg/command/usage"
+	"sum/pkg/command/watch"
 	"sum/pkg/config"
 	"sum/pkg/logger"
+	"sum/pkg/menu"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+	"sum/pkg/queue"
+	"sum/pkg/ratelimit"
 	"sum/pkg/repo"
+	"sum/pkg/wizard"
 
 	"github.com/go-telegram/bot"
 )
 
 // telegram represents a Telegram command handler.
 type telegram struct {
-	bot   *bot.Bot
-	reg   *reg.Telegram
-	ls    *ls.Telegram
-	ai    *ai.Telegram
-	start *start.Telegram
-	sum   *sum.Telegram
+	bot      *bot.Bot
+	guard    permission.IGuard
+	commands registry.IRegistry
+	menus    menu.ISyncer
+	logger   logger.Logger
+	reg      *reg.Telegram
+	ls       *ls.Telegram
+	ai       *ai.Telegram
+	inline   *ai.Inline
+	start    *start.Telegram
+	sum      *sum.Telegram
+	quota    *quota.Telegram
+	usage    *usage.Telegram
+	acl      *acl.Telegram
+	audit    *audit.Telegram
+	schedule *schedule.Telegram
+	watch    *watch.Telegram
+	autosum  *autosum.Telegram
 }
 
 // NewTelegram creates a new Telegram command handler.
-func NewTelegram(repo repo.Repository, t *bot.Bot, cfg config.Config, a adapter.IAdapter, logger logger.Logger) ICommand {
+func NewTelegram(repo repo.Repository, t *bot.Bot, cfg config.Config, a adapter.IAdapter, invoker *agent.Invoker, jobs queue.IQueue, limiter ratelimit.ILimiter, guard permission.IGuard, wizards wizard.IManager, commands registry.IRegistry, menus menu.ISyncer, logger logger.Logger) ICommand {
 	return &telegram{
-		bot:   t,
-		reg:   reg.NewTelegram(repo, cfg, logger),
-		ls:    ls.NewTelegram(repo, logger),
-		ai:    ai.NewTelegram(repo, cfg, a, logger),
-		start: start.NewTelegram(repo, logger),
-		sum:   sum.NewTelegram(cfg, a, logger),
+		bot:      t,
+		guard:    guard,
+		commands: commands,
+		menus:    menus,
+		logger:   logger,
+		reg:      reg.NewTelegram(repo, cfg, a.Dify(), wizards, menus, commands, logger),
+		ls:       ls.NewTelegram(repo, edit.New(repo, a.Dify(), commands), guard, wizards, menus, logger),
+		ai:       ai.NewTelegram(repo, cfg, invoker, guard, commands, logger),
+		inline:   ai.NewInline(repo, cfg, invoker, commands, logger),
+		start:    start.NewTelegram(repo, commands, guard, logger),
+		sum:      sum.NewTelegram(jobs, limiter, logger),
+		quota:    quota.NewTelegram(repo, cfg, limiter, logger),
+		usage:    usage.NewTelegram(repo, cfg, logger),
+		acl:      acl.NewTelegram(repo, logger),
+		audit:    audit.NewTelegram(repo, logger),
+		schedule: schedule.NewTelegram(repo, logger),
+		watch:    watch.NewTelegram(repo, a.Feed(), logger),
+		autosum:  autosum.NewTelegram(repo, a.Article(), invoker, logger),
 	}
 }
 
+// commandPattern matches the messages invoking the command name, as /name or /name@bot.
+// Unlike a prefix match, /sum doesn't match /summarize.
+func commandPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(`^/` + regexp.QuoteMeta(name) + `(@\w+)?(\s|$)`)
+}
+
 // AddHandler adds the command handler to the Telegram bot.
 // Currently, this method is empty and can be implemented as needed.
 func (t *telegram) AddHandler() {}
 
 // RegisterReg registers the reg command with the Telegram bot.
 func (t *telegram) RegisterReg() {
-	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/reg", bot.MatchTypePrefix, t.reg.Handle)
-	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "reg_", bot.MatchTypePrefix, t.reg.Handle)
+	handler := permission.Telegram(t.guard, t.logger, reg.Permission, t.reg.Handle)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("reg"), handler)
+	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "reg_", bot.MatchTypePrefix, handler)
+	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/start reg_", bot.MatchTypePrefix, t.reg.HandleDeepLink)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("cancel"), t.reg.HandleCancel)
+	t.bot.RegisterHandlerMatchFunc(t.reg.SecretInGroup, t.reg.HandleSecretInGroup)
+	t.bot.RegisterHandlerMatchFunc(t.reg.Waiting, t.reg.HandleAnswer)
+	t.commands.Enable(models.PlatformTelegram, reg.Command, reg.CancelCommand)
 }
 
 // RegisterLs registers the ls command with the Telegram bot.
 func (t *telegram) RegisterLs() {
-	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/ls", bot.MatchTypePrefix, t.ls.Handle)
-	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "ls_", bot.MatchTypePrefix, t.ls.Handle)
+	handler := permission.Telegram(t.guard, t.logger, ls.Permission, t.ls.Handle)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("ls"), handler)
+	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "ls_", bot.MatchTypePrefix, handler)
+	t.bot.RegisterHandlerMatchFunc(t.ls.Waiting, t.ls.HandleAnswer)
+	t.commands.Enable(models.PlatformTelegram, ls.Command)
 }
 
-// RegisterAI registers the ai command with the Telegram bot.
+// RegisterAI registers the ai, default and pipe commands with the Telegram bot, along with
+// the agent commands invoked by name, the messages addressed to the bot and inline queries.
 func (t *telegram) RegisterAi() {
-	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/ai", bot.MatchTypePrefix, t.ai.Handle)
+	t.ai.Identify(context.Background(), t.bot)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("ai"), t.ai.Handle)
+	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "compare:", bot.MatchTypePrefix, t.ai.HandleVote)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("default"), permission.Telegram(t.guard, t.logger, ai.DefaultPermission, t.ai.HandleDefault))
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("pipe"), permission.Telegram(t.guard, t.logger, ai.PipePermission, t.ai.HandlePipe))
+	t.bot.RegisterHandlerMatchFunc(t.ai.Native, t.ai.HandleNative)
+	t.bot.RegisterHandlerMatchFunc(t.ai.Natural, t.ai.HandleNatural)
+	t.bot.RegisterHandlerMatchFunc(t.inline.Query, t.inline.HandleQuery)
+	t.bot.RegisterHandlerMatchFunc(t.inline.Chosen, t.inline.HandleChosen)
+	t.commands.Enable(models.PlatformTelegram, ai.Command, ai.DefaultCommand, ai.PipeCommand)
 }
 
 // RegisterStart registers the start command with the Telegram bot.
 func (t *telegram) RegisterStart() {
-	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, t.start.Handle)
-	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypePrefix, t.start.Handle)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("start"), t.start.Handle)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("help"), t.start.Handle)
+	t.commands.Enable(models.PlatformTelegram, start.StartCommand, start.HelpCommand)
 }
 
 // RegisterSum registers the sum command with the Telegram bot.
 func (t *telegram) RegisterSum() {
-	t.bot.RegisterHandler(bot.HandlerTypeMessageText, "/sum", bot.MatchTypePrefix, t.sum.Handle)
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("sum"), t.sum.Handle)
+	t.commands.Enable(models.PlatformTelegram, sum.Command)
+}
+
+// RegisterQuota registers the quota command with the Telegram bot.
+func (t *telegram) RegisterQuota() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("quota"), t.quota.Handle)
+	t.commands.Enable(models.PlatformTelegram, quota.Command)
+}
+
+// RegisterUsage registers the usage command with the Telegram bot.
+func (t *telegram) RegisterUsage() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("usage"), t.usage.Handle)
+	t.commands.Enable(models.PlatformTelegram, usage.Command)
+}
+
+// RegisterAcl registers the acl command with the Telegram bot.
+func (t *telegram) RegisterAcl() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("acl"), t.acl.Handle)
+	t.commands.Enable(models.PlatformTelegram, acl.Command)
+}
+
+// RegisterAudit registers the audit command with the Telegram bot.
+func (t *telegram) RegisterAudit() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("audit"), t.audit.Handle)
+	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "audit_page:", bot.MatchTypePrefix, t.audit.Handle)
+	t.commands.Enable(models.PlatformTelegram, audit.Command)
+}
+
+// RegisterSchedule registers the schedule command with the Telegram bot.
+func (t *telegram) RegisterSchedule() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("schedule"), permission.Telegram(t.guard, t.logger, schedule.Permission, t.schedule.Handle))
+	t.commands.Enable(models.PlatformTelegram, schedule.Command)
+}
+
+// RegisterWatch registers the watch command with the Telegram bot.
+func (t *telegram) RegisterWatch() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("watch"), permission.Telegram(t.guard, t.logger, watch.Permission, t.watch.Handle))
+	t.commands.Enable(models.PlatformTelegram, watch.Command)
+}
+
+// RegisterAutoSum registers the autosum command with the Telegram bot, along with the
+// group messages with links. It is registered after the ai command, so messages addressed
+// to the bot are answered by their default command.
+func (t *telegram) RegisterAutoSum() {
+	t.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, commandPattern("autosum"), permission.Telegram(t.guard, t.logger, autosum.Permission, t.autosum.Handle))
+	t.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "autosum:", bot.MatchTypePrefix, t.autosum.HandleButton)
+	t.bot.RegisterHandlerMatchFunc(t.autosum.Links, t.autosum.HandleLinks)
+	t.commands.Enable(models.PlatformTelegram, autosum.Command)
+}
+
+// RegisterMenu sets the command menus of the chats without a menu of their own, from
+// the commands registered so far. The menus of groups and users are updated when their
+// configs change.
+func (t *telegram) RegisterMenu() {
+	if err := t.menus.SyncDefault(context.Background(), t.bot); err != nil {
+		t.logger.Error(err, "Failed to sync command menus")
+	}
 }
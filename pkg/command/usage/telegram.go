@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/ratelimit"
+	"sum/pkg/repo"
+	"time"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// period is a calendar period of /usage
+type period struct {
+	label string
+	start func(time.Time, *time.Location) time.Time
+}
+
+// periods maps the supported /usage arguments to their calendar period
+var periods = map[string]period{
+	"day":   {"today", ratelimit.DayStart},
+	"week":  {"this week", ratelimit.WeekStart},
+	"month": {"this month", ratelimit.MonthStart},
+}
+
+// Command describes /usage in help messages and command menus
+var Command = registry.Command{
+	Name:        "usage",
+	Usage:       "[day|week|month]",
+	Description: "Show the usage of the commands",
+	Details:     "Usage: /usage [day|week|month]\nShows your invocations of the commands since the start of the calendar day, week or month, today by default, and in a group the totals of the group.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatAll,
+}
+
+type Telegram struct {
+	repo   repo.Repository
+	loc    *time.Location // Time zone of the calendar periods
+	logger logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, cfg config.Config, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:   repo,
+		loc:    ratelimit.Location(cfg.RateLimit.Timezone),
+		logger: logger,
+	}
+}
+
+// Handle executes the /usage [day|week|month] command. It shows the caller's totals
+// and, in groups, the totals of the whole group.
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+
+	name := "day"
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) > 1 {
+		name = strings.ToLower(parts[1])
+	}
+
+	p, ok := periods[name]
+	if !ok {
+		t.sendMessage(ctx, b, update, "Usage: /usage [day|week|month]")
+		return
+	}
+	since := p.start(time.Now(), t.loc)
+
+	userID := fmt.Sprintf("%d", update.Message.From.ID)
+	userSummaries, err := t.repo.Invocation().SummarizeByUser(userID, string(models.PlatformTelegram), since)
+	if err != nil {
+		t.logger.Error(err, "Failed to summarize user invocations")
+		t.sendMessage(ctx, b, update, "Failed to retrieve usage. Please try again.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 Usage %s\n\n", p.label))
+	sb.WriteString("👤 You\n")
+	sb.WriteString(formatSummaries(userSummaries))
+
+	if update.Message.Chat.Type != "private" {
+		chatID := fmt.Sprintf("%d", update.Message.Chat.ID)
+		serverSummaries, err := t.repo.Invocation().SummarizeByServer(chatID, string(models.PlatformTelegram), since)
+		if err != nil {
+			t.logger.Error(err, "Failed to summarize server invocations")
+			t.sendMessage(ctx, b, update, "Failed to retrieve usage. Please try again.")
+			return
+		}
+
+		sb.WriteString("\n👥 This group\n")
+		sb.WriteString(formatSummaries(serverSummaries))
+	}
+
+	t.sendMessage(ctx, b, update, sb.String())
+}
+
+func formatSummaries(summaries []models.InvocationSummary) string {
+	if len(summaries) == 0 {
+		return "   No invocations.\n"
+	}
+
+	var (
+		sb          strings.Builder
+		totalCount  int
+		totalCached int
+		totalTokens int
+		totalCost   float64
+	)
+	for _, s := range summaries {
+		sb.WriteString(fmt.Sprintf("   🤖 %s: %d calls (%d failed, %d cached), %d tokens, $%.4f, avg %.1fs\n",
+			s.Command, s.Count, s.Errors, s.Cached, s.TotalTokens, s.Cost, s.AvgLatencyMS/1000))
+		totalCount += s.Count
+		totalCached += s.Cached
+		totalTokens += s.TotalTokens
+		totalCost += s.Cost
+	}
+	sb.WriteString(fmt.Sprintf("   Total: %d calls, %d cached, %d tokens, $%.4f\n", totalCount, totalCached, totalTokens, totalCost))
+
+	return sb.String()
+}
+
+func (t Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
@@ -0,0 +1,47 @@
+package watch
+
+import (
+	"strings"
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/permission"
+
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+const usageText = `Usage:
+/watch - List the feeds watched by this chat
+/watch <feed-url> [command] - Post the new items of an RSS or Atom feed here, summarized by the command or by /sum
+/watch rm <feed-id> - Stop watching a feed
+/watch pause <feed-id> - Pause a feed
+/watch resume <feed-id> - Resume a paused feed
+/watch interval <feed-id> <minutes> - Check a feed every 5 to 1440 minutes
+/watch max <feed-id> <items> - Post at most 1 to 20 new items per check, the older ones are skipped`
+
+// Command describes /watch in help messages and command menus
+var Command = registry.Command{
+	Name:        "watch",
+	Usage:       "[<feed-url> [command]|rm|pause|resume|interval|max ...]",
+	Description: "Post the new items of a feed",
+	Details:     usageText + "\n\nIn a group, watching and changing feeds needs the register permission of the group and removing one the remove permission.",
+	Platforms:   []models.PlatformType{models.PlatformTelegram},
+	Chats:       registry.ChatAll,
+}
+
+// Permission returns the permission check of a /watch update. Changing the feeds of a
+// group is checked, listing them and private feeds are not.
+func Permission(update *telegramMod.Update) (permission.Check, bool) {
+	if update.Message == nil || update.Message.Chat.Type == "private" {
+		return permission.Check{}, false
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	if len(parts) < 2 || parts[1] == "ls" {
+		return permission.Check{}, false
+	}
+
+	if parts[1] == "rm" {
+		return permission.TelegramCheck(update, permission.ActionRemove), true
+	}
+	return permission.TelegramCheck(update, permission.ActionRegister), true
+}
@@ -0,0 +1,292 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"sum/pkg/adapter/feed"
+	"sum/pkg/agent"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+	"sum/pkg/scheduler"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// maxFeeds bounds the feeds watched by a chat
+const maxFeeds = 10
+
+// Bounds and defaults of the poll interval of a feed, in minutes
+const (
+	defaultInterval = 30
+	minInterval     = 5
+	maxInterval     = 1440
+)
+
+// Bounds and defaults of the items posted per poll
+const (
+	defaultMaxItems = 5
+	maxMaxItems     = 20
+)
+
+type Telegram struct {
+	repo   repo.Repository
+	feeds  feed.FeedAdapter
+	logger logger.Logger
+}
+
+func NewTelegram(repo repo.Repository, feeds feed.FeedAdapter, logger logger.Logger) *Telegram {
+	return &Telegram{
+		repo:   repo,
+		feeds:  feeds,
+		logger: logger,
+	}
+}
+
+// Handle executes the /watch command. Feeds post to the chat they were watched in, their
+// items are summarized by a command of the group or of the caller, like /ai, or by /sum.
+func (t *Telegram) Handle(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	if update.Message == nil || update.Message.Text == "" || update.Message.From == nil {
+		return
+	}
+
+	parts := strings.Fields(update.Message.Text)
+	switch {
+	case len(parts) == 1 || parts[1] == "ls":
+		t.listFeeds(ctx, b, update)
+	case parts[1] == "rm" && len(parts) == 3:
+		t.removeFeed(ctx, b, update, parts[2])
+	case (parts[1] == "pause" || parts[1] == "resume") && len(parts) == 3:
+		t.pauseFeed(ctx, b, update, parts[2], parts[1] == "pause")
+	case parts[1] == "interval" && len(parts) == 4:
+		t.setLimit(ctx, b, update, parts[2], parts[3], "interval_minutes", minInterval, maxInterval)
+	case parts[1] == "max" && len(parts) == 4:
+		t.setLimit(ctx, b, update, parts[2], parts[3], "max_items", 1, maxMaxItems)
+	case isFeedURL(parts[1]) && len(parts) <= 3:
+		command := agent.BuiltinCommand
+		if len(parts) == 3 {
+			command = strings.TrimPrefix(parts[2], "/")
+		}
+		t.watchFeed(ctx, b, update, parts[1], command)
+	default:
+		t.sendMessage(ctx, b, update, usageText)
+	}
+}
+
+func (t *Telegram) listFeeds(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+	feeds, err := t.repo.Feed().ListByChatID(models.PlatformTelegram, chatID(update))
+	if err != nil {
+		t.logger.Error(err, "Failed to list feeds")
+		t.sendMessage(ctx, b, update, "Failed to retrieve feeds. Please try again.")
+		return
+	}
+
+	if len(feeds) == 0 {
+		t.sendMessage(ctx, b, update, "This chat doesn't watch any feeds.\n\n"+usageText)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📰 Feeds\n\n")
+	for _, f := range feeds {
+		sb.WriteString(fmt.Sprintf("#%d: %s\n   %s\n   /%s every %d min, up to %d items", f.ID, title(f), f.URL, f.Command, f.IntervalMinutes, f.MaxItems))
+		if f.Paused {
+			sb.WriteString(", paused")
+		}
+		if f.LastError != "" {
+			sb.WriteString("\n   ⚠️ " + f.LastError)
+		}
+		sb.WriteString("\n")
+	}
+
+	t.sendMessage(ctx, b, update, sb.String())
+}
+
+func (t *Telegram) watchFeed(ctx context.Context, b *bot.Bot, update *telegramMod.Update, feedURL, command string) {
+	feeds, err := t.repo.Feed().ListByChatID(models.PlatformTelegram, chatID(update))
+	if err != nil {
+		t.logger.Error(err, "Failed to list feeds")
+		t.sendMessage(ctx, b, update, "Failed to save feed. Please try again.")
+		return
+	}
+	for _, f := range feeds {
+		if f.URL == feedURL {
+			t.sendMessage(ctx, b, update, fmt.Sprintf("This chat already watches this feed as #%d.", f.ID))
+			return
+		}
+	}
+	if len(feeds) >= maxFeeds {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("This chat already watches %d feeds. Please remove one with /watch rm first.", maxFeeds))
+		return
+	}
+
+	f := models.Feed{
+		Platform:        models.PlatformTelegram,
+		ChatID:          chatID(update),
+		CreatedBy:       fmt.Sprintf("%d", update.Message.From.ID),
+		URL:             feedURL,
+		IntervalMinutes: defaultInterval,
+		MaxItems:        defaultMaxItems,
+		NextPollAt:      time.Now().Add(defaultInterval * time.Minute).UTC(),
+	}
+	if err := t.resolve(update, command, &f); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			t.sendMessage(ctx, b, update, fmt.Sprintf("Command '%s' not found. Try /ls or /ls server to check if the command is set up.", command))
+			return
+		}
+		t.logger.Error(err, "Failed to resolve command")
+		t.sendMessage(ctx, b, update, "Failed to save feed. Please try again.")
+		return
+	}
+
+	// The items already in the feed are not posted
+	fetched, err := t.feeds.Fetch(feedURL)
+	if err != nil {
+		t.logger.Warnf("Failed to fetch feed %s: %v", feedURL, err)
+		t.sendMessage(ctx, b, update, fmt.Sprintf("Failed to read the feed: %v", err))
+		return
+	}
+	f.Title = truncate(fetched.Title, 255)
+
+	f, err = t.repo.Feed().Create(f, scheduler.ItemKeys(fetched.Items))
+	if err != nil {
+		t.logger.Error(err, "Failed to create feed")
+		t.sendMessage(ctx, b, update, "Failed to save feed. Please try again.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, fmt.Sprintf("Watching %s as #%d. New items are summarized by /%s and posted here, checked every %d minutes.", title(f), f.ID, f.Command, f.IntervalMinutes))
+}
+
+func (t *Telegram) removeFeed(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string) {
+	if err := t.repo.Feed().RemoveByID(models.PlatformTelegram, chatID(update), strings.TrimPrefix(id, "#")); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.logger.Error(err, "Failed to remove feed")
+		}
+		t.sendMessage(ctx, b, update, "Failed to remove feed. Please check the feed ID.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, "Feed removed.")
+}
+
+func (t *Telegram) pauseFeed(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id string, paused bool) {
+	values := map[string]interface{}{"paused": paused}
+	if !paused {
+		// A resumed feed is checked on the next tick, the items published meanwhile count against its cap
+		values["next_poll_at"] = time.Now().UTC()
+	}
+
+	if err := t.repo.Feed().UpdateByID(models.PlatformTelegram, chatID(update), strings.TrimPrefix(id, "#"), values); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.logger.Error(err, "Failed to update feed")
+		}
+		t.sendMessage(ctx, b, update, "Failed to update feed. Please check the feed ID.")
+		return
+	}
+
+	if paused {
+		t.sendMessage(ctx, b, update, "Feed paused.")
+		return
+	}
+	t.sendMessage(ctx, b, update, "Feed resumed.")
+}
+
+// setLimit sets the interval or item cap of a feed to a value between low and high
+func (t *Telegram) setLimit(ctx context.Context, b *bot.Bot, update *telegramMod.Update, id, text, column string, low, high int) {
+	value, err := strconv.Atoi(text)
+	if err != nil || value < low || value > high {
+		t.sendMessage(ctx, b, update, fmt.Sprintf("Please choose a number between %d and %d.", low, high))
+		return
+	}
+
+	if err := t.repo.Feed().UpdateByID(models.PlatformTelegram, chatID(update), strings.TrimPrefix(id, "#"), map[string]interface{}{column: value}); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.logger.Error(err, "Failed to update feed")
+		}
+		t.sendMessage(ctx, b, update, "Failed to update feed. Please check the feed ID.")
+		return
+	}
+
+	t.sendMessage(ctx, b, update, "Feed updated.")
+}
+
+// resolve sets the summarizer of a feed: /sum, or in groups a command of the group and
+// then one of the caller, in private chats a command of the caller
+func (t *Telegram) resolve(update *telegramMod.Update, command string, f *models.Feed) error {
+	if command == agent.BuiltinCommand {
+		f.ConfigType, f.ConfigID, f.Command = models.ConfigTypeBuiltin, 0, agent.BuiltinCommand
+		return nil
+	}
+
+	if update.Message.Chat.Type != "private" {
+		server, err := t.repo.Server().GetByPlatformID(f.ChatID, string(models.PlatformTelegram))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			config, err := t.repo.ServerConfig().GetByServerIDAndCommand(server.ID, command)
+			if err == nil {
+				f.ConfigType, f.ConfigID, f.Command = models.ConfigTypeServer, config.ID, config.Command
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+	}
+
+	user, err := t.repo.User().GetByPlatformID(f.CreatedBy, string(models.PlatformTelegram))
+	if err != nil {
+		return err
+	}
+	config, err := t.repo.UserConfig().GetByUserIDAndCommand(user.ID, command)
+	if err != nil {
+		return err
+	}
+	f.ConfigType, f.ConfigID, f.Command = models.ConfigTypeUser, config.ID, config.Command
+	return nil
+}
+
+// isFeedURL reports whether s is an http or https URL
+func isFeedURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// title returns the title of a feed, or its URL when it has none
+func title(f models.Feed) string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return f.URL
+}
+
+func truncate(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit-1]) + "…"
+	}
+	return s
+}
+
+func chatID(update *telegramMod.Update) string {
+	return fmt.Sprintf("%d", update.Message.Chat.ID)
+}
+
+func (t *Telegram) sendMessage(ctx context.Context, b *bot.Bot, update *telegramMod.Update, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   text,
+	})
+	if err != nil {
+		t.logger.Error(err, "Failed to send message")
+	}
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,11 +14,83 @@ type Loader interface {
 
 // DBConfig holds the database configuration values
 type DBConfig struct {
+	Driver   string // Database driver, "postgres" or "sqlite"
+	Path     string // Database file for the sqlite driver, ":memory:" for an in-memory database
 	Host     string
 	Port     string
 	User     string
 	Password string
 	Name     string
+	SSLMode  string
+
+	MaxOpenConns           int // Maximum number of open connections, 0 for unlimited
+	MaxIdleConns           int // Maximum number of idle connections
+	ConnMaxLifetimeMinutes int // Maximum lifetime of a connection in minutes, 0 for unlimited
+	ConnectRetries         int // Number of connection retries on startup
+}
+
+// Supported database drivers
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+)
+
+// Supported secret stores
+const (
+	SecretStoreEnv   = "env"   // ENCRYPTION_KEY and ENCRYPTION_RETIRED_KEYS
+	SecretStoreFile  = "file"  // JSON key file
+	SecretStoreVault = "vault" // HashiCorp Vault transit engine
+	SecretStoreKMS   = "kms"   // Generic KMS HTTP API
+)
+
+// SecretStoreConfig selects where the keys encrypting stored API keys live
+type SecretStoreConfig struct {
+	Type    string // One of the SecretStore* constants
+	KeyFile string // Path of the JSON key file for the file store
+
+	VaultAddr  string // Vault address, e.g. http://127.0.0.1:8200
+	VaultToken string // Vault token allowed to use the transit key
+	VaultMount string // Mount path of the transit engine
+	VaultKey   string // Name of the transit key
+
+	KMSURL   string // Base URL of the KMS HTTP API
+	KMSToken string // Bearer token for the KMS HTTP API
+	KMSKeyID string // ID of the KMS key used for new secrets
+
+	LegacyCFB bool // Whether unauthenticated AES-CFB values written before AES-GCM are decrypted, off by default
+}
+
+// OutboundConfig holds the policy for requests made to user supplied agent endpoints
+type OutboundConfig struct {
+	AllowedSchemes   []string // URL schemes that may be requested
+	AllowedPorts     []int    // Ports that may be requested, empty to allow any port
+	AllowedHosts     []string // Host names exempt from the address checks
+	AllowedCIDRs     []string // Private, loopback or link-local ranges that may be requested anyway
+	MaxResponseBytes int64    // Maximum size of a response body
+}
+
+// RateLimitConfig holds the default token bucket settings for agent invocations
+type RateLimitConfig struct {
+	UserPerMinute    int    // Requests per minute allowed for a single user, 0 to disable
+	ChatPerMinute    int    // Requests per minute allowed for a single chat, 0 to disable
+	CommandPerMinute int    // Requests per minute allowed for a single command in a chat, 0 to disable
+	Burst            int    // Maximum bucket size, defaults to the per minute rate
+	Timezone         string // Time zone of the calendar days and months of quotas and usage reports, UTC by default
+}
+
+// CacheConfig holds the bounds of the cache of agent answers
+type CacheConfig struct {
+	TTLMinutes int // Minutes an answer is reused for identical invocations, 0 to disable the cache
+	MaxEntries int // Answers kept, the oldest are dropped first
+}
+
+// QueueConfig holds the worker pool and the priorities of the job queue of long-running agent tasks
+type QueueConfig struct {
+	Workers           int // Jobs run at once by an instance
+	VisibilitySeconds int // Seconds a claimed job is hidden from other workers, it runs again once they pass
+	MaxAttempts       int // Runs of a job before it is given up, a job runs again when its worker stops
+	PriorityTelegram  int // Priority of the jobs from Telegram, higher runs first
+	PriorityDiscord   int // Priority of the jobs from Discord, higher runs first
 }
 
 // Config holds the configuration values for the application
@@ -24,16 +99,37 @@ type Config struct {
 	TelegramBotToken string   // Token for Telegram bot
 	DB               DBConfig // Database configuration
 	DiscordEnabled   bool     // Flag to enable/disable Discord bot
+	DiscordContent   bool     // Whether the Discord bot reads every message, needs the privileged message content intent
 	TelegramEnabled  bool     // Flag to enable/disable Telegram bot
 	EncryptionKey    string   // Key for encryption/decryption operations
+	EncryptionKeyID  string   // ID the ciphertexts encrypted with EncryptionKey are tagged with
 	AgentURL         string   // URL for the agent
 	AgentToken       string   // Token for the agent
+
+	EncryptionRetiredKeys string // Comma separated id:base64 list of retired keys still accepted for decryption
+
+	SecretStore SecretStoreConfig // Store of the keys encrypting stored API keys
+	Outbound    OutboundConfig    // Policy for requests to agent endpoints
+
+	RateLimit RateLimitConfig // Rate limit configuration for agent invocations
+	Cache     CacheConfig     // Cache of the answers to identical invocations
+	Queue     QueueConfig     // Job queue of long-running agent tasks
+
+	WizardTimeoutMinutes int // Minutes a registration step waits for an answer
+
+	InlineDeadlineSeconds int // Seconds an inline query waits for the agent before offering to finish the answer later
+	InlineCacheSeconds    int // Seconds the answers to inline queries are cached
+
+	SchedulerIntervalSeconds int    // Seconds between the checks for due schedules
+	InstanceID               string // Identifies this instance when electing the one firing schedules
 }
 
 // ENV interface for environment variable retrieval
 type ENV interface {
 	GetString(string) string
 	GetBool(string) bool
+	GetInt(string) int
+	IsSet(string) bool
 }
 
 // Generate creates a Config struct from environment variables
@@ -42,20 +138,124 @@ func Generate(v ENV) Config {
 		DiscordBotToken:  v.GetString("DISCORD_BOT_TOKEN"),
 		TelegramBotToken: v.GetString("TELEGRAM_BOT_TOKEN"),
 		DB: DBConfig{
+			Driver:   getStringOr(v, "DB_DRIVER", DBDriverPostgres),
+			Path:     v.GetString("DB_PATH"),
 			Host:     v.GetString("DB_HOST"),
 			Port:     v.GetString("DB_PORT"),
 			User:     v.GetString("DB_USER"),
 			Password: v.GetString("DB_PASS"),
 			Name:     v.GetString("DB_NAME"),
+			SSLMode:  getStringOr(v, "DB_SSLMODE", "disable"),
+
+			MaxOpenConns:           getIntOr(v, "DB_MAX_OPEN_CONNS", 10),
+			MaxIdleConns:           getIntOr(v, "DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetimeMinutes: getIntOr(v, "DB_CONN_MAX_LIFETIME_MINUTES", 30),
+			ConnectRetries:         getIntOr(v, "DB_CONNECT_RETRIES", 5),
 		},
 		DiscordEnabled:  v.GetBool("DISCORD_ENABLED"),
+		DiscordContent:  v.GetBool("DISCORD_MESSAGE_CONTENT"),
 		TelegramEnabled: v.GetBool("TELEGRAM_ENABLED"),
 		EncryptionKey:   v.GetString("ENCRYPTION_KEY"),
+		EncryptionKeyID: getStringOr(v, "ENCRYPTION_KEY_ID", "1"),
 		AgentURL:        v.GetString("AGENT_URL"),
 		AgentToken:      v.GetString("AGENT_TOKEN"),
+
+		EncryptionRetiredKeys: v.GetString("ENCRYPTION_RETIRED_KEYS"),
+
+		Outbound: OutboundConfig{
+			AllowedSchemes:   getListOr(v, "OUTBOUND_ALLOWED_SCHEMES", []string{"https", "http"}),
+			AllowedPorts:     getIntListOr(v, "OUTBOUND_ALLOWED_PORTS", []int{80, 443}),
+			AllowedHosts:     getListOr(v, "OUTBOUND_ALLOWED_HOSTS", nil),
+			AllowedCIDRs:     getListOr(v, "OUTBOUND_ALLOWED_CIDRS", nil),
+			MaxResponseBytes: int64(getIntOr(v, "OUTBOUND_MAX_RESPONSE_BYTES", 10<<20)),
+		},
+		SecretStore: SecretStoreConfig{
+			Type:       getStringOr(v, "SECRET_STORE", SecretStoreEnv),
+			KeyFile:    v.GetString("SECRET_KEY_FILE"),
+			VaultAddr:  v.GetString("VAULT_ADDR"),
+			VaultToken: v.GetString("VAULT_TOKEN"),
+			VaultMount: getStringOr(v, "VAULT_TRANSIT_MOUNT", "transit"),
+			VaultKey:   v.GetString("VAULT_TRANSIT_KEY"),
+			KMSURL:     v.GetString("KMS_URL"),
+			KMSToken:   v.GetString("KMS_TOKEN"),
+			KMSKeyID:   v.GetString("KMS_KEY_ID"),
+			LegacyCFB:  v.GetBool("SECRET_LEGACY_CFB"),
+		},
+		RateLimit: RateLimitConfig{
+			UserPerMinute:    v.GetInt("RATE_LIMIT_USER_PER_MINUTE"),
+			ChatPerMinute:    v.GetInt("RATE_LIMIT_CHAT_PER_MINUTE"),
+			CommandPerMinute: v.GetInt("RATE_LIMIT_COMMAND_PER_MINUTE"),
+			Burst:            v.GetInt("RATE_LIMIT_BURST"),
+			Timezone:         getStringOr(v, "QUOTA_TIMEZONE", "UTC"),
+		},
+		Cache: CacheConfig{
+			TTLMinutes: getIntOr(v, "CACHE_TTL_MINUTES", 60),
+			MaxEntries: getIntOr(v, "CACHE_MAX_ENTRIES", 1000),
+		},
+		Queue: QueueConfig{
+			Workers:           getIntOr(v, "QUEUE_WORKERS", 4),
+			VisibilitySeconds: getIntOr(v, "QUEUE_VISIBILITY_SECONDS", 600),
+			MaxAttempts:       getIntOr(v, "QUEUE_MAX_ATTEMPTS", 3),
+			PriorityTelegram:  getIntOr(v, "QUEUE_PRIORITY_TELEGRAM", 0),
+			PriorityDiscord:   getIntOr(v, "QUEUE_PRIORITY_DISCORD", 0),
+		},
+		WizardTimeoutMinutes: getIntOr(v, "WIZARD_TIMEOUT_MINUTES", 10),
+
+		InlineDeadlineSeconds: getIntOr(v, "INLINE_DEADLINE_SECONDS", 5),
+		InlineCacheSeconds:    getIntOr(v, "INLINE_CACHE_SECONDS", 300),
+
+		SchedulerIntervalSeconds: getIntOr(v, "SCHEDULER_INTERVAL_SECONDS", 30),
+		InstanceID:               v.GetString("FLY_MACHINE_ID"),
 	}
 }
 
+// getStringOr returns the string value of key, or def when it is not set
+func getStringOr(v ENV, key, def string) string {
+	if !v.IsSet(key) {
+		return def
+	}
+	return v.GetString(key)
+}
+
+// getIntOr returns the int value of key, or def when it is not set
+func getIntOr(v ENV, key string, def int) int {
+	if !v.IsSet(key) {
+		return def
+	}
+	return v.GetInt(key)
+}
+
+// getListOr returns the comma separated values of key, or def when it is not set
+func getListOr(v ENV, key string, def []string) []string {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	var values []string
+	for _, value := range strings.Split(v.GetString(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getIntListOr returns the comma separated int values of key, or def when it
+// is not set. Values that are not numbers are ignored.
+func getIntListOr(v ENV, key string, def []int) []int {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	var values []int
+	for _, value := range getListOr(v, key, nil) {
+		if n, err := strconv.Atoi(value); err == nil {
+			values = append(values, n)
+		}
+	}
+	return values
+}
+
 // DefaultConfigLoaders returns a slice of default config loaders
 func DefaultConfigLoaders() []Loader {
 	loaders := []Loader{}
@@ -86,16 +286,45 @@ func LoadTestConfig() Config {
 		DiscordBotToken:  "test_discord_bot_token",
 		TelegramBotToken: "test_telegram_bot_token",
 		DB: DBConfig{
+			Driver:   DBDriverSQLite,
+			Path:     ":memory:",
 			Host:     "test_db_host",
 			Port:     "test_db_port",
 			User:     "test_db_user",
 			Password: "test_db_password",
 			Name:     "test_db_name",
+			SSLMode:  "disable",
 		},
 		DiscordEnabled:  false,
+		DiscordContent:  false,
 		TelegramEnabled: true,
-		EncryptionKey:   "test_encryption_key",
+		EncryptionKey:   "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=", // base64 of a 32-byte key
+		EncryptionKeyID: "1",
 		AgentURL:        "test_agent_url",
 		AgentToken:      "test_agent_token",
+		SecretStore: SecretStoreConfig{
+			Type: SecretStoreEnv,
+		},
+		RateLimit: RateLimitConfig{
+			UserPerMinute:    5,
+			ChatPerMinute:    20,
+			CommandPerMinute: 10,
+			Burst:            5,
+			Timezone:         "UTC",
+		},
+		Cache: CacheConfig{
+			TTLMinutes: 60,
+			MaxEntries: 1000,
+		},
+		Queue: QueueConfig{
+			Workers:           4,
+			VisibilitySeconds: 600,
+			MaxAttempts:       3,
+		},
+		WizardTimeoutMinutes:  10,
+		InlineDeadlineSeconds: 5,
+		InlineCacheSeconds:    300,
+
+		SchedulerIntervalSeconds: 30,
 	}
 }
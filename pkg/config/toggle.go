@@ -7,3 +7,10 @@ func IsDiscordEnabled(cfg Config) bool {
 func IsTelegramEnabled(cfg Config) bool {
 	return cfg.TelegramEnabled
 }
+
+func IsDBEnabled(cfg Config) bool {
+	if cfg.DB.Driver == DBDriverSQLite {
+		return cfg.DB.Path != ""
+	}
+	return cfg.DB.Host != "" && cfg.DB.Name != ""
+}
@@ -0,0 +1,95 @@
+// Package database provides connection and migration helpers for the application database.
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Open connects to the database selected by cfg.Driver, retrying with a
+// growing delay until it succeeds or the configured number of attempts is
+// exhausted, and sets up the connection pool.
+func Open(cfg config.DBConfig, log logger.Logger) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DBDriverPostgres, "":
+		dialector = postgresDialector(cfg)
+	case config.DBDriverSQLite:
+		dialector = sqliteDialector(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	attempts := cfg.ConnectRetries + 1
+	delay := time.Second
+
+	var (
+		db  *gorm.DB
+		err error
+	)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		db, err = connect(dialector)
+		if err == nil {
+			break
+		}
+
+		if attempt == attempts {
+			return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempts, err)
+		}
+
+		log.Warnf("Failed to connect to database (attempt %d/%d), retrying in %s: %v", attempt, attempts, delay, err)
+		time.Sleep(delay)
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+
+	if cfg.Driver == config.DBDriverSQLite {
+		// SQLite allows a single writer, and every connection to an in-memory
+		// database would otherwise see its own empty database
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		return db, nil
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetimeMinutes) * time.Minute)
+
+	return db, nil
+}
+
+// connect opens a gorm connection and verifies it with a ping
+func connect(dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		PrepareStmt: true,
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: false,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+
+	"sum/migrations"
+
+	migrate "github.com/rubenv/sql-migrate"
+	"gorm.io/gorm"
+)
+
+// migrationTable matches the table configured in dbconfig.yml so the binary
+// and the sql-migrate CLI share the same migration history.
+const migrationTable = "migrations"
+
+// Migrate applies all pending embedded schema migrations for the dialect of db
+// and returns how many were applied
+func Migrate(db *gorm.DB) (int, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database handle: %w", err)
+	}
+
+	var (
+		dialect string
+		files   embed.FS
+		root    string
+	)
+	switch name := db.Dialector.Name(); name {
+	case "postgres":
+		dialect, files, root = "postgres", migrations.Schemas, "schemas"
+	case "sqlite":
+		dialect, files, root = "sqlite3", migrations.SQLite, "sqlite"
+	default:
+		return 0, fmt.Errorf("no migrations for database dialect %q", name)
+	}
+
+	source := &migrate.EmbedFileSystemMigrationSource{
+		FileSystem: files,
+		Root:       root,
+	}
+
+	ms := migrate.MigrationSet{TableName: migrationTable}
+	n, err := ms.Exec(sqlDB, dialect, source, migrate.Up)
+	if err != nil {
+		return n, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"net"
+	"net/url"
+
+	"sum/pkg/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// postgresDialector builds the Postgres dialector from the connection settings
+func postgresDialector(cfg config.DBConfig) gorm.Dialector {
+	return postgres.Open(postgresDSN(cfg))
+}
+
+// postgresDSN builds the connection URL, escaping the credentials and the database name
+// so that passwords may hold characters such as @, : or /
+func postgresDSN(cfg config.DBConfig) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:     "/" + cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return dsn.String()
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+
+	"sum/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	cfg := config.DBConfig{
+		Host:     "db.internal",
+		Port:     "5433",
+		User:     "ask user",
+		Password: `p@ss:w/rd?#%& '"`,
+		Name:     "ask",
+		SSLMode:  "require",
+	}
+
+	parsed, err := pgconn.ParseConfig(postgresDSN(cfg))
+	require.NoError(t, err)
+
+	assert.Equal(t, "db.internal", parsed.Host)
+	assert.Equal(t, uint16(5433), parsed.Port)
+	assert.Equal(t, "ask user", parsed.User)
+	assert.Equal(t, `p@ss:w/rd?#%& '"`, parsed.Password)
+	assert.Equal(t, "ask", parsed.Database)
+	assert.NotNil(t, parsed.TLSConfig)
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"sum/pkg/config"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqlitePragmas enables foreign keys, which SQLite leaves off by default, so
+// ON DELETE CASCADE behaves as it does on Postgres, and waits on a locked
+// database instead of failing immediately.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+
+// sqliteDialector builds the SQLite dialector for the configured database file
+func sqliteDialector(cfg config.DBConfig) gorm.Dialector {
+	path := cfg.Path
+	if path == ":memory:" {
+		path = "file::memory:"
+	}
+
+	return sqlite.Open(path + "?" + sqlitePragmas)
+}
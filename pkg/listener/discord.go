@@ -8,15 +8,17 @@ import (
 
 // discord represents a Discord listener instance
 type discord struct {
-	session *discordgo.Session // Discord session
-	command command.ICommand   // Command handler for Discord
+	session   *discordgo.Session // Discord session
+	command   command.ICommand   // Command handler for Discord
+	dbEnabled bool               // Whether commands backed by the database are available
 }
 
 // NewDiscord initiates a Discord listener instance
-func NewDiscord(s *discordgo.Session, c command.ICommand) IListener {
+func NewDiscord(s *discordgo.Session, c command.ICommand, dbEnabled bool) IListener {
 	return &discord{
-		session: s,
-		command: c,
+		session:   s,
+		command:   c,
+		dbEnabled: dbEnabled,
 	}
 }
 
@@ -30,7 +32,14 @@ func (d discord) End() error {
 	return d.session.Close()
 }
 
-// Register registers the sum command for Discord
+// Register registers the Discord commands, all of which need the database
 func (d *discord) Register() {
+	if !d.dbEnabled {
+		return
+	}
+
 	d.command.RegisterReg()
+	d.command.RegisterLs()
+	d.command.RegisterAi()
+	d.command.RegisterAutoSum()
 }
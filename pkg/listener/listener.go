@@ -4,6 +4,8 @@ import (
 	"sum/pkg/command"
 	"sum/pkg/config"
 	"sum/pkg/logger"
+	"sum/pkg/queue"
+	"sum/pkg/scheduler"
 
 	"gorm.io/gorm"
 
@@ -23,6 +25,9 @@ type IListener interface {
 type Listener struct {
 	Discord  IListener
 	Telegram IListener
+
+	Scheduler scheduler.IScheduler // Nil without a database or Telegram bot
+	Queue     queue.IQueue         // Nil without a Telegram bot
 }
 
 // New creates an instance of Listener
@@ -32,14 +37,16 @@ func New(cfg config.Config, logger logger.Logger, d *discordgo.Session, t *bot.B
 	var discord, telegram IListener
 	if d != nil {
 		command.Discord.AddHandler()
-		discord = NewDiscord(d, command.Discord)
+		discord = NewDiscord(d, command.Discord, db != nil)
 	}
 	if t != nil {
-		telegram = NewTelegram(t, command.Telegram)
+		telegram = NewTelegram(t, command.Telegram, db != nil)
 	}
 
 	return Listener{
-		Discord:  discord,
-		Telegram: telegram,
+		Discord:   discord,
+		Telegram:  telegram,
+		Scheduler: command.Scheduler,
+		Queue:     command.Queue,
 	}
 }
@@ -10,15 +10,17 @@ import (
 
 // telegram represents a Telegram listener instance
 type telegram struct {
-	bot     *bot.Bot         // Telegram bot instance
-	command command.ICommand // Command handler for Telegram
+	bot       *bot.Bot         // Telegram bot instance
+	command   command.ICommand // Command handler for Telegram
+	dbEnabled bool             // Whether commands backed by the database are available
 }
 
 // NewTelegram initiates a Telegram listener instance
-func NewTelegram(b *bot.Bot, c command.ICommand) IListener {
+func NewTelegram(b *bot.Bot, c command.ICommand, dbEnabled bool) IListener {
 	return &telegram{
-		bot:     b,
-		command: c,
+		bot:       b,
+		command:   c,
+		dbEnabled: dbEnabled,
 	}
 }
 
@@ -33,11 +35,24 @@ func (t telegram) End() error {
 	return nil
 }
 
-// Register registers the sum command for Telegram
+// Register registers the Telegram commands, then sets the command menus.
+// Only the sum command is available when no database is configured.
 func (t *telegram) Register() {
-	// t.command.RegisterReg()
-	// t.command.RegisterLs()
-	// t.command.RegisterAi()
-	// t.command.RegisterStart()
 	t.command.RegisterSum()
+
+	if t.dbEnabled {
+		t.command.RegisterReg()
+		t.command.RegisterLs()
+		t.command.RegisterAi()
+		t.command.RegisterStart()
+		t.command.RegisterQuota()
+		t.command.RegisterUsage()
+		t.command.RegisterAcl()
+		t.command.RegisterAudit()
+		t.command.RegisterSchedule()
+		t.command.RegisterWatch()
+		t.command.RegisterAutoSum()
+	}
+
+	t.command.RegisterMenu()
 }
@@ -0,0 +1,25 @@
+// Package menu keeps the Telegram command menus in line with the registered configs.
+package menu
+
+import (
+	"context"
+
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+)
+
+// ISyncer defines the interface for updating the command menus of the bot
+type ISyncer interface {
+	SyncDefault(ctx context.Context, b *bot.Bot) error
+	ScopeOf(configType models.ConfigType, id string) (Scope, error)
+	Sync(ctx context.Context, b *bot.Bot, scope Scope) error
+	Track(ctx context.Context, b *bot.Bot, chatID, userID string) error
+}
+
+// Scope is the set of menus listing a config: the menus of a group for its configs, the
+// menus of a user, in private and in the groups they use, for theirs
+type Scope struct {
+	ChatID string // Platform-specific group identifier, empty for the menus of a user
+	UserID string // Platform-specific user identifier, empty for the menus of a group
+}
@@ -0,0 +1,331 @@
+package menu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"sum/pkg/command/registry"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// maxCommands is the number of commands a menu can hold
+const maxCommands = 100
+
+// maxDescriptionLength bounds the description of a menu command
+const maxDescriptionLength = 256
+
+// commandName matches the command names Telegram accepts in menus
+var commandName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// telegram implements ISyncer with the scopes of the Telegram bot API: the default
+// and private chats scopes list the built-in commands, the chat scope of a group adds
+// the group commands, the chat scope of a private chat the commands of the user, and
+// the chat member scope both, for the members tracked in a group
+type telegram struct {
+	repo     repo.Repository
+	commands registry.IRegistry
+}
+
+// NewTelegram creates a new syncer listing the built-in commands enabled in commands.
+// Without a database, SyncDefault is the only usable method.
+func NewTelegram(repo repo.Repository, commands registry.IRegistry) ISyncer {
+	return &telegram{
+		repo:     repo,
+		commands: commands,
+	}
+}
+
+// SyncDefault sets the menus of the chats without a menu of their own
+func (t *telegram) SyncDefault(ctx context.Context, b *bot.Bot) error {
+	if _, err := b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+		Commands: t.menu(false),
+		Scope:    &telegramMod.BotCommandScopeDefault{},
+	}); err != nil {
+		return fmt.Errorf("failed to set the default menu: %w", err)
+	}
+
+	if _, err := b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+		Commands: t.menu(true),
+		Scope:    &telegramMod.BotCommandScopeAllPrivateChats{},
+	}); err != nil {
+		return fmt.Errorf("failed to set the private chats menu: %w", err)
+	}
+	return nil
+}
+
+// ScopeOf returns the menus listing a config. Resolve it before removing the config.
+func (t *telegram) ScopeOf(configType models.ConfigType, id string) (Scope, error) {
+	switch configType {
+	case models.ConfigTypeUser:
+		config, err := t.repo.UserConfig().GetByID(id)
+		if err != nil {
+			return Scope{}, err
+		}
+		user, err := t.repo.User().GetByID(config.UserID)
+		if err != nil {
+			return Scope{}, err
+		}
+		if user.Platform != models.PlatformTelegram {
+			return Scope{}, nil
+		}
+		return Scope{UserID: user.UserID}, nil
+	case models.ConfigTypeServer:
+		config, err := t.repo.ServerConfig().GetByID(id)
+		if err != nil {
+			return Scope{}, err
+		}
+		if config.Server == nil || config.Server.Platform != models.PlatformTelegram {
+			return Scope{}, nil
+		}
+		return Scope{ChatID: config.Server.ServerID}, nil
+	}
+	return Scope{}, fmt.Errorf("unknown config type %q", configType)
+}
+
+// Sync updates the menus of a scope. An empty scope has no menus.
+func (t *telegram) Sync(ctx context.Context, b *bot.Bot, scope Scope) error {
+	switch {
+	case scope.ChatID != "" && scope.UserID != "":
+		return t.syncMember(ctx, b, scope.ChatID, scope.UserID)
+	case scope.ChatID != "":
+		return t.syncGroup(ctx, b, scope.ChatID)
+	case scope.UserID != "":
+		return t.syncUser(ctx, b, scope.UserID)
+	}
+	return nil
+}
+
+// Track lists the commands of a user in the menu of a group, from now on
+func (t *telegram) Track(ctx context.Context, b *bot.Bot, chatID, userID string) error {
+	if err := t.repo.MemberMenu().Save(models.PlatformTelegram, chatID, userID); err != nil {
+		return fmt.Errorf("failed to save member menu: %w", err)
+	}
+	return t.syncMember(ctx, b, chatID, userID)
+}
+
+// syncGroup sets the menu of a group and of its tracked members
+func (t *telegram) syncGroup(ctx context.Context, b *bot.Bot, chatID string) error {
+	configs, err := t.groupConfigs(chatID)
+	if err != nil {
+		return err
+	}
+
+	if err := t.set(ctx, b, t.menu(false, configs), &telegramMod.BotCommandScopeChat{ChatID: chatScopeID(chatID)}); err != nil {
+		return fmt.Errorf("failed to set the menu of chat %s: %w", chatID, err)
+	}
+
+	members, err := t.repo.MemberMenu().ListByChatID(models.PlatformTelegram, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to list member menus: %w", err)
+	}
+
+	var errs []error
+	for _, member := range members {
+		errs = append(errs, t.syncMember(ctx, b, chatID, member.UserID))
+	}
+	return errors.Join(errs...)
+}
+
+// syncUser sets the menu of the private chat with a user, and their menus in the groups
+// they own or are tracked in
+func (t *telegram) syncUser(ctx context.Context, b *bot.Bot, userID string) error {
+	configs, err := t.userConfigs(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := t.set(ctx, b, t.menu(true, configs), &telegramMod.BotCommandScopeChat{ChatID: chatScopeID(userID)}); err != nil {
+		return fmt.Errorf("failed to set the menu of user %s: %w", userID, err)
+	}
+
+	chats, err := t.memberChats(userID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, chatID := range chats {
+		errs = append(errs, t.syncMember(ctx, b, chatID, userID))
+	}
+	return errors.Join(errs...)
+}
+
+// syncMember sets the menu of a member of a group: the group commands, then theirs. A
+// member without commands of their own gets the menu of the group.
+func (t *telegram) syncMember(ctx context.Context, b *bot.Bot, chatID, userID string) error {
+	userConfigs, err := t.userConfigs(userID)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Telegram user ID %q: %w", userID, err)
+	}
+	scope := &telegramMod.BotCommandScopeChatMember{ChatID: chatScopeID(chatID), UserID: id}
+
+	if len(userConfigs) == 0 {
+		if _, err := b.DeleteMyCommands(ctx, &bot.DeleteMyCommandsParams{Scope: scope}); err != nil {
+			return fmt.Errorf("failed to delete the menu of user %s in chat %s: %w", userID, chatID, err)
+		}
+		return nil
+	}
+
+	groupConfigs, err := t.groupConfigs(chatID)
+	if err != nil {
+		return err
+	}
+
+	// The group commands come first, like when the commands are resolved
+	if err := t.set(ctx, b, t.menu(false, groupConfigs, userConfigs), scope); err != nil {
+		return fmt.Errorf("failed to set the menu of user %s in chat %s: %w", userID, chatID, err)
+	}
+	return nil
+}
+
+// set replaces the menu of a scope
+func (t *telegram) set(ctx context.Context, b *bot.Bot, commands []telegramMod.BotCommand, scope telegramMod.BotCommandScope) error {
+	_, err := b.SetMyCommands(ctx, &bot.SetMyCommandsParams{Commands: commands, Scope: scope})
+	return err
+}
+
+// memberChats returns the groups where a user has a menu of their own: the groups they
+// own, which are tracked on the way, and the groups they are tracked in
+func (t *telegram) memberChats(userID string) ([]string, error) {
+	var chats []string
+	seen := map[string]bool{}
+
+	user, err := t.repo.User().GetByPlatformID(userID, string(models.PlatformTelegram))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err == nil {
+		servers, err := t.repo.Server().ListByUserID(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list user servers: %w", err)
+		}
+		for _, server := range servers {
+			if server.Platform != models.PlatformTelegram || seen[server.ServerID] {
+				continue
+			}
+			if err := t.repo.MemberMenu().Save(models.PlatformTelegram, server.ServerID, userID); err != nil {
+				return nil, fmt.Errorf("failed to save member menu: %w", err)
+			}
+			seen[server.ServerID] = true
+			chats = append(chats, server.ServerID)
+		}
+	}
+
+	menus, err := t.repo.MemberMenu().ListByUserID(models.PlatformTelegram, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list member menus: %w", err)
+	}
+	for _, menu := range menus {
+		if !seen[menu.ChatID] {
+			seen[menu.ChatID] = true
+			chats = append(chats, menu.ChatID)
+		}
+	}
+	return chats, nil
+}
+
+// entry is a config listed in a menu
+type entry struct {
+	command     string
+	description string
+}
+
+// groupConfigs returns the menu entries of the configs of a group, none when the group isn't registered
+func (t *telegram) groupConfigs(chatID string) ([]entry, error) {
+	server, err := t.repo.Server().GetByPlatformID(chatID, string(models.PlatformTelegram))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server: %w", err)
+	}
+
+	configs, err := t.repo.ServerConfig().ListByServerID(server.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list server configs: %w", err)
+	}
+
+	entries := make([]entry, 0, len(configs))
+	for _, c := range configs {
+		entries = append(entries, entry{c.Command, c.Description})
+	}
+	return entries, nil
+}
+
+// userConfigs returns the menu entries of the configs of a user, none when the user isn't registered
+func (t *telegram) userConfigs(userID string) ([]entry, error) {
+	user, err := t.repo.User().GetByPlatformID(userID, string(models.PlatformTelegram))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	configs, err := t.repo.UserConfig().ListByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user configs: %w", err)
+	}
+
+	entries := make([]entry, 0, len(configs))
+	for _, c := range configs {
+		entries = append(entries, entry{c.Command, c.Description})
+	}
+	return entries, nil
+}
+
+// menu lists the enabled built-in commands of private chats or groups, then the configs. Configs
+// named like a built-in command or an earlier config, or with a name Telegram doesn't
+// accept, such as pending configs without a name, are left out.
+func (t *telegram) menu(private bool, configs ...[]entry) []telegramMod.BotCommand {
+	commands := []telegramMod.BotCommand{}
+	seen := map[string]bool{}
+
+	for _, c := range t.commands.Commands(models.PlatformTelegram) {
+		seen[c.Name] = true
+		if !c.AvailableIn(private) || len(commands) == maxCommands {
+			continue
+		}
+		commands = append(commands, telegramMod.BotCommand{Command: c.Name, Description: c.Description})
+	}
+
+	for _, entries := range configs {
+		for _, e := range entries {
+			if !commandName.MatchString(e.command) || seen[e.command] || len(commands) == maxCommands {
+				continue
+			}
+			seen[e.command] = true
+
+			description := e.description
+			if description == "" {
+				description = fmt.Sprintf("Ask the %s agent", e.command)
+			}
+			if runes := []rune(description); len(runes) > maxDescriptionLength {
+				description = string(runes[:maxDescriptionLength-1]) + "…"
+			}
+			commands = append(commands, telegramMod.BotCommand{Command: e.command, Description: description})
+		}
+	}
+	return commands
+}
+
+// chatScopeID returns the chat ID of a scope, Telegram chat IDs are numbers
+func chatScopeID(chatID string) any {
+	if id, err := strconv.ParseInt(chatID, 10, 64); err == nil {
+		return id
+	}
+	return chatID
+}
@@ -0,0 +1,14 @@
+package models
+
+import "strings"
+
+// SplitAliases returns the aliases of a comma separated list, without empty ones
+func SplitAliases(raw string) []string {
+	var aliases []string
+	for _, alias := range strings.Split(raw, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
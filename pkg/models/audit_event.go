@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audited entity types
+const (
+	AuditEntityUserConfig     = "user_agent_config"
+	AuditEntityServerConfig   = "server_admin_config"
+	AuditEntityPermissionRule = "permission_rule"
+)
+
+// Audited actions
+const (
+	AuditActionConfigCreate    = "config.create"
+	AuditActionConfigEdit      = "config.edit"
+	AuditActionConfigVerify    = "config.verify"
+	AuditActionConfigQuota     = "config.quota"
+	AuditActionConfigRotateKey = "config.rotate_key"
+	AuditActionConfigRemove    = "config.remove"
+	AuditActionRuleCreate      = "permission.create"
+	AuditActionRuleRemove      = "permission.remove"
+)
+
+// AuditEvent represents a change made to a configuration or permission rule, events are never updated or deleted
+type AuditEvent struct {
+	ID         int64        `json:"id" db:"id"`
+	Platform   PlatformType `json:"platform" db:"platform"`
+	ActorID    string       `json:"actor_id" db:"actor_id"` // Platform-specific user identifier of who made the change, "system" for none
+	Action     string       `json:"action" db:"action"`
+	EntityType string       `json:"entity_type" db:"entity_type"`
+	EntityID   int64        `json:"entity_id" db:"entity_id"`
+	ServerID   int64        `json:"server_id" db:"server_id"` // Server the entity belongs to, 0 for personal configs
+	Before     string       `json:"before" db:"before"`       // JSON of the changed fields before the change, secrets redacted
+	After      string       `json:"after" db:"after"`         // JSON of the changed fields after the change, secrets redacted
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the AuditEvent
+func (e *AuditEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == 0 {
+		e.ID = auditEventIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
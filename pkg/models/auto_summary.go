@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AutoSummaryMode tells how links posted in a server are summarized
+type AutoSummaryMode string
+
+const (
+	AutoSummaryOff    AutoSummaryMode = "off"    // Links are not summarized
+	AutoSummaryAuto   AutoSummaryMode = "auto"   // Links are answered with their summary
+	AutoSummaryButton AutoSummaryMode = "button" // Links are answered with a button summarizing them
+)
+
+// AutoSummary represents the summaries of the links posted in a server without /sum
+type AutoSummary struct {
+	ServerID     int64           `json:"server_id" db:"server_id" gorm:"primaryKey"`
+	Mode         AutoSummaryMode `json:"mode" db:"mode"`
+	AllowDomains string          `json:"allow_domains" db:"allow_domains"` // Comma separated, empty for every domain
+	DenyDomains  string          `json:"deny_domains" db:"deny_domains"`   // Comma separated
+	MinLength    int             `json:"min_length" db:"min_length"`       // Characters of text of the article, 0 for any
+	UpdatedAt    time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// Allows reports whether links to host are summarized. A domain matches its subdomains,
+// and a denied domain wins over an allowed one.
+func (a AutoSummary) Allows(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if matchesDomain(host, a.DenyDomains) {
+		return false
+	}
+	return strings.TrimSpace(a.AllowDomains) == "" || matchesDomain(host, a.AllowDomains)
+}
+
+func matchesDomain(host, domains string) bool {
+	for _, domain := range strings.Split(domains, ",") {
+		domain = strings.TrimSpace(domain)
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SummarizedURL records a link summarized in a chat, so it isn't summarized again for a while
+type SummarizedURL struct {
+	Platform     PlatformType `json:"platform" db:"platform" gorm:"primaryKey"`
+	ChatID       string       `json:"chat_id" db:"chat_id" gorm:"primaryKey"`
+	URLHash      string       `json:"url_hash" db:"url_hash" gorm:"primaryKey"` // SHA-256 of the link
+	SummarizedAt time.Time    `json:"summarized_at" db:"summarized_at"`
+}
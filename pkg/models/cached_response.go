@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// CachedResponse represents the answer of an agent kept to answer identical invocations
+type CachedResponse struct {
+	Key       string    `json:"key" db:"key" gorm:"primaryKey"` // SHA-256 of the endpoint, API key, normalized message and inputs
+	Answer    string    `json:"answer" db:"answer"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ChatSetting represents the settings of a chat, a private chat or a group
+type ChatSetting struct {
+	Platform       PlatformType `json:"platform" db:"platform" gorm:"primaryKey"`
+	ChatID         string       `json:"chat_id" db:"chat_id" gorm:"primaryKey"`
+	DefaultCommand string       `json:"default_command" db:"default_command"` // Command answering mentions and private messages, empty for the active config
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+}
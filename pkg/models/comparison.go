@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comparison represents a prompt sent to several agent commands at once, their answers are voted on
+type Comparison struct {
+	ID        int64        `json:"id" db:"id"`
+	Platform  PlatformType `json:"platform" db:"platform"`
+	ChatID    string       `json:"chat_id" db:"chat_id"`       // Platform-specific chat identifier the answers were posted to
+	MessageID string       `json:"message_id" db:"message_id"` // Platform-specific identifier of the message of the answers, empty until it is posted
+	CreatedBy string       `json:"created_by" db:"created_by"` // Platform-specific user identifier
+	Commands  string       `json:"commands" db:"commands"`     // Comma separated names of the compared configs, in the order of their answers
+	Prompt    string       `json:"prompt" db:"prompt"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the Comparison
+func (c *Comparison) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == 0 {
+		c.ID = comparisonIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
+
+// CommandList returns the names of the compared configs, in the order of their answers
+func (c Comparison) CommandList() []string {
+	return strings.Split(c.Commands, ",")
+}
+
+// ComparisonVote records the answer of a comparison a user found best
+type ComparisonVote struct {
+	ComparisonID int64     `json:"comparison_id" db:"comparison_id" gorm:"primaryKey"`
+	UserID       string    `json:"user_id" db:"user_id" gorm:"primaryKey"` // Platform-specific user identifier
+	Command      string    `json:"command" db:"command"`                   // Name of the config voted for
+	VotedAt      time.Time `json:"voted_at" db:"voted_at"`
+}
+
+// CommandVotes counts the votes for the answers of a command
+type CommandVotes struct {
+	Command string `json:"command" db:"command"`
+	Votes   int    `json:"votes" db:"votes"`
+}
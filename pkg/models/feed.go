@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Feed represents an RSS or Atom feed watched by a chat, its new items are summarized and posted to the chat
+type Feed struct {
+	ID              int64        `json:"id" db:"id"`
+	Platform        PlatformType `json:"platform" db:"platform"`
+	ChatID          string       `json:"chat_id" db:"chat_id"`       // Platform-specific chat identifier the items are posted to
+	CreatedBy       string       `json:"created_by" db:"created_by"` // Platform-specific user identifier
+	URL             string       `json:"url" db:"url"`
+	Title           string       `json:"title" db:"title"`
+	ConfigType      ConfigType   `json:"config_type" db:"config_type"` // ConfigTypeBuiltin for the /sum agent
+	ConfigID        int64        `json:"config_id" db:"config_id"`
+	Command         string       `json:"command" db:"command"` // Name of the summarizer when the feed was watched
+	IntervalMinutes int          `json:"interval_minutes" db:"interval_minutes"`
+	MaxItems        int          `json:"max_items" db:"max_items"` // Items posted per poll, the others are skipped
+	Paused          bool         `json:"paused" db:"paused"`
+	NextPollAt      time.Time    `json:"next_poll_at" db:"next_poll_at"`
+	LastPolledAt    *time.Time   `json:"last_polled_at" db:"last_polled_at"`
+	LastError       string       `json:"last_error" db:"last_error"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the Feed
+func (f *Feed) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == 0 {
+		f.ID = feedIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
+
+// FeedItem records an item of a feed already seen
+type FeedItem struct {
+	FeedID int64     `json:"feed_id" db:"feed_id" gorm:"primaryKey"`
+	GUID   string    `json:"guid" db:"guid" gorm:"primaryKey;column:guid"`
+	SeenAt time.Time `json:"seen_at" db:"seen_at"`
+}
@@ -12,6 +12,14 @@ const (
 	userAgentConfigNodeID   = 2
 	serverNodeID            = 3
 	serverAdminConfigNodeID = 4
+	invocationNodeID        = 5
+	permissionRuleNodeID    = 6
+	auditEventNodeID        = 7
+	scheduleNodeID          = 8
+	feedNodeID              = 9
+	jobNodeID               = 10
+	comparisonNodeID        = 11
+	pipelineNodeID          = 12
 )
 
 var (
@@ -19,6 +27,14 @@ var (
 	userAgentConfigIDGenerator   *snowflake.Node
 	serverIDGenerator            *snowflake.Node
 	serverAdminConfigIDGenerator *snowflake.Node
+	invocationIDGenerator        *snowflake.Node
+	permissionRuleIDGenerator    *snowflake.Node
+	auditEventIDGenerator        *snowflake.Node
+	scheduleIDGenerator          *snowflake.Node
+	feedIDGenerator              *snowflake.Node
+	jobIDGenerator               *snowflake.Node
+	comparisonIDGenerator        *snowflake.Node
+	pipelineIDGenerator          *snowflake.Node
 	once                         sync.Once
 )
 
@@ -49,6 +65,54 @@ func InitIDGenerators() error {
 			err = fmt.Errorf("failed to initialize server admin config ID generator: %w", err)
 			return
 		}
+
+		invocationIDGenerator, err = snowflake.NewNode(invocationNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize invocation ID generator: %w", err)
+			return
+		}
+
+		permissionRuleIDGenerator, err = snowflake.NewNode(permissionRuleNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize permission rule ID generator: %w", err)
+			return
+		}
+
+		auditEventIDGenerator, err = snowflake.NewNode(auditEventNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize audit event ID generator: %w", err)
+			return
+		}
+
+		scheduleIDGenerator, err = snowflake.NewNode(scheduleNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize schedule ID generator: %w", err)
+			return
+		}
+
+		feedIDGenerator, err = snowflake.NewNode(feedNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize feed ID generator: %w", err)
+			return
+		}
+
+		jobIDGenerator, err = snowflake.NewNode(jobNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize job ID generator: %w", err)
+			return
+		}
+
+		comparisonIDGenerator, err = snowflake.NewNode(comparisonNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize comparison ID generator: %w", err)
+			return
+		}
+
+		pipelineIDGenerator, err = snowflake.NewNode(pipelineNodeID)
+		if err != nil {
+			err = fmt.Errorf("failed to initialize pipeline ID generator: %w", err)
+			return
+		}
 	})
 	return err
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DecodeInputs parses the JSON object of agent inputs stored in a config, an empty value means no inputs
+func DecodeInputs(raw string) (map[string]any, error) {
+	inputs := map[string]any{}
+	if strings.TrimSpace(raw) == "" {
+		return inputs, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &inputs); err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
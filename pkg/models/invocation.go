@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConfigType represents which kind of configuration served an invocation
+type ConfigType string
+
+const (
+	ConfigTypeUser    ConfigType = "user"
+	ConfigTypeServer  ConfigType = "server"
+	ConfigTypeBuiltin ConfigType = "builtin"
+)
+
+// Invocation represents a single agent invocation with its usage and outcome
+type Invocation struct {
+	ID               int64        `json:"id" db:"id"`
+	Platform         PlatformType `json:"platform" db:"platform"`
+	UserID           string       `json:"user_id" db:"user_id"`     // Platform-specific user identifier
+	ServerID         string       `json:"server_id" db:"server_id"` // Platform-specific chat identifier
+	ConfigID         int64        `json:"config_id" db:"config_id"`
+	ConfigType       ConfigType   `json:"config_type" db:"config_type"`
+	Command          string       `json:"command" db:"command"`
+	LatencyMS        int64        `json:"latency_ms" db:"latency_ms"`
+	PromptTokens     int          `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int          `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int          `json:"total_tokens" db:"total_tokens"`
+	Cost             float64      `json:"cost" db:"cost"`
+	Currency         string       `json:"currency" db:"currency"`
+	Success          bool         `json:"success" db:"success"`
+	Cached           bool         `json:"cached" db:"cached"` // Answered from the cache, without running the agent
+	Error            string       `json:"error" db:"error"`
+	CreatedAt        time.Time    `json:"created_at" db:"created_at"`
+}
+
+// InvocationSummary represents aggregated invocation usage of a command
+type InvocationSummary struct {
+	Command      string  `json:"command" db:"command"`
+	Count        int     `json:"count" db:"count"`
+	Errors       int     `json:"errors" db:"errors"`
+	Cached       int     `json:"cached" db:"cached"`
+	TotalTokens  int     `json:"total_tokens" db:"total_tokens"`
+	Cost         float64 `json:"cost" db:"cost"`
+	AvgLatencyMS float64 `json:"avg_latency_ms" db:"avg_latency_ms"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the Invocation
+func (i *Invocation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == 0 {
+		i.ID = invocationIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job represents a long-running agent task waiting in the queue, or run by a worker until its visibility timeout
+type Job struct {
+	ID          int64        `json:"id" db:"id"`
+	Platform    PlatformType `json:"platform" db:"platform"`
+	ChatID      string       `json:"chat_id" db:"chat_id"`       // Platform-specific chat identifier the answer is posted to
+	UserID      string       `json:"user_id" db:"user_id"`       // Platform-specific user identifier
+	MessageID   string       `json:"message_id" db:"message_id"` // Message the answer replies to
+	NoticeID    string       `json:"notice_id" db:"notice_id"`   // Message telling the job is queued, removed with the answer
+	Command     string       `json:"command" db:"command"`       // Built-in agent running the job
+	Message     string       `json:"message" db:"message"`
+	Priority    int          `json:"priority" db:"priority"` // Higher runs first
+	Attempts    int          `json:"attempts" db:"attempts"`
+	AvailableAt time.Time    `json:"available_at" db:"available_at"` // Pushed past the visibility timeout while a worker runs the job
+	LockedBy    string       `json:"locked_by" db:"locked_by"`       // Instance that claimed the job last
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the Job
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == 0 {
+		j.ID = jobIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
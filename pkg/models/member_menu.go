@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// MemberMenu records a group member whose command menu in the group lists their own commands
+type MemberMenu struct {
+	Platform  PlatformType `json:"platform" db:"platform" gorm:"primaryKey"`
+	ChatID    string       `json:"chat_id" db:"chat_id" gorm:"primaryKey"`
+	UserID    string       `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
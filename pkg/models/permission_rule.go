@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PermissionEffect represents whether a rule grants or denies an action
+type PermissionEffect string
+
+const (
+	PermissionAllow PermissionEffect = "allow"
+	PermissionDeny  PermissionEffect = "deny"
+)
+
+// PermissionSubjectType represents who a permission rule applies to
+type PermissionSubjectType string
+
+const (
+	SubjectEveryone PermissionSubjectType = "everyone"
+	SubjectAdmins   PermissionSubjectType = "admins"
+	SubjectUser     PermissionSubjectType = "user"
+	SubjectRole     PermissionSubjectType = "role"
+)
+
+// PermissionRule represents an allow or deny rule of a server's access control list
+type PermissionRule struct {
+	ID          int64                 `json:"id" db:"id"`
+	ServerID    int64                 `json:"server_id" db:"server_id"`
+	ConfigID    int64                 `json:"config_id" db:"config_id"` // Server admin config the rule applies to, 0 for all commands
+	Action      string                `json:"action" db:"action"`       // Action the rule applies to, * for all actions
+	Effect      PermissionEffect      `json:"effect" db:"effect"`
+	SubjectType PermissionSubjectType `json:"subject_type" db:"subject_type"`
+	Subject     string                `json:"subject" db:"subject"` // User ID, @username or role name depending on SubjectType
+	CreatedBy   string                `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time             `json:"created_at" db:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the PermissionRule
+func (r *PermissionRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == 0 {
+		r.ID = permissionRuleIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
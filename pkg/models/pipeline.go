@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pipeline represents a named chain of agent commands of a user or a server, each
+// command answers the answer of the previous one
+type Pipeline struct {
+	ID        int64      `json:"id" db:"id"`
+	OwnerType ConfigType `json:"owner_type" db:"owner_type"` // ConfigTypeUser or ConfigTypeServer
+	OwnerID   int64      `json:"owner_id" db:"owner_id"`     // ID of the user or the server owning the pipeline
+	Name      string     `json:"name" db:"name"`
+	Steps     string     `json:"steps" db:"steps"`           // Commands separated by |, each with the inputs it overrides, e.g. summarize | translate lang=vi
+	CreatedBy string     `json:"created_by" db:"created_by"` // Platform-specific user identifier
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the Pipeline
+func (p *Pipeline) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == 0 {
+		p.ID = pipelineIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
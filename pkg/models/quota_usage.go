@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// QuotaPeriod represents the length of a quota window
+type QuotaPeriod string
+
+const (
+	QuotaPeriodDay   QuotaPeriod = "day"
+	QuotaPeriodMonth QuotaPeriod = "month"
+)
+
+// QuotaUsage represents the number of invocations of a server config within a quota window.
+// An empty UserID holds the group-wide counter.
+type QuotaUsage struct {
+	ConfigID    int64       `json:"config_id" db:"config_id" gorm:"primaryKey"`
+	UserID      string      `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	Period      QuotaPeriod `json:"period" db:"period" gorm:"primaryKey"`
+	PeriodStart time.Time   `json:"period_start" db:"period_start" gorm:"primaryKey"`
+	Count       int         `json:"count" db:"count"`
+}
+
+// RateLimitBucket represents the persisted state of a token bucket
+type RateLimitBucket struct {
+	Key       string    `json:"key" db:"key" gorm:"primaryKey"`
+	Tokens    float64   `json:"tokens" db:"tokens"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
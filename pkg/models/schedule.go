@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schedule represents an agent command run on a cron schedule, its answers are posted to a chat
+type Schedule struct {
+	ID         int64        `json:"id" db:"id"`
+	Platform   PlatformType `json:"platform" db:"platform"`
+	ChatID     string       `json:"chat_id" db:"chat_id"`       // Platform-specific chat identifier the answers are posted to
+	CreatedBy  string       `json:"created_by" db:"created_by"` // Platform-specific user identifier
+	ConfigType ConfigType   `json:"config_type" db:"config_type"`
+	ConfigID   int64        `json:"config_id" db:"config_id"`
+	Command    string       `json:"command" db:"command"` // Name of the config when the schedule was added
+	Cron       string       `json:"cron" db:"cron"`
+	Timezone   string       `json:"timezone" db:"timezone"`
+	Prompt     string       `json:"prompt" db:"prompt"`
+	NextRunAt  time.Time    `json:"next_run_at" db:"next_run_at"`
+	LastRunAt  *time.Time   `json:"last_run_at" db:"last_run_at"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a unique ID for the Schedule
+func (s *Schedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == 0 {
+		s.ID = scheduleIDGenerator.Generate().Int64()
+	}
+
+	return nil
+}
+
+// Lease represents the instance elected to run some shared background work until the lease expires
+type Lease struct {
+	Name      string    `json:"name" db:"name" gorm:"primaryKey"`
+	Holder    string    `json:"holder" db:"holder"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
@@ -18,17 +18,30 @@ type ServerAdminConfig struct {
 	Command     string    `json:"command" db:"command"`
 	Description string    `json:"description" db:"description"`
 
+	VerifiedAt *time.Time `json:"verified_at" db:"verified_at"` // When the registration probe passed or was skipped, nil until then
+	Inputs     string     `json:"inputs" db:"inputs"`           // JSON object of the inputs sent to the agent with every request
+	Aliases    string     `json:"aliases" db:"aliases"`         // Other names of the command, comma separated
+
+	DailyQuota     int `json:"daily_quota" db:"daily_quota"`           // Max invocations per day for the whole server, 0 for unlimited
+	MonthlyQuota   int `json:"monthly_quota" db:"monthly_quota"`       // Max invocations per month for the whole server, 0 for unlimited
+	UserDailyQuota int `json:"user_daily_quota" db:"user_daily_quota"` // Max invocations per day for a single user, 0 for unlimited
+
 	Server *Server `json:"server" db:"-"`
 }
 
 // BeforeCreate is a GORM hook that generates a unique ID for the ServerAdminConfig
 // and deactivates all other configs for the same server before activating this one
 func (sac *ServerAdminConfig) BeforeCreate(tx *gorm.DB) error {
-	if sac.ID == 0 {
-		sac.ID = serverAdminConfigIDGenerator.Generate().Int64()
-	}
+	sac.EnsureID()
 
 	sac.IsActive = true
 
 	return nil
 }
+
+// EnsureID generates the ID if it isn't set yet, so it is known before the row is created
+func (sac *ServerAdminConfig) EnsureID() {
+	if sac.ID == 0 {
+		sac.ID = serverAdminConfigIDGenerator.Generate().Int64()
+	}
+}
@@ -17,16 +17,25 @@ type UserAgentConfig struct {
 	IsActive    bool      `json:"is_active" db:"is_active"`
 	Command     string    `json:"command" db:"command"`
 	Description string    `json:"description" db:"description"`
+
+	VerifiedAt *time.Time `json:"verified_at" db:"verified_at"` // When the registration probe passed or was skipped, nil until then
+	Inputs     string     `json:"inputs" db:"inputs"`           // JSON object of the inputs sent to the agent with every request
+	Aliases    string     `json:"aliases" db:"aliases"`         // Other names of the command, comma separated
 }
 
 // BeforeCreate is a GORM hook that generates a unique ID for the UserAgentConfig
 // and deactivates all other configs for the same user before activating this one
 func (uac *UserAgentConfig) BeforeCreate(tx *gorm.DB) error {
-	if uac.ID == 0 {
-		uac.ID = userAgentConfigIDGenerator.Generate().Int64()
-	}
+	uac.EnsureID()
 
 	uac.IsActive = true
 
 	return nil
 }
+
+// EnsureID generates the ID if it isn't set yet, so it is known before the row is created
+func (uac *UserAgentConfig) EnsureID() {
+	if uac.ID == 0 {
+		uac.ID = userAgentConfigIDGenerator.Generate().Int64()
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// WizardSession represents the state of a multi-step conversation, such as the
+// registration wizard, of a user in a chat. A user has at most one per chat.
+type WizardSession struct {
+	Platform  PlatformType `json:"platform" db:"platform" gorm:"primaryKey"`
+	ChatID    string       `json:"chat_id" db:"chat_id" gorm:"primaryKey"`
+	UserID    string       `json:"user_id" db:"user_id" gorm:"primaryKey"`
+	Flow      string       `json:"flow" db:"flow"`             // Wizard the conversation belongs to, e.g. reg_user
+	Step      string       `json:"step" db:"step"`             // Step waiting for an answer
+	SubjectID string       `json:"subject_id" db:"subject_id"` // Record filled by the wizard, e.g. a config ID
+	Token     string       `json:"-" db:"token"`               // Set while the session waits for a private chat link to be opened
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"` // When the step stops waiting for an answer
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
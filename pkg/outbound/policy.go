@@ -0,0 +1,240 @@
+// Package outbound enforces the policy for HTTP requests made to user supplied URLs,
+// such as agent endpoints, to keep them from reaching internal services.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/logger"
+)
+
+// ErrBlocked is returned when a request is refused by the policy
+var ErrBlocked = errors.New("outbound request blocked")
+
+// ErrResponseTooLarge is returned when reading a response body beyond the configured limit
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// blockedPrefixes are the ranges that aren't covered by the netip helpers but
+// must not be reachable either
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT, used for Fly private networking
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, may embed an internal IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),  // Documentation
+	netip.MustParsePrefix("255.255.255.255/32"),
+}
+
+// resolver looks up the addresses of a host name, net.DefaultResolver outside of tests
+type resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Policy validates outbound requests and builds HTTP clients enforcing it
+type Policy struct {
+	schemes  []string
+	ports    []int
+	hosts    []string
+	trusted  []string // host:port origins exempt from every check
+	allowed  []netip.Prefix
+	maxBytes int64
+	resolver resolver
+	logger   logger.Logger
+}
+
+// New creates a Policy from the configuration. Invalid allow-listed ranges are logged and ignored.
+func New(cfg config.OutboundConfig, log logger.Logger) *Policy {
+	p := &Policy{
+		ports:    cfg.AllowedPorts,
+		maxBytes: cfg.MaxResponseBytes,
+		resolver: net.DefaultResolver,
+		logger:   log,
+	}
+	for _, scheme := range cfg.AllowedSchemes {
+		p.schemes = append(p.schemes, strings.ToLower(scheme))
+	}
+	for _, host := range cfg.AllowedHosts {
+		p.hosts = append(p.hosts, strings.ToLower(host))
+	}
+	for _, cidr := range cfg.AllowedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			log.Errorf(err, "Ignoring invalid allowed outbound range %q", cidr)
+			continue
+		}
+		p.allowed = append(p.allowed, prefix.Masked())
+	}
+	return p
+}
+
+// Trust returns a copy of the policy exempting the origin of rawURL, its host and
+// port, from every check. It is meant for the endpoints configured by the operator,
+// such as an agent on the internal network. An empty URL returns the policy as is.
+func (p *Policy) Trust(rawURL string) *Policy {
+	if rawURL == "" {
+		return p
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		p.logger.Errorf(err, "Ignoring invalid trusted outbound URL %q", rawURL)
+		return p
+	}
+
+	trusted := *p
+	trusted.trusted = append(slices.Clone(p.trusted), origin(u))
+	return &trusted
+}
+
+// CheckURL validates the scheme and port of a URL, without resolving its host
+func (p *Policy) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return p.checkURL(u)
+}
+
+// Client returns an HTTP client whose requests, including redirects, are checked
+// against the policy. A zero timeout means no timeout.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+
+	transport := &http.Transport{
+		// Proxies from the environment would resolve the host themselves, bypassing the address checks
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return p.dial(ctx, dialer, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &roundTripper{policy: p, next: transport},
+	}
+}
+
+// checkURL validates the scheme and port of a request URL
+func (p *Policy) checkURL(u *url.URL) error {
+	if slices.Contains(p.trusted, origin(u)) {
+		return nil
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(p.schemes, scheme) {
+		return p.block(u.Hostname(), "", fmt.Sprintf("scheme %q is not allowed", u.Scheme))
+	}
+
+	port := port(u)
+	if len(p.ports) > 0 {
+		n, err := strconv.Atoi(port)
+		if err != nil || !slices.Contains(p.ports, n) {
+			return p.block(u.Hostname(), "", fmt.Sprintf("port %q is not allowed", port))
+		}
+	}
+	return nil
+}
+
+// port returns the port of a URL, the default one of its scheme when it has none
+func port(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// origin returns the host:port a URL connects to
+func origin(u *url.URL) string {
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port(u))
+}
+
+// dial resolves the host, checks every address and connects to the first
+// allowed one, so the checked address is the one that is dialed
+func (p *Policy) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(p.hosts, strings.ToLower(host)) || slices.Contains(p.trusted, strings.ToLower(addr)) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked error
+	for _, ip := range addrs {
+		if reason := p.checkIP(ip); reason != "" {
+			blocked = p.block(host, ip.String(), reason)
+			continue
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+	}
+
+	if blocked == nil {
+		blocked = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, blocked
+}
+
+// checkIP returns why ip may not be requested, or an empty string when it may
+func (p *Policy) checkIP(ip netip.Addr) string {
+	ip = ip.Unmap()
+	for _, prefix := range p.allowed {
+		if prefix.Contains(ip) {
+			return ""
+		}
+	}
+
+	switch {
+	case ip.IsLoopback():
+		return "loopback address"
+	case ip.IsPrivate():
+		return "private address"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return "link-local address"
+	case ip.IsUnspecified():
+		return "unspecified address"
+	case ip.IsMulticast(), ip.IsInterfaceLocalMulticast():
+		return "multicast address"
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return "reserved address"
+		}
+	}
+	return ""
+}
+
+// block logs a refused request and returns the matching error
+func (p *Policy) block(host, ip, reason string) error {
+	p.logger.Fields(logger.Fields{"host": host, "ip": ip, "reason": reason}).Warn("Blocked outbound request")
+	return fmt.Errorf("%w: %s is not allowed (%s)", ErrBlocked, host, reason)
+}
@@ -0,0 +1,272 @@
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"sum/pkg/config"
+	"sum/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver answers host names from a table, each lookup of a host takes its next
+// answer and the last one repeats. IP literals resolve to themselves.
+type fakeResolver struct {
+	mu      sync.Mutex
+	answers map[string][]string
+	lookups map[string]int
+}
+
+func newFakeResolver(answers map[string][]string) *fakeResolver {
+	return &fakeResolver{answers: answers, lookups: map[string]int{}}
+}
+
+func (r *fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	answers, ok := r.answers[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	answer := answers[min(r.lookups[host], len(answers)-1)]
+	r.lookups[host]++
+	return []netip.Addr{netip.MustParseAddr(answer)}, nil
+}
+
+func newPolicy(cfg config.OutboundConfig, resolver resolver) *Policy {
+	if cfg.AllowedSchemes == nil {
+		cfg.AllowedSchemes = []string{"http", "https"}
+	}
+	p := New(cfg, logger.NewLogrusLogger())
+	if resolver != nil {
+		p.resolver = resolver
+	}
+	return p
+}
+
+// newServer starts a test server on 127.0.0.2, an address the tests allow while the
+// rest of the loopback range stays blocked
+func newServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("can't listen on 127.0.0.2: %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckIP(t *testing.T) {
+	p := newPolicy(config.OutboundConfig{AllowedCIDRs: []string{"10.1.0.0/16", "not a range"}}, nil)
+
+	tests := []struct {
+		ip     string
+		reason string
+	}{
+		{"127.0.0.1", "loopback address"},
+		{"127.1.2.3", "loopback address"},
+		{"::1", "loopback address"},
+		{"169.254.169.254", "link-local address"},
+		{"fe80::1", "link-local address"},
+		{"10.0.0.1", "private address"},
+		{"172.16.0.1", "private address"},
+		{"172.31.255.255", "private address"},
+		{"192.168.1.1", "private address"},
+		{"fd00::1", "private address"},
+		{"::ffff:127.0.0.1", "loopback address"},
+		{"::ffff:10.0.0.1", "private address"},
+		{"::ffff:169.254.169.254", "link-local address"},
+		{"0.0.0.0", "unspecified address"},
+		{"::", "unspecified address"},
+		{"224.0.0.1", "link-local address"},
+		{"239.1.1.1", "multicast address"},
+		{"100.64.0.1", "reserved address"},
+		{"64:ff9b::a00:1", "reserved address"},
+		{"255.255.255.255", "reserved address"},
+		{"10.1.2.3", ""},
+		{"::ffff:10.1.2.3", ""},
+		{"172.32.0.1", ""},
+		{"93.184.216.34", ""},
+		{"2606:4700::1111", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.reason, p.checkIP(netip.MustParseAddr(tt.ip)))
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	p := newPolicy(config.OutboundConfig{AllowedSchemes: []string{"HTTPS"}, AllowedPorts: []int{443, 8443}}, nil)
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://agent.example.com/v1", true},
+		{"HTTPS://agent.example.com:8443/v1", true},
+		{"http://agent.example.com/v1", false},
+		{"https://agent.example.com:22/v1", false},
+		{"file:///etc/passwd", false},
+		{"gopher://agent.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := p.CheckURL(tt.url)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrBlocked)
+			}
+		})
+	}
+}
+
+func TestClientBlockedHosts(t *testing.T) {
+	resolver := newFakeResolver(map[string][]string{
+		"internal.test": {"10.0.0.1"},
+		"metadata.test": {"169.254.169.254"},
+		"mapped.test":   {"::ffff:127.0.0.1"},
+	})
+	client := newPolicy(config.OutboundConfig{}, resolver).Client(0)
+
+	for _, host := range []string{"127.0.0.1", "[::1]", "internal.test", "metadata.test", "mapped.test", "[::ffff:169.254.169.254]"} {
+		t.Run(host, func(t *testing.T) {
+			_, err := client.Get(fmt.Sprintf("http://%s/", host))
+			assert.ErrorIs(t, err, ErrBlocked)
+		})
+	}
+
+	_, err := client.Get("http://missing.test/")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrBlocked)
+}
+
+func TestClientRedirect(t *testing.T) {
+	var target string
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	resolver := newFakeResolver(map[string][]string{
+		"agent.test":    {"127.0.0.2"},
+		"internal.test": {"127.0.0.1"},
+	})
+	client := newPolicy(config.OutboundConfig{AllowedCIDRs: []string{"127.0.0.2/32"}}, resolver).Client(0)
+
+	target = fmt.Sprintf("http://agent.test:%s/ok", port)
+	resp, err := client.Get(fmt.Sprintf("http://agent.test:%s/", port))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, blocked := range []string{
+		fmt.Sprintf("http://internal.test:%s/ok", port),
+		fmt.Sprintf("http://127.0.0.1:%s/ok", port),
+		fmt.Sprintf("http://[::ffff:127.0.0.1]:%s/ok", port),
+		"http://169.254.169.254/latest/meta-data/",
+		"ftp://agent.test/ok",
+	} {
+		t.Run(blocked, func(t *testing.T) {
+			target = blocked
+			_, err := client.Get(fmt.Sprintf("http://agent.test:%s/", port))
+			assert.ErrorIs(t, err, ErrBlocked)
+		})
+	}
+}
+
+func TestClientRebinding(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	server.Config.SetKeepAlivesEnabled(false)
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	// The host passes the first lookup, then resolves to an internal address
+	resolver := newFakeResolver(map[string][]string{"rebind.test": {"127.0.0.2", "127.0.0.1"}})
+	p := newPolicy(config.OutboundConfig{AllowedCIDRs: []string{"127.0.0.2/32"}}, resolver)
+	url := fmt.Sprintf("http://rebind.test:%s/", port)
+	require.NoError(t, p.CheckURL(url))
+
+	client := p.Client(0)
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Every connection resolves the host again and checks the address it dials
+	_, err = client.Get(url)
+	assert.ErrorIs(t, err, ErrBlocked)
+	assert.Equal(t, 2, resolver.lookups["rebind.test"])
+}
+
+func TestClientAllowedHost(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+
+	// Allowed hosts are dialed without resolving or checking their address
+	resolver := newFakeResolver(nil)
+	client := newPolicy(config.OutboundConfig{AllowedHosts: []string{"127.0.0.2"}}, resolver).Client(0)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resolver.lookups)
+}
+
+func TestTrust(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	resolver := newFakeResolver(map[string][]string{"other.test": {"127.0.0.2"}})
+	p := newPolicy(config.OutboundConfig{AllowedPorts: []int{80, 443}}, resolver)
+	trusted := p.Trust(fmt.Sprintf("http://127.0.0.2:%s/v1", port))
+
+	// The origin of the operator's agent is reached on its internal address and port
+	resp, err := trusted.Client(0).Get(fmt.Sprintf("http://127.0.0.2:%s/chat-messages", port))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Other origins and the policy it was made from are still checked
+	for _, blocked := range []string{
+		fmt.Sprintf("http://other.test:%s/", port),
+		"http://127.0.0.2/",
+		"http://127.0.0.2:5001/",
+	} {
+		t.Run(blocked, func(t *testing.T) {
+			_, err := trusted.Client(0).Get(blocked)
+			assert.ErrorIs(t, err, ErrBlocked)
+		})
+	}
+	_, err = p.Client(0).Get(fmt.Sprintf("http://127.0.0.2:%s/", port))
+	assert.ErrorIs(t, err, ErrBlocked)
+
+	assert.Same(t, p, p.Trust(""))
+}
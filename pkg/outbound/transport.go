@@ -0,0 +1,60 @@
+package outbound
+
+import (
+	"io"
+	"net/http"
+)
+
+// roundTripper checks every request, including redirects, and caps the size of the response bodies
+type roundTripper struct {
+	policy *Policy
+	next   http.RoundTripper
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.policy.maxBytes > 0 {
+		if resp.ContentLength > t.policy.maxBytes {
+			resp.Body.Close()
+			return nil, ErrResponseTooLarge
+		}
+		resp.Body = &limitedBody{body: resp.Body, remaining: t.policy.maxBytes}
+	}
+	return resp, nil
+}
+
+// limitedBody fails with ErrResponseTooLarge once more than remaining bytes were read
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Tell a body of exactly the limit apart from a larger one
+		var probe [1]byte
+		if n, _ := b.body.Read(probe[:]); n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,80 @@
+package outbound
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sum/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientResponseSize(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		body := strings.Repeat("a", size)
+		if r.URL.Query().Get("chunked") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+			fmt.Fprint(w, body)
+			return
+		}
+		// Without a length the body is only checked as it is read
+		for _, c := range body {
+			fmt.Fprint(w, string(c))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	client := newPolicy(config.OutboundConfig{AllowedHosts: []string{"127.0.0.2"}, MaxResponseBytes: 16}, nil).Client(0)
+
+	tests := []struct {
+		name    string
+		query   string
+		size    int
+		tooLong bool
+	}{
+		{"under the limit", "size=10", 10, false},
+		{"at the limit", "size=16", 16, false},
+		{"declared over the limit", "size=17", 0, true},
+		{"chunked under the limit", "size=10&chunked=1", 10, false},
+		{"chunked at the limit", "size=16&chunked=1", 16, false},
+		{"chunked over the limit", "size=64&chunked=1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(server.URL + "/?" + tt.query)
+			if err != nil {
+				assert.True(t, tt.tooLong)
+				assert.ErrorIs(t, err, ErrResponseTooLarge)
+				return
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if tt.tooLong {
+				assert.ErrorIs(t, err, ErrResponseTooLarge)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, body, tt.size)
+		})
+	}
+}
+
+func TestClientUnlimitedResponse(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 1024))
+	}))
+	client := newPolicy(config.OutboundConfig{AllowedHosts: []string{"127.0.0.2"}}, nil).Client(0)
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, body, 1024)
+}
@@ -0,0 +1,37 @@
+package permission
+
+import "github.com/bwmarrin/discordgo"
+
+// DiscordSubject builds the subject of the member who created an interaction
+func DiscordSubject(i *discordgo.InteractionCreate) Subject {
+	if i.Member == nil || i.Member.User == nil {
+		return Subject{}
+	}
+
+	return Subject{
+		UserID:   i.Member.User.ID,
+		Username: i.Member.User.Username,
+		Roles:    i.Member.Roles,
+		IsAdmin:  i.Member.Permissions&discordgo.PermissionAdministrator != 0,
+	}
+}
+
+// DiscordMessageSubject builds the subject of the author of a guild message. Messages
+// don't carry the permissions of their author, they are computed for the channel.
+func DiscordMessageSubject(s *discordgo.Session, m *discordgo.MessageCreate) Subject {
+	if m.Author == nil {
+		return Subject{}
+	}
+
+	subject := Subject{
+		UserID:   m.Author.ID,
+		Username: m.Author.Username,
+	}
+	if m.Member != nil {
+		subject.Roles = m.Member.Roles
+	}
+	if permissions, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID); err == nil {
+		subject.IsAdmin = permissions&discordgo.PermissionAdministrator != 0
+	}
+	return subject
+}
@@ -0,0 +1,74 @@
+package permission
+
+import (
+	"strings"
+
+	"sum/pkg/models"
+)
+
+// Evaluate decides whether the subject may perform the action on the config.
+// Matching deny rules win over allow rules. When no allow rule applies to the
+// action, the default policy is used: everyone may use commands and only
+// admins may register or remove them.
+func Evaluate(rules []models.PermissionRule, action Action, configID int64, subject Subject) bool {
+	var allows []models.PermissionRule
+	for _, rule := range rules {
+		if !appliesTo(rule, action, configID) {
+			continue
+		}
+
+		if rule.Effect == models.PermissionDeny {
+			if matches(rule, subject) {
+				return false
+			}
+			continue
+		}
+		allows = append(allows, rule)
+	}
+
+	if len(allows) == 0 {
+		return defaultPolicy(action, subject)
+	}
+
+	for _, rule := range allows {
+		if matches(rule, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+func appliesTo(rule models.PermissionRule, action Action, configID int64) bool {
+	if rule.Action != string(ActionAll) && rule.Action != string(action) {
+		return false
+	}
+	return rule.ConfigID == 0 || rule.ConfigID == configID
+}
+
+func matches(rule models.PermissionRule, subject Subject) bool {
+	switch rule.SubjectType {
+	case models.SubjectEveryone:
+		return true
+	case models.SubjectAdmins:
+		return subject.IsAdmin
+	case models.SubjectUser:
+		if strings.HasPrefix(rule.Subject, "@") {
+			return subject.Username != "" && strings.EqualFold(strings.TrimPrefix(rule.Subject, "@"), subject.Username)
+		}
+		return rule.Subject == subject.UserID
+	case models.SubjectRole:
+		for _, role := range subject.Roles {
+			if strings.EqualFold(role, rule.Subject) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func defaultPolicy(action Action, subject Subject) bool {
+	if action == ActionUse {
+		return true
+	}
+	return subject.IsAdmin
+}
@@ -0,0 +1,80 @@
+package permission
+
+import (
+	"errors"
+	"fmt"
+
+	"sum/pkg/repo"
+
+	"gorm.io/gorm"
+)
+
+// guard implements IGuard using the rules stored in the repository
+type guard struct {
+	repo repo.Repository
+}
+
+// New creates a new guard
+func New(repo repo.Repository) IGuard {
+	return &guard{repo: repo}
+}
+
+// Resolve finds the server and config a check applies to. Unregistered chats
+// and commands resolve to an empty target, which falls back to the default policy.
+func (g *guard) Resolve(check Check) (Target, error) {
+	if check.ConfigID != 0 {
+		config, err := g.repo.ServerConfig().GetByID(fmt.Sprintf("%d", check.ConfigID))
+		if err != nil {
+			return Target{}, fmt.Errorf("failed to get server config: %w", err)
+		}
+
+		target := Target{Server: config.Server, ConfigID: config.ID}
+		if config.Server != nil {
+			target.ChatID = config.Server.ServerID
+		}
+		return target, nil
+	}
+
+	if check.ServerID != 0 {
+		server, err := g.repo.Server().GetByID(check.ServerID)
+		if err != nil {
+			return Target{}, fmt.Errorf("failed to get server: %w", err)
+		}
+		return Target{Server: &server, ChatID: server.ServerID}, nil
+	}
+
+	target := Target{ChatID: check.ChatID}
+	server, err := g.repo.Server().GetByPlatformID(check.ChatID, string(check.Platform))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return target, nil
+		}
+		return Target{}, fmt.Errorf("failed to get server: %w", err)
+	}
+	target.Server = &server
+
+	if check.Command != "" {
+		config, err := g.repo.ServerConfig().GetByServerIDAndCommand(server.ID, check.Command)
+		if err == nil {
+			target.ConfigID = config.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return Target{}, fmt.Errorf("failed to get server config: %w", err)
+		}
+	}
+
+	return target, nil
+}
+
+// Allowed evaluates the server's rules for the subject
+func (g *guard) Allowed(target Target, action Action, subject Subject) (bool, error) {
+	if target.Server == nil {
+		return defaultPolicy(action, subject), nil
+	}
+
+	rules, err := g.repo.PermissionRule().ListByServerID(target.Server.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to list permission rules: %w", err)
+	}
+
+	return Evaluate(rules, action, target.ConfigID, subject), nil
+}
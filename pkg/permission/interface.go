@@ -0,0 +1,45 @@
+// Package permission provides access control for server commands based on per-server allow and deny rules.
+package permission
+
+import "sum/pkg/models"
+
+// Action represents an operation guarded by permission rules
+type Action string
+
+const (
+	ActionUse      Action = "use"      // Invoke a server command
+	ActionRegister Action = "register" // Register or set up a server command
+	ActionRemove   Action = "remove"   // Remove a server command
+	ActionAll      Action = "*"        // Matches every action in a rule
+)
+
+// IGuard defines the interface for resolving and enforcing permission checks
+type IGuard interface {
+	Resolve(check Check) (Target, error)
+	Allowed(target Target, action Action, subject Subject) (bool, error)
+}
+
+// Check describes a permission check for an incoming request
+type Check struct {
+	Action   Action
+	Platform models.PlatformType
+	ChatID   string // Platform-specific chat identifier, used when neither ConfigID nor ServerID is known
+	ServerID int64  // Server the check applies to, used when ConfigID is unknown
+	ConfigID int64  // Server admin config the check applies to
+	Command  string // Server command the check applies to, resolved to a config in ChatID
+}
+
+// Target is the server and command a check was resolved to
+type Target struct {
+	Server   *models.Server // Nil when the chat is not a registered server
+	ConfigID int64          // 0 when the check doesn't apply to a registered server command
+	ChatID   string         // Platform-specific chat identifier of the server
+}
+
+// Subject describes the caller of a request
+type Subject struct {
+	UserID   string   // Platform-specific user identifier
+	Username string   // Platform-specific username
+	Roles    []string // Telegram member status or Discord role IDs
+	IsAdmin  bool     // Whether the caller administers the chat
+}
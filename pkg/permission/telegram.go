@@ -0,0 +1,153 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// TelegramResolver maps an update to the permission check it requires.
+// It returns false when the update doesn't need a check.
+type TelegramResolver func(update *telegramMod.Update) (Check, bool)
+
+// Telegram wraps a handler so it only runs when the caller passes the check
+// returned by resolve.
+func Telegram(g IGuard, logger logger.Logger, resolve TelegramResolver, next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *telegramMod.Update) {
+		check, ok := resolve(update)
+		if !ok {
+			next(ctx, b, update)
+			return
+		}
+
+		from, chatID, replyChatID := telegramCaller(update)
+		if from == nil {
+			return
+		}
+
+		allowed, err := AllowedTelegram(ctx, b, g, check, from, chatID)
+		if err != nil {
+			logger.Error(err, "Failed to check permission")
+			sendDenied(ctx, b, logger, replyChatID, "An error occurred. Please try again.")
+			return
+		}
+
+		if !allowed {
+			logger.Warnf("Permission denied for user %d to %s", from.ID, check.Action)
+			sendDenied(ctx, b, logger, replyChatID, fmt.Sprintf("You don't have permission to %s this command.", check.Action))
+			return
+		}
+
+		next(ctx, b, update)
+	}
+}
+
+// AllowedTelegram resolves the check and evaluates it for a Telegram user. The user's
+// member status is looked up in the server's chat, or in chatID, the chat the update
+// came from, when the check doesn't name a server. The check is denied when neither
+// chat is known, chatID is 0 when the update doesn't tell its chat.
+func AllowedTelegram(ctx context.Context, b *bot.Bot, g IGuard, check Check, user *telegramMod.User, chatID int64) (bool, error) {
+	target, err := g.Resolve(check)
+	if err != nil {
+		return false, err
+	}
+
+	// A check naming a config or server is only evaluated in the chat of its server
+	if check.ConfigID != 0 || check.ServerID != 0 {
+		chatID = 0
+	}
+	if target.ChatID != "" {
+		if id, err := strconv.ParseInt(target.ChatID, 10, 64); err == nil {
+			chatID = id
+		}
+	}
+	if chatID == 0 {
+		return false, nil
+	}
+
+	return g.Allowed(target, check.Action, TelegramSubject(ctx, b, chatID, user))
+}
+
+// TelegramSubject builds the subject of a Telegram user in a chat from its member status
+func TelegramSubject(ctx context.Context, b *bot.Bot, chatID int64, user *telegramMod.User) Subject {
+	subject := Subject{
+		UserID:   fmt.Sprintf("%d", user.ID),
+		Username: user.Username,
+	}
+
+	// Everyone administers their own private chat
+	if user.ID == chatID {
+		subject.IsAdmin = true
+		subject.Roles = []string{string(telegramMod.ChatMemberTypeOwner)}
+		return subject
+	}
+
+	member, err := b.GetChatMember(ctx, &bot.GetChatMemberParams{
+		ChatID: chatID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return subject
+	}
+
+	subject.Roles = []string{string(member.Type)}
+	subject.IsAdmin = member.Type == telegramMod.ChatMemberTypeAdministrator || member.Type == telegramMod.ChatMemberTypeOwner
+
+	return subject
+}
+
+// IsTelegramAdmin reports whether the user is an owner or administrator of the chat
+func IsTelegramAdmin(ctx context.Context, b *bot.Bot, chatID int64, user *telegramMod.User) bool {
+	return TelegramSubject(ctx, b, chatID, user).IsAdmin
+}
+
+// TelegramChat returns the platform chat identifier of an update
+func TelegramChat(update *telegramMod.Update) string {
+	if update.Message != nil {
+		return fmt.Sprintf("%d", update.Message.Chat.ID)
+	}
+	if update.CallbackQuery != nil && update.CallbackQuery.Message.Message != nil {
+		return fmt.Sprintf("%d", update.CallbackQuery.Message.Message.Chat.ID)
+	}
+	return ""
+}
+
+// TelegramCheck builds a check for the chat of an update
+func TelegramCheck(update *telegramMod.Update, action Action) Check {
+	return Check{
+		Action:   action,
+		Platform: models.PlatformTelegram,
+		ChatID:   TelegramChat(update),
+	}
+}
+
+// telegramCaller returns the sender of an update, the chat it came from and the chat
+// to reply to. The chat is 0 for a callback whose message is no longer accessible.
+func telegramCaller(update *telegramMod.Update) (*telegramMod.User, int64, int64) {
+	if update.Message != nil && update.Message.From != nil {
+		return update.Message.From, update.Message.Chat.ID, update.Message.Chat.ID
+	}
+	if update.CallbackQuery != nil {
+		var chatID int64
+		if update.CallbackQuery.Message.Message != nil {
+			chatID = update.CallbackQuery.Message.Message.Chat.ID
+		}
+		return &update.CallbackQuery.From, chatID, update.CallbackQuery.From.ID
+	}
+	return nil, 0, 0
+}
+
+func sendDenied(ctx context.Context, b *bot.Bot, logger logger.Logger, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+	}); err != nil {
+		logger.Error(err, "Failed to send permission denied message")
+	}
+}
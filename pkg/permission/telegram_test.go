@@ -0,0 +1,150 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelegram is a Bot API answering getChatMember with the status of each chat, it
+// records the chats looked up and the messages sent
+type fakeTelegram struct {
+	mu       sync.Mutex
+	statuses map[string]string // Member status of the caller by chat ID
+	lookups  []string
+	sent     []string
+}
+
+func newBot(t *testing.T, statuses map[string]string) (*fakeTelegram, *bot.Bot) {
+	t.Helper()
+	api := &fakeTelegram{statuses: statuses}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	b, err := bot.New("token", bot.WithServerURL(server.URL), bot.WithSkipGetMe())
+	require.NoError(t, err)
+	return api, b
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = r.ParseMultipartForm(1 << 20)
+
+	switch path.Base(r.URL.Path) {
+	case "getChatMember":
+		chatID := r.FormValue("chat_id")
+		f.lookups = append(f.lookups, chatID)
+		status, ok := f.statuses[chatID]
+		if !ok {
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"status":%q,"user":{"id":%s}}}`, status, r.FormValue("user_id"))
+	case "sendMessage":
+		f.sent = append(f.sent, r.FormValue("text"))
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":%s,"type":"private"}}}`, r.FormValue("chat_id"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeGuard resolves every check to target and applies the default policy
+type fakeGuard struct {
+	target Target
+}
+
+func (g fakeGuard) Resolve(Check) (Target, error) {
+	return g.target, nil
+}
+
+func (g fakeGuard) Allowed(_ Target, action Action, subject Subject) (bool, error) {
+	return defaultPolicy(action, subject), nil
+}
+
+// callback is a callback query of user 1 pressing a button of a message in chatID, or
+// of a message no longer accessible when chatID is 0
+func callback(chatID int64) *telegramMod.Update {
+	query := &telegramMod.CallbackQuery{ID: "q", From: telegramMod.User{ID: 1}, Data: "reg_remove_server:7"}
+	if chatID != 0 {
+		query.Message.Message = &telegramMod.Message{ID: 1, Chat: telegramMod.Chat{ID: chatID}}
+	} else {
+		query.Message.InaccessibleMessage = &telegramMod.InaccessibleMessage{}
+	}
+	return &telegramMod.Update{CallbackQuery: query}
+}
+
+// run passes update through a handler guarded by check, it reports whether the handler ran
+func run(b *bot.Bot, g IGuard, check Check, update *telegramMod.Update) bool {
+	ran := false
+	handler := Telegram(g, logger.NewLogrusLogger(), func(*telegramMod.Update) (Check, bool) { return check, true }, func(context.Context, *bot.Bot, *telegramMod.Update) {
+		ran = true
+	})
+	handler(context.Background(), b, update)
+	return ran
+}
+
+func TestTelegramCallbackWithoutServer(t *testing.T) {
+	remove := Check{Action: ActionRemove, Platform: models.PlatformTelegram, ConfigID: 7}
+
+	// Neither a private chat nor an inaccessible message make the caller an admin of a config without a server
+	for name, update := range map[string]*telegramMod.Update{
+		"private chat":         callback(1),
+		"inaccessible message": callback(0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			api, b := newBot(t, nil)
+			assert.False(t, run(b, fakeGuard{}, remove, update))
+			assert.Empty(t, api.lookups)
+			assert.Equal(t, []string{"You don't have permission to remove this command."}, api.sent)
+		})
+	}
+}
+
+func TestTelegramCallbackInServer(t *testing.T) {
+	remove := Check{Action: ActionRemove, Platform: models.PlatformTelegram, ConfigID: 7}
+	g := fakeGuard{target: Target{Server: &models.Server{ID: 2, ServerID: "-100"}, ConfigID: 7, ChatID: "-100"}}
+
+	// The member status is looked up in the chat of the server, wherever the button was pressed
+	for status, allowed := range map[string]bool{"administrator": true, "creator": true, "member": false} {
+		t.Run(status, func(t *testing.T) {
+			for _, update := range []*telegramMod.Update{callback(1), callback(-200), callback(0)} {
+				api, b := newBot(t, map[string]string{"-100": status, "-200": "creator"})
+				assert.Equal(t, allowed, run(b, g, remove, update))
+				assert.Equal(t, []string{"-100"}, api.lookups)
+			}
+		})
+	}
+}
+
+func TestTelegramChatCheck(t *testing.T) {
+	register := Check{Action: ActionRegister, Platform: models.PlatformTelegram}
+
+	// Without a server the caller's status in the chat of the update decides
+	api, b := newBot(t, map[string]string{"-200": "member"})
+	assert.False(t, run(b, fakeGuard{}, register, callback(-200)))
+	assert.Equal(t, []string{"-200"}, api.lookups)
+
+	api, b = newBot(t, nil)
+	assert.True(t, run(b, fakeGuard{}, register, &telegramMod.Update{Message: &telegramMod.Message{
+		From: &telegramMod.User{ID: 1},
+		Chat: telegramMod.Chat{ID: 1, Type: telegramMod.ChatTypePrivate},
+	}}))
+	assert.Empty(t, api.lookups)
+
+	api, b = newBot(t, nil)
+	assert.False(t, run(b, fakeGuard{}, register, callback(0)))
+	assert.Empty(t, api.lookups)
+}
@@ -0,0 +1,40 @@
+// Package queue runs long-running agent tasks in the background. Handlers enqueue jobs,
+// a bounded pool of workers on every instance claims them, asks the built-in agent and
+// posts the answers to the chats the jobs came from. Jobs wait in the database, so the
+// jobs queued or in flight when an instance stops are run by the next one.
+package queue
+
+import (
+	"context"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// IQueue defines the interface for queueing jobs and running the workers claiming them
+type IQueue interface {
+	// Enqueue adds a job, with the priority of its platform, for the next free worker
+	Enqueue(job models.Job) error
+	// Start runs the workers until End is called
+	Start() error
+	// End stops the workers once the jobs they run are answered
+	End() error
+	// RunNext claims the next available job and runs it, it reports whether there was one
+	RunNext(ctx context.Context) (bool, error)
+}
+
+// IStore defines the persistence of the queued jobs
+type IStore interface {
+	Push(job models.Job) error
+	// Claim hides the next available job from other workers for the visibility timeout
+	// and counts the attempt, it returns nil when no job is available
+	Claim(holder string, now time.Time, visibility time.Duration) (*models.Job, error)
+	// Remove drops a job answered by the claim it was returned by, a job claimed again since is kept
+	Remove(job models.Job) error
+}
+
+// IDeliverer posts the answers of jobs to the chats of a platform
+type IDeliverer interface {
+	// Deliver replies to the message of a job with text and removes its notice
+	Deliver(ctx context.Context, job models.Job, text string) error
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// memoryStore is an in-memory IStore, suitable for tests and deployments without a
+// database. Its jobs are lost when the instance stops.
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   []models.Job
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() IStore {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Push(job models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.ID == 0 {
+		s.nextID++
+		job.ID = s.nextID
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+func (s *memoryStore) Claim(holder string, now time.Time, visibility time.Duration) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := -1
+	for i, job := range s.jobs {
+		if job.AvailableAt.After(now) {
+			continue
+		}
+		// Jobs are pushed in order, the first of the highest priority was queued first
+		if next < 0 || job.Priority > s.jobs[next].Priority {
+			next = i
+		}
+	}
+	if next < 0 {
+		return nil, nil
+	}
+
+	s.jobs[next].Attempts++
+	s.jobs[next].AvailableAt = now.Add(visibility)
+	s.jobs[next].LockedBy = holder
+	job := s.jobs[next]
+	return &job, nil
+}
+
+func (s *memoryStore) Remove(job models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, queued := range s.jobs {
+		if queued.ID == job.ID && queued.LockedBy == job.LockedBy && queued.Attempts == job.Attempts {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"errors"
+	"time"
+
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postgresStore is an IStore backed by the jobs table
+type postgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a new database backed store. It also works on SQLite, where
+// the row lock is dropped and writes are serialized by the database.
+func NewPostgresStore(db *gorm.DB) IStore {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Push(job models.Job) error {
+	return s.db.Create(&job).Error
+}
+
+// Claim locks the next available job, skipping the ones other workers are claiming, so
+// the workers of every instance take different jobs without waiting on each other
+func (s *postgresStore) Claim(holder string, now time.Time, visibility time.Duration) (*models.Job, error) {
+	var job models.Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("available_at <= ?", now).
+			Order("priority DESC, id").
+			Take(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Attempts++
+		job.AvailableAt = now.Add(visibility)
+		job.LockedBy = holder
+		return tx.Model(&models.Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"attempts":     job.Attempts,
+			"available_at": job.AvailableAt,
+			"locked_by":    job.LockedBy,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *postgresStore) Remove(job models.Job) error {
+	return s.db.Where("id = ? AND locked_by = ? AND attempts = ?", job.ID, job.LockedBy, job.Attempts).
+		Delete(&models.Job{}).Error
+}
@@ -0,0 +1,209 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"sum/pkg/agent"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+)
+
+// pollInterval is how often an idle worker looks for the jobs queued by other instances
+const pollInterval = 2 * time.Second
+
+// deliverTimeout bounds how long a worker waits for an answer to be posted
+const deliverTimeout = 30 * time.Second
+
+// queue implements IQueue
+type queue struct {
+	store      IStore
+	runner     agent.IRunner
+	deliverers map[models.PlatformType]IDeliverer
+	holder     string
+	workers    int
+	visibility time.Duration
+	attempts   int
+	priorities map[models.PlatformType]int
+	now        func() time.Time
+	logger     logger.Logger
+
+	// Jobs run the built-in agent
+	agentURL   string
+	agentToken string
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a new queue posting the answers of jobs with the deliverer of their platform
+func New(store IStore, cfg config.Config, runner agent.IRunner, deliverers map[models.PlatformType]IDeliverer, logger logger.Logger) IQueue {
+	return NewWithClock(store, cfg, runner, deliverers, logger, time.Now)
+}
+
+// NewWithClock creates a new queue using a custom clock, mainly useful for tests
+func NewWithClock(store IStore, cfg config.Config, runner agent.IRunner, deliverers map[models.PlatformType]IDeliverer, logger logger.Logger, now func() time.Time) IQueue {
+	holder := cfg.InstanceID
+	if holder == "" {
+		hostname, _ := os.Hostname()
+		holder = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	return &queue{
+		store:      store,
+		runner:     runner,
+		deliverers: deliverers,
+		holder:     holder,
+		workers:    cfg.Queue.Workers,
+		visibility: time.Duration(cfg.Queue.VisibilitySeconds) * time.Second,
+		attempts:   cfg.Queue.MaxAttempts,
+		priorities: map[models.PlatformType]int{
+			models.PlatformTelegram: cfg.Queue.PriorityTelegram,
+			models.PlatformDiscord:  cfg.Queue.PriorityDiscord,
+		},
+		now:    now,
+		logger: logger,
+
+		agentURL:   cfg.AgentURL,
+		agentToken: cfg.AgentToken,
+
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (q *queue) Enqueue(job models.Job) error {
+	job.Priority = q.priorities[job.Platform]
+	job.AvailableAt = q.now().UTC()
+	if err := q.store.Push(job); err != nil {
+		return fmt.Errorf("failed to queue job: %w", err)
+	}
+
+	// An idle worker of this instance takes the job right away, the others poll
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *queue) Start() error {
+	if q.workers <= 0 {
+		return fmt.Errorf("invalid number of queue workers %d", q.workers)
+	}
+	if q.visibility <= 0 {
+		return fmt.Errorf("invalid queue visibility timeout %s", q.visibility)
+	}
+	q.stop = make(chan struct{})
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return nil
+}
+
+func (q *queue) End() error {
+	if q.stop == nil {
+		return nil
+	}
+	close(q.stop)
+	q.wg.Wait()
+	return nil
+}
+
+// work runs jobs until End is called, waiting for new ones when the queue is empty
+func (q *queue) work() {
+	defer q.wg.Done()
+
+	for {
+		ran, err := q.RunNext(context.Background())
+		if err != nil {
+			q.logger.Error(err, "Failed to run queued job")
+		}
+
+		if ran {
+			select {
+			case <-q.stop:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// RunNext runs the next job and posts its answer, or why there is none. A job is removed
+// once answered, when its worker stops first the job runs again after the visibility timeout.
+func (q *queue) RunNext(ctx context.Context) (bool, error) {
+	job, err := q.store.Claim(q.holder, q.now().UTC(), q.visibility)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	var errs []error
+	text := q.run(*job)
+
+	if deliverer, ok := q.deliverers[job.Platform]; ok {
+		ctx, cancel := context.WithTimeout(ctx, deliverTimeout)
+		defer cancel()
+
+		if err := deliverer.Deliver(ctx, *job, text); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post the answer of job %d: %w", job.ID, err))
+		}
+	} else {
+		q.logger.Warnf("No deliverer for job %d on %s", job.ID, job.Platform)
+	}
+
+	if err := q.store.Remove(*job); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove job %d: %w", job.ID, err))
+	}
+	return true, errors.Join(errs...)
+}
+
+// run asks the built-in agent, it returns the answer or why there is none
+func (q *queue) run(job models.Job) string {
+	// A job claimed that many times stopped its workers, or never finished in time
+	if q.attempts > 0 && job.Attempts > q.attempts {
+		q.logger.Warnf("Giving up job %d after %d attempts", job.ID, job.Attempts-1)
+		return "Error executing command: the request was interrupted too many times. Please try again."
+	}
+
+	resp, err := q.runner.Run(agent.Request{
+		Platform:   job.Platform,
+		UserID:     job.UserID,
+		ServerID:   job.ChatID,
+		ConfigType: models.ConfigTypeBuiltin,
+		Command:    job.Command,
+		Message:    job.Message,
+		URL:        q.agentURL,
+		Token:      q.agentToken,
+	})
+	if err != nil {
+		q.logger.Errorf(err, "Failed to run job %d", job.ID)
+		return fmt.Sprintf("Error executing command: %v", err)
+	}
+	if resp.Answer == "" {
+		return "Error executing command: empty response from LLM"
+	}
+
+	if resp.Cached {
+		return resp.Answer + "\n\n" + agent.CachedNote
+	}
+	return resp.Answer
+}
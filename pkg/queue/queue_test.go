@@ -0,0 +1,309 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/agent"
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for the queue
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeRunner answers every message with "answer to" the message, and counts the runs
+type fakeRunner struct {
+	mu   sync.Mutex
+	runs int
+}
+
+func (r *fakeRunner) Run(req agent.Request) (*dify.ChatResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs++
+	return &dify.ChatResponse{Answer: "answer to " + req.Message}, nil
+}
+
+// fakeDeliverer records the answers posted, in order
+type fakeDeliverer struct {
+	mu      sync.Mutex
+	answers []string
+}
+
+func (d *fakeDeliverer) Deliver(_ context.Context, _ models.Job, text string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.answers = append(d.answers, text)
+	return nil
+}
+
+func (d *fakeDeliverer) posted() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.answers...)
+}
+
+// stores creates the stores the queue tests run against
+var stores = map[string]func(t *testing.T) IStore{
+	"memory": func(t *testing.T) IStore { return NewMemoryStore() },
+	"sqlite": func(t *testing.T) IStore {
+		require.NoError(t, models.InitIDGenerators())
+		db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+		require.NoError(t, err)
+		_, err = database.Migrate(db)
+		require.NoError(t, err)
+		return NewPostgresStore(db)
+	},
+}
+
+// fixture is a queue hiding claimed jobs for a minute, Discord jobs run before Telegram ones
+type fixture struct {
+	queue     IQueue
+	store     IStore
+	runner    *fakeRunner
+	deliverer *fakeDeliverer
+	clock     *clock
+}
+
+// forEachStore runs test with a queue giving up jobs after maxAttempts, over each store
+func forEachStore(t *testing.T, maxAttempts int, test func(t *testing.T, f *fixture)) {
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			f := &fixture{
+				store:     store(t),
+				runner:    &fakeRunner{},
+				deliverer: &fakeDeliverer{},
+				clock:     &clock{now: time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)},
+			}
+			cfg := config.Config{InstanceID: "test", Queue: config.QueueConfig{
+				Workers:           1,
+				VisibilitySeconds: 60,
+				MaxAttempts:       maxAttempts,
+				PriorityTelegram:  1,
+				PriorityDiscord:   2,
+			}}
+			deliverers := map[models.PlatformType]IDeliverer{
+				models.PlatformTelegram: f.deliverer,
+				models.PlatformDiscord:  f.deliverer,
+			}
+			f.queue = NewWithClock(f.store, cfg, f.runner, deliverers, logger.NewLogrusLogger(), f.clock.Now)
+			test(t, f)
+		})
+	}
+}
+
+// runAll runs the available jobs and returns how many ran
+func (f *fixture) runAll(t *testing.T) int {
+	t.Helper()
+	count := 0
+	for {
+		ran, err := f.queue.RunNext(context.Background())
+		require.NoError(t, err)
+		if !ran {
+			return count
+		}
+		count++
+	}
+}
+
+func TestPriority(t *testing.T) {
+	forEachStore(t, 3, func(t *testing.T, f *fixture) {
+		jobs := []models.Job{
+			{Platform: models.PlatformTelegram, Message: "t1"},
+			{Platform: models.PlatformDiscord, Message: "d1"},
+			{Platform: models.PlatformTelegram, Message: "t2"},
+			{Platform: models.PlatformDiscord, Message: "d2"},
+		}
+		for _, job := range jobs {
+			require.NoError(t, f.queue.Enqueue(job))
+			f.clock.Advance(time.Millisecond)
+		}
+
+		// Higher priorities run first, then the jobs queued first
+		assert.Equal(t, 4, f.runAll(t))
+		assert.Equal(t, []string{"answer to d1", "answer to d2", "answer to t1", "answer to t2"}, f.deliverer.posted())
+	})
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	forEachStore(t, 3, func(t *testing.T, f *fixture) {
+		require.NoError(t, f.queue.Enqueue(models.Job{Platform: models.PlatformTelegram, Message: "a"}))
+
+		// A worker claims the job and stops before answering it
+		stopped, err := f.store.Claim("stopped", f.clock.Now(), time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, stopped)
+		assert.Equal(t, 1, stopped.Attempts)
+
+		// The job is hidden from the other workers until the visibility timeout passes
+		f.clock.Advance(59 * time.Second)
+		assert.Zero(t, f.runAll(t))
+
+		f.clock.Advance(time.Second)
+		assert.Equal(t, 1, f.runAll(t))
+		assert.Equal(t, []string{"answer to a"}, f.deliverer.posted())
+
+		// The job is gone once answered
+		f.clock.Advance(time.Hour)
+		assert.Zero(t, f.runAll(t))
+	})
+}
+
+func TestStaleRemove(t *testing.T) {
+	forEachStore(t, 3, func(t *testing.T, f *fixture) {
+		require.NoError(t, f.queue.Enqueue(models.Job{Platform: models.PlatformTelegram, Message: "a"}))
+
+		first, err := f.store.Claim("slow", f.clock.Now(), time.Minute)
+		require.NoError(t, err)
+		f.clock.Advance(time.Minute)
+		second, err := f.store.Claim("other", f.clock.Now(), time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, second)
+		assert.Equal(t, first.ID, second.ID)
+
+		// The worker that timed out doesn't drop the job claimed again since
+		require.NoError(t, f.store.Remove(*first))
+		f.clock.Advance(time.Minute)
+		third, err := f.store.Claim("other", f.clock.Now(), time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, third)
+		assert.Equal(t, 3, third.Attempts)
+
+		require.NoError(t, f.store.Remove(*third))
+		f.clock.Advance(time.Minute)
+		none, err := f.store.Claim("other", f.clock.Now(), time.Minute)
+		require.NoError(t, err)
+		assert.Nil(t, none)
+	})
+}
+
+func TestMaxAttempts(t *testing.T) {
+	forEachStore(t, 2, func(t *testing.T, f *fixture) {
+		require.NoError(t, f.queue.Enqueue(models.Job{Platform: models.PlatformTelegram, Message: "a"}))
+		for range 2 {
+			_, err := f.store.Claim("stopped", f.clock.Now(), time.Minute)
+			require.NoError(t, err)
+			f.clock.Advance(time.Minute)
+		}
+
+		// The job is given up without running the agent, and the user is told
+		assert.Equal(t, 1, f.runAll(t))
+		assert.Zero(t, f.runner.runs)
+		assert.Equal(t, []string{"Error executing command: the request was interrupted too many times. Please try again."}, f.deliverer.posted())
+	})
+}
+
+func TestConcurrentClaim(t *testing.T) {
+	forEachStore(t, 3, func(t *testing.T, f *fixture) {
+		for i := 0; i < 20; i++ {
+			require.NoError(t, f.queue.Enqueue(models.Job{Platform: models.PlatformTelegram, Message: "a"}))
+		}
+
+		// Workers claiming at once each take a different job
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			claimed = map[int64]int{}
+		)
+		for i := 0; i < 30; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				job, err := f.store.Claim("worker", f.clock.Now(), time.Minute)
+				assert.NoError(t, err)
+				if job != nil {
+					mu.Lock()
+					claimed[job.ID]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, claimed, 20)
+		for id, count := range claimed {
+			assert.Equal(t, 1, count, id)
+		}
+	})
+}
+
+// blockingRunner holds every run until release is closed, and tracks the runs at once
+type blockingRunner struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingRunner) Run(req agent.Request) (*dify.ChatResponse, error) {
+	r.mu.Lock()
+	r.running++
+	r.peak = max(r.peak, r.running)
+	r.mu.Unlock()
+	r.started <- struct{}{}
+
+	<-r.release
+
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return &dify.ChatResponse{Answer: "answer to " + req.Message}, nil
+}
+
+func TestWorkers(t *testing.T) {
+	runner := &blockingRunner{started: make(chan struct{}, 10), release: make(chan struct{})}
+	deliverer := &fakeDeliverer{}
+	cfg := config.Config{InstanceID: "test", Queue: config.QueueConfig{Workers: 2, VisibilitySeconds: 60}}
+	q := New(NewMemoryStore(), cfg, runner, map[models.PlatformType]IDeliverer{models.PlatformTelegram: deliverer}, logger.NewLogrusLogger())
+
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, q.Enqueue(models.Job{Platform: models.PlatformTelegram, Message: message}))
+	}
+	require.NoError(t, q.Start())
+
+	// Two jobs run at once, the others wait for a free worker
+	<-runner.started
+	<-runner.started
+	select {
+	case <-runner.started:
+		t.Fatal("a third job ran at once")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(runner.release)
+	require.Eventually(t, func() bool { return len(deliverer.posted()) == 5 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, q.End())
+	assert.Equal(t, 2, runner.peak)
+}
+
+func TestStartInvalid(t *testing.T) {
+	for _, cfg := range []config.QueueConfig{{Workers: 0, VisibilitySeconds: 60}, {Workers: 1}} {
+		q := New(NewMemoryStore(), config.Config{InstanceID: "test", Queue: cfg}, &fakeRunner{}, nil, logger.NewLogrusLogger())
+		assert.Error(t, q.Start())
+	}
+}
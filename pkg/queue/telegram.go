@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// maxTelegramMessageLength is the length of the longest message Telegram accepts
+const maxTelegramMessageLength = 4096
+
+// telegramDeliverer posts answers with the Telegram bot
+type telegramDeliverer struct {
+	bot *bot.Bot
+}
+
+// NewTelegramDeliverer creates a deliverer replying in Telegram chats
+func NewTelegramDeliverer(b *bot.Bot) IDeliverer {
+	return &telegramDeliverer{bot: b}
+}
+
+func (d *telegramDeliverer) Deliver(ctx context.Context, job models.Job, text string) error {
+	chatID, err := strconv.ParseInt(job.ChatID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Telegram chat ID %q: %w", job.ChatID, err)
+	}
+
+	if runes := []rune(text); len(runes) > maxTelegramMessageLength {
+		text = string(runes[:maxTelegramMessageLength-1]) + "…"
+	}
+	params := &bot.SendMessageParams{
+		ChatID:              chatID,
+		Text:                text,
+		ParseMode:           telegramMod.ParseModeMarkdownV1,
+		ProtectContent:      true,
+		DisableNotification: true,
+	}
+	if messageID, err := strconv.Atoi(job.MessageID); err == nil {
+		params.ReplyParameters = &telegramMod.ReplyParameters{
+			ChatID:                   chatID,
+			MessageID:                messageID,
+			AllowSendingWithoutReply: true,
+		}
+	}
+
+	if _, err := d.bot.SendMessage(ctx, params); err != nil {
+		// Answers that aren't valid Markdown are posted as plain text
+		params.ParseMode = ""
+		if _, err := d.bot.SendMessage(ctx, params); err != nil {
+			return err
+		}
+	}
+
+	if noticeID, err := strconv.Atoi(job.NoticeID); err == nil {
+		if _, err := d.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: chatID, MessageID: noticeID}); err != nil {
+			return fmt.Errorf("failed to delete the notice: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// refill returns the number of tokens in a bucket after the time elapsed since last
+func refill(tokens float64, last, now time.Time, rate, burst float64) float64 {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(burst, tokens+elapsed*rate)
+}
+
+// take tries to take a single token from a bucket holding the given number of tokens.
+// It returns the remaining tokens, whether a token was taken and how long to wait otherwise.
+func take(tokens, rate float64) (float64, bool, time.Duration) {
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	if rate <= 0 {
+		return tokens, false, 0
+	}
+	wait := time.Duration((1 - tokens) / rate * float64(time.Second))
+	return tokens, false, wait
+}
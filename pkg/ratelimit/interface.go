@@ -0,0 +1,74 @@
+// Package ratelimit provides token bucket rate limiting and quota accounting for agent invocations.
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// ILimiter defines the interface for checking and tracking agent invocation limits
+type ILimiter interface {
+	Allow(req Request) (Decision, error)
+	Usage(configID int64) ([]models.QuotaUsage, error)
+	Reset(configID int64) error
+}
+
+// IStore defines the persistence used by the limiter for buckets and quota counters
+type IStore interface {
+	// Take takes a token from every bucket and increments every quota counter at once. When
+	// a bucket is empty or a counter reached its limit nothing is taken, and the returned
+	// Refusal names the limit.
+	Take(buckets []Bucket, quotas []Quota, now time.Time) (*Refusal, error)
+	ListCounts(configID int64) ([]models.QuotaUsage, error)
+	ResetCounts(configID int64) error
+}
+
+// Bucket is a token bucket an invocation takes a token from
+type Bucket struct {
+	Key   string
+	Rate  float64 // Tokens added per second
+	Burst float64 // Maximum number of tokens
+}
+
+// Quota is a counter an invocation increments, up to its limit
+type Quota struct {
+	Key   models.QuotaUsage
+	Limit int // 0 for no limit, the counter is still incremented for reporting
+}
+
+// Refusal names the limit refusing an invocation
+type Refusal struct {
+	Bucket     int           // Index of the empty bucket, -1 when a quota refused
+	Quota      int           // Index of the quota that reached its limit, -1 when a bucket refused
+	RetryAfter time.Duration // When the empty bucket has a token again
+}
+
+// full reports whether a counter reached the limit of q
+func (q Quota) full(count int) bool {
+	return q.Limit > 0 && count >= q.Limit
+}
+
+// Request describes a single agent invocation to be checked against limits
+type Request struct {
+	UserID  string                    // Platform-specific user identifier
+	ChatID  string                    // Platform-specific chat identifier
+	Command string                    // Command being invoked
+	Config  *models.ServerAdminConfig // Server config carrying quotas, nil for personal or built-in commands
+}
+
+// Decision is the result of a limit check
+type Decision struct {
+	Allowed    bool          // Whether the invocation may proceed
+	Reason     string        // Human readable reason when denied
+	RetryAfter time.Duration // Suggested wait time when rate limited
+}
+
+// Message returns a user facing explanation of a denied decision
+func (d Decision) Message() string {
+	if d.RetryAfter > 0 {
+		return fmt.Sprintf("⏳ %s Please try again in %s.", d.Reason, d.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("⏳ %s", d.Reason)
+}
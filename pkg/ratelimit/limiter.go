@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/models"
+)
+
+// limiter implements ILimiter using token buckets and quota counters kept in a store
+type limiter struct {
+	store IStore
+	cfg   config.RateLimitConfig
+	loc   *time.Location // Time zone of the daily and monthly quota windows
+	now   func() time.Time
+}
+
+// New creates a new limiter with the provided store and rate limit configuration
+func New(store IStore, cfg config.RateLimitConfig) ILimiter {
+	return NewWithClock(store, cfg, time.Now)
+}
+
+// NewWithClock creates a new limiter using a custom clock, mainly useful for tests
+func NewWithClock(store IStore, cfg config.RateLimitConfig, now func() time.Time) ILimiter {
+	return &limiter{
+		store: store,
+		cfg:   cfg,
+		loc:   Location(cfg.Timezone),
+		now:   now,
+	}
+}
+
+// Allow checks the request against the user, chat and command buckets and the
+// quotas of the server config. Tokens are taken and quota counters incremented in one
+// step, and only when every limit allows the request.
+func (l *limiter) Allow(req Request) (Decision, error) {
+	now := l.now().UTC()
+
+	var (
+		buckets []Bucket
+		reasons []string
+	)
+	for _, b := range []struct {
+		key       string
+		perMinute int
+		reason    string
+	}{
+		{fmt.Sprintf("user:%s", req.UserID), l.cfg.UserPerMinute, "You're sending requests too quickly."},
+		{fmt.Sprintf("chat:%s", req.ChatID), l.cfg.ChatPerMinute, "This chat is sending requests too quickly."},
+		{fmt.Sprintf("command:%s:%s", req.ChatID, req.Command), l.cfg.CommandPerMinute, fmt.Sprintf("Command '%s' is being used too quickly.", req.Command)},
+	} {
+		if b.perMinute <= 0 {
+			continue
+		}
+
+		burst := float64(l.cfg.Burst)
+		if burst < 1 {
+			burst = float64(b.perMinute)
+		}
+		buckets = append(buckets, Bucket{Key: b.key, Rate: float64(b.perMinute) / 60, Burst: burst})
+		reasons = append(reasons, b.reason)
+	}
+
+	var quotas []Quota
+	var quotaReasons []string
+	if req.Config != nil && req.Config.ID != 0 {
+		quotas = []Quota{
+			{l.dayKey(req.Config.ID, "", now), req.Config.DailyQuota},
+			{l.monthKey(req.Config.ID, "", now), req.Config.MonthlyQuota},
+			{l.dayKey(req.Config.ID, req.UserID, now), req.Config.UserDailyQuota},
+		}
+		quotaReasons = []string{
+			fmt.Sprintf("Daily quota for '%s' has been reached.", req.Command),
+			fmt.Sprintf("Monthly quota for '%s' has been reached.", req.Command),
+			fmt.Sprintf("You have reached your daily quota for '%s'.", req.Command),
+		}
+	}
+
+	refusal, err := l.store.Take(buckets, quotas, now)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to check limits: %w", err)
+	}
+	switch {
+	case refusal == nil:
+		return Decision{Allowed: true}, nil
+	case refusal.Bucket >= 0:
+		return Decision{Reason: reasons[refusal.Bucket], RetryAfter: refusal.RetryAfter}, nil
+	}
+	return Decision{Reason: quotaReasons[refusal.Quota]}, nil
+}
+
+// Usage returns the quota counters of the current day and month for a server config
+func (l *limiter) Usage(configID int64) ([]models.QuotaUsage, error) {
+	now := l.now().UTC()
+	day := DayStart(now, l.loc)
+	month := MonthStart(now, l.loc)
+
+	usages, err := l.store.ListCounts(configID)
+	if err != nil {
+		return nil, err
+	}
+
+	var current []models.QuotaUsage
+	for _, u := range usages {
+		if (u.Period == models.QuotaPeriodDay && u.PeriodStart.Equal(day)) ||
+			(u.Period == models.QuotaPeriodMonth && u.PeriodStart.Equal(month)) {
+			current = append(current, u)
+		}
+	}
+	return current, nil
+}
+
+// Reset clears all quota counters of a server config
+func (l *limiter) Reset(configID int64) error {
+	return l.store.ResetCounts(configID)
+}
+
+// dayKey returns the counter of the calendar day of now in the quota time zone
+func (l *limiter) dayKey(configID int64, userID string, now time.Time) models.QuotaUsage {
+	return models.QuotaUsage{
+		ConfigID:    configID,
+		UserID:      userID,
+		Period:      models.QuotaPeriodDay,
+		PeriodStart: DayStart(now, l.loc),
+	}
+}
+
+// monthKey returns the counter of the calendar month of now in the quota time zone
+func (l *limiter) monthKey(configID int64, userID string, now time.Time) models.QuotaUsage {
+	return models.QuotaUsage{
+		ConfigID:    configID,
+		UserID:      userID,
+		Period:      models.QuotaPeriodMonth,
+		PeriodStart: MonthStart(now, l.loc),
+	}
+}
@@ -0,0 +1,262 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for the limiter
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)}
+}
+
+// stores creates the stores the limiter tests run against
+var stores = map[string]func(t *testing.T) IStore{
+	"memory": func(t *testing.T) IStore { return NewMemoryStore() },
+	"sqlite": func(t *testing.T) IStore {
+		require.NoError(t, models.InitIDGenerators())
+		db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+		require.NoError(t, err)
+		_, err = database.Migrate(db)
+		require.NoError(t, err)
+
+		// The quotas count the invocations of server config 7
+		for _, statement := range []string{
+			"INSERT INTO users (id, user_id, platform, username) VALUES (1, 'u1', 'telegram', 'u1')",
+			"INSERT INTO servers (id, server_id, platform, server_name, owner_id) VALUES (2, 'c1', 'telegram', 'c1', 1)",
+			"INSERT INTO server_admin_configs (id, server_id, api_key, endpoint_url, command) VALUES (7, 2, '', '', 'ask')",
+		} {
+			require.NoError(t, db.Exec(statement).Error)
+		}
+		return NewPostgresStore(db)
+	},
+}
+
+// forEachStore runs test with a limiter of cfg, over each store
+func forEachStore(t *testing.T, cfg config.RateLimitConfig, test func(t *testing.T, l ILimiter, c *clock)) {
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := newClock()
+			test(t, NewWithClock(store(t), cfg, c.Now), c)
+		})
+	}
+}
+
+func TestAllowUserBucket(t *testing.T) {
+	forEachStore(t, config.RateLimitConfig{UserPerMinute: 2, Burst: 2}, func(t *testing.T, l ILimiter, c *clock) {
+		req := Request{UserID: "u1", ChatID: "c1", Command: "ask"}
+
+		for i := 0; i < 2; i++ {
+			d, err := l.Allow(req)
+			require.NoError(t, err)
+			assert.True(t, d.Allowed)
+		}
+
+		d, err := l.Allow(req)
+		require.NoError(t, err)
+		assert.False(t, d.Allowed)
+		assert.Equal(t, "You're sending requests too quickly.", d.Reason)
+		assert.Equal(t, 30*time.Second, d.RetryAfter)
+
+		// Other users have their own bucket
+		d, err = l.Allow(Request{UserID: "u2", ChatID: "c1", Command: "ask"})
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+
+		c.Advance(30 * time.Second)
+		d, err = l.Allow(req)
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+	})
+}
+
+func TestAllowQuotas(t *testing.T) {
+	forEachStore(t, config.RateLimitConfig{}, func(t *testing.T, l ILimiter, c *clock) {
+		cfg := &models.ServerAdminConfig{ID: 7, DailyQuota: 3, UserDailyQuota: 2}
+
+		allow := func(userID string) Decision {
+			d, err := l.Allow(Request{UserID: userID, ChatID: "c1", Command: "ask", Config: cfg})
+			require.NoError(t, err)
+			return d
+		}
+
+		assert.True(t, allow("u1").Allowed)
+		assert.True(t, allow("u1").Allowed)
+
+		d := allow("u1")
+		assert.False(t, d.Allowed)
+		assert.Equal(t, "You have reached your daily quota for 'ask'.", d.Reason)
+
+		assert.True(t, allow("u2").Allowed)
+		d = allow("u2")
+		assert.False(t, d.Allowed)
+		assert.Equal(t, "Daily quota for 'ask' has been reached.", d.Reason)
+
+		// Refused requests aren't counted
+		usages, err := l.Usage(7)
+		require.NoError(t, err)
+		counts := map[string]int{}
+		for _, u := range usages {
+			counts[string(u.Period)+":"+u.UserID] = u.Count
+		}
+		assert.Equal(t, map[string]int{"day:": 3, "month:": 3, "day:u1": 2, "day:u2": 1}, counts)
+
+		// The day quota starts over the next day
+		c.Advance(24 * time.Hour)
+		assert.True(t, allow("u1").Allowed)
+
+		require.NoError(t, l.Reset(7))
+		usages, err = l.Usage(7)
+		require.NoError(t, err)
+		assert.Empty(t, usages)
+	})
+}
+
+func TestAllowRefusedQuotaKeepsTokens(t *testing.T) {
+	forEachStore(t, config.RateLimitConfig{UserPerMinute: 2, Burst: 2}, func(t *testing.T, l ILimiter, c *clock) {
+		cfg := &models.ServerAdminConfig{ID: 7, DailyQuota: 1}
+
+		d, err := l.Allow(Request{UserID: "u1", ChatID: "c1", Command: "ask", Config: cfg})
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+
+		// The quota refuses without taking the last token of the user
+		d, err = l.Allow(Request{UserID: "u1", ChatID: "c1", Command: "ask", Config: cfg})
+		require.NoError(t, err)
+		require.False(t, d.Allowed)
+
+		d, err = l.Allow(Request{UserID: "u1", ChatID: "c1", Command: "other"})
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+	})
+}
+
+func TestAllowRefusedBucketKeepsCounts(t *testing.T) {
+	forEachStore(t, config.RateLimitConfig{ChatPerMinute: 1, Burst: 1}, func(t *testing.T, l ILimiter, c *clock) {
+		cfg := &models.ServerAdminConfig{ID: 7, DailyQuota: 5}
+
+		for i := 0; i < 3; i++ {
+			_, err := l.Allow(Request{UserID: "u1", ChatID: "c1", Command: "ask", Config: cfg})
+			require.NoError(t, err)
+		}
+
+		usages, err := l.Usage(7)
+		require.NoError(t, err)
+		for _, u := range usages {
+			assert.Equal(t, 1, u.Count, "%s %s", u.Period, u.UserID)
+		}
+	})
+}
+
+func TestAllowConcurrentQuota(t *testing.T) {
+	forEachStore(t, config.RateLimitConfig{}, func(t *testing.T, l ILimiter, c *clock) {
+		cfg := &models.ServerAdminConfig{ID: 7, DailyQuota: 10}
+
+		var (
+			wg      sync.WaitGroup
+			allowed atomic.Int32
+		)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d, err := l.Allow(Request{UserID: "u1", ChatID: "c1", Command: "ask", Config: cfg})
+				if err == nil && d.Allowed {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(10), allowed.Load())
+	})
+}
+
+func TestAllowConcurrentBucket(t *testing.T) {
+	forEachStore(t, config.RateLimitConfig{CommandPerMinute: 60, Burst: 5}, func(t *testing.T, l ILimiter, c *clock) {
+
+		var (
+			wg      sync.WaitGroup
+			allowed atomic.Int32
+		)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d, err := l.Allow(Request{UserID: "u1", ChatID: "c1", Command: "ask"})
+				if err == nil && d.Allowed {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(5), allowed.Load())
+	})
+}
+
+func TestWindows(t *testing.T) {
+	loc := Location("Asia/Ho_Chi_Minh")
+	// 20:00 UTC on Sunday the 30th of November is 03:00 on Monday the 1st of December in UTC+7
+	now := time.Date(2025, 11, 30, 20, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, 11, 30, 17, 0, 0, 0, time.UTC), DayStart(now, loc))
+	assert.Equal(t, time.Date(2025, 11, 30, 17, 0, 0, 0, time.UTC), WeekStart(now, loc))
+	assert.Equal(t, time.Date(2025, 11, 30, 17, 0, 0, 0, time.UTC), MonthStart(now, loc))
+
+	assert.Equal(t, time.Date(2025, 11, 30, 0, 0, 0, 0, time.UTC), DayStart(now, time.UTC))
+	assert.Equal(t, time.Date(2025, 11, 24, 0, 0, 0, 0, time.UTC), WeekStart(now, time.UTC))
+	assert.Equal(t, time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC), MonthStart(now, time.UTC))
+
+	assert.Equal(t, time.UTC, Location(""))
+	assert.Equal(t, time.UTC, Location("Nowhere/City"))
+}
+
+func TestAllowMonthlyQuotaCalendar(t *testing.T) {
+	c := &clock{now: time.Date(2025, 1, 31, 16, 0, 0, 0, time.UTC)}
+	l := NewWithClock(NewMemoryStore(), config.RateLimitConfig{Timezone: "Asia/Ho_Chi_Minh"}, c.Now)
+	cfg := &models.ServerAdminConfig{ID: 7, MonthlyQuota: 1}
+	req := Request{UserID: "u1", ChatID: "c1", Command: "ask", Config: cfg}
+
+	d, err := l.Allow(req)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+
+	d, err = l.Allow(req)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "Monthly quota for 'ask' has been reached.", d.Reason)
+
+	// Midnight in UTC+7 starts February, although it is still January in UTC
+	c.Advance(time.Hour)
+	d, err = l.Allow(req)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+}
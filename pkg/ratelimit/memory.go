@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// memoryBucket holds the state of a single in-memory token bucket
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// memoryStore is an in-memory IStore, suitable for tests and deployments without a database
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	counts  map[models.QuotaUsage]int
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() IStore {
+	return &memoryStore{
+		buckets: make(map[string]memoryBucket),
+		counts:  make(map[models.QuotaUsage]int),
+	}
+}
+
+func (s *memoryStore) Take(buckets []Bucket, quotas []Quota, now time.Time) (*Refusal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Every limit is checked before anything is taken
+	tokens := make([]float64, len(buckets))
+	for i, b := range buckets {
+		bucket, ok := s.buckets[b.Key]
+		if !ok {
+			bucket = memoryBucket{tokens: b.Burst, updatedAt: now}
+		}
+
+		var allowed bool
+		var wait time.Duration
+		tokens[i], allowed, wait = take(refill(bucket.tokens, bucket.updatedAt, now, b.Rate, b.Burst), b.Rate)
+		if !allowed {
+			return &Refusal{Bucket: i, Quota: -1, RetryAfter: wait}, nil
+		}
+	}
+	for i, q := range quotas {
+		if q.full(s.counts[normalizeKey(q.Key)]) {
+			return &Refusal{Bucket: -1, Quota: i}, nil
+		}
+	}
+
+	for i, b := range buckets {
+		s.buckets[b.Key] = memoryBucket{tokens: tokens[i], updatedAt: now}
+	}
+	for _, q := range quotas {
+		s.counts[normalizeKey(q.Key)]++
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) ListCounts(configID int64) ([]models.QuotaUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var usages []models.QuotaUsage
+	for key, count := range s.counts {
+		if key.ConfigID != configID {
+			continue
+		}
+		key.Count = count
+		usages = append(usages, key)
+	}
+	return usages, nil
+}
+
+func (s *memoryStore) ResetCounts(configID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.counts {
+		if key.ConfigID == configID {
+			delete(s.counts, key)
+		}
+	}
+	return nil
+}
+
+// normalizeKey strips the counter value so a QuotaUsage can be used as a map key
+func normalizeKey(key models.QuotaUsage) models.QuotaUsage {
+	key.Count = 0
+	return key
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postgresStore is an IStore backed by the rate_limit_buckets and quota_usages tables
+type postgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a new database backed store. It also works on SQLite,
+// where the row lock is dropped and writes are serialized by the database.
+func NewPostgresStore(db *gorm.DB) IStore {
+	return &postgresStore{db: db}
+}
+
+// errRefused rolls back the transaction of Take when a limit refuses the invocation
+var errRefused = errors.New("refused")
+
+func (s *postgresStore) Take(buckets []Bucket, quotas []Quota, now time.Time) (*Refusal, error) {
+	var refusal *Refusal
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i, b := range buckets {
+			// A new bucket is inserted full first, so that concurrent first requests lock the same row
+			bucket := models.RateLimitBucket{Key: b.Key, Tokens: b.Burst, UpdatedAt: now}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&bucket).Error; err != nil {
+				return err
+			}
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", b.Key).First(&bucket).Error
+			if err != nil {
+				return err
+			}
+
+			var (
+				allowed bool
+				wait    time.Duration
+			)
+			bucket.Tokens, allowed, wait = take(refill(bucket.Tokens, bucket.UpdatedAt, now, b.Rate, b.Burst), b.Rate)
+			if !allowed {
+				refusal = &Refusal{Bucket: i, Quota: -1, RetryAfter: wait}
+				return errRefused
+			}
+
+			err = tx.Model(&models.RateLimitBucket{}).Where("key = ?", b.Key).Updates(map[string]interface{}{
+				"tokens":     bucket.Tokens,
+				"updated_at": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, q := range quotas {
+			key := q.Key
+			key.Count = 0
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&key).Error
+			if err != nil {
+				return err
+			}
+
+			// The counter is only incremented below its limit, concurrent requests can't both pass
+			update := tx.Model(&models.QuotaUsage{}).
+				Where("config_id = ? AND user_id = ? AND period = ? AND period_start = ?",
+					q.Key.ConfigID, q.Key.UserID, q.Key.Period, q.Key.PeriodStart)
+			if q.Limit > 0 {
+				update = update.Where("count < ?", q.Limit)
+			}
+			result := update.Update("count", gorm.Expr("count + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				refusal = &Refusal{Bucket: -1, Quota: i}
+				return errRefused
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errRefused) {
+		return refusal, nil
+	}
+	return nil, err
+}
+
+func (s *postgresStore) ListCounts(configID int64) ([]models.QuotaUsage, error) {
+	var usages []models.QuotaUsage
+	return usages, s.db.Where("config_id = ?", configID).Find(&usages).Error
+}
+
+func (s *postgresStore) ResetCounts(configID int64) error {
+	return s.db.Delete(&models.QuotaUsage{}, "config_id = ?", configID).Error
+}
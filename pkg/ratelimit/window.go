@@ -0,0 +1,34 @@
+package ratelimit
+
+import "time"
+
+// Location returns the time zone of the quota windows, UTC when timezone is empty or unknown
+func Location(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DayStart returns the midnight starting the day of t in loc
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).UTC()
+}
+
+// WeekStart returns the midnight starting the week of t in loc, weeks start on Monday
+func WeekStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	days := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, loc).UTC()
+}
+
+// MonthStart returns the midnight starting the calendar month of t in loc
+func MonthStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).UTC()
+}
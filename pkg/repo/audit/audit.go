@@ -0,0 +1,11 @@
+package audit
+
+import "gorm.io/gorm"
+
+type audit struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IAudit {
+	return &audit{db: db}
+}
@@ -0,0 +1,7 @@
+package audit
+
+import "sum/pkg/models"
+
+func (a *audit) Create(event models.AuditEvent) error {
+	return a.db.Create(&event).Error
+}
@@ -0,0 +1,15 @@
+package audit
+
+import "sum/pkg/models"
+
+type IAudit interface {
+	Create(event models.AuditEvent) error
+	ListByServerID(serverID int64, limit, offset int) ([]models.AuditEvent, error)
+	CountByServerID(serverID int64) (int64, error)
+}
+
+// Actor is the user a repository records changes for, the zero Actor stands for the system itself
+type Actor struct {
+	Platform models.PlatformType
+	UserID   string // Platform-specific user identifier
+}
@@ -0,0 +1,14 @@
+package audit
+
+import "sum/pkg/models"
+
+// ListByServerID returns a page of the events of a server, newest first
+func (a *audit) ListByServerID(serverID int64, limit, offset int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	return events, a.db.Where("server_id = ?", serverID).Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&events).Error
+}
+
+func (a *audit) CountByServerID(serverID int64) (int64, error) {
+	var count int64
+	return count, a.db.Model(&models.AuditEvent{}).Where("server_id = ?", serverID).Count(&count).Error
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// SystemActor is the actor ID of changes made without a user, such as key rotations
+const SystemActor = "system"
+
+// redacted replaces secrets in the recorded values
+const redacted = "[redacted]"
+
+// secretFields are the JSON fields never written to the audit log
+var secretFields = []string{"api_key"}
+
+// ignoredFields are the JSON fields left out of the recorded changes, as noise
+// or already held by the event
+var ignoredFields = []string{"id", "created_at", "updated_at", "server", "user"}
+
+// Record writes event with db on behalf of actor. Repositories call it in the
+// transaction of the change, so that no change is left unrecorded.
+func Record(db *gorm.DB, actor Actor, event models.AuditEvent) error {
+	event.Platform = actor.Platform
+	event.ActorID = actor.UserID
+	if event.ActorID == "" {
+		event.ActorID = SystemActor
+	}
+	return db.Create(&event).Error
+}
+
+// Changes returns the JSON of the fields that differ between before and after,
+// with secrets redacted. Both are pointers, a nil before records a creation and
+// a nil after a removal.
+func Changes(before, after any) (string, string, error) {
+	b, err := fields(before)
+	if err != nil {
+		return "", "", err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return "", "", err
+	}
+
+	changedBefore, changedAfter := map[string]any{}, map[string]any{}
+	for key, value := range b {
+		if other, ok := a[key]; !ok || !reflect.DeepEqual(value, other) {
+			changedBefore[key] = value
+		}
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || !reflect.DeepEqual(value, other) {
+			changedAfter[key] = value
+		}
+	}
+
+	// A new secret is recorded as changed even though its value is hidden
+	for _, key := range secretFields {
+		if _, ok := changedBefore[key]; ok && changedBefore[key] != "" {
+			changedBefore[key] = redacted
+		}
+		if _, ok := changedAfter[key]; ok && changedAfter[key] != "" {
+			changedAfter[key] = redacted
+		}
+	}
+
+	return encode(changedBefore), encode(changedAfter), nil
+}
+
+// fields returns the JSON fields of v, without the ignored ones
+func fields(v any) (map[string]any, error) {
+	values := map[string]any{}
+	if v == nil || reflect.ValueOf(v).IsNil() {
+		return values, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Snowflake IDs don't fit in a float64
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	for _, key := range ignoredFields {
+		delete(values, key)
+	}
+	return values, nil
+}
+
+// encode returns the JSON of values, or an empty string when there are none
+func encode(values map[string]any) string {
+	if len(values) == 0 {
+		return ""
+	}
+	raw, _ := json.Marshal(values)
+	return string(raw)
+}
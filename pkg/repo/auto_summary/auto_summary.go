@@ -0,0 +1,11 @@
+package autosummary
+
+import "gorm.io/gorm"
+
+type autoSummary struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IAutoSummary {
+	return &autoSummary{db: db}
+}
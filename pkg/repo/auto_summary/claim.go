@@ -0,0 +1,31 @@
+package autosummary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Claim records url as summarized in a chat at now. It reports false when the link was
+// already summarized there within window, so concurrent messages summarize it once.
+func (a *autoSummary) Claim(platform models.PlatformType, chatID, url string, window time.Duration, now time.Time) (bool, error) {
+	sum := sha256.Sum256([]byte(url))
+	hash := hex.EncodeToString(sum[:])
+
+	result := a.db.Model(&models.SummarizedURL{}).
+		Where("platform = ? AND chat_id = ? AND url_hash = ? AND summarized_at < ?", platform, chatID, hash, now.Add(-window).UTC()).
+		Update("summarized_at", now.UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	result = a.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.SummarizedURL{Platform: platform, ChatID: chatID, URLHash: hash, SummarizedAt: now.UTC()})
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,18 @@
+package autosummary
+
+import (
+	"errors"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Get returns the auto summary setting of a server, servers without one don't summarize links
+func (a *autoSummary) Get(serverID int64) (models.AutoSummary, error) {
+	var setting models.AutoSummary
+	err := a.db.Where("server_id = ?", serverID).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.AutoSummary{ServerID: serverID, Mode: models.AutoSummaryOff}, nil
+	}
+	return setting, err
+}
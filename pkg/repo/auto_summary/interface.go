@@ -0,0 +1,12 @@
+package autosummary
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+type IAutoSummary interface {
+	Get(serverID int64) (models.AutoSummary, error)
+	Save(setting models.AutoSummary) error
+	Claim(platform models.PlatformType, chatID, url string, window time.Duration, now time.Time) (bool, error)
+}
@@ -0,0 +1,17 @@
+package autosummary
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Save stores the auto summary setting of a server
+func (a *autoSummary) Save(setting models.AutoSummary) error {
+	setting.UpdatedAt = time.Now()
+	return a.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "server_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mode", "allow_domains", "deny_domains", "min_length", "updated_at"}),
+	}).Create(&setting).Error
+}
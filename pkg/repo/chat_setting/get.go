@@ -0,0 +1,18 @@
+package chatsetting
+
+import (
+	"errors"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Get returns the settings of a chat, chats without settings get the zero settings
+func (c *chatSetting) Get(platform models.PlatformType, chatID string) (models.ChatSetting, error) {
+	var setting models.ChatSetting
+	err := c.db.Where("platform = ? AND chat_id = ?", platform, chatID).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ChatSetting{Platform: platform, ChatID: chatID}, nil
+	}
+	return setting, err
+}
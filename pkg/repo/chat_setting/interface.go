@@ -0,0 +1,8 @@
+package chatsetting
+
+import "sum/pkg/models"
+
+type IChatSetting interface {
+	Get(platform models.PlatformType, chatID string) (models.ChatSetting, error)
+	SaveDefaultCommand(platform models.PlatformType, chatID, command string) error
+}
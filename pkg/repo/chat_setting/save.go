@@ -0,0 +1,23 @@
+package chatsetting
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// SaveDefaultCommand stores the command answering mentions and private messages in a chat,
+// an empty command falls back to the active config
+func (c *chatSetting) SaveDefaultCommand(platform models.PlatformType, chatID, command string) error {
+	setting := models.ChatSetting{
+		Platform:       platform,
+		ChatID:         chatID,
+		DefaultCommand: command,
+		UpdatedAt:      time.Now(),
+	}
+	return c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "platform"}, {Name: "chat_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"default_command", "updated_at"}),
+	}).Create(&setting).Error
+}
@@ -0,0 +1,11 @@
+package chatsetting
+
+import "gorm.io/gorm"
+
+type chatSetting struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IChatSetting {
+	return &chatSetting{db: db}
+}
@@ -0,0 +1,11 @@
+package comparison
+
+import "gorm.io/gorm"
+
+type comparison struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IComparison {
+	return &comparison{db: db}
+}
@@ -0,0 +1,12 @@
+package comparison
+
+import "sum/pkg/models"
+
+func (c *comparison) Create(comparison models.Comparison) (models.Comparison, error) {
+	return comparison, c.db.Create(&comparison).Error
+}
+
+// SaveMessage records the message posting the answers of a comparison, the one its votes come from
+func (c *comparison) SaveMessage(id int64, messageID string) error {
+	return c.db.Model(&models.Comparison{}).Where("id = ?", id).Update("message_id", messageID).Error
+}
@@ -0,0 +1,8 @@
+package comparison
+
+import "sum/pkg/models"
+
+func (c *comparison) Get(id int64) (models.Comparison, error) {
+	var comparison models.Comparison
+	return comparison, c.db.Where("id = ?", id).First(&comparison).Error
+}
@@ -0,0 +1,12 @@
+package comparison
+
+import "sum/pkg/models"
+
+type IComparison interface {
+	Create(comparison models.Comparison) (models.Comparison, error)
+	Get(id int64) (models.Comparison, error)
+	SaveMessage(id int64, messageID string) error
+	Vote(vote models.ComparisonVote) error
+	CountVotes(comparisonID int64) (map[string]int, error)
+	Standings(platform models.PlatformType, chatID string) ([]models.CommandVotes, error)
+}
@@ -0,0 +1,48 @@
+package comparison
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Vote records the answer a user found best, replacing their previous vote on the comparison
+func (c *comparison) Vote(vote models.ComparisonVote) error {
+	vote.VotedAt = time.Now()
+	return c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "comparison_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"command", "voted_at"}),
+	}).Create(&vote).Error
+}
+
+// CountVotes returns the votes of a comparison by command
+func (c *comparison) CountVotes(comparisonID int64) (map[string]int, error) {
+	var counts []models.CommandVotes
+	err := c.db.Model(&models.ComparisonVote{}).
+		Select("command, COUNT(*) AS votes").
+		Where("comparison_id = ?", comparisonID).
+		Group("command").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make(map[string]int, len(counts))
+	for _, count := range counts {
+		votes[count.Command] = count.Votes
+	}
+	return votes, nil
+}
+
+// Standings returns the votes of the comparisons of a chat by command, the most voted first
+func (c *comparison) Standings(platform models.PlatformType, chatID string) ([]models.CommandVotes, error) {
+	var standings []models.CommandVotes
+	return standings, c.db.Model(&models.ComparisonVote{}).
+		Select("comparison_votes.command, COUNT(*) AS votes").
+		Joins("JOIN comparisons ON comparisons.id = comparison_votes.comparison_id").
+		Where("comparisons.platform = ? AND comparisons.chat_id = ?", platform, chatID).
+		Group("comparison_votes.command").
+		Order("votes DESC, comparison_votes.command").
+		Scan(&standings).Error
+}
@@ -0,0 +1,18 @@
+package feed
+
+import (
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Create creates the feed with the GUIDs of its current items marked as seen, so only
+// the items published from now on are posted
+func (f *feed) Create(feed models.Feed, seen []string) (models.Feed, error) {
+	return feed, f.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&feed).Error; err != nil {
+			return err
+		}
+		return markSeen(tx, feed.ID, seen, feed.CreatedAt)
+	})
+}
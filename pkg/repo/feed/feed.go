@@ -0,0 +1,11 @@
+package feed
+
+import "gorm.io/gorm"
+
+type feed struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IFeed {
+	return &feed{db: db}
+}
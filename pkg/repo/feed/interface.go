@@ -0,0 +1,21 @@
+package feed
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+type IFeed interface {
+	Create(feed models.Feed, seen []string) (models.Feed, error)
+	CountByChatID(platform models.PlatformType, chatID string) (int64, error)
+	ListByChatID(platform models.PlatformType, chatID string) ([]models.Feed, error)
+	ListDue(now time.Time, limit int) ([]models.Feed, error)
+	Claim(id int64, due, next, now time.Time) (bool, error)
+	UpdateByID(platform models.PlatformType, chatID, id string, values map[string]interface{}) error
+	SetError(id int64, message string) error
+	RemoveByID(platform models.PlatformType, chatID, id string) error
+	Remove(id int64) error
+	Seen(feedID int64, guids []string) (map[string]bool, error)
+	MarkSeen(feedID int64, guids []string, now time.Time) error
+	Prune(feedID int64, keep []string, before time.Time) error
+}
@@ -0,0 +1,53 @@
+package feed
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Seen returns which of guids were already seen in a feed
+func (f *feed) Seen(feedID int64, guids []string) (map[string]bool, error) {
+	seen := make(map[string]bool, len(guids))
+	if len(guids) == 0 {
+		return seen, nil
+	}
+
+	var items []models.FeedItem
+	if err := f.db.Where("feed_id = ? AND guid IN ?", feedID, guids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		seen[item.GUID] = true
+	}
+	return seen, nil
+}
+
+// MarkSeen records guids as seen in a feed
+func (f *feed) MarkSeen(feedID int64, guids []string, now time.Time) error {
+	return markSeen(f.db, feedID, guids, now)
+}
+
+// Prune forgets the items seen before before that left the feed, keep lists the GUIDs
+// still in the feed, which would be posted again if forgotten
+func (f *feed) Prune(feedID int64, keep []string, before time.Time) error {
+	query := f.db.Where("feed_id = ? AND seen_at < ?", feedID, before.UTC())
+	if len(keep) > 0 {
+		query = query.Where("guid NOT IN ?", keep)
+	}
+	return query.Delete(&models.FeedItem{}).Error
+}
+
+func markSeen(db *gorm.DB, feedID int64, guids []string, now time.Time) error {
+	if len(guids) == 0 {
+		return nil
+	}
+
+	items := make([]models.FeedItem, 0, len(guids))
+	for _, guid := range guids {
+		items = append(items, models.FeedItem{FeedID: feedID, GUID: guid, SeenAt: now.UTC()})
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
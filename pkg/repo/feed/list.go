@@ -0,0 +1,24 @@
+package feed
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+// CountByChatID returns the number of feeds watched by a chat
+func (f *feed) CountByChatID(platform models.PlatformType, chatID string) (int64, error) {
+	var count int64
+	return count, f.db.Model(&models.Feed{}).Where("platform = ? AND chat_id = ?", platform, chatID).Count(&count).Error
+}
+
+// ListByChatID returns the feeds watched by a chat, the oldest first
+func (f *feed) ListByChatID(platform models.PlatformType, chatID string) ([]models.Feed, error) {
+	var feeds []models.Feed
+	return feeds, f.db.Where("platform = ? AND chat_id = ?", platform, chatID).Order("created_at").Find(&feeds).Error
+}
+
+// ListDue returns up to limit feeds not paused and due at now, the most overdue first
+func (f *feed) ListDue(now time.Time, limit int) ([]models.Feed, error) {
+	var feeds []models.Feed
+	return feeds, f.db.Where("paused = ? AND next_poll_at <= ?", false, now.UTC()).Order("next_poll_at").Limit(limit).Find(&feeds).Error
+}
@@ -0,0 +1,34 @@
+package feed
+
+import (
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// RemoveByID removes a feed watched by a chat, it returns gorm.ErrRecordNotFound when
+// the chat has no such feed
+func (f *feed) RemoveByID(platform models.PlatformType, chatID, id string) error {
+	return f.db.Transaction(func(tx *gorm.DB) error {
+		var feed models.Feed
+		if err := tx.Where("platform = ? AND chat_id = ? AND id = ?", platform, chatID, id).First(&feed).Error; err != nil {
+			return err
+		}
+		return remove(tx, feed.ID)
+	})
+}
+
+// Remove removes a feed, e.g. once its summarizer is gone
+func (f *feed) Remove(id int64) error {
+	return f.db.Transaction(func(tx *gorm.DB) error {
+		return remove(tx, id)
+	})
+}
+
+// remove deletes a feed with its seen items, SQLite doesn't enforce the cascade by default
+func remove(tx *gorm.DB, id int64) error {
+	if err := tx.Delete(&models.FeedItem{}, "feed_id = ?", id).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Feed{}, "id = ?", id).Error
+}
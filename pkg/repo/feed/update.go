@@ -0,0 +1,39 @@
+package feed
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Claim moves a feed due at due to its next poll. It reports false when another
+// instance claimed the poll first, only the instance claiming a poll may run it.
+func (f *feed) Claim(id int64, due, next, now time.Time) (bool, error) {
+	result := f.db.Model(&models.Feed{}).
+		Where("id = ? AND next_poll_at = ?", id, due.UTC()).
+		Updates(map[string]interface{}{
+			"next_poll_at":   next.UTC(),
+			"last_polled_at": now.UTC(),
+			"updated_at":     now.UTC(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateByID updates a feed watched by a chat, it returns gorm.ErrRecordNotFound when
+// the chat has no such feed
+func (f *feed) UpdateByID(platform models.PlatformType, chatID, id string, values map[string]interface{}) error {
+	result := f.db.Model(&models.Feed{}).Where("platform = ? AND chat_id = ? AND id = ?", platform, chatID, id).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetError records why the last poll of a feed failed, an empty message clears it
+func (f *feed) SetError(id int64, message string) error {
+	return f.db.Model(&models.Feed{}).Where("id = ?", id).Update("last_error", message).Error
+}
@@ -0,0 +1,20 @@
+package invocation
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+// CountByServerConfig counts the invocations of a server config since a time, of a single
+// user when userID is not empty
+func (i *invocation) CountByServerConfig(configID int64, userID string, since time.Time) (int, error) {
+	query := i.db.Model(&models.Invocation{}).
+		Where("config_type = ? AND config_id = ? AND created_at >= ?", models.ConfigTypeServer, configID, since)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return int(count), err
+}
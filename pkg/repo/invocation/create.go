@@ -0,0 +1,7 @@
+package invocation
+
+import "sum/pkg/models"
+
+func (i *invocation) Create(invocation models.Invocation) error {
+	return i.db.Create(&invocation).Error
+}
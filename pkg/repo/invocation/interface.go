@@ -0,0 +1,13 @@
+package invocation
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+type IInvocation interface {
+	Create(invocation models.Invocation) error
+	SummarizeByUser(userID, platform string, since time.Time) ([]models.InvocationSummary, error)
+	SummarizeByServer(serverID, platform string, since time.Time) ([]models.InvocationSummary, error)
+	CountByServerConfig(configID int64, userID string, since time.Time) (int, error)
+}
@@ -0,0 +1,11 @@
+package invocation
+
+import "gorm.io/gorm"
+
+type invocation struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IInvocation {
+	return &invocation{db: db}
+}
@@ -0,0 +1,33 @@
+package invocation
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const summarySelect = `command,
+	COUNT(*) AS count,
+	SUM(CASE WHEN success THEN 0 ELSE 1 END) AS errors,
+	SUM(CASE WHEN cached THEN 1 ELSE 0 END) AS cached,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(cost), 0) AS cost,
+	COALESCE(AVG(latency_ms), 0) AS avg_latency_ms`
+
+func (i *invocation) SummarizeByUser(userID, platform string, since time.Time) ([]models.InvocationSummary, error) {
+	return i.summarize(i.db.Where("user_id = ? AND platform = ? AND created_at >= ?", userID, platform, since))
+}
+
+func (i *invocation) SummarizeByServer(serverID, platform string, since time.Time) ([]models.InvocationSummary, error) {
+	return i.summarize(i.db.Where("server_id = ? AND platform = ? AND created_at >= ?", serverID, platform, since))
+}
+
+func (i *invocation) summarize(query *gorm.DB) ([]models.InvocationSummary, error) {
+	var summaries []models.InvocationSummary
+	return summaries, query.Model(&models.Invocation{}).
+		Select(summarySelect).
+		Group("command").
+		Order("count DESC").
+		Scan(&summaries).Error
+}
@@ -0,0 +1,34 @@
+package lease
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Acquire takes or renews the lease name for holder until now+ttl. It reports false
+// while another holder has an unexpired lease.
+func (l *lease) Acquire(name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	expires := now.Add(ttl).UTC()
+
+	result := l.db.Model(&models.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now.UTC()).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": expires})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// Nobody held the lease yet, the first instance to insert it wins
+	result = l.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Lease{Name: name, Holder: holder, ExpiresAt: expires})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Release gives up the lease name if holder has it, so another instance takes over without waiting for it to expire
+func (l *lease) Release(name, holder string) error {
+	return l.db.Delete(&models.Lease{}, "name = ? AND holder = ?", name, holder).Error
+}
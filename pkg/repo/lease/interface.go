@@ -0,0 +1,8 @@
+package lease
+
+import "time"
+
+type ILease interface {
+	Acquire(name, holder string, ttl time.Duration, now time.Time) (bool, error)
+	Release(name, holder string) error
+}
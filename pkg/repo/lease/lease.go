@@ -0,0 +1,11 @@
+package lease
+
+import "gorm.io/gorm"
+
+type lease struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) ILease {
+	return &lease{db: db}
+}
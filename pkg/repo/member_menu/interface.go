@@ -0,0 +1,9 @@
+package membermenu
+
+import "sum/pkg/models"
+
+type IMemberMenu interface {
+	Save(platform models.PlatformType, chatID, userID string) error
+	ListByChatID(platform models.PlatformType, chatID string) ([]models.MemberMenu, error)
+	ListByUserID(platform models.PlatformType, userID string) ([]models.MemberMenu, error)
+}
@@ -0,0 +1,15 @@
+package membermenu
+
+import "sum/pkg/models"
+
+// ListByChatID returns the members of a group with their own menu
+func (m *memberMenu) ListByChatID(platform models.PlatformType, chatID string) ([]models.MemberMenu, error) {
+	var menus []models.MemberMenu
+	return menus, m.db.Where("platform = ? AND chat_id = ?", platform, chatID).Find(&menus).Error
+}
+
+// ListByUserID returns the groups where a user has their own menu
+func (m *memberMenu) ListByUserID(platform models.PlatformType, userID string) ([]models.MemberMenu, error) {
+	var menus []models.MemberMenu
+	return menus, m.db.Where("platform = ? AND user_id = ?", platform, userID).Find(&menus).Error
+}
@@ -0,0 +1,11 @@
+package membermenu
+
+import "gorm.io/gorm"
+
+type memberMenu struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IMemberMenu {
+	return &memberMenu{db: db}
+}
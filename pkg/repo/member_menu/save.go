@@ -0,0 +1,22 @@
+package membermenu
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Save records that the member's menu in the group lists their own commands
+func (m *memberMenu) Save(platform models.PlatformType, chatID, userID string) error {
+	menu := models.MemberMenu{
+		Platform:  platform,
+		ChatID:    chatID,
+		UserID:    userID,
+		UpdatedAt: time.Now(),
+	}
+	return m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "platform"}, {Name: "chat_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at"}),
+	}).Create(&menu).Error
+}
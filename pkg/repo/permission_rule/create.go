@@ -0,0 +1,16 @@
+package permissionrule
+
+import (
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+func (r *permissionRule) Create(rule models.PermissionRule) (models.PermissionRule, error) {
+	return rule, r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		return r.record(tx, models.AuditActionRuleCreate, nil, &rule)
+	})
+}
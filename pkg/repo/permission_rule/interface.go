@@ -0,0 +1,9 @@
+package permissionrule
+
+import "sum/pkg/models"
+
+type IPermissionRule interface {
+	Create(rule models.PermissionRule) (models.PermissionRule, error)
+	ListByServerID(serverID int64) ([]models.PermissionRule, error)
+	RemoveByID(serverID int64, id string) error
+}
@@ -0,0 +1,8 @@
+package permissionrule
+
+import "sum/pkg/models"
+
+func (r *permissionRule) ListByServerID(serverID int64) ([]models.PermissionRule, error) {
+	var rules []models.PermissionRule
+	return rules, r.db.Where("server_id = ?", serverID).Order("created_at").Find(&rules).Error
+}
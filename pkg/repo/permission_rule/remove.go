@@ -0,0 +1,25 @@
+package permissionrule
+
+import (
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+func (r *permissionRule) RemoveByID(serverID int64, id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var before models.PermissionRule
+		if err := tx.Where("server_id = ? AND id = ?", serverID, id).First(&before).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&models.PermissionRule{}, "server_id = ? AND id = ?", serverID, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return r.record(tx, models.AuditActionRuleRemove, &before, nil)
+	})
+}
@@ -0,0 +1,39 @@
+package permissionrule
+
+import (
+	"sum/pkg/models"
+	"sum/pkg/repo/audit"
+
+	"gorm.io/gorm"
+)
+
+type permissionRule struct {
+	db    *gorm.DB
+	actor audit.Actor
+}
+
+// New creates the permission rule repository, its changes are recorded in the audit log on behalf of actor
+func New(db *gorm.DB, actor audit.Actor) IPermissionRule {
+	return &permissionRule{db: db, actor: actor}
+}
+
+// record writes the audit event of a created or removed rule
+func (r *permissionRule) record(tx *gorm.DB, action string, before, after *models.PermissionRule) error {
+	rule := after
+	if rule == nil {
+		rule = before
+	}
+
+	beforeJSON, afterJSON, err := audit.Changes(before, after)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, r.actor, models.AuditEvent{
+		Action:     action,
+		EntityType: models.AuditEntityPermissionRule,
+		EntityID:   rule.ID,
+		ServerID:   rule.ServerID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+	})
+}
@@ -0,0 +1,8 @@
+package pipeline
+
+import "sum/pkg/models"
+
+func (p *pipeline) Get(ownerType models.ConfigType, ownerID int64, name string) (models.Pipeline, error) {
+	var pipeline models.Pipeline
+	return pipeline, p.db.Where("owner_type = ? AND owner_id = ? AND name = ?", ownerType, ownerID, name).First(&pipeline).Error
+}
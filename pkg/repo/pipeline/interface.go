@@ -0,0 +1,10 @@
+package pipeline
+
+import "sum/pkg/models"
+
+type IPipeline interface {
+	Save(pipeline models.Pipeline) error
+	Get(ownerType models.ConfigType, ownerID int64, name string) (models.Pipeline, error)
+	List(ownerType models.ConfigType, ownerID int64) ([]models.Pipeline, error)
+	Remove(ownerType models.ConfigType, ownerID int64, name string) error
+}
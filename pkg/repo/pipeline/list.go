@@ -0,0 +1,8 @@
+package pipeline
+
+import "sum/pkg/models"
+
+func (p *pipeline) List(ownerType models.ConfigType, ownerID int64) ([]models.Pipeline, error) {
+	var pipelines []models.Pipeline
+	return pipelines, p.db.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).Order("name").Find(&pipelines).Error
+}
@@ -0,0 +1,11 @@
+package pipeline
+
+import "gorm.io/gorm"
+
+type pipeline struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) IPipeline {
+	return &pipeline{db: db}
+}
@@ -0,0 +1,19 @@
+package pipeline
+
+import (
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Remove deletes a pipeline, it returns gorm.ErrRecordNotFound when its owner has none with that name
+func (p *pipeline) Remove(ownerType models.ConfigType, ownerID int64, name string) error {
+	result := p.db.Where("owner_type = ? AND owner_id = ? AND name = ?", ownerType, ownerID, name).Delete(&models.Pipeline{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
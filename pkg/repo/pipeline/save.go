@@ -0,0 +1,17 @@
+package pipeline
+
+import (
+	"sum/pkg/models"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Save stores a pipeline, replacing the steps of the pipeline of its owner with the same name
+func (p *pipeline) Save(pipeline models.Pipeline) error {
+	pipeline.UpdatedAt = time.Now()
+	return p.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "owner_type"}, {Name: "owner_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"steps", "created_by", "updated_at"}),
+	}).Create(&pipeline).Error
+}
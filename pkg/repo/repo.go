@@ -2,6 +2,18 @@ package repo
 
 import (
 	"fmt"
+	"sum/pkg/repo/audit"
+	autosummary "sum/pkg/repo/auto_summary"
+	chatsetting "sum/pkg/repo/chat_setting"
+	"sum/pkg/repo/comparison"
+	"sum/pkg/repo/feed"
+	"sum/pkg/repo/invocation"
+	"sum/pkg/repo/lease"
+	membermenu "sum/pkg/repo/member_menu"
+	permissionrule "sum/pkg/repo/permission_rule"
+	"sum/pkg/repo/pipeline"
+	"sum/pkg/repo/schedule"
+	"sum/pkg/repo/secret"
 	"sum/pkg/repo/server"
 	serverconfig "sum/pkg/repo/server_config"
 	"sum/pkg/repo/user"
@@ -17,7 +29,22 @@ type Repository interface {
 	Server() server.IServer
 	ServerConfig() serverconfig.IServerConfig
 	UserConfig() userconfig.IUserConfig
+	Invocation() invocation.IInvocation
+	PermissionRule() permissionrule.IPermissionRule
+	Audit() audit.IAudit
+	ChatSetting() chatsetting.IChatSetting
+	MemberMenu() membermenu.IMemberMenu
+	Schedule() schedule.ISchedule
+	Lease() lease.ILease
+	Feed() feed.IFeed
+	AutoSummary() autosummary.IAutoSummary
+	Comparison() comparison.IComparison
+	Pipeline() pipeline.IPipeline
+	Secret() secret.ISecret
 	WithTx(fn func(txRepo Repository) error) error
+
+	// As returns the repository recording its changes in the audit log on behalf of actor
+	As(actor audit.Actor) Repository
 }
 
 type repository struct {
@@ -26,31 +53,61 @@ type repository struct {
 	server       server.IServer
 	serverConfig serverconfig.IServerConfig
 	userConfig   userconfig.IUserConfig
+	invocation   invocation.IInvocation
+	permission   permissionrule.IPermissionRule
+	audit        audit.IAudit
+	chatSetting  chatsetting.IChatSetting
+	memberMenu   membermenu.IMemberMenu
+	schedule     schedule.ISchedule
+	lease        lease.ILease
+	feed         feed.IFeed
+	autoSummary  autosummary.IAutoSummary
+	comparison   comparison.IComparison
+	pipeline     pipeline.IPipeline
+	secret       secret.ISecret
+	actor        audit.Actor
+}
+
+// NewRepository creates the repository, every API key it stores is encrypted with secret.
+// Its changes are recorded in the audit log as made by the system, see As.
+func NewRepository(db *gorm.DB, secret secret.ISecret) Repository {
+	return newRepository(db, secret, audit.Actor{})
 }
 
-func NewRepository(db *gorm.DB) Repository {
+func newRepository(db *gorm.DB, secret secret.ISecret, actor audit.Actor) *repository {
 	return &repository{
 		db:           db,
-		user:         user.New(db),
+		user:         user.New(db, secret, actor),
 		server:       server.New(db),
-		serverConfig: serverconfig.New(db),
-		userConfig:   userconfig.New(db),
+		serverConfig: serverconfig.New(db, secret, actor),
+		userConfig:   userconfig.New(db, secret, actor),
+		invocation:   invocation.New(db),
+		permission:   permissionrule.New(db, actor),
+		audit:        audit.New(db),
+		chatSetting:  chatsetting.New(db),
+		memberMenu:   membermenu.New(db),
+		schedule:     schedule.New(db),
+		lease:        lease.New(db),
+		feed:         feed.New(db),
+		autoSummary:  autosummary.New(db),
+		comparison:   comparison.New(db),
+		pipeline:     pipeline.New(db),
+		secret:       secret,
+		actor:        actor,
 	}
 }
 
+func (r *repository) As(actor audit.Actor) Repository {
+	return newRepository(r.db, r.secret, actor)
+}
+
 func (r *repository) WithTx(fn func(txRepo Repository) error) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
 		return fmt.Errorf("failed to begin transaction: %w", tx.Error)
 	}
 
-	txRepo := &repository{
-		db:           tx,
-		user:         user.New(tx),
-		server:       server.New(tx),
-		serverConfig: serverconfig.New(tx),
-		userConfig:   userconfig.New(tx),
-	}
+	txRepo := newRepository(tx, r.secret, r.actor)
 
 	defer func() {
 		if p := recover(); p != nil {
@@ -87,3 +144,51 @@ func (r *repository) ServerConfig() serverconfig.IServerConfig {
 func (r *repository) UserConfig() userconfig.IUserConfig {
 	return r.userConfig
 }
+
+func (r *repository) Invocation() invocation.IInvocation {
+	return r.invocation
+}
+
+func (r *repository) PermissionRule() permissionrule.IPermissionRule {
+	return r.permission
+}
+
+func (r *repository) Audit() audit.IAudit {
+	return r.audit
+}
+
+func (r *repository) ChatSetting() chatsetting.IChatSetting {
+	return r.chatSetting
+}
+
+func (r *repository) MemberMenu() membermenu.IMemberMenu {
+	return r.memberMenu
+}
+
+func (r *repository) Schedule() schedule.ISchedule {
+	return r.schedule
+}
+
+func (r *repository) Lease() lease.ILease {
+	return r.lease
+}
+
+func (r *repository) Feed() feed.IFeed {
+	return r.feed
+}
+
+func (r *repository) AutoSummary() autosummary.IAutoSummary {
+	return r.autoSummary
+}
+
+func (r *repository) Comparison() comparison.IComparison {
+	return r.comparison
+}
+
+func (r *repository) Pipeline() pipeline.IPipeline {
+	return r.pipeline
+}
+
+func (r *repository) Secret() secret.ISecret {
+	return r.secret
+}
@@ -0,0 +1,441 @@
+package repo_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"sum/migrations"
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+	"sum/pkg/repo/secret"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newRepository opens an in-memory SQLite database with every migration applied, and
+// the repository encrypting API keys with the key of the test config
+func newRepository(t *testing.T) (repo.Repository, *gorm.DB) {
+	t.Helper()
+	require.NoError(t, models.InitIDGenerators())
+
+	db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+	require.NoError(t, err)
+
+	applied, err := database.Migrate(db)
+	require.NoError(t, err)
+	files, err := migrations.SQLite.ReadDir("sqlite")
+	require.NoError(t, err)
+	require.Equal(t, len(files), applied)
+
+	secrets, err := secret.NewFromConfig(config.LoadTestConfig())
+	require.NoError(t, err)
+	return repo.NewRepository(db, secrets), db
+}
+
+func TestMigrateTwice(t *testing.T) {
+	_, db := newRepository(t)
+
+	applied, err := database.Migrate(db)
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+}
+
+func TestUserConfigLifecycle(t *testing.T) {
+	r, db := newRepository(t)
+
+	user, err := r.User().Create(models.User{
+		UserID:   "42",
+		Platform: models.PlatformTelegram,
+		UserAgentConfigs: []models.UserAgentConfig{
+			{Command: "ask", EndpointURL: "https://agent.example.com/v1/chat-messages", APIKey: "key-1"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, user.UserAgentConfigs, 1)
+	cfg := user.UserAgentConfigs[0]
+	id := strconv.FormatInt(cfg.ID, 10)
+
+	// The API key is stored encrypted, bound to the config
+	var stored models.UserAgentConfig
+	require.NoError(t, db.First(&stored, cfg.ID).Error)
+	assert.NotEqual(t, "key-1", stored.APIKey)
+	plaintext, err := r.Secret().Decrypt(stored.APIKey, id)
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", plaintext)
+	_, err = r.Secret().Decrypt(stored.APIKey, "1")
+	assert.Error(t, err)
+
+	configs, err := r.UserConfig().ListByUserID(user.ID)
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "ask", configs[0].Command)
+
+	// Configs are pending until they are verified
+	pending, err := r.UserConfig().ListPendingByUserID("42", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+	pending, err = r.UserConfig().ListPendingByUserID("42", string(models.PlatformDiscord))
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	require.NoError(t, r.UserConfig().MarkVerified(id))
+	pending, err = r.UserConfig().ListPendingByUserID("42", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// A new API key has to be verified again
+	require.NoError(t, r.UserConfig().SaveAPIKey(id, "key-2"))
+	saved, err := r.UserConfig().GetByID(id)
+	require.NoError(t, err)
+	assert.Nil(t, saved.VerifiedAt)
+	plaintext, err = r.Secret().Decrypt(saved.APIKey, id)
+	require.NoError(t, err)
+	assert.Equal(t, "key-2", plaintext)
+
+	// A new endpoint URL drops the key entered for the previous one
+	require.NoError(t, r.UserConfig().SaveEndpointURL(id, "https://other.example.com/v1/chat-messages"))
+	saved, err = r.UserConfig().GetByID(id)
+	require.NoError(t, err)
+	assert.Empty(t, saved.APIKey)
+
+	var events int64
+	require.NoError(t, db.Model(&models.AuditEvent{}).Where("entity_id = ?", cfg.ID).Count(&events).Error)
+	assert.Equal(t, int64(4), events)
+}
+
+func TestUserConfigRemoveForUser(t *testing.T) {
+	r, _ := newRepository(t)
+
+	owner, err := r.User().Create(models.User{
+		UserID:           "1",
+		Platform:         models.PlatformTelegram,
+		UserAgentConfigs: []models.UserAgentConfig{{Command: "ask", EndpointURL: "https://agent.example.com", APIKey: "key"}},
+	})
+	require.NoError(t, err)
+	other, err := r.User().Create(models.User{UserID: "2", Platform: models.PlatformTelegram})
+	require.NoError(t, err)
+	id := strconv.FormatInt(owner.UserAgentConfigs[0].ID, 10)
+
+	err = r.UserConfig().RemoveByIDForUser(id, other.ID)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	_, err = r.UserConfig().GetByID(id)
+	require.NoError(t, err)
+
+	require.NoError(t, r.UserConfig().RemoveByIDForUser(id, owner.ID))
+	_, err = r.UserConfig().GetByID(id)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+// newServer registers user 1 with a group chat and a server config of the command ask
+func newServer(t *testing.T, r repo.Repository, chatID string) models.ServerAdminConfig {
+	t.Helper()
+	user, err := r.User().Create(models.User{
+		UserID:   "1",
+		Platform: models.PlatformTelegram,
+		Servers: []models.Server{{
+			ServerID:          chatID,
+			Platform:          models.PlatformTelegram,
+			ServerName:        "Group " + chatID,
+			ServerAdminConfig: []models.ServerAdminConfig{{Command: "ask", EndpointURL: "https://agent.example.com", APIKey: "key"}},
+		}},
+	})
+	require.NoError(t, err)
+	return user.Servers[0].ServerAdminConfig[0]
+}
+
+func TestServerConfigLifecycle(t *testing.T) {
+	r, _ := newRepository(t)
+	cfg := newServer(t, r, "-100")
+	id := strconv.FormatInt(cfg.ID, 10)
+
+	saved, err := r.ServerConfig().GetByID(id)
+	require.NoError(t, err)
+	require.NotNil(t, saved.Server)
+	assert.Equal(t, "-100", saved.Server.ServerID)
+
+	server, err := r.Server().GetByPlatformID("-100", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ServerID, server.ID)
+
+	pending, err := r.ServerConfig().ListPendingByUserID("1", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+	require.NoError(t, r.ServerConfig().MarkVerified(id))
+	require.NoError(t, r.ServerConfig().SaveDailyQuota(id, 5))
+	pending, err = r.ServerConfig().ListPendingByUserID("1", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	active, err := r.ServerConfig().GetActiveByServerPlatformID("-100", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ID, active.ID)
+	assert.Equal(t, 5, active.DailyQuota)
+	byCommand, err := r.ServerConfig().GetByServerIDAndCommand(server.ID, "ask")
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ID, byCommand.ID)
+
+	// The changes are in the audit log of the server, the newest first
+	count, err := r.Audit().CountByServerID(server.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	events, err := r.Audit().ListByServerID(server.ID, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.AuditActionConfigVerify, events[1].Action)
+	events, err = r.Audit().ListByServerID(server.ID, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.AuditActionConfigCreate, events[0].Action)
+
+	require.NoError(t, r.ServerConfig().RemoveByID(id))
+	_, err = r.ServerConfig().GetByID(id)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+func TestUserCreateExisting(t *testing.T) {
+	r, _ := newRepository(t)
+	first := newServer(t, r, "-100")
+
+	// A user registering another group keeps their user and adds the server
+	second := newServer(t, r, "-200")
+	user, err := r.User().GetByPlatformID("1", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	servers, err := r.Server().ListByUserID(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, servers, 2)
+	assert.NotEqual(t, first.ServerID, second.ServerID)
+
+	// A group registered again is kept
+	_, err = r.Server().Create(models.Server{ServerID: "-100", Platform: models.PlatformTelegram, OwnerID: strconv.FormatInt(user.ID, 10)})
+	require.NoError(t, err)
+	saved, err := r.Server().GetByPlatformID("-100", string(models.PlatformTelegram))
+	require.NoError(t, err)
+	assert.Equal(t, "Group -100", saved.ServerName)
+}
+
+func TestInvocationSummaries(t *testing.T) {
+	r, _ := newRepository(t)
+	cfg := newServer(t, r, "-100")
+	since := time.Now().Add(-time.Hour)
+
+	for _, inv := range []models.Invocation{
+		{UserID: "1", Success: true, TotalTokens: 10, Cost: 0.5, LatencyMS: 100},
+		{UserID: "1", Success: false, Error: "timeout", LatencyMS: 300},
+		{UserID: "2", Success: true, Cached: true},
+	} {
+		inv.Platform = models.PlatformTelegram
+		inv.ServerID = "-100"
+		inv.ConfigType = models.ConfigTypeServer
+		inv.ConfigID = cfg.ID
+		inv.Command = "ask"
+		require.NoError(t, r.Invocation().Create(inv))
+	}
+
+	summaries, err := r.Invocation().SummarizeByServer("-100", string(models.PlatformTelegram), since)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, models.InvocationSummary{Command: "ask", Count: 3, Errors: 1, Cached: 1, TotalTokens: 10, Cost: 0.5, AvgLatencyMS: 400.0 / 3}, summaries[0])
+
+	summaries, err = r.Invocation().SummarizeByUser("1", string(models.PlatformTelegram), since)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, 2, summaries[0].Count)
+
+	count, err := r.Invocation().CountByServerConfig(cfg.ID, "", since)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = r.Invocation().CountByServerConfig(cfg.ID, "2", since)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = r.Invocation().CountByServerConfig(cfg.ID, "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestScheduleClaim(t *testing.T) {
+	r, _ := newRepository(t)
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	_, err := r.Schedule().Create(models.Schedule{
+		Platform:   models.PlatformTelegram,
+		ChatID:     "-100",
+		CreatedBy:  "1",
+		ConfigType: models.ConfigTypeBuiltin,
+		Command:    "sum",
+		Cron:       "0 9 * * *",
+		Timezone:   "UTC",
+		NextRunAt:  now.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	due, err := r.Schedule().ListDue(now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	// A run is claimed once, by the instance that read it due first
+	claimed, err := r.Schedule().Claim(due[0].ID, due[0].NextRunAt, now.Add(24*time.Hour), now)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = r.Schedule().Claim(due[0].ID, due[0].NextRunAt, now.Add(24*time.Hour), now)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	due, err = r.Schedule().ListDue(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestFeedItems(t *testing.T) {
+	r, _ := newRepository(t)
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	f, err := r.Feed().Create(models.Feed{
+		Platform:        models.PlatformTelegram,
+		ChatID:          "-100",
+		CreatedBy:       "1",
+		URL:             "https://news.example.com/rss",
+		ConfigType:      models.ConfigTypeBuiltin,
+		IntervalMinutes: 10,
+		MaxItems:        5,
+		NextPollAt:      now,
+		CreatedAt:       now,
+	}, []string{"a", "b"})
+	require.NoError(t, err)
+
+	due, err := r.Feed().ListDue(now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	claimed, err := r.Feed().Claim(f.ID, due[0].NextPollAt, now.Add(10*time.Minute), now)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = r.Feed().Claim(f.ID, due[0].NextPollAt, now.Add(10*time.Minute), now)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Items seen again are kept once
+	require.NoError(t, r.Feed().MarkSeen(f.ID, []string{"b", "c"}, now.Add(time.Hour)))
+	seen, err := r.Feed().Seen(f.ID, []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "c": true}, seen)
+
+	// Only the items seen before the cutoff that left the feed are forgotten
+	require.NoError(t, r.Feed().Prune(f.ID, []string{"b"}, now.Add(time.Minute)))
+	seen, err = r.Feed().Seen(f.ID, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"b": true, "c": true}, seen)
+}
+
+func TestLease(t *testing.T) {
+	r, _ := newRepository(t)
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	acquire := func(holder string, at time.Time) bool {
+		acquired, err := r.Lease().Acquire("scheduler", holder, time.Minute, at)
+		require.NoError(t, err)
+		return acquired
+	}
+
+	assert.True(t, acquire("a", now))
+	assert.False(t, acquire("b", now))
+	assert.True(t, acquire("a", now.Add(30*time.Second)))
+	assert.False(t, acquire("b", now.Add(time.Minute)))
+
+	// The lease passes on once it expires, or is released
+	assert.True(t, acquire("b", now.Add(2*time.Minute)))
+	assert.False(t, acquire("a", now.Add(2*time.Minute)))
+	require.NoError(t, r.Lease().Release("scheduler", "b"))
+	assert.True(t, acquire("a", now.Add(2*time.Minute)))
+}
+
+func TestComparisonVotes(t *testing.T) {
+	r, _ := newRepository(t)
+
+	c, err := r.Comparison().Create(models.Comparison{Platform: models.PlatformTelegram, ChatID: "-100", CreatedBy: "1", Commands: "a,b", Prompt: "hi"})
+	require.NoError(t, err)
+	require.NoError(t, r.Comparison().SaveMessage(c.ID, "42"))
+	c, err = r.Comparison().Get(c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "42", c.MessageID)
+
+	// A user's last vote replaces the previous one
+	for _, vote := range []models.ComparisonVote{{UserID: "1", Command: "a"}, {UserID: "1", Command: "b"}, {UserID: "2", Command: "b"}, {UserID: "3", Command: "a"}, {UserID: "4", Command: "b"}} {
+		vote.ComparisonID = c.ID
+		require.NoError(t, r.Comparison().Vote(vote))
+	}
+
+	votes, err := r.Comparison().CountVotes(c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 3}, votes)
+
+	standings, err := r.Comparison().Standings(models.PlatformTelegram, "-100")
+	require.NoError(t, err)
+	assert.Equal(t, []models.CommandVotes{{Command: "b", Votes: 3}, {Command: "a", Votes: 1}}, standings)
+}
+
+func TestPipelineSave(t *testing.T) {
+	r, _ := newRepository(t)
+
+	require.NoError(t, r.Pipeline().Save(models.Pipeline{OwnerType: models.ConfigTypeUser, OwnerID: 1, Name: "digest", Steps: "sum | translate", CreatedBy: "1"}))
+	require.NoError(t, r.Pipeline().Save(models.Pipeline{OwnerType: models.ConfigTypeUser, OwnerID: 1, Name: "digest", Steps: "sum", CreatedBy: "1"}))
+	require.NoError(t, r.Pipeline().Save(models.Pipeline{OwnerType: models.ConfigTypeServer, OwnerID: 1, Name: "digest", Steps: "ask", CreatedBy: "1"}))
+
+	saved, err := r.Pipeline().Get(models.ConfigTypeUser, 1, "digest")
+	require.NoError(t, err)
+	assert.Equal(t, "sum", saved.Steps)
+	pipelines, err := r.Pipeline().List(models.ConfigTypeUser, 1)
+	require.NoError(t, err)
+	assert.Len(t, pipelines, 1)
+
+	require.NoError(t, r.Pipeline().Remove(models.ConfigTypeUser, 1, "digest"))
+	_, err = r.Pipeline().Get(models.ConfigTypeUser, 1, "digest")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	_, err = r.Pipeline().Get(models.ConfigTypeServer, 1, "digest")
+	assert.NoError(t, err)
+}
+
+func TestChatSettings(t *testing.T) {
+	r, _ := newRepository(t)
+
+	require.NoError(t, r.ChatSetting().SaveDefaultCommand(models.PlatformTelegram, "-100", "ask"))
+	require.NoError(t, r.ChatSetting().SaveDefaultCommand(models.PlatformTelegram, "-100", "sum"))
+	setting, err := r.ChatSetting().Get(models.PlatformTelegram, "-100")
+	require.NoError(t, err)
+	assert.Equal(t, "sum", setting.DefaultCommand)
+
+	require.NoError(t, r.MemberMenu().Save(models.PlatformTelegram, "-100", "1"))
+	require.NoError(t, r.MemberMenu().Save(models.PlatformTelegram, "-100", "1"))
+	require.NoError(t, r.MemberMenu().Save(models.PlatformTelegram, "-100", "2"))
+	menus, err := r.MemberMenu().ListByChatID(models.PlatformTelegram, "-100")
+	require.NoError(t, err)
+	assert.Len(t, menus, 2)
+}
+
+func TestAutoSummary(t *testing.T) {
+	r, _ := newRepository(t)
+	cfg := newServer(t, r, "-100")
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, r.AutoSummary().Save(models.AutoSummary{ServerID: cfg.ServerID, Mode: models.AutoSummaryMode("auto")}))
+	require.NoError(t, r.AutoSummary().Save(models.AutoSummary{ServerID: cfg.ServerID, Mode: models.AutoSummaryMode("button"), MinLength: 100}))
+	setting, err := r.AutoSummary().Get(cfg.ServerID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AutoSummaryMode("button"), setting.Mode)
+	assert.Equal(t, 100, setting.MinLength)
+
+	// A link is summarized once in its window
+	claim := func(at time.Time) bool {
+		claimed, err := r.AutoSummary().Claim(models.PlatformTelegram, "-100", "https://news.example.com/a", time.Hour, at)
+		require.NoError(t, err)
+		return claimed
+	}
+	assert.True(t, claim(now))
+	assert.False(t, claim(now.Add(30*time.Minute)))
+	assert.True(t, claim(now.Add(2*time.Hour)))
+}
@@ -0,0 +1,19 @@
+package schedule
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+// Claim moves a schedule due at due to its next run. It reports false when another
+// instance claimed the run first, only the instance claiming a run may fire it.
+func (s *schedule) Claim(id int64, due, next, now time.Time) (bool, error) {
+	result := s.db.Model(&models.Schedule{}).
+		Where("id = ? AND next_run_at = ?", id, due.UTC()).
+		Updates(map[string]interface{}{
+			"next_run_at": next.UTC(),
+			"last_run_at": now.UTC(),
+			"updated_at":  now.UTC(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
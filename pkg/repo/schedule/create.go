@@ -0,0 +1,7 @@
+package schedule
+
+import "sum/pkg/models"
+
+func (s *schedule) Create(schedule models.Schedule) (models.Schedule, error) {
+	return schedule, s.db.Create(&schedule).Error
+}
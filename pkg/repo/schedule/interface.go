@@ -0,0 +1,16 @@
+package schedule
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+type ISchedule interface {
+	Create(schedule models.Schedule) (models.Schedule, error)
+	CountByChatID(platform models.PlatformType, chatID string) (int64, error)
+	ListByChatID(platform models.PlatformType, chatID string) ([]models.Schedule, error)
+	ListDue(now time.Time, limit int) ([]models.Schedule, error)
+	Claim(id int64, due, next, now time.Time) (bool, error)
+	RemoveByID(platform models.PlatformType, chatID, id string) error
+	Remove(id int64) error
+}
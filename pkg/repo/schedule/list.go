@@ -0,0 +1,24 @@
+package schedule
+
+import (
+	"sum/pkg/models"
+	"time"
+)
+
+// CountByChatID returns the number of schedules posting to a chat
+func (s *schedule) CountByChatID(platform models.PlatformType, chatID string) (int64, error) {
+	var count int64
+	return count, s.db.Model(&models.Schedule{}).Where("platform = ? AND chat_id = ?", platform, chatID).Count(&count).Error
+}
+
+// ListByChatID returns the schedules posting to a chat, the next to run first
+func (s *schedule) ListByChatID(platform models.PlatformType, chatID string) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	return schedules, s.db.Where("platform = ? AND chat_id = ?", platform, chatID).Order("next_run_at").Find(&schedules).Error
+}
+
+// ListDue returns up to limit schedules due at now, the most overdue first
+func (s *schedule) ListDue(now time.Time, limit int) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	return schedules, s.db.Where("next_run_at <= ?", now.UTC()).Order("next_run_at").Limit(limit).Find(&schedules).Error
+}
@@ -0,0 +1,25 @@
+package schedule
+
+import (
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// RemoveByID removes a schedule posting to a chat, it returns gorm.ErrRecordNotFound
+// when the chat has no such schedule
+func (s *schedule) RemoveByID(platform models.PlatformType, chatID, id string) error {
+	result := s.db.Delete(&models.Schedule{}, "platform = ? AND chat_id = ? AND id = ?", platform, chatID, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Remove removes a schedule, e.g. once its config is gone
+func (s *schedule) Remove(id int64) error {
+	return s.db.Delete(&models.Schedule{}, "id = ?", id).Error
+}
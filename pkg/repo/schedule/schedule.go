@@ -0,0 +1,11 @@
+package schedule
+
+import "gorm.io/gorm"
+
+type schedule struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) ISchedule {
+	return &schedule{db: db}
+}
@@ -0,0 +1,7 @@
+package secret
+
+type ISecret interface {
+	Encrypt(plaintext, configID string) (string, error)
+	Decrypt(ciphertext, configID string) (string, error)
+	Rotate(value, configID string) (string, bool, error)
+}
@@ -0,0 +1,151 @@
+// Package secret encrypts the secrets stored by the repository, such as agent API keys.
+package secret
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"sum/pkg/config"
+	"sum/pkg/utils/encryptutils"
+)
+
+// ErrNoKeyring is returned when secrets are used without a configured encryption key
+var ErrNoKeyring = errors.New("encryption key is not configured")
+
+type secret struct {
+	keyring *encryptutils.Keyring
+}
+
+// New creates a secret service using keyring, a nil keyring makes every operation fail with ErrNoKeyring
+func New(keyring *encryptutils.Keyring) ISecret {
+	return &secret{keyring: keyring}
+}
+
+// NewFromConfig builds the keyring from the configured secret store. For the
+// remote stores, ENCRYPTION_KEY and ENCRYPTION_RETIRED_KEYS are optional and
+// only used to read values written before moving to the store. Legacy AES-CFB
+// values are only read when SECRET_LEGACY_CFB is set.
+func NewFromConfig(cfg config.Config) (ISecret, error) {
+	store, err := newStore(cfg)
+	if err != nil {
+		return New(nil), err
+	}
+
+	var retired *encryptutils.LocalStore
+	if cfg.SecretStore.Type == config.SecretStoreVault || cfg.SecretStore.Type == config.SecretStoreKMS {
+		if cfg.EncryptionKey != "" {
+			if retired, err = newEnvStore(cfg); err != nil {
+				return New(nil), err
+			}
+		}
+	}
+
+	keyring, err := encryptutils.NewKeyring(store, retired, cfg.SecretStore.LegacyCFB)
+	if err != nil {
+		return New(nil), err
+	}
+	return New(keyring), nil
+}
+
+// newStore creates the secret store selected by the configuration
+func newStore(cfg config.Config) (encryptutils.SecretStore, error) {
+	c := cfg.SecretStore
+	switch c.Type {
+	case config.SecretStoreEnv, "":
+		return newEnvStore(cfg)
+	case config.SecretStoreFile:
+		return encryptutils.NewKeyFileStore(c.KeyFile)
+	case config.SecretStoreVault:
+		return encryptutils.NewVaultStore(c.VaultAddr, c.VaultToken, c.VaultMount, c.VaultKey, nil)
+	case config.SecretStoreKMS:
+		return encryptutils.NewKMSStore(c.KMSURL, c.KMSToken, c.KMSKeyID, nil)
+	default:
+		return nil, fmt.Errorf("unsupported secret store %q", c.Type)
+	}
+}
+
+// newEnvStore creates a local store from the primary key, its ID and the
+// comma separated id:base64 list of retired keys
+func newEnvStore(cfg config.Config) (*encryptutils.LocalStore, error) {
+	primary, err := encryptutils.NewEncryptionKey(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	retired, err := encryptutils.ParseKeys(cfg.EncryptionRetiredKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptutils.NewLocalStore(cfg.EncryptionKeyID, primary, retired)
+}
+
+// Encrypt encrypts plaintext with the primary key, bound to the ID of the config row it is stored in
+func (s *secret) Encrypt(plaintext, configID string) (string, error) {
+	if s.keyring == nil {
+		return "", ErrNoKeyring
+	}
+	return s.keyring.Encrypt(plaintext, configID)
+}
+
+// Decrypt decrypts a value stored in the config row configID
+func (s *secret) Decrypt(ciphertext, configID string) (string, error) {
+	if s.keyring == nil {
+		return "", ErrNoKeyring
+	}
+	return s.keyring.Decrypt(ciphertext, configID)
+}
+
+// Rotate re-encrypts value with the primary key in the current envelope
+// format and reports whether it changed. Values that were stored in plaintext,
+// which can't be valid ciphertexts, are encrypted as is. A plaintext such as
+// "abc:def" would parse as a tagged legacy value, it is only decrypted when its
+// tag is a known key ID.
+func (s *secret) Rotate(value, configID string) (string, bool, error) {
+	if s.keyring == nil {
+		return "", false, ErrNoKeyring
+	}
+	if value == "" {
+		return value, false, nil
+	}
+
+	envelope := encryptutils.ParseEnvelope(value)
+	if envelope.Version == encryptutils.VersionGCM && envelope.KeyID == s.keyring.PrimaryID() {
+		return value, false, nil
+	}
+
+	plaintext := value
+	if encrypted(s.keyring, envelope) {
+		var err error
+		plaintext, err = s.keyring.Decrypt(value, configID)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	rotated, err := s.keyring.Encrypt(plaintext, configID)
+	if err != nil {
+		return "", false, err
+	}
+	return rotated, true, nil
+}
+
+// encrypted reports whether a stored value is a ciphertext rather than a legacy plaintext
+func encrypted(keyring *encryptutils.Keyring, envelope encryptutils.Envelope) bool {
+	switch {
+	case envelope.Version == encryptutils.VersionGCM:
+		return true
+	case envelope.Tagged:
+		return keyring.HasKey(envelope.KeyID)
+	default:
+		return isCiphertext(envelope.Ciphertext)
+	}
+}
+
+// isCiphertext reports whether an untagged value looks like a legacy ciphertext
+func isCiphertext(value string) bool {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	return err == nil && len(raw) >= aes.BlockSize
+}
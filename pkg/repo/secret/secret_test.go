@@ -0,0 +1,59 @@
+package secret
+
+import (
+	"bytes"
+	"testing"
+
+	"sum/pkg/utils/encryptutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSecret creates a secret service with the primary key "2", the retired key "1"
+// and legacy values enabled
+func newSecret(t *testing.T) (ISecret, encryptutils.EncryptionKey) {
+	t.Helper()
+	retired := encryptutils.EncryptionKey(bytes.Repeat([]byte{1}, 32))
+	store, err := encryptutils.NewLocalStore("2", encryptutils.EncryptionKey(bytes.Repeat([]byte{2}, 32)), map[string]encryptutils.EncryptionKey{"1": retired})
+	require.NoError(t, err)
+	keyring, err := encryptutils.NewKeyring(store, nil, true)
+	require.NoError(t, err)
+	return New(keyring), retired
+}
+
+func TestRotate(t *testing.T) {
+	s, retired := newSecret(t)
+	legacy, err := encryptutils.EncryptAPIKey(retired, "sk-legacy")
+	require.NoError(t, err)
+
+	for value, want := range map[string]string{
+		legacy:          "sk-legacy",
+		"1:" + legacy:   "sk-legacy",
+		"sk-plain":      "sk-plain",
+		"app:sk-plain":  "app:sk-plain",
+		"user:pass:key": "user:pass:key",
+	} {
+		rotated, changed, err := s.Rotate(value, "42")
+		require.NoError(t, err, value)
+		assert.True(t, changed, value)
+
+		plaintext, err := s.Decrypt(rotated, "42")
+		require.NoError(t, err, value)
+		assert.Equal(t, want, plaintext, value)
+
+		// Values encrypted with the primary key are left as they are
+		again, changed, err := s.Rotate(rotated, "42")
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, rotated, again)
+	}
+}
+
+func TestRotateUnknownKey(t *testing.T) {
+	s, _ := newSecret(t)
+
+	// A tagged value with a known key that doesn't decrypt is still an error
+	_, _, err := s.Rotate("1:not-a-ciphertext", "42")
+	assert.Error(t, err)
+}
@@ -2,6 +2,11 @@ package server
 
 import "sum/pkg/models"
 
+func (s server) GetByID(id int64) (models.Server, error) {
+	var server models.Server
+	return server, s.db.Where("id = ?", id).First(&server).Error
+}
+
 func (s server) GetByServerID(serverID int64) (models.Server, error) {
 	var server models.Server
 	return server, s.db.Where("server_id = ?", serverID).First(&server).Error
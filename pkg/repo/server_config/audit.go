@@ -0,0 +1,46 @@
+package serverconfig
+
+import (
+	"sum/pkg/models"
+	"sum/pkg/repo/audit"
+
+	"gorm.io/gorm"
+)
+
+// update applies updates to a config and records the change in the audit log, in one transaction
+func (c serverConfig) update(id, action string, updates map[string]interface{}) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		var before, after models.ServerAdminConfig
+		if err := tx.Where("id = ?", id).First(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ServerAdminConfig{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", id).First(&after).Error; err != nil {
+			return err
+		}
+		return c.record(tx, action, &before, &after)
+	})
+}
+
+// record writes the audit event of a change to a config, before or after is nil for a creation or removal
+func (c serverConfig) record(tx *gorm.DB, action string, before, after *models.ServerAdminConfig) error {
+	config := after
+	if config == nil {
+		config = before
+	}
+
+	beforeJSON, afterJSON, err := audit.Changes(before, after)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, c.actor, models.AuditEvent{
+		Action:     action,
+		EntityType: models.AuditEntityServerConfig,
+		EntityID:   config.ID,
+		ServerID:   config.ServerID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+	})
+}
@@ -1,11 +1,19 @@
 package serverconfig
 
-import "gorm.io/gorm"
+import (
+	"sum/pkg/repo/audit"
+	"sum/pkg/repo/secret"
+
+	"gorm.io/gorm"
+)
 
 type serverConfig struct {
-	db *gorm.DB
+	db     *gorm.DB
+	secret secret.ISecret
+	actor  audit.Actor
 }
 
-func New(db *gorm.DB) IServerConfig {
-	return &serverConfig{db: db}
+// New creates the server config repository, its changes are recorded in the audit log on behalf of actor
+func New(db *gorm.DB, secret secret.ISecret, actor audit.Actor) IServerConfig {
+	return &serverConfig{db: db, secret: secret, actor: actor}
 }
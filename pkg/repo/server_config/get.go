@@ -1,6 +1,12 @@
 package serverconfig
 
-import "sum/pkg/models"
+import (
+	"errors"
+	"slices"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
 
 func (c serverConfig) GetByID(id string) (models.ServerAdminConfig, error) {
 	var config models.ServerAdminConfig
@@ -14,9 +20,25 @@ func (c serverConfig) GetActiveByServerPlatformID(serverID, platform string) (mo
 		First(&config).Error
 }
 
+// GetByServerIDAndCommand returns the config of the server invoked as command, by its name or one of its aliases
 func (c serverConfig) GetByServerIDAndCommand(serverID int64, command string) (models.ServerAdminConfig, error) {
 	var config models.ServerAdminConfig
-	return config, c.db.Table("server_admin_configs").
+	err := c.db.Table("server_admin_configs").
 		Where("server_id = ? AND command = ?", serverID, command).
 		First(&config).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return config, err
+	}
+
+	// Aliases are a comma separated list, matched here so an alias can't match part of another
+	var configs []models.ServerAdminConfig
+	if err := c.db.Where("server_id = ? AND aliases <> ''", serverID).Order("id").Find(&configs).Error; err != nil {
+		return config, err
+	}
+	for _, candidate := range configs {
+		if slices.Contains(models.SplitAliases(candidate.Aliases), command) {
+			return candidate, nil
+		}
+	}
+	return config, gorm.ErrRecordNotFound
 }
@@ -3,14 +3,21 @@ package serverconfig
 import "sum/pkg/models"
 
 type IServerConfig interface {
-	ListPendingByUserID(userID string) ([]models.ServerAdminConfig, error)
+	ListPendingByUserID(userID, platform string) ([]models.ServerAdminConfig, error)
 	GetByID(id string) (models.ServerAdminConfig, error)
 	SaveAPIKey(id string, apiKey string) error
 	SaveEndpointURL(id string, endpointURL string) error
+	MarkVerified(id string) error
 	SaveCommand(id string, command string) error
 	RemoveByID(id string) error
 	GetActiveByServerPlatformID(serverID, platform string) (models.ServerAdminConfig, error)
 	SaveDescription(id string, description string) error
+	SaveInputs(id string, inputs string) error
+	SaveAliases(id string, aliases string) error
 	ListByServerID(serverID int64) ([]models.ServerAdminConfig, error)
 	GetByServerIDAndCommand(serverID int64, command string) (models.ServerAdminConfig, error)
+	SaveDailyQuota(id string, quota int) error
+	SaveMonthlyQuota(id string, quota int) error
+	SaveUserDailyQuota(id string, quota int) error
+	RotateAPIKeys() (int, error)
 }
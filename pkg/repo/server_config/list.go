@@ -2,14 +2,16 @@ package serverconfig
 
 import "sum/pkg/models"
 
-func (c serverConfig) ListPendingByUserID(userID string) ([]models.ServerAdminConfig, error) {
+// ListPendingByUserID returns the configs of the servers owned by the user of the platform
+// that are incomplete or not verified yet
+func (c serverConfig) ListPendingByUserID(userID, platform string) ([]models.ServerAdminConfig, error) {
 	var configs []models.ServerAdminConfig
 	return configs, c.db.Table("server_admin_configs").
 		Preload("Server").
 		Select("server_admin_configs.*").
 		Joins("JOIN servers ON servers.id = server_admin_configs.server_id").
 		Joins("JOIN users ON users.id = servers.owner_id").
-		Where("users.user_id = ? AND (server_admin_configs.endpoint_url = '' OR server_admin_configs.api_key = '' OR server_admin_configs.command = '')", userID).
+		Where("users.user_id = ? AND users.platform = ? AND (server_admin_configs.endpoint_url = '' OR server_admin_configs.api_key = '' OR server_admin_configs.command = '' OR server_admin_configs.verified_at IS NULL)", userID, platform).
 		Find(&configs).Error
 }
 
@@ -1,7 +1,26 @@
 package serverconfig
 
-import "sum/pkg/models"
+import (
+	"errors"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
 
 func (c serverConfig) RemoveByID(id string) error {
-	return c.db.Delete(&models.ServerAdminConfig{}, "id = ?", id).Error
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		var before models.ServerAdminConfig
+		err := tx.Where("id = ?", id).First(&before).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&models.ServerAdminConfig{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return c.record(tx, models.AuditActionConfigRemove, &before, nil)
+	})
 }
@@ -0,0 +1,39 @@
+package serverconfig
+
+import (
+	"fmt"
+	"strconv"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// RotateAPIKeys re-encrypts every stored API key that isn't encrypted with the
+// primary key in the current envelope format, upgrading legacy AES-CFB values
+// to AES-GCM, and returns how many rows were updated
+func (c serverConfig) RotateAPIKeys() (int, error) {
+	var (
+		configs []models.ServerAdminConfig
+		rotated int
+	)
+	err := c.db.Model(&models.ServerAdminConfig{}).Select("id", "api_key").Where("api_key <> ''").
+		FindInBatches(&configs, 100, func(tx *gorm.DB, batch int) error {
+			for _, config := range configs {
+				apiKey, changed, err := c.secret.Rotate(config.APIKey, strconv.FormatInt(config.ID, 10))
+				if err != nil {
+					return fmt.Errorf("failed to rotate API key of server_admin_configs %d: %w", config.ID, err)
+				}
+				if !changed {
+					continue
+				}
+
+				if err := c.update(strconv.FormatInt(config.ID, 10), models.AuditActionConfigRotateKey, map[string]interface{}{"api_key": apiKey}); err != nil {
+					return err
+				}
+				rotated++
+			}
+			return nil
+		}).Error
+
+	return rotated, err
+}
@@ -1,19 +1,73 @@
 package serverconfig
 
-import "sum/pkg/models"
+import (
+	"sum/pkg/models"
+	"time"
+)
 
+// SaveAPIKey encrypts apiKey with the primary key, bound to the config ID, before
+// storing it. The config has to pass the registration probe again.
 func (c serverConfig) SaveAPIKey(id string, apiKey string) error {
-	return c.db.Model(&models.ServerAdminConfig{}).Where("id = ?", id).Update("api_key", apiKey).Error
+	encrypted, err := c.secret.Encrypt(apiKey, id)
+	if err != nil {
+		return err
+	}
+	return c.update(id, models.AuditActionConfigEdit, map[string]interface{}{
+		"api_key":     encrypted,
+		"verified_at": nil,
+	})
 }
 
+// SaveEndpointURL stores the endpoint URL. The config has to pass the registration probe
+// again, and a changed URL clears the API key, so a key is only sent to the endpoint it
+// was entered for.
 func (c serverConfig) SaveEndpointURL(id string, endpointURL string) error {
-	return c.db.Model(&models.ServerAdminConfig{}).Where("id = ?", id).Update("endpoint_url", endpointURL).Error
+	current, err := c.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"endpoint_url": endpointURL,
+		"verified_at":  nil,
+	}
+	if current.EndpointURL != endpointURL {
+		updates["api_key"] = ""
+	}
+	return c.update(id, models.AuditActionConfigEdit, updates)
+}
+
+// MarkVerified records that the config passed the registration probe, or that it was skipped
+func (c serverConfig) MarkVerified(id string) error {
+	return c.update(id, models.AuditActionConfigVerify, map[string]interface{}{"verified_at": time.Now()})
 }
 
 func (c serverConfig) SaveCommand(id string, command string) error {
-	return c.db.Model(&models.ServerAdminConfig{}).Where("id = ?", id).Update("command", command).Error
+	return c.update(id, models.AuditActionConfigEdit, map[string]interface{}{"command": command})
 }
 
 func (c serverConfig) SaveDescription(id string, description string) error {
-	return c.db.Model(&models.ServerAdminConfig{}).Where("id = ?", id).Update("description", description).Error
+	return c.update(id, models.AuditActionConfigEdit, map[string]interface{}{"description": description})
+}
+
+// SaveInputs stores the JSON object of inputs sent to the agent with every request
+func (c serverConfig) SaveInputs(id string, inputs string) error {
+	return c.update(id, models.AuditActionConfigEdit, map[string]interface{}{"inputs": inputs})
+}
+
+// SaveAliases stores the other names of the command, comma separated
+func (c serverConfig) SaveAliases(id string, aliases string) error {
+	return c.update(id, models.AuditActionConfigEdit, map[string]interface{}{"aliases": aliases})
+}
+
+func (c serverConfig) SaveDailyQuota(id string, quota int) error {
+	return c.update(id, models.AuditActionConfigQuota, map[string]interface{}{"daily_quota": quota})
+}
+
+func (c serverConfig) SaveMonthlyQuota(id string, quota int) error {
+	return c.update(id, models.AuditActionConfigQuota, map[string]interface{}{"monthly_quota": quota})
+}
+
+func (c serverConfig) SaveUserDailyQuota(id string, quota int) error {
+	return c.update(id, models.AuditActionConfigQuota, map[string]interface{}{"user_daily_quota": quota})
 }
@@ -1,14 +1,98 @@
 package user
 
 import (
+	"strconv"
 	"sum/pkg/models"
+	"sum/pkg/repo/audit"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// Create creates the user with its servers and agent configs, encrypting the
+// API keys of the nested configs before they are stored. Config IDs are
+// generated up front because the ciphertexts are bound to them.
 func (u *user) Create(user models.User) (models.User, error) {
-	return user, u.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "user_id"}, {Name: "platform"}},
-		DoNothing: true,
-	}).Create(&user).Error
+	if err := u.encryptAPIKeys(&user); err != nil {
+		return user, err
+	}
+
+	return user, u.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "platform"}},
+			DoNothing: true,
+		}).Create(&user).Error
+		if err != nil {
+			return err
+		}
+		return u.recordConfigs(tx, user)
+	})
+}
+
+// recordConfigs writes the audit events of the configs created with a user
+func (u *user) recordConfigs(tx *gorm.DB, user models.User) error {
+	for i := range user.UserAgentConfigs {
+		config := &user.UserAgentConfigs[i]
+		if err := u.record(tx, models.AuditEntityUserConfig, config.ID, 0, config); err != nil {
+			return err
+		}
+	}
+	for i := range user.Servers {
+		for j := range user.Servers[i].ServerAdminConfig {
+			config := &user.Servers[i].ServerAdminConfig[j]
+			if err := u.record(tx, models.AuditEntityServerConfig, config.ID, user.Servers[i].ID, config); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// record writes the audit event of a created config
+func (u *user) record(tx *gorm.DB, entityType string, id, serverID int64, config any) error {
+	_, after, err := audit.Changes(nil, config)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, u.actor, models.AuditEvent{
+		Action:     models.AuditActionConfigCreate,
+		EntityType: entityType,
+		EntityID:   id,
+		ServerID:   serverID,
+		After:      after,
+	})
+}
+
+func (u *user) encryptAPIKeys(user *models.User) error {
+	for i := range user.UserAgentConfigs {
+		config := &user.UserAgentConfigs[i]
+		config.EnsureID()
+		if err := u.encrypt(&config.APIKey, config.ID); err != nil {
+			return err
+		}
+	}
+	for i := range user.Servers {
+		for j := range user.Servers[i].ServerAdminConfig {
+			config := &user.Servers[i].ServerAdminConfig[j]
+			config.EnsureID()
+			if err := u.encrypt(&config.APIKey, config.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encrypt encrypts apiKey in place, bound to the ID of the config it belongs to
+func (u *user) encrypt(apiKey *string, configID int64) error {
+	if *apiKey == "" {
+		return nil
+	}
+
+	encrypted, err := u.secret.Encrypt(*apiKey, strconv.FormatInt(configID, 10))
+	if err != nil {
+		return err
+	}
+	*apiKey = encrypted
+	return nil
 }
@@ -6,3 +6,8 @@ func (u *user) GetByPlatformID(userID, platform string) (models.User, error) {
 	var user models.User
 	return user, u.db.Where("user_id = ? AND platform = ?", userID, platform).First(&user).Error
 }
+
+func (u *user) GetByID(id int64) (models.User, error) {
+	var user models.User
+	return user, u.db.Where("id = ?", id).First(&user).Error
+}
@@ -5,4 +5,5 @@ import "sum/pkg/models"
 type IUser interface {
 	Create(user models.User) (models.User, error)
 	GetByPlatformID(userID, platform string) (models.User, error)
+	GetByID(id int64) (models.User, error)
 }
@@ -1,11 +1,19 @@
 package user
 
-import "gorm.io/gorm"
+import (
+	"sum/pkg/repo/audit"
+	"sum/pkg/repo/secret"
+
+	"gorm.io/gorm"
+)
 
 type user struct {
-	db *gorm.DB
+	db     *gorm.DB
+	secret secret.ISecret
+	actor  audit.Actor
 }
 
-func New(db *gorm.DB) IUser {
-	return &user{db: db}
+// New creates the user repository, the configs it creates are recorded in the audit log on behalf of actor
+func New(db *gorm.DB, secret secret.ISecret, actor audit.Actor) IUser {
+	return &user{db: db, secret: secret, actor: actor}
 }
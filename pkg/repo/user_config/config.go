@@ -1,11 +1,19 @@
 package userconfig
 
-import "gorm.io/gorm"
+import (
+	"sum/pkg/repo/audit"
+	"sum/pkg/repo/secret"
+
+	"gorm.io/gorm"
+)
 
 type userConfig struct {
-	db *gorm.DB
+	db     *gorm.DB
+	secret secret.ISecret
+	actor  audit.Actor
 }
 
-func New(db *gorm.DB) IUserConfig {
-	return &userConfig{db: db}
+// New creates the user config repository, its changes are recorded in the audit log on behalf of actor
+func New(db *gorm.DB, secret secret.ISecret, actor audit.Actor) IUserConfig {
+	return &userConfig{db: db, secret: secret, actor: actor}
 }
@@ -1,6 +1,12 @@
 package userconfig
 
-import "sum/pkg/models"
+import (
+	"errors"
+	"slices"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
 
 func (c userConfig) GetByID(id string) (models.UserAgentConfig, error) {
 	var config models.UserAgentConfig
@@ -15,9 +21,25 @@ func (c userConfig) GetActiveByUserPlatformID(userID, platform string) (models.U
 		First(&config).Error
 }
 
+// GetByUserIDAndCommand returns the config of the user invoked as command, by its name or one of its aliases
 func (c userConfig) GetByUserIDAndCommand(userID int64, command string) (models.UserAgentConfig, error) {
 	var config models.UserAgentConfig
-	return config, c.db.Table("user_agent_configs").
+	err := c.db.Table("user_agent_configs").
 		Where("user_id = ? AND command = ?", userID, command).
 		First(&config).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return config, err
+	}
+
+	// Aliases are a comma separated list, matched here so an alias can't match part of another
+	var configs []models.UserAgentConfig
+	if err := c.db.Where("user_id = ? AND aliases <> ''", userID).Order("id").Find(&configs).Error; err != nil {
+		return config, err
+	}
+	for _, candidate := range configs {
+		if slices.Contains(models.SplitAliases(candidate.Aliases), command) {
+			return candidate, nil
+		}
+	}
+	return config, gorm.ErrRecordNotFound
 }
@@ -3,14 +3,19 @@ package userconfig
 import "sum/pkg/models"
 
 type IUserConfig interface {
-	ListPendingByUserID(userID string) ([]models.UserAgentConfig, error)
+	ListPendingByUserID(userID, platform string) ([]models.UserAgentConfig, error)
 	GetByID(id string) (models.UserAgentConfig, error)
 	SaveAPIKey(id string, apiKey string) error
 	SaveEndpointURL(id string, endpointURL string) error
+	MarkVerified(id string) error
 	SaveCommand(id string, command string) error
 	RemoveByID(id string) error
+	RemoveByIDForUser(id string, ownerID int64) error
 	GetActiveByUserPlatformID(userID, platform string) (models.UserAgentConfig, error)
 	SaveDescription(id string, description string) error
+	SaveInputs(id string, inputs string) error
+	SaveAliases(id string, aliases string) error
 	ListByUserID(userID int64) ([]models.UserAgentConfig, error)
 	GetByUserIDAndCommand(userID int64, command string) (models.UserAgentConfig, error)
+	RotateAPIKeys() (int, error)
 }
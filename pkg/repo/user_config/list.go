@@ -2,12 +2,14 @@ package userconfig
 
 import "sum/pkg/models"
 
-func (c userConfig) ListPendingByUserID(userID string) ([]models.UserAgentConfig, error) {
+// ListPendingByUserID returns the configs of the user of the platform that are incomplete,
+// not verified yet or inactive
+func (c userConfig) ListPendingByUserID(userID, platform string) ([]models.UserAgentConfig, error) {
 	var configs []models.UserAgentConfig
 	return configs, c.db.Table("user_agent_configs").
 		Select("user_agent_configs.*").
 		Joins("JOIN users ON users.id = user_agent_configs.user_id").
-		Where("users.user_id = ? AND (user_agent_configs.api_key = '' OR user_agent_configs.endpoint_url = '') OR user_agent_configs.is_active = false", userID).
+		Where("users.user_id = ? AND users.platform = ? AND (user_agent_configs.api_key = '' OR user_agent_configs.endpoint_url = '' OR user_agent_configs.verified_at IS NULL OR user_agent_configs.is_active = false)", userID, platform).
 		Find(&configs).Error
 }
 
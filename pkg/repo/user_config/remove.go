@@ -1,7 +1,46 @@
 package userconfig
 
-import "sum/pkg/models"
+import (
+	"errors"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
 
 func (c userConfig) RemoveByID(id string) error {
-	return c.db.Delete(&models.UserAgentConfig{}, "id = ?", id).Error
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		var before models.UserAgentConfig
+		err := tx.Where("id = ?", id).First(&before).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&models.UserAgentConfig{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return c.record(tx, models.AuditActionConfigRemove, &before, nil)
+	})
+}
+
+// RemoveByIDForUser deletes a config of the user ownerID, it returns gorm.ErrRecordNotFound
+// when the user has no config with that ID
+func (c userConfig) RemoveByIDForUser(id string, ownerID int64) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		var before models.UserAgentConfig
+		if err := tx.Where("id = ? AND user_id = ?", id, ownerID).First(&before).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&models.UserAgentConfig{}, "id = ? AND user_id = ?", id, ownerID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return c.record(tx, models.AuditActionConfigRemove, &before, nil)
+	})
 }
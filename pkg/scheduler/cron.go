@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// macros are the cron shorthands accepted in place of the five fields
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// maxSearchYears bounds the search for the next run, expressions such as 0 0 30 2 * never match
+const maxSearchYears = 5
+
+// ErrNeverRuns is returned for expressions matching no date, such as February 30th
+var ErrNeverRuns = errors.New("cron expression never matches")
+
+// field bounds the values of a cron field
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	{name: "day of week", min: 0, max: 7, names: dayNames}, // 0 and 7 are Sunday
+}
+
+// Cron is a parsed cron expression: minute, hour, day of month, month and day of week
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // Bit sets of the matching values
+
+	// A restricted day of month or day of week matches either, like in cron
+	domAny, dowAny bool
+}
+
+// ParseCron parses a five field cron expression, with the usual lists, ranges, steps and
+// month and day names, or one of the @yearly, @monthly, @weekly, @daily and @hourly macros
+func ParseCron(expr string) (Cron, error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	if strings.HasPrefix(expr, "@") {
+		macro, ok := macros[expr]
+		if !ok {
+			return Cron{}, fmt.Errorf("unknown cron macro %q", expr)
+		}
+		expr = macro
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return Cron{}, fmt.Errorf("cron expression needs %d fields, got %d", len(fields), len(parts))
+	}
+
+	var (
+		c    Cron
+		sets [5]uint64
+	)
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Cron{}, err
+		}
+		sets[i] = set
+	}
+
+	c.minute, c.hour, c.dom, c.month, c.dow = sets[0], sets[1], sets[2], sets[3], sets[4]
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 << 0
+	}
+	c.domAny = strings.HasPrefix(parts[2], "*")
+	c.dowAny = strings.HasPrefix(parts[4], "*")
+
+	// The search for the next run starts from a leap year to find February 29th
+	if c.Next(time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return Cron{}, ErrNeverRuns
+	}
+	return c, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps into a bit set
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(part, ",") {
+		spec, stepText, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepText, f.name)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case spec == "*":
+		case strings.Contains(spec, "-"):
+			lowText, highText, _ := strings.Cut(spec, "-")
+			var err error
+			if low, err = parseValue(lowText, f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(highText, f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s field", spec, f.name)
+			}
+		default:
+			value, err := parseValue(spec, f)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			// A single value with a step runs from the value to the end of the range
+			if !hasStep {
+				high = value
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a number or a name of a field
+func parseValue(text string, f field) (int, error) {
+	if v, ok := f.names[text]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected %d-%d", text, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matching the expression, in the location of t.
+// Local times skipped when clocks go forward never match. It returns the zero time when
+// nothing matches within a few years.
+func (c Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			// Minutes are added rather than hours set, so repeated hours when clocks go back move forward
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day of month and day of week fields
+func (c Cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// advance moves to next unless the change of clocks of the location would move back
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour)
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"sum/pkg/adapter/feed"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// maxFeedsPerRun bounds the feeds polled at once, the rest are polled on the next tick
+const maxFeedsPerRun = 20
+
+// maxItemKeyLength is the length of the longest GUID stored as is, longer ones are hashed
+const maxItemKeyLength = 255
+
+// seenRetention is how long the items that left a feed are remembered
+const seenRetention = 30 * 24 * time.Hour
+
+// PollDue polls the feeds due now, if this instance holds the lease, and posts their
+// new items. Each poll is claimed first, like the runs of schedules.
+func (s *scheduler) PollDue(ctx context.Context) error {
+	now := s.now()
+
+	leader, err := s.lead(now)
+	if err != nil || !leader {
+		return err
+	}
+
+	due, err := s.repo.Feed().ListDue(now, maxFeedsPerRun)
+	if err != nil {
+		return fmt.Errorf("failed to list due feeds: %w", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, f := range due {
+		next := now.Add(time.Duration(f.IntervalMinutes) * time.Minute)
+		claimed, err := s.repo.Feed().Claim(f.ID, f.NextPollAt, next, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to claim feed %d: %w", f.ID, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		wg.Add(1)
+		go func(f models.Feed) {
+			defer wg.Done()
+			s.poll(ctx, f, now)
+		}(f)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// poll fetches a feed and posts its new items, the oldest first. Beyond the item cap
+// of the feed, the oldest new items are skipped.
+func (s *scheduler) poll(ctx context.Context, f models.Feed, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+
+	sender, ok := s.senders[f.Platform]
+	if !ok {
+		s.logger.Warnf("No sender for feed %d on %s", f.ID, f.Platform)
+		return
+	}
+
+	fetched, err := s.feeds.Fetch(f.URL)
+	if err != nil {
+		s.logger.Warnf("Failed to fetch feed %d: %v", f.ID, err)
+		if err := s.repo.Feed().SetError(f.ID, err.Error()); err != nil {
+			s.logger.Errorf(err, "Failed to save the error of feed %d", f.ID)
+		}
+		return
+	}
+	if f.LastError != "" {
+		if err := s.repo.Feed().SetError(f.ID, ""); err != nil {
+			s.logger.Errorf(err, "Failed to clear the error of feed %d", f.ID)
+		}
+	}
+
+	keys := ItemKeys(fetched.Items)
+	seen, err := s.repo.Feed().Seen(f.ID, keys)
+	if err != nil {
+		s.logger.Errorf(err, "Failed to read the seen items of feed %d", f.ID)
+		return
+	}
+
+	var (
+		fresh     []feed.Item
+		freshKeys []string
+	)
+	for i := len(fetched.Items) - 1; i >= 0; i-- {
+		if seen[keys[i]] {
+			continue
+		}
+		seen[keys[i]] = true
+		fresh = append(fresh, fetched.Items[i])
+		freshKeys = append(freshKeys, keys[i])
+	}
+
+	// Items are marked before they are posted, an item is posted at most once
+	if err := s.repo.Feed().MarkSeen(f.ID, freshKeys, now); err != nil {
+		s.logger.Errorf(err, "Failed to mark the items of feed %d as seen", f.ID)
+		return
+	}
+	if err := s.repo.Feed().Prune(f.ID, keys, now.Add(-seenRetention)); err != nil {
+		s.logger.Errorf(err, "Failed to prune the seen items of feed %d", f.ID)
+	}
+
+	skipped := 0
+	if len(fresh) > f.MaxItems {
+		skipped = len(fresh) - f.MaxItems
+		fresh = fresh[skipped:]
+	}
+
+	for _, item := range fresh {
+		text, err := s.summarize(f, item)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The summarizer was removed, the feed goes with it
+			if err := s.repo.Feed().Remove(f.ID); err != nil {
+				s.logger.Errorf(err, "Failed to remove feed %d", f.ID)
+			}
+			text = fmt.Sprintf("⚠️ /%s no longer exists, feed %d was removed.", f.Command, f.ID)
+			if err := sender.Send(ctx, f.ChatID, text); err != nil {
+				s.logger.Errorf(err, "Failed to post the removal of feed %d", f.ID)
+			}
+			return
+		}
+
+		if err := sender.Send(ctx, f.ChatID, text); err != nil {
+			s.logger.Errorf(err, "Failed to post an item of feed %d", f.ID)
+		}
+	}
+
+	if skipped > 0 {
+		if err := sender.Send(ctx, f.ChatID, fmt.Sprintf("⏭ %d older items of %s were skipped.", skipped, feedName(f))); err != nil {
+			s.logger.Errorf(err, "Failed to post the skipped items of feed %d", f.ID)
+		}
+	}
+}
+
+// summarize returns the post of an item: its title, link and summary. Items are posted
+// without a summary when the summarizer fails, unless it was removed.
+func (s *scheduler) summarize(f models.Feed, item feed.Item) (string, error) {
+	title := item.Title
+	if title == "" {
+		title = item.Link
+	}
+	post := fmt.Sprintf("📰 %s", title)
+	if item.Link != "" && item.Link != title {
+		post += "\n" + item.Link
+	}
+
+	// Like /sum, the summarizer is sent the link of the article
+	message := item.Link
+	if message == "" {
+		message = item.Title
+	}
+
+	_, summary, err := s.ask(f.Platform, f.CreatedBy, f.ChatID, f.ConfigType, f.ConfigID, message, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err != nil {
+		s.logger.Warnf("Posting an item of feed %d without a summary: %v", f.ID, err)
+		return post, nil
+	}
+	return post + "\n\n" + strings.TrimSpace(summary), nil
+}
+
+// ItemKeys returns the keys the items of a feed are remembered by once seen
+func ItemKeys(items []feed.Item) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		key := item.GUID
+		if len(key) > maxItemKeyLength {
+			sum := sha256.Sum256([]byte(key))
+			key = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// feedName returns the title of a feed, or its URL when it has none
+func feedName(f models.Feed) string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return f.URL
+}
@@ -0,0 +1,254 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sum/pkg/adapter/dify"
+	"sum/pkg/adapter/feed"
+	"sum/pkg/agent"
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/ratelimit"
+	"sum/pkg/repo"
+	"sum/pkg/repo/secret"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for the scheduler
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeRunner summarizes every message as "summary of" the message
+type fakeRunner struct{}
+
+func (fakeRunner) Run(req agent.Request) (*dify.ChatResponse, error) {
+	return &dify.ChatResponse{Answer: "summary of " + req.Message}, nil
+}
+
+// fakeSender records the posts of every chat
+type fakeSender struct {
+	mu    sync.Mutex
+	posts []string
+}
+
+func (s *fakeSender) Send(_ context.Context, _, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts = append(s.posts, text)
+	return nil
+}
+
+// take returns the posts since the last call
+func (s *fakeSender) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	posts := s.posts
+	s.posts = nil
+	return posts
+}
+
+// feedServer serves an RSS feed of the given GUIDs, the newest first, or fails
+type feedServer struct {
+	mu     sync.Mutex
+	guids  []string
+	failed bool
+}
+
+func (f *feedServer) set(guids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.guids = guids
+}
+
+func (f *feedServer) fail(failed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = failed
+}
+
+func (f *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failed {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>`)
+	for _, guid := range f.guids {
+		fmt.Fprintf(w, "<item><guid>%s</guid><title>%s</title><link>https://news.test/%s</link></item>", guid, guid, guid)
+	}
+	fmt.Fprint(w, `</channel></rss>`)
+}
+
+// fixture is a scheduler polling a feed served by a test server every 10 minutes
+type fixture struct {
+	scheduler IScheduler
+	repo      repo.Repository
+	server    *feedServer
+	sender    *fakeSender
+	clock     *clock
+}
+
+// newFixture watches the feed served with the given GUIDs, they are seen already like
+// the items of a feed when it is watched
+func newFixture(t *testing.T, maxItems int, guids ...string) *fixture {
+	t.Helper()
+	require.NoError(t, models.InitIDGenerators())
+
+	db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+	require.NoError(t, err)
+	_, err = database.Migrate(db)
+	require.NoError(t, err)
+	cfg := config.LoadTestConfig()
+	secrets, err := secret.NewFromConfig(cfg)
+	require.NoError(t, err)
+	r := repo.NewRepository(db, secrets)
+
+	server := &feedServer{guids: guids}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	f := &fixture{
+		repo:   r,
+		server: server,
+		sender: &fakeSender{},
+		clock:  &clock{now: time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)},
+	}
+
+	_, err = r.Feed().Create(models.Feed{
+		Platform:        models.PlatformTelegram,
+		ChatID:          "-100",
+		CreatedBy:       "1",
+		URL:             httpServer.URL,
+		Title:           "News",
+		ConfigType:      models.ConfigTypeBuiltin,
+		IntervalMinutes: 10,
+		MaxItems:        maxItems,
+		NextPollAt:      f.clock.Now(),
+	}, guids)
+	require.NoError(t, err)
+
+	cfg.InstanceID = "test"
+	cfg.SchedulerIntervalSeconds = 60
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), config.RateLimitConfig{})
+	senders := map[models.PlatformType]ISender{models.PlatformTelegram: f.sender}
+	f.scheduler = NewWithClock(r, cfg, agent.NewInvoker(r, fakeRunner{}, limiter, cfg, logger.NewLogrusLogger()), feed.New(httpServer.Client()), senders, logger.NewLogrusLogger(), f.clock.Now)
+	return f
+}
+
+// poll polls the due feeds and returns what was posted
+func (f *fixture) poll(t *testing.T) []string {
+	t.Helper()
+	require.NoError(t, f.scheduler.PollDue(context.Background()))
+	return f.sender.take()
+}
+
+// post is the post of an item served by feedServer
+func post(guid string) string {
+	return fmt.Sprintf("📰 %s\nhttps://news.test/%s\n\nsummary of https://news.test/%s", guid, guid, guid)
+}
+
+func TestPollNewItems(t *testing.T) {
+	f := newFixture(t, 5, "b", "a")
+
+	// The items there when the feed was watched aren't posted
+	assert.Empty(t, f.poll(t))
+
+	f.server.set("d", "c", "b", "a")
+	f.clock.Advance(10 * time.Minute)
+	assert.Equal(t, []string{post("c"), post("d")}, f.poll(t))
+
+	// A feed isn't polled before its interval
+	f.server.set("e", "d", "c", "b", "a")
+	f.clock.Advance(5 * time.Minute)
+	assert.Empty(t, f.poll(t))
+	f.clock.Advance(5 * time.Minute)
+	assert.Equal(t, []string{post("e")}, f.poll(t))
+}
+
+func TestPollDedupe(t *testing.T) {
+	f := newFixture(t, 5, "a")
+
+	// An item repeated in a fetch is posted once
+	f.server.set("b", "b", "a")
+	assert.Equal(t, []string{post("b")}, f.poll(t))
+
+	// Items already posted aren't posted again, even when they come back after leaving the feed
+	f.server.set("c")
+	f.clock.Advance(10 * time.Minute)
+	assert.Equal(t, []string{post("c")}, f.poll(t))
+	f.server.set("c", "b", "a")
+	f.clock.Advance(10 * time.Minute)
+	assert.Empty(t, f.poll(t))
+}
+
+func TestPollMaxItems(t *testing.T) {
+	f := newFixture(t, 2, "a")
+
+	// Beyond the cap the oldest new items are skipped, and never posted later
+	f.server.set("f", "e", "d", "c", "b", "a")
+	assert.Equal(t, []string{post("e"), post("f"), "⏭ 3 older items of News were skipped."}, f.poll(t))
+
+	f.server.set("g", "f", "e", "d", "c", "b", "a")
+	f.clock.Advance(10 * time.Minute)
+	assert.Equal(t, []string{post("g")}, f.poll(t))
+}
+
+func TestPollError(t *testing.T) {
+	f := newFixture(t, 5, "a")
+	lastError := func() string {
+		feeds, err := f.repo.Feed().ListByChatID(models.PlatformTelegram, "-100")
+		require.NoError(t, err)
+		require.Len(t, feeds, 1)
+		return feeds[0].LastError
+	}
+
+	f.server.fail(true)
+	f.server.set("b", "a")
+	assert.Empty(t, f.poll(t))
+	assert.Contains(t, lastError(), "503")
+
+	// The items are posted once the feed is back, and the error is cleared
+	f.server.fail(false)
+	f.clock.Advance(10 * time.Minute)
+	assert.Equal(t, []string{post("b")}, f.poll(t))
+	assert.Empty(t, lastError())
+}
+
+func TestItemKeys(t *testing.T) {
+	long := "https://news.test/" + strings.Repeat("a", maxItemKeyLength)
+	keys := ItemKeys([]feed.Item{{GUID: "a"}, {GUID: long}, {GUID: long + "b"}})
+
+	assert.Equal(t, "a", keys[0])
+	assert.True(t, strings.HasPrefix(keys[1], "sha256:"), keys[1])
+	assert.LessOrEqual(t, len(keys[1]), maxItemKeyLength)
+	assert.NotEqual(t, keys[1], keys[2])
+	assert.Equal(t, keys[1], ItemKeys([]feed.Item{{GUID: long}})[0])
+}
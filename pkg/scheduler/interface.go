@@ -0,0 +1,48 @@
+// Package scheduler runs agent commands on cron schedules and watches feeds, posting the
+// answers and the summaries of new feed items to chats. Every instance runs a worker,
+// the instance holding the scheduler lease fires the due schedules and polls the due feeds.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	// Schedules name IANA time zones, which the image may not ship
+	_ "time/tzdata"
+)
+
+// IScheduler defines the interface for starting and stopping the worker firing due schedules and polling due feeds
+type IScheduler interface {
+	// Start fires the due schedules and polls the due feeds every interval until End is called
+	Start() error
+	// End stops the worker and gives up the lease, so another instance takes over
+	End() error
+	// RunDue fires the schedules due now, if this instance holds the lease, and waits for their answers
+	RunDue(ctx context.Context) error
+	// PollDue polls the feeds due now, if this instance holds the lease, and waits for their items to be posted
+	PollDue(ctx context.Context) error
+}
+
+// ISender posts the answers of schedules and the items of feeds to the chats of a platform
+type ISender interface {
+	Send(ctx context.Context, chatID, text string) error
+}
+
+// NextRun returns the first run of a cron expression in a time zone after t
+func NextRun(cron, timezone string, t time.Time) (time.Time, error) {
+	c, err := ParseCron(cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", timezone)
+	}
+
+	next := c.Next(t.In(loc))
+	if next.IsZero() {
+		return time.Time{}, ErrNeverRuns
+	}
+	return next, nil
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sum/pkg/agent"
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// fire runs a schedule and posts its answer, or why there is none, to its chat
+func (s *scheduler) fire(ctx context.Context, schedule models.Schedule) {
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+
+	sender, ok := s.senders[schedule.Platform]
+	if !ok {
+		s.logger.Warnf("No sender for schedule %d on %s", schedule.ID, schedule.Platform)
+		return
+	}
+
+	var limit *agent.LimitError
+	command, answer, err := s.ask(schedule.Platform, schedule.CreatedBy, schedule.ChatID, schedule.ConfigType, schedule.ConfigID, schedule.Prompt, true)
+	text := fmt.Sprintf("🗓 /%s\n\n%s", command, answer)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// The config was removed, the schedule goes with it
+		if err := s.repo.Schedule().Remove(schedule.ID); err != nil {
+			s.logger.Errorf(err, "Failed to remove schedule %d", schedule.ID)
+		}
+		text = fmt.Sprintf("⚠️ /%s no longer exists, schedule %d was removed.", schedule.Command, schedule.ID)
+	case errors.As(err, &limit):
+		text = fmt.Sprintf("Scheduled /%s skipped. %s", command, limit.Decision.Message())
+	case err != nil:
+		s.logger.Errorf(err, "Failed to run schedule %d", schedule.ID)
+		text = fmt.Sprintf("⚠️ Scheduled /%s failed. %s", schedule.Command, s.invoker.Failure(err))
+	}
+
+	if err := sender.Send(ctx, schedule.ChatID, text); err != nil {
+		s.logger.Errorf(err, "Failed to post the answer of schedule %d", schedule.ID)
+	}
+}
+
+// ask sends message to the agent of a config on behalf of the user who set it up, it
+// returns the name of the config and the answer. Fresh answers skip the response cache,
+// a schedule asks the same question every time and expects a new answer.
+func (s *scheduler) ask(platform models.PlatformType, userID, chatID string, configType models.ConfigType, configID int64, message string, fresh bool) (string, string, error) {
+	t, err := s.invoker.Load(configType, configID)
+	if err != nil {
+		return "", "", err
+	}
+
+	req, err := s.invoker.Request(agent.Caller{Platform: platform, UserID: userID, ChatID: chatID}, t, message)
+	if err != nil {
+		return t.Command, "", err
+	}
+	req.Fresh = fresh
+
+	resp, err := s.invoker.Send(req)
+	if err != nil {
+		return t.Command, "", err
+	}
+	return t.Command, resp.Answer, nil
+}
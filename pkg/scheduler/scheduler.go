@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"sum/pkg/adapter/feed"
+	"sum/pkg/agent"
+	"sum/pkg/config"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+	"sum/pkg/repo"
+)
+
+// leaseName is the lease held by the instance firing the schedules
+const leaseName = "scheduler"
+
+// maxDuePerRun bounds the schedules fired at once, the rest are fired on the next tick
+const maxDuePerRun = 50
+
+// runTimeout bounds how long a schedule waits for its answer to be posted
+const runTimeout = 5 * time.Minute
+
+// scheduler implements IScheduler
+type scheduler struct {
+	repo     repo.Repository
+	invoker  *agent.Invoker // Asks the agents of the schedules and feeds, the built-in one for feeds watched without a command
+	feeds    feed.FeedAdapter
+	senders  map[models.PlatformType]ISender
+	holder   string
+	interval time.Duration
+	now      func() time.Time
+	logger   logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a new scheduler posting answers and feed items with the sender of the
+// platform of each schedule and feed
+func New(repo repo.Repository, cfg config.Config, invoker *agent.Invoker, feeds feed.FeedAdapter, senders map[models.PlatformType]ISender, logger logger.Logger) IScheduler {
+	return NewWithClock(repo, cfg, invoker, feeds, senders, logger, time.Now)
+}
+
+// NewWithClock creates a new scheduler using a custom clock, mainly useful for tests
+func NewWithClock(repo repo.Repository, cfg config.Config, invoker *agent.Invoker, feeds feed.FeedAdapter, senders map[models.PlatformType]ISender, logger logger.Logger, now func() time.Time) IScheduler {
+	holder := cfg.InstanceID
+	if holder == "" {
+		hostname, _ := os.Hostname()
+		holder = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	return &scheduler{
+		repo:     repo,
+		invoker:  invoker,
+		feeds:    feeds,
+		senders:  senders,
+		holder:   holder,
+		interval: time.Duration(cfg.SchedulerIntervalSeconds) * time.Second,
+		now:      now,
+		logger:   logger,
+	}
+}
+
+func (s *scheduler) Start() error {
+	if s.interval <= 0 {
+		return fmt.Errorf("invalid scheduler interval %s", s.interval)
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunDue(context.Background()); err != nil {
+				s.logger.Error(err, "Failed to run due schedules")
+			}
+			if err := s.PollDue(context.Background()); err != nil {
+				s.logger.Error(err, "Failed to poll due feeds")
+			}
+
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *scheduler) End() error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	<-s.done
+	return s.repo.Lease().Release(leaseName, s.holder)
+}
+
+// RunDue claims each due schedule before firing it, so a run is fired once even when
+// the lease changes hands between instances
+func (s *scheduler) RunDue(ctx context.Context) error {
+	now := s.now()
+
+	leader, err := s.lead(now)
+	if err != nil || !leader {
+		return err
+	}
+
+	due, err := s.repo.Schedule().ListDue(now, maxDuePerRun)
+	if err != nil {
+		return fmt.Errorf("failed to list due schedules: %w", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, schedule := range due {
+		// Runs missed while no instance was up are fired once, then the schedule moves on from now
+		next, err := NextRun(schedule.Cron, schedule.Timezone, now)
+		if err != nil {
+			s.logger.Errorf(err, "Removing schedule %d with an invalid expression", schedule.ID)
+			errs = append(errs, s.repo.Schedule().Remove(schedule.ID))
+			continue
+		}
+
+		claimed, err := s.repo.Schedule().Claim(schedule.ID, schedule.NextRunAt, next, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to claim schedule %d: %w", schedule.ID, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		wg.Add(1)
+		go func(schedule models.Schedule) {
+			defer wg.Done()
+			s.fire(ctx, schedule)
+		}(schedule)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// lead reports whether this instance holds the lease, taking or renewing it
+func (s *scheduler) lead(now time.Time) (bool, error) {
+	// The lease outlives a few ticks, so a missed renewal doesn't hand it over
+	leader, err := s.repo.Lease().Acquire(leaseName, s.holder, 3*s.interval, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire the scheduler lease: %w", err)
+	}
+	return leader, nil
+}
@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-telegram/bot"
+)
+
+// maxTelegramMessageLength is the length of the longest message Telegram accepts
+const maxTelegramMessageLength = 4096
+
+// telegramSender posts answers with the Telegram bot
+type telegramSender struct {
+	bot *bot.Bot
+}
+
+// NewTelegramSender creates a sender posting to Telegram chats
+func NewTelegramSender(b *bot.Bot) ISender {
+	return &telegramSender{bot: b}
+}
+
+func (s *telegramSender) Send(ctx context.Context, chatID, text string) error {
+	id, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Telegram chat ID %q: %w", chatID, err)
+	}
+
+	if runes := []rune(text); len(runes) > maxTelegramMessageLength {
+		text = string(runes[:maxTelegramMessageLength-1]) + "…"
+	}
+	_, err = s.bot.SendMessage(ctx, &bot.SendMessageParams{ChatID: id, Text: text})
+	return err
+}
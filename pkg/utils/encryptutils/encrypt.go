@@ -25,6 +25,9 @@ func NewEncryptionKey(base64Key string) (EncryptionKey, error) {
 }
 
 // EncryptAPIKey encrypts the given API key using AES encryption
+//
+// Deprecated: AES-CFB is unauthenticated, use EncryptGCM. It is kept to read
+// and upgrade values written before the AES-GCM envelope.
 func EncryptAPIKey(key EncryptionKey, apiKey string) (string, error) {
 	plaintext := []byte(apiKey)
 	block, err := aes.NewCipher(key)
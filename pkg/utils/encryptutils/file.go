@@ -0,0 +1,46 @@
+package encryptutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// keyFile is the JSON format of a local key file, e.g.
+//
+//	{"primary": "2", "keys": {"1": "<base64 key>", "2": "<base64 key>"}}
+type keyFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// NewKeyFileStore creates a LocalStore from a JSON key file, a stand-in for a
+// KMS that keeps the keys out of the environment
+func NewKeyFileStore(path string) (*LocalStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keys := make(map[string]EncryptionKey, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := NewEncryptionKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q in key file: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	primary, ok := keys[file.Primary]
+	if !ok {
+		return nil, fmt.Errorf("primary key %q is missing from the key file", file.Primary)
+	}
+	delete(keys, file.Primary)
+
+	return NewLocalStore(file.Primary, primary, keys)
+}
@@ -0,0 +1,57 @@
+package encryptutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultHTTPTimeout bounds calls to remote secret stores
+const defaultHTTPTimeout = 10 * time.Second
+
+// maxHTTPResponse caps the size of a remote secret store response
+const maxHTTPResponse = 1 << 20
+
+// postJSON sends body as JSON to url with the given headers and decodes the JSON response into out
+func postJSON(client *http.Client, url string, headers map[string]string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("secret store returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// httpClientOrDefault returns client, or a client with the default timeout when it is nil
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: defaultHTTPTimeout}
+}
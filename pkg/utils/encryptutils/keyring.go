@@ -0,0 +1,122 @@
+package encryptutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LegacyKeyID is the key ID assumed for ciphertexts written before they were tagged with a key ID
+const LegacyKeyID = "1"
+
+// Envelope versions of stored ciphertexts
+const (
+	// VersionCFB is unauthenticated AES-CFB, stored as "<ciphertext>" or "<key ID>:<ciphertext>"
+	VersionCFB = 1
+	// VersionGCM is authenticated encryption bound to associated data, stored
+	// as "v2:<key ID>:<ciphertext>". The ciphertext format is up to the SecretStore.
+	VersionGCM = 2
+)
+
+// envelopeSeparator separates the envelope fields, it is not part of the base64 alphabet
+const envelopeSeparator = ":"
+
+const gcmPrefix = "v2"
+
+// ErrLegacyUnsupported is returned when a legacy value is read from a store without raw keys
+var ErrLegacyUnsupported = errors.New("secret store can't decrypt legacy AES-CFB values")
+
+// ErrLegacyDisabled is returned when a legacy value is read from a Keyring that doesn't accept them
+var ErrLegacyDisabled = errors.New("decrypting legacy AES-CFB values is disabled")
+
+// Envelope is a stored ciphertext split into its format version, key ID and ciphertext
+type Envelope struct {
+	Version    int
+	KeyID      string
+	Ciphertext string
+	Tagged     bool // Whether the key ID was stored or assumed to be LegacyKeyID
+}
+
+// ParseEnvelope splits a stored value into its envelope fields
+func ParseEnvelope(value string) Envelope {
+	parts := strings.SplitN(value, envelopeSeparator, 3)
+	switch {
+	case len(parts) == 3 && parts[0] == gcmPrefix:
+		return Envelope{Version: VersionGCM, KeyID: parts[1], Ciphertext: parts[2], Tagged: true}
+	case len(parts) == 2:
+		return Envelope{Version: VersionCFB, KeyID: parts[0], Ciphertext: parts[1], Tagged: true}
+	default:
+		return Envelope{Version: VersionCFB, KeyID: LegacyKeyID, Ciphertext: value}
+	}
+}
+
+// Keyring wraps the ciphertexts of a SecretStore in versioned envelopes
+type Keyring struct {
+	store   SecretStore
+	retired *LocalStore
+	legacy  bool // Whether unauthenticated VersionCFB values are decrypted
+}
+
+// NewKeyring creates a Keyring encrypting with store. retired optionally holds
+// local keys still needed to read values written before moving to store, it may be nil.
+// A retired key can't have the ID of the primary key of store, the values of either
+// would be sent to the other. VersionCFB values are only decrypted when legacy is set,
+// they aren't authenticated and should only be read to upgrade them.
+func NewKeyring(store SecretStore, retired *LocalStore, legacy bool) (*Keyring, error) {
+	if retired != nil && retired.HasKey(store.PrimaryKeyID()) {
+		return nil, fmt.Errorf("retired key %q has the same ID as the primary key of the secret store", store.PrimaryKeyID())
+	}
+	return &Keyring{store: store, retired: retired, legacy: legacy}, nil
+}
+
+// PrimaryID returns the ID of the key used for new ciphertexts
+func (k *Keyring) PrimaryID() string {
+	return k.store.PrimaryKeyID()
+}
+
+// HasKey reports whether the key id can decrypt VersionCFB values, either as a
+// retired key or as a raw key of the store
+func (k *Keyring) HasKey(id string) bool {
+	if k.retired != nil && k.retired.HasKey(id) {
+		return true
+	}
+	local, ok := k.store.(interface{ HasKey(id string) bool })
+	return ok && local.HasKey(id)
+}
+
+// Encrypt encrypts plaintext with the primary key, bound to associatedData,
+// and wraps the result in a VersionGCM envelope
+func (k *Keyring) Encrypt(plaintext, associatedData string) (string, error) {
+	keyID := k.store.PrimaryKeyID()
+	ciphertext, err := k.store.Encrypt(keyID, plaintext, associatedData)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{gcmPrefix, keyID, ciphertext}, envelopeSeparator), nil
+}
+
+// Decrypt decrypts a stored value with the key its envelope names.
+// associatedData is only checked for VersionGCM envelopes.
+func (k *Keyring) Decrypt(value, associatedData string) (string, error) {
+	envelope := ParseEnvelope(value)
+	retired := k.retired != nil && k.retired.HasKey(envelope.KeyID)
+
+	if envelope.Version == VersionGCM {
+		if retired {
+			return k.retired.Decrypt(envelope.KeyID, envelope.Ciphertext, associatedData)
+		}
+		return k.store.Decrypt(envelope.KeyID, envelope.Ciphertext, associatedData)
+	}
+
+	if !k.legacy {
+		return "", ErrLegacyDisabled
+	}
+	if k.retired != nil {
+		return k.retired.DecryptCFB(envelope.KeyID, envelope.Ciphertext)
+	}
+	legacy, ok := k.store.(LegacyStore)
+	if !ok {
+		return "", ErrLegacyUnsupported
+	}
+	return legacy.DecryptCFB(envelope.KeyID, envelope.Ciphertext)
+}
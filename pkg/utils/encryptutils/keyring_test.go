@@ -0,0 +1,65 @@
+package encryptutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns a 32-byte key filled with b
+func testKey(b byte) EncryptionKey {
+	return EncryptionKey(bytes.Repeat([]byte{b}, 32))
+}
+
+func newKeyring(t *testing.T, store SecretStore, retired *LocalStore, legacy bool) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(store, retired, legacy)
+	require.NoError(t, err)
+	return keyring
+}
+
+func TestKeyringLegacyCFB(t *testing.T) {
+	store, err := NewLocalStore("1", testKey(1), nil)
+	require.NoError(t, err)
+
+	legacy, err := EncryptAPIKey(testKey(1), "secret")
+	require.NoError(t, err)
+
+	for _, value := range []string{legacy, "1:" + legacy} {
+		_, err = newKeyring(t, store, nil, false).Decrypt(value, "42")
+		assert.ErrorIs(t, err, ErrLegacyDisabled)
+
+		plaintext, err := newKeyring(t, store, nil, true).Decrypt(value, "42")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", plaintext)
+	}
+
+	// Authenticated values don't need the flag
+	keyring := newKeyring(t, store, nil, false)
+	value, err := keyring.Encrypt("secret", "42")
+	require.NoError(t, err)
+	plaintext, err := keyring.Decrypt(value, "42")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+
+	_, err = keyring.Decrypt(value, "43")
+	assert.Error(t, err)
+}
+
+func TestParseKeys(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey(2))
+
+	keys, err := ParseKeys(" 2:" + encoded + ", ")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]EncryptionKey{"2": testKey(2)}, keys)
+
+	_, err = ParseKeys("2" + encoded)
+	assert.Error(t, err)
+	_, err = ParseKeys("2:c2hvcnQ=")
+	assert.Error(t, err)
+	_, err = ParseKeys("2:" + encoded + ",2:" + encoded)
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package encryptutils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// KMSStore is a SecretStore backed by a generic KMS HTTP API. It posts JSON to
+// <url>/encrypt and <url>/decrypt with base64 encoded values:
+//
+//	POST /encrypt {"key_id", "plaintext", "aad"} -> {"ciphertext"}
+//	POST /decrypt {"key_id", "ciphertext", "aad"} -> {"plaintext"}
+//
+// Requests are authenticated with a bearer token when one is set.
+type KMSStore struct {
+	url    string
+	token  string
+	keyID  string
+	client *http.Client
+}
+
+// NewKMSStore creates a KMSStore encrypting new secrets with keyID. A nil client uses a default timeout.
+func NewKMSStore(url, token, keyID string, client *http.Client) (*KMSStore, error) {
+	if url == "" || keyID == "" {
+		return nil, fmt.Errorf("KMS URL and key ID are required")
+	}
+	if err := validateKeyID(keyID); err != nil {
+		return nil, err
+	}
+
+	return &KMSStore{
+		url:    strings.TrimRight(url, "/"),
+		token:  token,
+		keyID:  keyID,
+		client: httpClientOrDefault(client),
+	}, nil
+}
+
+func (s *KMSStore) PrimaryKeyID() string {
+	return s.keyID
+}
+
+func (s *KMSStore) Encrypt(keyID, plaintext, associatedData string) (string, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := postJSON(s.client, s.url+"/encrypt", s.headers(), map[string]string{
+		"key_id":    keyID,
+		"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext)),
+		"aad":       base64.StdEncoding.EncodeToString([]byte(associatedData)),
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("KMS encrypt failed: %w", err)
+	}
+	return resp.Ciphertext, nil
+}
+
+func (s *KMSStore) Decrypt(keyID, ciphertext, associatedData string) (string, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := postJSON(s.client, s.url+"/decrypt", s.headers(), map[string]string{
+		"key_id":     keyID,
+		"ciphertext": ciphertext,
+		"aad":        base64.StdEncoding.EncodeToString([]byte(associatedData)),
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("KMS decrypt failed: %w", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (s *KMSStore) headers() map[string]string {
+	if s.token == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + s.token}
+}
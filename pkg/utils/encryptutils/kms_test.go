@@ -0,0 +1,150 @@
+package encryptutils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMS is a KMS HTTP API holding the given keys, it remembers the key, plaintext and
+// associated data of every ciphertext it returned
+type fakeKMS struct {
+	mu       sync.Mutex
+	keys     map[string]bool
+	values   map[string][3]string // Key ID, plaintext and associated data by ciphertext
+	requests int
+}
+
+func newFakeKMS(t *testing.T, keys ...string) (*fakeKMS, *httptest.Server) {
+	k := &fakeKMS{keys: map[string]bool{}, values: map[string][3]string{}}
+	for _, key := range keys {
+		k.keys[key] = true
+	}
+	server := httptest.NewServer(k)
+	t.Cleanup(server.Close)
+	return k, server
+}
+
+func (k *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.requests++
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !k.keys[body["key_id"]] {
+		http.Error(w, "unknown key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/kms/encrypt":
+		plaintext, _ := base64.StdEncoding.DecodeString(body["plaintext"])
+		ciphertext := fmt.Sprintf("c%d", len(k.values))
+		k.values[ciphertext] = [3]string{body["key_id"], string(plaintext), body["aad"]}
+		_ = json.NewEncoder(w).Encode(map[string]string{"ciphertext": ciphertext})
+	case "/kms/decrypt":
+		value, ok := k.values[body["ciphertext"]]
+		if !ok || value[0] != body["key_id"] || value[2] != body["aad"] {
+			http.Error(w, "decryption failed", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"plaintext": base64.StdEncoding.EncodeToString([]byte(value[1]))})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestKMSStore(t *testing.T) {
+	kms, server := newFakeKMS(t, "k1")
+	store, err := NewKMSStore(server.URL+"/kms/", "token", "k1", nil)
+	require.NoError(t, err)
+	keyring := newKeyring(t, store, nil, false)
+
+	value, err := keyring.Encrypt("secret", "42")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, "v2:k1:"), value)
+
+	plaintext, err := keyring.Decrypt(value, "42")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+	assert.Equal(t, 2, kms.requests)
+
+	// The ciphertext is bound to its config
+	_, err = keyring.Decrypt(value, "43")
+	assert.ErrorContains(t, err, "decryption failed")
+
+	anonymous, err := NewKMSStore(server.URL+"/kms", "", "k1", nil)
+	require.NoError(t, err)
+	_, err = anonymous.Encrypt("k1", "secret", "42")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestKMSStoreRotation(t *testing.T) {
+	_, server := newFakeKMS(t, "k1", "k2")
+	first, err := NewKMSStore(server.URL+"/kms", "token", "k1", nil)
+	require.NoError(t, err)
+	before, err := newKeyring(t, first, nil, false).Encrypt("old", "42")
+	require.NoError(t, err)
+
+	// New secrets use the new key, the old ones are decrypted with the key they name
+	second, err := NewKMSStore(server.URL+"/kms", "token", "k2", nil)
+	require.NoError(t, err)
+	keyring := newKeyring(t, second, nil, false)
+	after, err := keyring.Encrypt("new", "42")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(after, "v2:k2:"), after)
+
+	for value, want := range map[string]string{before: "old", after: "new"} {
+		plaintext, err := keyring.Decrypt(value, "42")
+		require.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+}
+
+func TestKMSStoreRetiredKey(t *testing.T) {
+	kms, server := newFakeKMS(t, "k1")
+	store, err := NewKMSStore(server.URL+"/kms", "token", "k1", nil)
+	require.NoError(t, err)
+
+	// Values written with the environment keys before moving to the KMS
+	local, err := NewLocalStore("2", testKey(2), map[string]EncryptionKey{"1": testKey(1)})
+	require.NoError(t, err)
+	old, err := newKeyring(t, local, nil, false).Encrypt("old", "42")
+	require.NoError(t, err)
+	retired, err := EncryptAPIKey(testKey(1), "retired")
+	require.NoError(t, err)
+
+	keyring := newKeyring(t, store, local, true)
+	for value, want := range map[string]string{old: "old", "1:" + retired: "retired"} {
+		plaintext, err := keyring.Decrypt(value, "42")
+		require.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+	assert.Zero(t, kms.requests)
+
+	_, err = keyring.Decrypt("v2:3:c0", "42")
+	assert.ErrorContains(t, err, "unknown key")
+}
+
+func TestKMSStoreRetiredKeyID(t *testing.T) {
+	_, server := newFakeKMS(t, "1")
+	store, err := NewKMSStore(server.URL+"/kms", "token", "1", nil)
+	require.NoError(t, err)
+	local, err := NewLocalStore("2", testKey(2), map[string]EncryptionKey{"1": testKey(1)})
+	require.NoError(t, err)
+
+	_, err = NewKeyring(store, local, false)
+	assert.ErrorContains(t, err, `retired key "1" has the same ID`)
+}
@@ -0,0 +1,115 @@
+package encryptutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownKeyID is returned when a ciphertext was encrypted with a key that is not in the store
+var ErrUnknownKeyID = errors.New("ciphertext was encrypted with an unknown key")
+
+// LocalStore is a SecretStore holding the raw keys in memory, loaded from the
+// environment or a key file
+type LocalStore struct {
+	primaryID string
+	keys      map[string]EncryptionKey
+}
+
+// NewLocalStore creates a LocalStore from the primary key and its ID, and the retired keys by ID
+func NewLocalStore(primaryID string, primary EncryptionKey, retired map[string]EncryptionKey) (*LocalStore, error) {
+	if err := validateKeyID(primaryID); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]EncryptionKey{primaryID: primary}
+	for id, key := range retired {
+		if err := validateKeyID(id); err != nil {
+			return nil, err
+		}
+		if id == primaryID {
+			return nil, fmt.Errorf("retired key %q has the same ID as the primary key", id)
+		}
+		keys[id] = key
+	}
+
+	return &LocalStore{primaryID: primaryID, keys: keys}, nil
+}
+
+// ParseKeys parses a comma separated list of id:base64 keys, every ID must be unique
+func ParseKeys(s string) (map[string]EncryptionKey, error) {
+	keys := make(map[string]EncryptionKey)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, envelopeSeparator)
+		if !ok {
+			return nil, fmt.Errorf("key %q must be in the id:base64 format", entry)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("key %q is listed more than once", id)
+		}
+		key, err := NewEncryptionKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+func (s *LocalStore) PrimaryKeyID() string {
+	return s.primaryID
+}
+
+func (s *LocalStore) Encrypt(keyID, plaintext, associatedData string) (string, error) {
+	key, err := s.key(keyID)
+	if err != nil {
+		return "", err
+	}
+	return EncryptGCM(key, plaintext, associatedData)
+}
+
+func (s *LocalStore) Decrypt(keyID, ciphertext, associatedData string) (string, error) {
+	key, err := s.key(keyID)
+	if err != nil {
+		return "", err
+	}
+	return DecryptGCM(key, ciphertext, associatedData)
+}
+
+func (s *LocalStore) DecryptCFB(keyID, ciphertext string) (string, error) {
+	key, err := s.key(keyID)
+	if err != nil {
+		return "", err
+	}
+	return DecryptAPIKey(key, ciphertext)
+}
+
+// HasKey reports whether the store holds the key id
+func (s *LocalStore) HasKey(id string) bool {
+	_, ok := s.keys[id]
+	return ok
+}
+
+func (s *LocalStore) key(id string) (EncryptionKey, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKeyID, id)
+	}
+	return key, nil
+}
+
+// validateKeyID checks that a key ID can be used in an envelope
+func validateKeyID(id string) error {
+	if id == "" {
+		return errors.New("key ID must not be empty")
+	}
+	if strings.Contains(id, envelopeSeparator) || strings.Contains(id, ",") {
+		return fmt.Errorf("key ID %q must not contain %q or \",\"", id, envelopeSeparator)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package encryptutils
+
+// SecretStore encrypts and decrypts secrets with keys identified by an ID.
+// The key material may live in memory or in an external service.
+type SecretStore interface {
+	// PrimaryKeyID returns the ID of the key used for new ciphertexts
+	PrimaryKeyID() string
+	// Encrypt encrypts plaintext with the key keyID, bound to associatedData
+	Encrypt(keyID, plaintext, associatedData string) (string, error)
+	// Decrypt decrypts a ciphertext produced by Encrypt with the same key and associated data
+	Decrypt(keyID, ciphertext, associatedData string) (string, error)
+}
+
+// LegacyStore is implemented by stores holding raw keys, which can still
+// decrypt the unauthenticated AES-CFB values written before AES-GCM
+type LegacyStore interface {
+	DecryptCFB(keyID, ciphertext string) (string, error)
+}
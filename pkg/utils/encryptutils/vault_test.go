@@ -0,0 +1,172 @@
+package encryptutils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault is a transit engine mounted at secrets/transit, it remembers the plaintext
+// and associated data of every ciphertext it returned
+type fakeVault struct {
+	mu       sync.Mutex
+	versions map[string]int       // Latest version of each transit key
+	values   map[string][2]string // Plaintext and associated data by ciphertext
+	requests int
+}
+
+func newFakeVault(t *testing.T, keys ...string) (*fakeVault, *httptest.Server) {
+	v := &fakeVault{versions: map[string]int{}, values: map[string][2]string{}}
+	for _, key := range keys {
+		v.versions[key] = 1
+	}
+	server := httptest.NewServer(v)
+	t.Cleanup(server.Close)
+	return v, server
+}
+
+// rotate starts a new version of a transit key, the previous versions still decrypt
+func (v *fakeVault) rotate(key string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.versions[key]++
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.requests++
+
+	if r.Header.Get("X-Vault-Token") != "token" {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	operation, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/secrets/transit/"), "/")
+	version, known := v.versions[key]
+	if !ok || !known {
+		http.Error(w, `{"errors":["encryption key not found"]}`, http.StatusBadRequest)
+		return
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"errors":["invalid request"]}`, http.StatusBadRequest)
+		return
+	}
+
+	switch operation {
+	case "encrypt":
+		plaintext, _ := base64.StdEncoding.DecodeString(body["plaintext"])
+		ciphertext := fmt.Sprintf("vault:v%d:%d", version, len(v.values))
+		v.values[ciphertext] = [2]string{string(plaintext), body["associated_data"]}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": ciphertext}})
+	case "decrypt":
+		value, ok := v.values[body["ciphertext"]]
+		if !ok || value[1] != body["associated_data"] {
+			http.Error(w, `{"errors":["cipher: message authentication failed"]}`, http.StatusBadRequest)
+			return
+		}
+		plaintext := base64.StdEncoding.EncodeToString([]byte(value[0]))
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": plaintext}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestVaultStore(t *testing.T) {
+	vault, server := newFakeVault(t, "ask")
+	store, err := NewVaultStore(server.URL+"/", "token", "/secrets/transit/", "ask", nil)
+	require.NoError(t, err)
+	keyring := newKeyring(t, store, nil, false)
+
+	value, err := keyring.Encrypt("secret", "42")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, "v2:ask:vault:v1:"), value)
+
+	plaintext, err := keyring.Decrypt(value, "42")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+	assert.Equal(t, 2, vault.requests)
+
+	// The ciphertext is bound to its config
+	_, err = keyring.Decrypt(value, "43")
+	assert.ErrorContains(t, err, "message authentication failed")
+
+	denied, err := NewVaultStore(server.URL, "other", "secrets/transit", "ask", nil)
+	require.NoError(t, err)
+	_, err = denied.Encrypt("ask", "secret", "42")
+	assert.ErrorContains(t, err, "403")
+
+	missing, err := NewVaultStore(server.URL, "token", "secrets/transit", "other", nil)
+	require.NoError(t, err)
+	_, err = missing.Encrypt("other", "secret", "42")
+	assert.ErrorContains(t, err, "encryption key not found")
+}
+
+func TestVaultStoreRotation(t *testing.T) {
+	vault, server := newFakeVault(t, "ask")
+	store, err := NewVaultStore(server.URL, "token", "secrets/transit", "ask", nil)
+	require.NoError(t, err)
+	keyring := newKeyring(t, store, nil, false)
+
+	before, err := keyring.Encrypt("old", "42")
+	require.NoError(t, err)
+
+	// Vault versions the transit key, the key ID of the envelopes doesn't change
+	vault.rotate("ask")
+	after, err := keyring.Encrypt("new", "42")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(after, "v2:ask:vault:v2:"), after)
+
+	for value, want := range map[string]string{before: "old", after: "new"} {
+		plaintext, err := keyring.Decrypt(value, "42")
+		require.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+}
+
+func TestVaultStoreRetiredKey(t *testing.T) {
+	vault, server := newFakeVault(t, "ask")
+	store, err := NewVaultStore(server.URL, "token", "secrets/transit", "ask", nil)
+	require.NoError(t, err)
+
+	// Values written with the environment key before moving to Vault
+	local, err := NewLocalStore("1", testKey(1), nil)
+	require.NoError(t, err)
+	old, err := newKeyring(t, local, nil, false).Encrypt("old", "42")
+	require.NoError(t, err)
+	legacy, err := EncryptAPIKey(testKey(1), "legacy")
+	require.NoError(t, err)
+
+	keyring := newKeyring(t, store, local, true)
+	for value, want := range map[string]string{old: "old", legacy: "legacy"} {
+		plaintext, err := keyring.Decrypt(value, "42")
+		require.NoError(t, err)
+		assert.Equal(t, want, plaintext)
+	}
+	assert.Zero(t, vault.requests)
+
+	value, err := keyring.Encrypt("new", "42")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, "v2:ask:"), value)
+	assert.Equal(t, 1, vault.requests)
+}
+
+func TestVaultStoreRetiredKeyID(t *testing.T) {
+	_, server := newFakeVault(t, "1")
+	store, err := NewVaultStore(server.URL, "token", "secrets/transit", "1", nil)
+	require.NoError(t, err)
+	local, err := NewLocalStore("1", testKey(1), nil)
+	require.NoError(t, err)
+
+	_, err = NewKeyring(store, local, false)
+	assert.ErrorContains(t, err, `retired key "1" has the same ID`)
+}
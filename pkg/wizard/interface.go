@@ -0,0 +1,58 @@
+// Package wizard tracks multi-step conversations, such as the registration
+// wizard, per chat and user so that concurrent conversations stay isolated.
+package wizard
+
+import (
+	"errors"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// ErrExpired is returned when the step of a session timed out before it was answered
+var ErrExpired = errors.New("wizard step timed out")
+
+// ErrInvalidToken is returned when a link token doesn't match the session of the user
+var ErrInvalidToken = errors.New("invalid or used wizard link")
+
+// Key identifies the conversation of a user in a chat
+type Key struct {
+	Platform models.PlatformType
+	ChatID   string
+	UserID   string
+}
+
+// Step is a step of a wizard waiting for an answer
+type Step struct {
+	Name    string
+	Timeout time.Duration // How long the step waits for an answer, the manager default when zero
+}
+
+// IManager defines the interface for starting, advancing and ending conversations
+type IManager interface {
+	// Start begins a conversation at step, replacing any conversation the user had in the chat
+	Start(key Key, flow, subjectID string, step Step) (models.WizardSession, error)
+	// Link begins a conversation that waits for no answer until Redeem is called with the
+	// returned one-time token, e.g. once the user opened a link to a private chat
+	Link(key Key, flow, subjectID string, step Step) (string, error)
+	// Redeem resumes a conversation begun by Link, it returns ErrInvalidToken when token doesn't match
+	Redeem(key Key, token string) (*models.WizardSession, error)
+	// Active returns the conversation waiting for an answer, or nil when there is none or it waits for Redeem.
+	// It returns ErrExpired, and ends the conversation, when the step timed out.
+	Active(key Key) (*models.WizardSession, error)
+	// WaitingFlow returns the flow of the conversation waiting for an answer of the user in the chat,
+	// or an empty string when there is none. It doesn't check the timeout.
+	WaitingFlow(key Key) string
+	// Cancel ends the conversation and reports whether there was one
+	Cancel(key Key) (bool, error)
+	// Lock serializes the handling of the answers of a conversation, call the returned func to unlock
+	Lock(key Key) func()
+}
+
+// IStore defines the persistence of conversation sessions
+type IStore interface {
+	Get(key Key) (*models.WizardSession, error) // Returns nil when there is no session
+	Save(session models.WizardSession) error
+	Delete(key Key) (bool, error)
+	DeleteExpired(now time.Time) error
+}
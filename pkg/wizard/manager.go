@@ -0,0 +1,167 @@
+package wizard
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// manager implements IManager on top of an IStore
+type manager struct {
+	store   IStore
+	timeout time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	locks map[Key]*keyLock
+}
+
+// keyLock is the lock of a conversation, removed once nobody holds or waits for it
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// New creates a manager whose steps wait timeout for an answer by default
+func New(store IStore, timeout time.Duration) IManager {
+	return NewWithClock(store, timeout, time.Now)
+}
+
+// NewWithClock creates a manager reading the current time from now
+func NewWithClock(store IStore, timeout time.Duration, now func() time.Time) IManager {
+	return &manager{
+		store:   store,
+		timeout: timeout,
+		now:     now,
+		locks:   make(map[Key]*keyLock),
+	}
+}
+
+func (m *manager) Start(key Key, flow, subjectID string, step Step) (models.WizardSession, error) {
+	return m.start(key, flow, subjectID, step, "")
+}
+
+func (m *manager) Link(key Key, flow, subjectID string, step Step) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.start(key, flow, subjectID, step, token)
+	return token, err
+}
+
+func (m *manager) Redeem(key Key, token string) (*models.WizardSession, error) {
+	session, err := m.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.Token == "" || subtle.ConstantTimeCompare([]byte(session.Token), []byte(token)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	now := m.now().UTC()
+	if !now.Before(session.ExpiresAt) {
+		if _, err := m.store.Delete(key); err != nil {
+			return nil, err
+		}
+		return nil, ErrExpired
+	}
+
+	// The token can only be used once
+	session.Token = ""
+	session.ExpiresAt = now.Add(m.timeout)
+	session.UpdatedAt = now
+	return session, m.store.Save(*session)
+}
+
+// start saves a session waiting at step, or for token to be redeemed when token isn't empty
+func (m *manager) start(key Key, flow, subjectID string, step Step, token string) (models.WizardSession, error) {
+	now := m.now().UTC()
+
+	// Sessions are otherwise only removed when answered, drop the abandoned ones
+	if err := m.store.DeleteExpired(now); err != nil {
+		return models.WizardSession{}, err
+	}
+
+	timeout := step.Timeout
+	if timeout == 0 {
+		timeout = m.timeout
+	}
+
+	session := models.WizardSession{
+		Platform:  key.Platform,
+		ChatID:    key.ChatID,
+		UserID:    key.UserID,
+		Flow:      flow,
+		Step:      step.Name,
+		SubjectID: subjectID,
+		Token:     token,
+		ExpiresAt: now.Add(timeout),
+		UpdatedAt: now,
+	}
+	return session, m.store.Save(session)
+}
+
+func (m *manager) Active(key Key) (*models.WizardSession, error) {
+	session, err := m.store.Get(key)
+	if err != nil || session == nil || session.Token != "" {
+		return nil, err
+	}
+
+	if !m.now().Before(session.ExpiresAt) {
+		if _, err := m.store.Delete(key); err != nil {
+			return nil, err
+		}
+		return nil, ErrExpired
+	}
+	return session, nil
+}
+
+func (m *manager) WaitingFlow(key Key) string {
+	session, err := m.store.Get(key)
+	if err != nil || session == nil || session.Token != "" {
+		return ""
+	}
+	return session.Flow
+}
+
+func (m *manager) Cancel(key Key) (bool, error) {
+	return m.store.Delete(key)
+}
+
+func (m *manager) Lock(key Key) func() {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// newToken returns a random token usable in a Telegram deep link
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,216 @@
+package wizard
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"sum/pkg/config"
+	"sum/pkg/database"
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time source for the manager
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// stores creates the stores the manager tests run against
+var stores = map[string]func(t *testing.T) IStore{
+	"memory": func(t *testing.T) IStore { return NewMemoryStore() },
+	"sqlite": func(t *testing.T) IStore {
+		require.NoError(t, models.InitIDGenerators())
+		db, err := database.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: ":memory:"}, logger.NewLogrusLogger())
+		require.NoError(t, err)
+		_, err = database.Migrate(db)
+		require.NoError(t, err)
+		return NewPostgresStore(db)
+	},
+}
+
+// forEachStore runs test with a manager waiting 10 minutes for answers, over each store
+func forEachStore(t *testing.T, test func(t *testing.T, m IManager, c *clock)) {
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{now: time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)}
+			test(t, NewWithClock(store(t), 10*time.Minute, c.Now), c)
+		})
+	}
+}
+
+var alice = Key{Platform: models.PlatformTelegram, ChatID: "-100", UserID: "1"}
+
+func TestExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m IManager, c *clock) {
+		_, err := m.Start(alice, "reg", "42", Step{Name: "url"})
+		require.NoError(t, err)
+
+		c.Advance(9 * time.Minute)
+		session, err := m.Active(alice)
+		require.NoError(t, err)
+		require.NotNil(t, session)
+		assert.Equal(t, "url", session.Step)
+		assert.Equal(t, "reg", m.WaitingFlow(alice))
+
+		// The session ends when its step timed out
+		c.Advance(time.Minute)
+		_, err = m.Active(alice)
+		assert.ErrorIs(t, err, ErrExpired)
+		session, err = m.Active(alice)
+		require.NoError(t, err)
+		assert.Nil(t, session)
+		assert.Empty(t, m.WaitingFlow(alice))
+	})
+}
+
+func TestStepTimeout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m IManager, c *clock) {
+		_, err := m.Start(alice, "reg", "42", Step{Name: "key", Timeout: time.Minute})
+		require.NoError(t, err)
+
+		c.Advance(time.Minute)
+		_, err = m.Active(alice)
+		assert.ErrorIs(t, err, ErrExpired)
+	})
+}
+
+func TestReplace(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m IManager, c *clock) {
+		_, err := m.Start(alice, "reg", "42", Step{Name: "url"})
+		require.NoError(t, err)
+
+		// A new conversation replaces the previous one, and waits for its own timeout
+		c.Advance(9 * time.Minute)
+		_, err = m.Start(alice, "edit_user", "43", Step{Name: "command"})
+		require.NoError(t, err)
+
+		c.Advance(5 * time.Minute)
+		session, err := m.Active(alice)
+		require.NoError(t, err)
+		require.NotNil(t, session)
+		assert.Equal(t, "edit_user", session.Flow)
+		assert.Equal(t, "command", session.Step)
+		assert.Equal(t, "43", session.SubjectID)
+
+		ended, err := m.Cancel(alice)
+		require.NoError(t, err)
+		assert.True(t, ended)
+		ended, err = m.Cancel(alice)
+		require.NoError(t, err)
+		assert.False(t, ended)
+	})
+}
+
+func TestUsersInOneChat(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m IManager, c *clock) {
+		bob := Key{Platform: alice.Platform, ChatID: alice.ChatID, UserID: "2"}
+		private := Key{Platform: alice.Platform, ChatID: alice.UserID, UserID: alice.UserID}
+
+		_, err := m.Start(alice, "reg", "42", Step{Name: "url"})
+		require.NoError(t, err)
+		_, err = m.Start(bob, "reg", "43", Step{Name: "key"})
+		require.NoError(t, err)
+
+		session, err := m.Active(alice)
+		require.NoError(t, err)
+		require.NotNil(t, session)
+		assert.Equal(t, "42", session.SubjectID)
+
+		session, err = m.Active(bob)
+		require.NoError(t, err)
+		require.NotNil(t, session)
+		assert.Equal(t, "43", session.SubjectID)
+
+		// The conversation of a user in a group isn't the one in the private chat
+		session, err = m.Active(private)
+		require.NoError(t, err)
+		assert.Nil(t, session)
+
+		_, err = m.Cancel(alice)
+		require.NoError(t, err)
+		session, err = m.Active(bob)
+		require.NoError(t, err)
+		assert.NotNil(t, session)
+	})
+}
+
+func TestLink(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m IManager, c *clock) {
+		token, err := m.Link(alice, "reg", "42", Step{Name: "key"})
+		require.NoError(t, err)
+
+		// A linked conversation waits for no answer until it is redeemed
+		session, err := m.Active(alice)
+		require.NoError(t, err)
+		assert.Nil(t, session)
+		assert.Empty(t, m.WaitingFlow(alice))
+
+		_, err = m.Redeem(alice, "wrong")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		session, err = m.Redeem(alice, token)
+		require.NoError(t, err)
+		assert.Equal(t, "key", session.Step)
+
+		session, err = m.Active(alice)
+		require.NoError(t, err)
+		assert.NotNil(t, session)
+
+		// Tokens are used once
+		_, err = m.Redeem(alice, token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestLinkExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, m IManager, c *clock) {
+		token, err := m.Link(alice, "reg", "42", Step{Name: "key"})
+		require.NoError(t, err)
+
+		c.Advance(10 * time.Minute)
+		_, err = m.Redeem(alice, token)
+		assert.ErrorIs(t, err, ErrExpired)
+		_, err = m.Redeem(alice, token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestLock(t *testing.T) {
+	m := New(NewMemoryStore(), time.Minute)
+	bob := Key{Platform: alice.Platform, ChatID: alice.ChatID, UserID: "2"}
+
+	unlock := m.Lock(alice)
+	locked := make(chan struct{})
+	go func() {
+		defer m.Lock(alice)()
+		close(locked)
+	}()
+
+	// Other conversations aren't held up
+	m.Lock(bob)()
+
+	select {
+	case <-locked:
+		t.Fatal("the conversation was locked twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
@@ -0,0 +1,64 @@
+package wizard
+
+import (
+	"sync"
+	"time"
+
+	"sum/pkg/models"
+)
+
+// memoryStore is an in-memory IStore, suitable for tests
+type memoryStore struct {
+	mu       sync.Mutex
+	sessions map[Key]models.WizardSession
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() IStore {
+	return &memoryStore{sessions: make(map[Key]models.WizardSession)}
+}
+
+func (s *memoryStore) Get(key Key) (*models.WizardSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (s *memoryStore) Save(session models.WizardSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[keyOf(session)] = session
+	return nil
+}
+
+func (s *memoryStore) Delete(key Key) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[key]
+	delete(s.sessions, key)
+	return ok, nil
+}
+
+func (s *memoryStore) DeleteExpired(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	return nil
+}
+
+// keyOf returns the key of the conversation a session belongs to
+func keyOf(session models.WizardSession) Key {
+	return Key{Platform: session.Platform, ChatID: session.ChatID, UserID: session.UserID}
+}
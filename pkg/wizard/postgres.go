@@ -0,0 +1,49 @@
+package wizard
+
+import (
+	"errors"
+	"time"
+
+	"sum/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postgresStore is an IStore backed by the wizard_sessions table
+type postgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a new database backed store, it also works on SQLite
+func NewPostgresStore(db *gorm.DB) IStore {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Get(key Key) (*models.WizardSession, error) {
+	var session models.WizardSession
+	err := s.db.Where("platform = ? AND chat_id = ? AND user_id = ?", key.Platform, key.ChatID, key.UserID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *postgresStore) Save(session models.WizardSession) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "platform"}, {Name: "chat_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"flow", "step", "subject_id", "token", "expires_at", "updated_at"}),
+	}).Create(&session).Error
+}
+
+func (s *postgresStore) Delete(key Key) (bool, error) {
+	result := s.db.Delete(&models.WizardSession{}, "platform = ? AND chat_id = ? AND user_id = ?", key.Platform, key.ChatID, key.UserID)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *postgresStore) DeleteExpired(now time.Time) error {
+	return s.db.Delete(&models.WizardSession{}, "expires_at <= ?", now).Error
+}
@@ -0,0 +1,39 @@
+package wizard
+
+import (
+	"context"
+	"strconv"
+
+	"sum/pkg/logger"
+	"sum/pkg/models"
+
+	"github.com/go-telegram/bot"
+	telegramMod "github.com/go-telegram/bot/models"
+)
+
+// TelegramKey returns the key of the private chat with a Telegram user, where the
+// wizards asking for secrets send their prompts
+func TelegramKey(userID int64) Key {
+	id := strconv.FormatInt(userID, 10)
+	return Key{Platform: models.PlatformTelegram, ChatID: id, UserID: id}
+}
+
+// MessageKey returns the key of the chat and sender of a Telegram message
+func MessageKey(message *telegramMod.Message) Key {
+	return Key{
+		Platform: models.PlatformTelegram,
+		ChatID:   strconv.FormatInt(message.Chat.ID, 10),
+		UserID:   strconv.FormatInt(message.From.ID, 10),
+	}
+}
+
+// Send sends a plain text prompt or reply of a wizard to a Telegram chat, logging failures
+func Send(ctx context.Context, b *bot.Bot, log logger.Logger, chatID int64, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+	})
+	if err != nil {
+		log.Error(err, "Failed to send message")
+	}
+}